@@ -8,32 +8,77 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"time"
+	_ "time/tzdata" // fusos das agendas de hábitos (a imagem Alpine não traz o zoneinfo)
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 
+	"go-guardiao-api/internal/admin"
 	"go-guardiao-api/internal/auth"
+	"go-guardiao-api/internal/exports"
 	"go-guardiao-api/internal/gamification"
 	"go-guardiao-api/internal/habits"
 	"go-guardiao-api/internal/platforms/cache"
 	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/internal/platforms/mailer"
+	"go-guardiao-api/internal/platforms/storage"
 	"go-guardiao-api/internal/users"
+	"go-guardiao-api/pkg/models"
 )
 
 type Config struct {
-	DBURL     string
-	RedisAddr string
-	Port      string
+	DBDriver             string
+	DBURL                string
+	RedisAddr            string
+	CacheMaxEntries      int
+	CacheRetryInterval   time.Duration
+	Port                 string
+	AdminEmails          []string
+	AccountPurgeInterval time.Duration
+	DataExportInterval   time.Duration
 }
 
 func loadConfig() *Config {
+	purgeInterval, err := time.ParseDuration(getenv("ACCOUNT_PURGE_INTERVAL", "1h"))
+	if err != nil || purgeInterval <= 0 {
+		purgeInterval = time.Hour
+	}
+	exportInterval, err := time.ParseDuration(getenv("DATA_EXPORT_POLL_INTERVAL", "1m"))
+	if err != nil || exportInterval <= 0 {
+		exportInterval = time.Minute
+	}
+	cacheRetry, err := time.ParseDuration(getenv("CACHE_RECONNECT_INTERVAL", "30s"))
+	if err != nil || cacheRetry <= 0 {
+		cacheRetry = cache.DefaultReconnectInterval
+	}
+	cacheEntries, err := strconv.Atoi(getenv("CACHE_MAX_ENTRIES", strconv.Itoa(cache.DefaultMemoryEntries)))
+	if err != nil || cacheEntries <= 0 {
+		cacheEntries = cache.DefaultMemoryEntries
+	}
 	return &Config{
-		DBURL:     getenv("DATABASE_URL", "postgres://user:password@db:5432/guardiaodb?sslmode=disable"),
-		RedisAddr: getenv("REDIS_ADDR", "cache:6379"),
-		Port:      getenv("PORT", "8080"),
+		DBDriver:             strings.ToLower(getenv("DB_DRIVER", "postgres")),
+		DBURL:                getenv("DATABASE_URL", "postgres://user:password@db:5432/guardiaodb?sslmode=disable"),
+		RedisAddr:            getenv("REDIS_ADDR", "cache:6379"),
+		CacheMaxEntries:      cacheEntries,
+		CacheRetryInterval:   cacheRetry,
+		Port:                 getenv("PORT", "8080"),
+		AdminEmails:          splitList(getenv("BOOTSTRAP_ADMIN_EMAILS", "")),
+		AccountPurgeInterval: purgeInterval,
+		DataExportInterval:   exportInterval,
+	}
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
 	}
+	return out
 }
 
 func getenv(env, fallback string) string {
@@ -43,7 +88,20 @@ func getenv(env, fallback string) string {
 	return fallback
 }
 
-func mustInitDB(dbURL string) *db.Client {
+// mustInitDB abre o PostgreSQL ou, com DB_DRIVER=memory, um armazenamento em memória
+// (desenvolvimento offline; os dados somem ao encerrar).
+func mustInitDB(driver, dbURL string) db.Store {
+	switch driver {
+	case "memory":
+		if strings.EqualFold(os.Getenv("GO_ENV"), "production") {
+			log.Fatal("❌ DB_DRIVER=memory não é permitido em produção")
+		}
+		log.Println("⚠️ DB_DRIVER=memory: usando armazenamento em memória (dados não persistem)")
+		return db.NewMemoryStore()
+	case "postgres":
+	default:
+		log.Fatalf("❌ DB_DRIVER inválido: %q (use postgres ou memory)", driver)
+	}
 	dbClient, err := db.NewDBClient(dbURL)
 	if err != nil {
 		log.Fatalf("❌ Falha ao conectar ao banco: %v", err)
@@ -51,59 +109,206 @@ func mustInitDB(dbURL string) *db.Client {
 	return dbClient
 }
 
-func tryInitCache(redisAddr string) *cache.Client {
-	cacheClient, err := cache.NewCacheClient(redisAddr, "")
-	if err != nil {
-		log.Printf("⚠️ Redis indisponível (continuando sem cache): %v", err)
-		return nil
+// initCache usa o Redis e, enquanto ele estiver fora, um cache em memória limitado
+// (a reconexão é tentada em segundo plano).
+func initCache(cfg *Config) *cache.Fallback {
+	return cache.NewFallback(cfg.RedisAddr, "", cfg.CacheMaxEntries, cfg.CacheRetryInterval)
+}
+
+// bootstrapAdmins garante o papel admin para as contas listadas em BOOTSTRAP_ADMIN_EMAILS.
+func bootstrapAdmins(dbClient db.Store, emails []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, email := range emails {
+		u, err := dbClient.GetUserByEmail(ctx, email)
+		if err != nil {
+			log.Printf("⚠️ Admin inicial %s não encontrado (registre a conta primeiro): %v", email, err)
+			continue
+		}
+		if err := dbClient.GrantRole(ctx, u.ID, models.RoleAdmin, ""); err != nil {
+			log.Printf("⚠️ Falha ao atribuir papel admin para %s: %v", email, err)
+		}
 	}
-	return cacheClient
 }
 
 // defineServiceRoutes configura todas as rotas protegidas e injeta o DB e Cache.
-func defineServiceRoutes(router *mux.Router, dbClient *db.Client, cacheClient *cache.Client) {
-	userService := users.NewService(dbClient)
-	habitService := habits.NewService(dbClient)
+func defineServiceRoutes(router *mux.Router, dbClient db.Store, cacheClient cache.Cache, mailClient mailer.Mailer, exportService *exports.Service, loginGuard *auth.LoginGuard, oidcLogin *auth.OIDCLogin, keyAuth *auth.APIKeyAuth) {
+	userService := users.NewService(dbClient, mailClient)
+	habitService := habits.NewService(dbClient, cacheClient)
 	gamificationService := gamification.NewService(dbClient, cacheClient)
+	adminService := admin.NewService(dbClient)
 
 	// --- USUÁRIOS ---
 	router.HandleFunc("/user/profile", userService.HandleGetUserProfile).Methods("GET")
 	router.HandleFunc("/user/profile", userService.HandleUpdateProfile).Methods("PUT")
-	router.HandleFunc("/user/email", userService.HandleUpdateEmail).Methods("PUT")       // NOVO
+	router.HandleFunc("/user/email", userService.HandleUpdateEmail).Methods("PUT") // NOVO
+	router.HandleFunc("/user/email/verification", userService.HandleResendEmailVerification).Methods("POST")
 	router.HandleFunc("/user/password", userService.HandleUpdatePassword).Methods("PUT") // NOVO
-	router.HandleFunc("/user/support-contact", userService.HandleAddSupportContact).Methods("POST")
+	router.HandleFunc("/user/account", userService.HandleDeleteAccount).Methods("DELETE")
+	router.HandleFunc("/user/account/deletion/cancel", userService.HandleCancelAccountDeletion).Methods("POST")
+	router.HandleFunc("/user/data-export", exportService.HandleRequestExport).Methods("POST")
+	router.HandleFunc("/user/data-export", exportService.HandleListExports).Methods("GET")
+	router.HandleFunc("/user/data-export/{exportId}", exportService.HandleGetExport).Methods("GET")
+	router.HandleFunc("/user/2fa/enroll", func(w http.ResponseWriter, r *http.Request) {
+		auth.HandleTOTPEnrollWithDB(w, r, dbClient)
+	}).Methods("POST")
+	router.HandleFunc("/user/2fa/confirm", func(w http.ResponseWriter, r *http.Request) {
+		auth.HandleTOTPConfirmWithDB(w, r, dbClient)
+	}).Methods("POST")
+	router.HandleFunc("/user/2fa/disable", func(w http.ResponseWriter, r *http.Request) {
+		auth.HandleTOTPDisableWithDB(w, r, dbClient)
+	}).Methods("POST")
+	router.HandleFunc("/user/passkeys/register/start", func(w http.ResponseWriter, r *http.Request) {
+		auth.HandlePasskeyRegisterStartWithDB(w, r, dbClient)
+	}).Methods("POST")
+	router.HandleFunc("/user/passkeys/register/finish", func(w http.ResponseWriter, r *http.Request) {
+		auth.HandlePasskeyRegisterFinishWithDB(w, r, dbClient)
+	}).Methods("POST")
+	router.HandleFunc("/user/passkeys", userService.HandleListPasskeys).Methods("GET")
+	router.HandleFunc("/user/passkeys/{passkeyId}", userService.HandleDeletePasskey).Methods("DELETE")
+	router.HandleFunc("/user/sessions", userService.HandleListSessions).Methods("GET")
+	router.HandleFunc("/user/sessions", userService.HandleRevokeOtherSessions).Methods("DELETE")
+	router.HandleFunc("/user/sessions/{sessionId}", userService.HandleRevokeSession).Methods("DELETE")
+	router.HandleFunc("/user/api-keys", userService.HandleCreateAPIKey).Methods("POST")
+	router.HandleFunc("/user/api-keys", userService.HandleListAPIKeys).Methods("GET")
+	router.HandleFunc("/user/api-keys/{keyId}", userService.HandleRevokeAPIKey).Methods("DELETE")
+	router.HandleFunc("/user/security-events", userService.HandleListSecurityEvents).Methods("GET")
+	router.HandleFunc("/user/consents", userService.HandleGetConsents).Methods("GET")
+	router.HandleFunc("/user/consents", userService.HandleUpdateConsents).Methods("PUT")
+	router.HandleFunc("/user/consents/history", userService.HandleGetConsentHistory).Methods("GET")
+	router.HandleFunc("/user/identities", userService.HandleListIdentities).Methods("GET")
+	router.HandleFunc("/user/identities/{provider}", oidcLogin.HandleLinkStart).Methods("POST")
+	router.HandleFunc("/user/identities/{provider}", userService.HandleUnlinkIdentity).Methods("DELETE")
+	// Rede de apoio e resgates exigem e-mail confirmado; adicionar contatos exige consentimento de compartilhamento
+	sharing := auth.RequireConsent(dbClient, models.ConsentSupportSharing)
+	router.Handle("/user/support-contact", auth.RequireVerifiedEmail(sharing(http.HandlerFunc(userService.HandleAddSupportContact)))).Methods("POST")
 	router.HandleFunc("/user/support-contact", userService.HandleGetSupportContacts).Methods("GET")
 	router.HandleFunc("/user/support-contact/{contactId}", userService.HandleDeleteSupportContact).Methods("DELETE")
 
-	// --- HÁBITOS ---
-	router.HandleFunc("/habits", habitService.HandleCreateHabit).Methods("POST")
-	router.HandleFunc("/habits", habitService.HandleGetHabits).Methods("GET")
-	router.HandleFunc("/habits/{habitId}/log", habitService.HandleLogHabit).Methods("POST")
-	router.HandleFunc("/habits/{habitId}/logs", habitService.HandleGetHabitLogs).Methods("GET")
-	router.HandleFunc("/habits/{habitId}", habitService.HandleDeleteHabit).Methods("DELETE")
+	// --- HÁBITOS (também acessíveis por chave de API com o escopo indicado) ---
+	// Dados de saúde: exigem consentimento com a finalidade principal na versão vigente dos termos
+	tracking := auth.RequireConsent(dbClient, models.ConsentCoreTracking)
+	keyAuth.Allow(router.Handle("/habits", tracking(http.HandlerFunc(habitService.HandleCreateHabit))).Methods("POST"), models.ScopeHabitsWrite)
+	keyAuth.Allow(router.Handle("/habits", tracking(http.HandlerFunc(habitService.HandleGetHabits))).Methods("GET"), models.ScopeHabitsRead)
+	keyAuth.Allow(router.Handle("/habits/{habitId}/log", tracking(http.HandlerFunc(habitService.HandleLogHabit))).Methods("POST"), models.ScopeHabitsWrite)
+	keyAuth.Allow(router.Handle("/habits/{habitId}/logs", tracking(http.HandlerFunc(habitService.HandleGetHabitLogs))).Methods("GET"), models.ScopeHabitsRead)
+	keyAuth.Allow(router.Handle("/habits/{habitId}/logs/{logId}", tracking(http.HandlerFunc(habitService.HandleUpdateHabitLog))).Methods("PUT"), models.ScopeHabitsWrite)
+	keyAuth.Allow(router.Handle("/habits/{habitId}/logs/{logId}", tracking(http.HandlerFunc(habitService.HandleDeleteHabitLog))).Methods("DELETE"), models.ScopeHabitsWrite)
+	keyAuth.Allow(router.Handle("/habits/{habitId}/occurrences", tracking(http.HandlerFunc(habitService.HandleGetHabitOccurrences))).Methods("GET"), models.ScopeHabitsRead)
+	keyAuth.Allow(router.Handle("/habits/{habitId}/progress", tracking(http.HandlerFunc(habitService.HandleGetHabitProgress))).Methods("GET"), models.ScopeHabitsRead)
+	keyAuth.Allow(router.Handle("/habits/{habitId}/streak", tracking(http.HandlerFunc(habitService.HandleGetHabitStreak))).Methods("GET"), models.ScopeHabitsRead)
+	// Gasta mana: mesmo escopo e exigência de e-mail confirmado do resgate de recompensas
+	keyAuth.Allow(router.Handle("/habits/{habitId}/streak-freezes", tracking(auth.RequireVerifiedEmail(http.HandlerFunc(habitService.HandleBuyStreakFreeze)))).Methods("POST"), models.ScopeManaWrite)
+	keyAuth.Allow(router.Handle("/habits/{habitId}", tracking(http.HandlerFunc(habitService.HandleUpdateHabit))).Methods("PUT", "PATCH"), models.ScopeHabitsWrite)
+	keyAuth.Allow(router.Handle("/habits/{habitId}/archive", tracking(http.HandlerFunc(habitService.HandleArchiveHabit))).Methods("POST"), models.ScopeHabitsWrite)
+	keyAuth.Allow(router.Handle("/habits/{habitId}/unarchive", tracking(http.HandlerFunc(habitService.HandleUnarchiveHabit))).Methods("POST"), models.ScopeHabitsWrite)
+	keyAuth.Allow(router.Handle("/habits/{habitId}", tracking(http.HandlerFunc(habitService.HandleDeleteHabit))).Methods("DELETE"), models.ScopeHabitsWrite)
 
 	// --- GAMIFICAÇÃO ---
-	router.HandleFunc("/mana/balance", gamificationService.HandleGetManaBalance).Methods("GET")
-	router.HandleFunc("/mana/redeem", gamificationService.HandleRedeemReward).Methods("POST")
-	router.HandleFunc("/challenges", gamificationService.HandleListChallenges).Methods("GET")
-	router.HandleFunc("/leaderboard", gamificationService.HandleGetLeaderboard).Methods("GET")
+	keyAuth.Allow(router.HandleFunc("/mana/balance", gamificationService.HandleGetManaBalance).Methods("GET"), models.ScopeManaRead)
+	keyAuth.Allow(router.Handle("/mana/redeem", auth.RequireVerifiedEmail(http.HandlerFunc(gamificationService.HandleRedeemReward))).Methods("POST"), models.ScopeManaWrite)
+	keyAuth.Allow(router.HandleFunc("/challenges", gamificationService.HandleListChallenges).Methods("GET"), models.ScopeManaRead)
+	keyAuth.Allow(router.HandleFunc("/leaderboard", gamificationService.HandleGetLeaderboard).Methods("GET"), models.ScopeManaRead)
+
+	// --- ADMIN (exige papel admin) ---
+	adminRouter := router.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(auth.RequireRole(models.RoleAdmin))
+	adminRouter.HandleFunc("/users/{userId}/roles", adminService.HandleGetUserRoles).Methods("GET")
+	adminRouter.HandleFunc("/users/{userId}/roles", adminService.HandleSetUserRoles).Methods("PUT")
+	adminRouter.HandleFunc("/audit-events", adminService.HandleListAuditEvents).Methods("GET")
+	adminRouter.HandleFunc("/lockouts", loginGuard.HandleListLockouts).Methods("GET")
+	adminRouter.HandleFunc("/lockouts/{lockoutId}/unlock", loginGuard.HandleUnlock).Methods("POST")
+}
+
+func mustInitMailer() mailer.Mailer {
+	m, err := mailer.NewFromEnv()
+	if err != nil {
+		log.Fatalf("❌ Falha ao configurar envio de e-mails: %v", err)
+	}
+	return m
+}
+
+func mustInitOIDC(dbClient db.Store) *auth.OIDCLogin {
+	o, err := auth.NewOIDCLogin(dbClient)
+	if err != nil {
+		log.Fatalf("❌ Falha ao configurar login externo (OIDC): %v", err)
+	}
+	return o
 }
 
-func setupRouter(dbClient *db.Client, cacheClient *cache.Client) *mux.Router {
+func mustInitStorage() storage.Storage {
+	s, err := storage.NewFromEnv()
+	if err != nil {
+		log.Fatalf("❌ Falha ao configurar armazenamento de arquivos: %v", err)
+	}
+	return s
+}
+
+func mustInitExports(dbClient db.Store, store storage.Storage) *exports.Service {
+	e, err := exports.NewService(dbClient, store)
+	if err != nil {
+		log.Fatalf("❌ Falha ao configurar exportação de dados: %v", err)
+	}
+	return e
+}
+
+func setupRouter(dbClient db.Store, cacheClient cache.Cache, mailClient mailer.Mailer, exportService *exports.Service, loginGuard *auth.LoginGuard) *mux.Router {
 	r := mux.NewRouter().StrictSlash(true)
+	oidcLogin := mustInitOIDC(dbClient)
 
 	// Auth públicas
 	r.HandleFunc("/api/v1/auth/register", func(w http.ResponseWriter, r *http.Request) {
-		auth.HandleRegisterWithDB(w, r, dbClient)
+		auth.HandleRegisterWithDB(w, r, dbClient, mailClient)
 	}).Methods("POST")
 	r.HandleFunc("/api/v1/auth/login", func(w http.ResponseWriter, r *http.Request) {
-		auth.HandleLoginWithDB(w, r, dbClient)
+		auth.HandleLoginWithDB(w, r, dbClient, loginGuard)
+	}).Methods("POST")
+	r.HandleFunc("/api/v1/auth/login/mfa", func(w http.ResponseWriter, r *http.Request) {
+		auth.HandleLoginMFAWithDB(w, r, dbClient, loginGuard)
+	}).Methods("POST")
+	r.HandleFunc("/api/v1/auth/login/mfa/passkey/start", func(w http.ResponseWriter, r *http.Request) {
+		auth.HandlePasskeyMFAStartWithDB(w, r, dbClient)
+	}).Methods("POST")
+	r.HandleFunc("/api/v1/auth/login/mfa/passkey/finish", func(w http.ResponseWriter, r *http.Request) {
+		auth.HandlePasskeyMFAFinishWithDB(w, r, dbClient, loginGuard)
+	}).Methods("POST")
+	r.HandleFunc("/api/v1/auth/login/passkey/start", func(w http.ResponseWriter, r *http.Request) {
+		auth.HandlePasskeyLoginStartWithDB(w, r, dbClient)
+	}).Methods("POST")
+	r.HandleFunc("/api/v1/auth/login/passkey/finish", func(w http.ResponseWriter, r *http.Request) {
+		auth.HandlePasskeyLoginFinishWithDB(w, r, dbClient)
+	}).Methods("POST")
+	r.HandleFunc("/api/v1/auth/refresh", func(w http.ResponseWriter, r *http.Request) {
+		auth.HandleRefreshWithDB(w, r, dbClient)
 	}).Methods("POST")
+	r.HandleFunc("/api/v1/auth/logout", func(w http.ResponseWriter, r *http.Request) {
+		auth.HandleLogoutWithDB(w, r, dbClient)
+	}).Methods("POST")
+	r.HandleFunc("/api/v1/auth/password/forgot", func(w http.ResponseWriter, r *http.Request) {
+		auth.HandleForgotPasswordWithDB(w, r, dbClient, mailClient)
+	}).Methods("POST")
+	r.HandleFunc("/api/v1/auth/password/reset", func(w http.ResponseWriter, r *http.Request) {
+		auth.HandleResetPasswordWithDB(w, r, dbClient)
+	}).Methods("POST")
+	r.HandleFunc("/api/v1/auth/email/verify", func(w http.ResponseWriter, r *http.Request) {
+		auth.HandleVerifyEmailWithDB(w, r, dbClient)
+	}).Methods("POST")
+	r.HandleFunc("/api/v1/consents/terms", auth.HandleGetTerms).Methods("GET")
+	r.HandleFunc("/api/v1/auth/oidc/providers", oidcLogin.HandleListProviders).Methods("GET")
+	r.HandleFunc("/api/v1/auth/oidc/{provider}/start", oidcLogin.HandleStart).Methods("GET")
+	r.HandleFunc("/api/v1/auth/oidc/{provider}/callback", oidcLogin.HandleCallback).Methods("POST")
 
-	// Rotas Protegidas (API) - JWT Middleware
+	// Download de exportação de dados: autorizado pelo link assinado, sem token
+	r.HandleFunc("/api/v1/exports/{exportId}/download", exportService.HandleDownload).Methods("GET")
+
+	// Rotas Protegidas (API) - JWT ou chave de API
 	apiRouter := r.PathPrefix("/api/v1").Subrouter()
-	apiRouter.Use(auth.JWTAuthMiddleware)
-	defineServiceRoutes(apiRouter, dbClient, cacheClient)
+	keyAuth := auth.NewAPIKeyAuth(dbClient)
+	apiRouter.Use(keyAuth.Middleware(auth.JWTAuthMiddleware(dbClient)))
+	defineServiceRoutes(apiRouter, dbClient, cacheClient, mailClient, exportService, loginGuard, oidcLogin, keyAuth)
+
+	// Chaves públicas para verificação dos tokens por outros serviços
+	r.HandleFunc("/.well-known/jwks.json", auth.HandleJWKS).Methods("GET")
 
 	// Health
 	r.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
@@ -121,17 +326,41 @@ func setupRouter(dbClient *db.Client, cacheClient *cache.Client) *mux.Router {
 
 func main() {
 	cfg := loadConfig()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(cfg.DBURL, os.Args[2:])
+		return
+	}
 	addr := fmt.Sprintf(":%s", cfg.Port)
 
-	dbClient := mustInitDB(cfg.DBURL)
+	if err := auth.LoadSigningKeys(); err != nil {
+		log.Fatalf("❌ Falha ao carregar chaves JWT: %v", err)
+	}
+	if err := auth.LoadPasswordBlocklist(); err != nil {
+		log.Fatalf("❌ Falha ao carregar lista de senhas bloqueadas: %v", err)
+	}
+
+	dbClient := mustInitDB(cfg.DBDriver, cfg.DBURL)
 	defer dbClient.Close()
 
-	cacheClient := tryInitCache(cfg.RedisAddr)
-	if cacheClient != nil {
-		defer cacheClient.Close()
-	}
+	cacheClient := initCache(cfg)
+	defer cacheClient.Close()
+
+	bootstrapAdmins(dbClient, cfg.AdminEmails)
+
+	mailClient := mustInitMailer()
+	exportService := mustInitExports(dbClient, mustInitStorage())
+
+	// Exclusão das contas cujo prazo de carência terminou (LGPD)
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go users.NewAccountPurger(dbClient, cacheClient).Run(jobsCtx, cfg.AccountPurgeInterval)
+	// Geração das exportações de dados pedidas pelos usuários
+	go exportService.Run(jobsCtx, cfg.DataExportInterval)
+	// Limpeza de contadores e bloqueios de login antigos
+	loginGuard := auth.NewLoginGuard(dbClient, cacheClient)
+	go loginGuard.Run(jobsCtx, cfg.AccountPurgeInterval)
 
-	r := setupRouter(dbClient, cacheClient)
+	r := setupRouter(dbClient, cacheClient, mailClient, exportService, loginGuard)
 
 	// CORS compatível com Vercel (produção e previews)
 	corsHandler := handlers.CORS(
@@ -149,7 +378,7 @@ func main() {
 			return false
 		}),
 		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}),
-		handlers.AllowedHeaders([]string{"Authorization", "Content-Type", "X-Requested-With"}),
+		handlers.AllowedHeaders([]string{"Authorization", "Content-Type", "X-Requested-With", "X-API-Key"}),
 		handlers.MaxAge(12*60*60),
 	)
 
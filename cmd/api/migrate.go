@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+
+	"go-guardiao-api/internal/platforms/db"
+)
+
+const migrateUsage = `Uso: api migrate <comando>
+
+Comandos:
+  up              aplica todas as migrações pendentes
+  down [-steps N] desfaz as últimas N migrações (padrão 1)
+  status          lista as migrações e quando foram aplicadas
+`
+
+// runMigrate trata "api migrate ..." sem subir o servidor.
+func runMigrate(dbURL string, args []string) {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	dbClient, err := db.Connect(dbURL)
+	if err != nil {
+		log.Fatalf("❌ Falha ao conectar ao banco: %v", err)
+	}
+	defer dbClient.Close()
+
+	switch args[0] {
+	case "up":
+		n, err := dbClient.MigrateUp(ctx)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		log.Printf("✅ %d migração(ões) aplicada(s).", n)
+
+	case "down":
+		fs := flag.NewFlagSet("down", flag.ExitOnError)
+		steps := fs.Int("steps", 1, "quantidade de migrações a desfazer")
+		_ = fs.Parse(args[1:])
+		if *steps <= 0 {
+			log.Fatal("❌ -steps deve ser maior que zero")
+		}
+		n, err := dbClient.MigrateDown(ctx, *steps)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		log.Printf("✅ %d migração(ões) desfeita(s).", n)
+
+	case "status":
+		statuses, err := dbClient.MigrationStatuses(ctx)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSÃO\tNOME\tAPLICADA EM")
+		for _, st := range statuses {
+			applied := "pendente"
+			if st.AppliedAt != nil {
+				applied = st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if st.Missing {
+				applied += " (arquivo ausente neste binário)"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", st.Version, st.Name, applied)
+		}
+		_ = w.Flush()
+
+	default:
+		fmt.Fprint(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
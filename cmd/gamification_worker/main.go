@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"time"
 
+	"go-guardiao-api/internal/gamification"
 	"go-guardiao-api/internal/platforms/db"
 	"go-guardiao-api/pkg/models"
 )
@@ -39,39 +40,41 @@ func getEnvInt(k string, def int) int {
 	return n
 }
 
+// habitLogMessage é a mensagem da fila: o registro e a meta do hábito quando ele foi feito.
+type habitLogMessage struct {
+	models.HabitLog
+	Goal *models.HabitGoal `json:"goal,omitempty"`
+}
+
 // WorkerProcessar simula o recebimento de uma mensagem do SQS e executa a lógica de cálculo.
-func WorkerProcessar(ctx context.Context, dbClient *db.Client, messagePayload []byte) error {
-	var logData models.HabitLog
+func WorkerProcessar(ctx context.Context, dbClient db.ManaRepository, messagePayload []byte) error {
+	var msg habitLogMessage
 
 	// 1. Deserializa a mensagem (que seria o log do hábito da API)
-	if err := json.Unmarshal(messagePayload, &logData); err != nil {
+	if err := json.Unmarshal(messagePayload, &msg); err != nil {
 		log.Printf("ERRO: Falha ao desserializar payload: %v. Payload: %s", err, string(messagePayload))
 		// No SQS real, esta mensagem seria movida para uma Dead Letter Queue.
 		return err
 	}
 
-	// 2. Lógica de Negócio: Calcular Mana
-	manaGained := 0
-	switch {
-	case logData.Value >= 1 && logData.HabitID == "h1":
-		// Ex: Beber Água
-		manaGained = 25
-	case logData.Value >= 30 && logData.HabitID == "h2":
-		// Ex: Caminhar 30 minutos
-		manaGained = 50
-	default:
+	logData := msg.HabitLog
+
+	// 2. Lógica de Negócio: Calcular Mana (a mesma regra da API)
+	manaGained := gamification.HabitLogMana(msg.Goal, logData)
+	if manaGained == 0 {
 		log.Printf("INFO: Hábito %s não atendeu aos critérios para Mana.", logData.HabitID)
 		return nil // Nenhuma Mana gerada, mas o processamento foi bem-sucedido
 	}
 
 	log.Printf("CALCULADO: Usuário %s ganhou %d Mana (habit=%s value=%d).", logData.UserID, manaGained, logData.HabitID, logData.Value)
 
-	// 3. Persistência: Criar Transação de Mana (usando a função transacional)
+	// 3. Persistência: Criar Transação de Mana (usando a função transacional).
+	// A referência é o registro, para que editá-lo ou excluí-lo ajuste a mana ganha.
 	tx := models.ManaTransaction{
 		UserID:      logData.UserID,
-		Type:        "HABIT_COMPLETION",
+		Type:        models.ManaTypeHabitCompletion,
 		Amount:      manaGained,
-		ReferenceID: logData.HabitID,
+		ReferenceID: logData.ID,
 		CreatedAt:   time.Now(),
 	}
 
@@ -89,7 +92,7 @@ func WorkerProcessar(ctx context.Context, dbClient *db.Client, messagePayload []
 	return nil
 }
 
-func simulateConsumption(ctx context.Context, dbClient *db.Client) {
+func simulateConsumption(ctx context.Context, dbClient db.ManaRepository) {
 	log.Println("Worker iniciado. Simulando consumo de fila SQS...")
 
 	enableMock := getEnv("WORKER_ENABLE_MOCK", "true") == "true"
@@ -104,9 +107,12 @@ func simulateConsumption(ctx context.Context, dbClient *db.Client) {
 
 	// Mock de mensagens que viriam da API (via SQS)
 	mockMessages := []string{
-		`{"habit_id": "h1", "user_id": "mock-user-456", "value": 1}`,  // Gera 25 Mana
-		`{"habit_id": "h2", "user_id": "mock-user-456", "value": 30}`, // Gera 50 Mana
-		`{"habit_id": "h2", "user_id": "mock-user-456", "value": 10}`, // Não gera Mana (abaixo de 30)
+		// Sem meta: gera 25 Mana
+		`{"id": "1", "habit_id": "h1", "user_id": "mock-user-456", "value": 1}`,
+		// Meta de 30 minutos cumprida: gera 25 Mana
+		`{"id": "2", "habit_id": "h2", "user_id": "mock-user-456", "value": 30, "goal": {"target": 30, "unit": "minutes"}}`,
+		// Metade da meta: gera 12 Mana
+		`{"id": "3", "habit_id": "h2", "user_id": "mock-user-456", "value": 15, "goal": {"target": 30, "unit": "minutes"}}`,
 	}
 	messageIndex := 0
 
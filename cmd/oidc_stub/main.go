@@ -0,0 +1,212 @@
+// oidc_stub é um provedor OpenID Connect mínimo para desenvolvimento e testes locais
+// do login externo. Aprova automaticamente toda autorização (sem tela de login),
+// exige PKCE S256 e assina o id_token com uma chave RSA efêmera.
+//
+// Uso:
+//
+//	go run ./cmd/oidc_stub
+//	OIDC_PROVIDERS=local OIDC_LOCAL_ISSUER=http://localhost:9090 OIDC_LOCAL_CLIENT_ID=guardiao-dev go run ./cmd/api
+//
+// O e-mail do usuário simulado vem de ?login_hint= na URL de autorização ou de STUB_EMAIL.
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// getEnv busca variável de ambiente com fallback
+func getEnv(k, def string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+	return def
+}
+
+// authorization guarda o que foi pedido em /authorize até a troca do código em /token.
+type authorization struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	nonce       string
+	email       string
+	expiresAt   time.Time
+}
+
+type stub struct {
+	issuer   string
+	clientID string
+	email    string
+	key      *rsa.PrivateKey
+	kid      string
+
+	mu    sync.Mutex
+	codes map[string]authorization
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func oauthError(w http.ResponseWriter, code, desc string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code, "error_description": desc})
+}
+
+func (s *stub) handleDiscovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                s.issuer,
+		"authorization_endpoint":                s.issuer + "/authorize",
+		"token_endpoint":                        s.issuer + "/token",
+		"jwks_uri":                              s.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (s *stub) handleJWKS(w http.ResponseWriter, _ *http.Request) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]string{{
+		"kty": "RSA", "use": "sig", "alg": "RS256", "kid": s.kid,
+		"n": b64(s.key.N.Bytes()), "e": b64(big.NewInt(int64(s.key.E)).Bytes()),
+	}}})
+}
+
+// GET /authorize — aprova na hora e redireciona com ?code&state.
+func (s *stub) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" || q.Get("client_id") != s.clientID {
+		oauthError(w, "unauthorized_client", "response_type ou client_id inválido")
+		return
+	}
+	if q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256" {
+		oauthError(w, "invalid_request", "PKCE S256 é obrigatório")
+		return
+	}
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirect.Scheme == "" {
+		oauthError(w, "invalid_request", "redirect_uri inválido")
+		return
+	}
+
+	email := q.Get("login_hint")
+	if email == "" {
+		email = s.email
+	}
+	code := randomString()
+	s.mu.Lock()
+	s.codes[code] = authorization{
+		clientID:    s.clientID,
+		redirectURI: redirect.String(),
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+		email:       strings.ToLower(email),
+		expiresAt:   time.Now().Add(time.Minute),
+	}
+	s.mu.Unlock()
+
+	params := redirect.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// POST /token — troca o código (uso único) pelo id_token, conferindo o code_verifier.
+func (s *stub) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		oauthError(w, "unsupported_grant_type", "apenas authorization_code")
+		return
+	}
+	code := r.PostForm.Get("code")
+	s.mu.Lock()
+	auth, ok := s.codes[code]
+	delete(s.codes, code)
+	s.mu.Unlock()
+	if !ok || time.Now().After(auth.expiresAt) {
+		oauthError(w, "invalid_grant", "código inválido ou expirado")
+		return
+	}
+	if r.PostForm.Get("client_id") != auth.clientID || r.PostForm.Get("redirect_uri") != auth.redirectURI {
+		oauthError(w, "invalid_grant", "client_id ou redirect_uri não conferem")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != auth.challenge {
+		oauthError(w, "invalid_grant", "code_verifier inválido")
+		return
+	}
+
+	subject := sha256.Sum256([]byte(auth.email))
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            s.issuer,
+		"aud":            auth.clientID,
+		"sub":            base64.RawURLEncoding.EncodeToString(subject[:16]),
+		"email":          auth.email,
+		"email_verified": true,
+		"name":           strings.Split(auth.email, "@")[0],
+		"nonce":          auth.nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+	})
+	token.Header["kid"] = s.kid
+	idToken, err := token.SignedString(s.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func main() {
+	port := getEnv("STUB_PORT", "9090")
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatalf("❌ Falha ao gerar chave: %v", err)
+	}
+	s := &stub{
+		issuer:   strings.TrimRight(getEnv("STUB_ISSUER", "http://localhost:"+port), "/"),
+		clientID: getEnv("STUB_CLIENT_ID", "guardiao-dev"),
+		email:    getEnv("STUB_EMAIL", "dev@guardiao.local"),
+		key:      key,
+		kid:      randomString()[:8],
+		codes:    map[string]authorization{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.handleDiscovery)
+	mux.HandleFunc("GET /jwks", s.handleJWKS)
+	mux.HandleFunc("GET /authorize", s.handleAuthorize)
+	mux.HandleFunc("POST /token", s.handleToken)
+
+	log.Printf("🔑 IdP OIDC de testes em %s (client_id=%s)", s.issuer, s.clientID)
+	log.Fatal(http.ListenAndServe(":"+port, mux))
+}
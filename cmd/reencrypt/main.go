@@ -0,0 +1,63 @@
+// reencrypt regrava os dados pessoais cifrados com a chave ativa. Use após configurar uma
+// chave nova (rotação), para cifrar dados gravados antes da criptografia de campos ou para
+// regravar valores no formato antigo enc:v1.
+//
+// Rotação:
+//
+//  1. acrescente a nova chave em FIELD_ENCRYPTION_KEYS e aponte FIELD_ENCRYPTION_ACTIVE_KEY para ela;
+//  2. reinicie a API (novas gravações já usam a chave nova);
+//  3. rode go run ./cmd/reencrypt até não sobrar nada regravado nem pulado;
+//  4. remova a chave antiga de FIELD_ENCRYPTION_KEYS.
+//
+// Uso:
+//
+//	DATABASE_URL=postgres://... go run ./cmd/reencrypt [-batch 500] [-dry-run]
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+
+	"go-guardiao-api/internal/platforms/db"
+)
+
+func main() {
+	batch := flag.Int("batch", 500, "linhas lidas por consulta")
+	dryRun := flag.Bool("dry-run", false, "apenas conta as linhas que seriam regravadas")
+	flag.Parse()
+	if *batch <= 0 {
+		log.Fatal("ERRO: -batch deve ser maior que zero")
+	}
+
+	dbClient, err := db.NewDBClient(os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Fatalf("ERRO CRÍTICO: %v", err)
+	}
+	defer dbClient.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	stats, err := dbClient.ReencryptFields(ctx, *batch, *dryRun)
+	pending := false
+	for _, st := range stats {
+		log.Printf("%s: %d lida(s), %d regravada(s), %d pulada(s)", st.Table, st.Scanned, st.Rewritten, st.Skipped)
+		if st.Skipped > 0 || (*dryRun && st.Rewritten > 0) {
+			pending = true
+		}
+	}
+	if err != nil {
+		log.Fatalf("ERRO: recriptografia interrompida: %v", err)
+	}
+	switch {
+	case *dryRun && pending:
+		log.Println("Há dados a regravar; rode sem -dry-run.")
+	case pending:
+		log.Println("Algumas linhas mudaram durante a execução; rode de novo.")
+	default:
+		log.Println("Todos os dados pessoais estão cifrados com a chave ativa.")
+	}
+}
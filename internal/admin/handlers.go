@@ -0,0 +1,206 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+
+	"go-guardiao-api/internal/auth"
+	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/pkg/models"
+)
+
+// Service representa as operações administrativas (back-office).
+type Service struct {
+	DBClient db.Store
+}
+
+func NewService(dbClient db.Store) *Service {
+	return &Service{DBClient: dbClient}
+}
+
+// ===== Helpers JSON =====
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// ===== Papéis =====
+
+// GET /admin/users/{userId}/roles
+func (s *Service) HandleGetUserRoles(w http.ResponseWriter, r *http.Request) {
+	userID := strings.TrimSpace(mux.Vars(r)["userId"])
+
+	if _, err := s.DBClient.GetUserByID(r.Context(), userID); errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Usuário não encontrado.")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao buscar usuário: %v", err))
+		return
+	}
+
+	roles, err := s.DBClient.GetUserRoles(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao buscar papéis: %v", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"user_id": userID, "roles": roles})
+}
+
+// PUT /admin/users/{userId}/roles { "roles": ["caregiver", "clinician"] }
+// Substitui os papéis do usuário ("user" é sempre mantido). Se algum papel for removido,
+// as sessões do usuário são revogadas para que a perda de permissão valha imediatamente.
+func (s *Service) HandleSetUserRoles(w http.ResponseWriter, r *http.Request) {
+	adminID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+	userID := strings.TrimSpace(mux.Vars(r)["userId"])
+
+	var payload struct {
+		Roles []string `json:"roles"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, "Requisição inválida.")
+		return
+	}
+	roles := make([]string, 0, len(payload.Roles))
+	for _, role := range payload.Roles {
+		role = strings.ToLower(strings.TrimSpace(role))
+		if !models.IsValidRole(role) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Papel inválido: %q.", role))
+			return
+		}
+		if !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	if userID == adminID && !slices.Contains(roles, models.RoleAdmin) {
+		writeError(w, http.StatusBadRequest, "Não é possível remover o próprio papel de admin.")
+		return
+	}
+
+	if _, err := s.DBClient.GetUserByID(r.Context(), userID); errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Usuário não encontrado.")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao buscar usuário: %v", err))
+		return
+	}
+
+	previous, err := s.DBClient.GetUserRoles(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao buscar papéis: %v", err))
+		return
+	}
+	if err := s.DBClient.SetUserRoles(r.Context(), userID, roles, adminID); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao atualizar papéis: %v", err))
+		return
+	}
+
+	for _, role := range previous {
+		if role != models.RoleUser && !slices.Contains(roles, role) {
+			if err := s.DBClient.RevokeUserSessions(r.Context(), userID); err != nil {
+				log.Printf("AVISO: Falha ao revogar sessões após remoção de papel de %s: %v", userID, err)
+			}
+			break
+		}
+	}
+
+	current, err := s.DBClient.GetUserRoles(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao buscar papéis: %v", err))
+		return
+	}
+	if !slices.Equal(previous, current) {
+		auth.Audit(r, s.DBClient, models.AuditEvent{
+			UserID:  userID,
+			Action:  models.AuditRolesChanged,
+			Changes: auth.AuditDiff("roles", previous, current),
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"message": "Papéis atualizados. As mudanças valem a partir do próximo token.",
+		"user_id": userID,
+		"roles":   current,
+	})
+}
+
+// ===== Auditoria =====
+
+// GET /admin/audit-events?user_id=&actor_id=&action=&target_type=&target_id=&ip=&from=&to=&limit=&offset=
+// from/to em RFC 3339; limit padrão 50 (máx. 200).
+func (s *Service) HandleListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := db.AuditEventFilter{
+		UserID:     strings.TrimSpace(q.Get("user_id")),
+		ActorID:    strings.TrimSpace(q.Get("actor_id")),
+		Action:     strings.TrimSpace(q.Get("action")),
+		TargetType: strings.TrimSpace(q.Get("target_type")),
+		TargetID:   strings.TrimSpace(q.Get("target_id")),
+		IP:         strings.TrimSpace(q.Get("ip")),
+	}
+	for _, id := range []string{filter.UserID, filter.ActorID} {
+		if id != "" {
+			if _, err := uuid.Parse(id); err != nil {
+				writeError(w, http.StatusBadRequest, "user_id/actor_id inválido.")
+				return
+			}
+		}
+	}
+	var err error
+	if v := q.Get("from"); v != "" {
+		if filter.From, err = time.Parse(time.RFC3339, v); err != nil {
+			writeError(w, http.StatusBadRequest, "from inválido (use RFC 3339).")
+			return
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if filter.To, err = time.Parse(time.RFC3339, v); err != nil {
+			writeError(w, http.StatusBadRequest, "to inválido (use RFC 3339).")
+			return
+		}
+	}
+	limit, offset := pagination(r)
+
+	events, err := s.DBClient.ListAuditEvents(r.Context(), filter, limit, offset)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao listar eventos: %v", err))
+		return
+	}
+	if events == nil {
+		events = []models.AuditEvent{}
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// pagination lê limit (padrão 50, máx. 200) e offset da query string.
+func pagination(r *http.Request) (int, int) {
+	q := r.URL.Query()
+	limit := 50
+	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+	offset := 0
+	if o, err := strconv.Atoi(q.Get("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+	return limit, offset
+}
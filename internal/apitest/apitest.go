@@ -0,0 +1,87 @@
+package apitest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"go-guardiao-api/internal/auth"
+	"go-guardiao-api/internal/platforms/cache"
+	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/pkg/models"
+)
+
+var loadKeys sync.Once
+
+// API é o router de teste com as dependências em memória expostas para conferência.
+type API struct {
+	Store   *db.MemoryStore
+	Cache   *cache.Memory
+	Handler http.Handler
+}
+
+// New monta as rotas registradas por register atrás do JWTAuthMiddleware.
+// As chaves de assinatura efêmeras são carregadas uma vez por processo.
+func New(t *testing.T, register func(r *mux.Router, store *db.MemoryStore, c *cache.Memory)) *API {
+	t.Helper()
+	loadKeys.Do(func() {
+		if err := auth.LoadSigningKeys(); err != nil {
+			panic(err)
+		}
+	})
+	store := db.NewMemoryStore()
+	c := cache.NewMemory(0)
+	router := mux.NewRouter()
+	router.Use(auth.JWTAuthMiddleware(store))
+	register(router, store, c)
+	return &API{Store: store, Cache: c, Handler: router}
+}
+
+// SignIn cria a conta u (com ID gerado se vazio) e uma sessão ativa, e devolve
+// o ID e o access token.
+func (a *API) SignIn(t *testing.T, u models.User) (string, string) {
+	t.Helper()
+	ctx := context.Background()
+	if u.ID == "" {
+		u.ID = uuid.New().String()
+	}
+	if u.Name == "" {
+		u.Name = "Ana"
+	}
+	u.EmailVerified = true
+	if err := a.Store.CreateUser(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+	sessionID, err := a.Store.CreateSession(ctx, u.ID, "teste", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := auth.GenerateToken(u, sessionID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.ID, token
+}
+
+// Do envia a requisição e decodifica a resposta JSON em out (se não for nil).
+func (a *API) Do(t *testing.T, method, path, token, body string, out any) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	a.Handler.ServeHTTP(rec, req)
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: resposta inválida %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+
+	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Chaves de API pessoais =====
+
+// Formato: gk_<12 hex>_<64 hex>. O trecho "gk_<12 hex>" é o prefixo guardado em claro.
+const apiKeyTag = "gk_"
+
+const apiKeyTouchInterval = time.Minute
+
+// GenerateAPIKey cria uma nova chave. Devolve a chave completa (exibida uma única vez),
+// o prefixo de identificação e o hash a ser persistido.
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	id := make([]byte, 6)
+	secret := make([]byte, 32)
+	if _, err = rand.Read(id); err != nil {
+		return "", "", "", err
+	}
+	if _, err = rand.Read(secret); err != nil {
+		return "", "", "", err
+	}
+	prefix = apiKeyTag + hex.EncodeToString(id)
+	key = prefix + "_" + hex.EncodeToString(secret)
+	return key, prefix, hashToken(key), nil
+}
+
+// apiKeyPrefix extrai o prefixo de uma chave bem formada ("" se não for uma chave de API).
+func apiKeyPrefix(key string) string {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(key, apiKeyTag), "_")
+	if !strings.HasPrefix(key, apiKeyTag) || !ok || len(id) != 12 || len(secret) != 64 {
+		return ""
+	}
+	return apiKeyTag + id
+}
+
+// apiKeyFromRequest lê a chave de "X-API-Key" ou de "Authorization: Bearer gk_...".
+func apiKeyFromRequest(r *http.Request) string {
+	if k := strings.TrimSpace(r.Header.Get("X-API-Key")); k != "" {
+		return k
+	}
+	authHeader := r.Header.Get("Authorization")
+	if strings.HasPrefix(strings.ToLower(authHeader), "bearer ") {
+		if token := strings.TrimSpace(authHeader[7:]); strings.HasPrefix(token, apiKeyTag) {
+			return token
+		}
+	}
+	return ""
+}
+
+// GetAPIKeyScopesFromContext retorna os escopos da chave de API (nil quando autenticado por JWT).
+func GetAPIKeyScopesFromContext(r *http.Request) []string {
+	scopes, _ := r.Context().Value(apiKeyScopesKey).([]string)
+	return scopes
+}
+
+// APIKeyAuth autentica requisições por chave de API como alternativa ao JWT.
+// Chaves só valem nas rotas liberadas com Allow, e apenas com o escopo exigido pela rota.
+type APIKeyAuth struct {
+	dbClient db.Store
+
+	mu     sync.RWMutex
+	routes map[*mux.Route]string
+}
+
+func NewAPIKeyAuth(dbClient db.Store) *APIKeyAuth {
+	return &APIKeyAuth{dbClient: dbClient, routes: map[*mux.Route]string{}}
+}
+
+// Allow libera a rota para chaves de API que tenham o escopo informado.
+func (a *APIKeyAuth) Allow(route *mux.Route, scope string) *mux.Route {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.routes[route] = scope
+	return route
+}
+
+// Middleware usa a chave de API quando presente; caso contrário delega ao middleware de JWT.
+// O ID do usuário vai para o mesmo lugar do contexto lido por GetUserIDFromContext.
+func (a *APIKeyAuth) Middleware(jwtMiddleware func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		viaJWT := jwtMiddleware(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw := apiKeyFromRequest(r)
+			if raw == "" {
+				viaJWT.ServeHTTP(w, r)
+				return
+			}
+
+			key, ok := a.authenticate(w, r, raw)
+			if !ok {
+				return
+			}
+
+			a.mu.RLock()
+			scope, allowed := a.routes[mux.CurrentRoute(r)]
+			a.mu.RUnlock()
+			if !allowed {
+				errorJSON(w, http.StatusForbidden, "Esta rota não aceita chaves de API.")
+				return
+			}
+			if !slices.Contains(key.Scopes, scope) {
+				errorJSON(w, http.StatusForbidden, "Chave de API sem o escopo "+scope+".")
+				return
+			}
+
+			u, err := a.dbClient.GetUserByID(r.Context(), key.UserID)
+			if err != nil {
+				errorJSON(w, http.StatusUnauthorized, "Chave de API inválida")
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userIDKey, u.ID)
+			ctx = context.WithValue(ctx, emailKey, u.Email)
+			ctx = context.WithValue(ctx, emailVerifiedKey, u.EmailVerified)
+			ctx = context.WithValue(ctx, apiKeyScopesKey, key.Scopes)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// authenticate valida a chave (hash, revogação, expiração) e registra o último uso.
+func (a *APIKeyAuth) authenticate(w http.ResponseWriter, r *http.Request, raw string) (models.APIKey, bool) {
+	prefix := apiKeyPrefix(raw)
+	if prefix == "" {
+		errorJSON(w, http.StatusUnauthorized, "Chave de API inválida")
+		return models.APIKey{}, false
+	}
+	key, err := a.dbClient.GetAPIKeyByPrefix(r.Context(), prefix)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao validar chave de API")
+		return models.APIKey{}, false
+	}
+	if err != nil || subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(hashToken(raw))) != 1 {
+		errorJSON(w, http.StatusUnauthorized, "Chave de API inválida")
+		return models.APIKey{}, false
+	}
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt)) {
+		errorJSON(w, http.StatusUnauthorized, "Chave de API revogada ou expirada")
+		return models.APIKey{}, false
+	}
+
+	if key.LastUsedAt == nil || time.Since(*key.LastUsedAt) > apiKeyTouchInterval {
+		if err := a.dbClient.TouchAPIKey(r.Context(), key.ID); err != nil {
+			log.Printf("AVISO: %v", err)
+		}
+	}
+	return key, true
+}
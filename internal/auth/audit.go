@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"log"
+	"net/http"
+
+	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Trilha de auditoria =====
+
+// Audit grava o evento com IP e user agent da requisição. Sem ActorID, usa o usuário
+// autenticado; sem UserID (conta afetada), usa o próprio ator.
+// Falhas só vão para o log: a auditoria não interrompe a operação já concluída.
+func Audit(r *http.Request, dbClient db.Store, ev models.AuditEvent) {
+	if ev.ActorID == "" {
+		ev.ActorID, _ = GetUserIDFromContext(r)
+	}
+	if ev.UserID == "" {
+		ev.UserID = ev.ActorID
+	}
+	ev.IP = ClientIP(r)
+	ev.UserAgent = r.UserAgent()
+	if err := dbClient.CreateAuditEvent(r.Context(), ev); err != nil {
+		log.Printf("AVISO: Falha ao auditar %s de %s: %v", ev.Action, ev.UserID, err)
+	}
+}
+
+// auditLoginFailure registra uma tentativa de login recusada. userID vazio: conta inexistente.
+// O e-mail digitado não é gravado.
+func auditLoginFailure(r *http.Request, dbClient db.Store, userID, method, reason string) {
+	Audit(r, dbClient, models.AuditEvent{
+		UserID:   userID,
+		Action:   models.AuditLoginFailed,
+		Metadata: map[string]any{"method": method, "reason": reason},
+	})
+}
+
+// AuditDiff monta o antes/depois de um único campo.
+func AuditDiff(field string, from, to any) map[string]models.AuditChange {
+	return map[string]models.AuditChange{field: {From: from, To: to}}
+}
+
+// AuditRedacted registra que campos com dados pessoais mudaram, sem os valores.
+func AuditRedacted(fields ...string) map[string]models.AuditChange {
+	changes := make(map[string]models.AuditChange, len(fields))
+	for _, f := range fields {
+		changes[f] = models.AuditChange{Redacted: true}
+	}
+	return changes
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"regexp"
@@ -13,9 +13,9 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/jackc/pgx/v5"
-	"golang.org/x/crypto/bcrypt"
 
 	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/internal/platforms/mailer"
 	"go-guardiao-api/pkg/models"
 )
 
@@ -24,13 +24,15 @@ import (
 type contextKey string
 
 const (
-	userIDKey contextKey = "userID"
-	emailKey  contextKey = "userEmail"
+	userIDKey        contextKey = "userID"
+	emailKey         contextKey = "userEmail"
+	sessionIDKey     contextKey = "sessionID"
+	emailVerifiedKey contextKey = "emailVerified"
+	rolesKey         contextKey = "roles"
+	apiKeyScopesKey  contextKey = "apiKeyScopes" // escopos quando autenticado por chave de API
 )
 
-// ===== JWT secret =====
-
-var jwtKey = []byte(getEnv("JWT_SECRET", "sua-chave-secreta-muito-forte"))
+// ===== Env =====
 
 func getEnv(k, fallback string) string {
 	if v := os.Getenv(k); v != "" {
@@ -42,23 +44,43 @@ func getEnv(k, fallback string) string {
 // ===== Claims =====
 
 type Claims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
+	UserID        string   `json:"user_id"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	Roles         []string `json:"roles,omitempty"`
+	SessionID     string   `json:"sid,omitempty"`
+	Purpose       string   `json:"purpose,omitempty"` // vazio = access token; "mfa" = desafio de 2FA
 	jwt.RegisteredClaims
 }
 
 // ===== JWT helpers =====
 
-func GenerateToken(userID, email string, expiration time.Duration) (string, error) {
+func GenerateToken(u models.User, sessionID string, expiration time.Duration) (string, error) {
 	claims := &Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:        u.ID,
+		Email:         u.Email,
+		EmailVerified: u.EmailVerified,
+		Roles:         u.Roles,
+		SessionID:     sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiration)),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+	return signClaims(claims)
+}
+
+// parseToken valida assinatura e expiração do JWT e retorna os claims.
+func parseToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, verificationKey,
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("token inválido")
+	}
+	return claims, nil
 }
 
 func GetUserIDFromContext(r *http.Request) (string, error) {
@@ -69,7 +91,15 @@ func GetUserIDFromContext(r *http.Request) (string, error) {
 	return userID, nil
 }
 
-// ===== Password rules + bcrypt =====
+func GetSessionIDFromContext(r *http.Request) (string, error) {
+	sessionID, ok := r.Context().Value(sessionIDKey).(string)
+	if !ok || strings.TrimSpace(sessionID) == "" {
+		return "", errors.New("session ID não encontrado no contexto")
+	}
+	return sessionID, nil
+}
+
+// ===== Password rules =====
 
 // Regras: mínimo 8, 1 maiúscula, 1 minúscula, 1 dígito, 1 especial
 var (
@@ -95,46 +125,67 @@ func ValidatePassword(pw string) error {
 	if !reSpecial.MatchString(pw) {
 		return errors.New("senha deve conter ao menos um caractere especial")
 	}
+	if isBreachedPassword(pw) {
+		return errors.New("senha muito comum ou exposta em vazamentos; escolha outra")
+	}
 	return nil
 }
 
-func HashPassword(pw string) (string, error) {
-	b, err := bcrypt.GenerateFromPassword([]byte(pw), bcrypt.DefaultCost)
-	return string(b), err
-}
-
-func CheckPassword(pw, hash string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(pw))
-}
+// dummyHash é comparado quando a conta não existe, para que o tempo de resposta
+// não revele quais e-mails estão cadastrados.
+var dummyHash, _ = HashPassword("Dummy@Password#1")
 
 // ===== Middleware =====
 
-func JWTAuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if !strings.HasPrefix(strings.ToLower(authHeader), "bearer ") {
-			errorJSON(w, http.StatusUnauthorized, "Token inválido ou ausente")
-			return
-		}
-		tokenString := strings.TrimSpace(authHeader[7:])
+const sessionTouchInterval = time.Minute
 
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("método de assinatura inválido")
+// JWTAuthMiddleware valida o access token e recusa tokens cuja sessão foi revogada.
+func JWTAuthMiddleware(dbClient db.Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+			if !strings.HasPrefix(strings.ToLower(authHeader), "bearer ") {
+				errorJSON(w, http.StatusUnauthorized, "Token inválido ou ausente")
+				return
 			}
-			return jwtKey, nil
-		})
+			tokenString := strings.TrimSpace(authHeader[7:])
 
-		if err != nil || !token.Valid {
-			errorJSON(w, http.StatusUnauthorized, "Token inválido ou expirado")
-			return
-		}
+			claims, err := parseToken(tokenString)
+			if err != nil {
+				errorJSON(w, http.StatusUnauthorized, "Token inválido ou expirado")
+				return
+			}
 
-		ctx := context.WithValue(r.Context(), userIDKey, claims.UserID)
-		ctx = context.WithValue(ctx, emailKey, claims.Email)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+			// Tokens sem sessão (emitidos antes da rotação), de propósito especial (desafio 2FA)
+			// ou de sessões revogadas são recusados
+			if strings.TrimSpace(claims.SessionID) == "" || claims.Purpose != "" {
+				errorJSON(w, http.StatusUnauthorized, "Token inválido ou expirado")
+				return
+			}
+			session, err := dbClient.GetSession(r.Context(), claims.SessionID)
+			if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				errorJSON(w, http.StatusInternalServerError, "Falha ao validar sessão")
+				return
+			}
+			if err != nil || session.RevokedAt != nil || session.UserID != claims.UserID {
+				errorJSON(w, http.StatusUnauthorized, "Sessão encerrada. Faça login novamente.")
+				return
+			}
+			// Último acesso do dispositivo, com no máximo uma escrita por minuto
+			if time.Since(session.LastSeenAt) > sessionTouchInterval {
+				if err := dbClient.TouchSession(r.Context(), session.ID, ClientIP(r)); err != nil {
+					log.Printf("AVISO: %v", err)
+				}
+			}
+
+			ctx := context.WithValue(r.Context(), userIDKey, claims.UserID)
+			ctx = context.WithValue(ctx, emailKey, claims.Email)
+			ctx = context.WithValue(ctx, sessionIDKey, claims.SessionID)
+			ctx = context.WithValue(ctx, emailVerifiedKey, claims.EmailVerified)
+			ctx = context.WithValue(ctx, rolesKey, claims.Roles)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
 }
 
 // ===== JSON helpers =====
@@ -159,13 +210,19 @@ type AuthPayload struct {
 	Email    string `json:"email"`
 	Name     string `json:"name,omitempty"`
 	Password string `json:"password,omitempty"`
+
+	// Cadastro: aceite da versão vigente dos termos e finalidades opcionais consentidas
+	AcceptTerms  bool     `json:"accept_terms,omitempty"`
+	TermsVersion string   `json:"terms_version,omitempty"`
+	Consents     []string `json:"consents,omitempty"`
 }
 
 // ===== Handlers (com DB) =====
 
-// Registro: exige email + senha forte, salva hash e retorna token.
+// Registro: exige email + senha forte e o aceite da versão vigente dos termos, salva hash e consentimentos,
+// envia a verificação de e-mail e retorna access + refresh token.
 // Se o email já existir e NÃO tiver password_hash (usuário legado), define a senha e retorna sucesso.
-func HandleRegisterWithDB(w http.ResponseWriter, r *http.Request, dbClient *db.Client) {
+func HandleRegisterWithDB(w http.ResponseWriter, r *http.Request, dbClient db.Store, m mailer.Mailer) {
 	var p AuthPayload
 	if err := json.NewDecoder(r.Body).Decode(&p); err != nil || strings.TrimSpace(p.Email) == "" {
 		errorJSON(w, http.StatusBadRequest, "Email é obrigatório.")
@@ -175,6 +232,11 @@ func HandleRegisterWithDB(w http.ResponseWriter, r *http.Request, dbClient *db.C
 		errorJSON(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	consents, err := registrationConsents(p.AcceptTerms, p.TermsVersion, p.Consents)
+	if err != nil {
+		errorJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	u, err := dbClient.GetUserByEmail(r.Context(), p.Email)
 	switch {
@@ -196,10 +258,17 @@ func HandleRegisterWithDB(w http.ResponseWriter, r *http.Request, dbClient *db.C
 			return
 		}
 		u, _ = dbClient.GetUserByEmail(r.Context(), p.Email)
+		Audit(r, dbClient, models.AuditEvent{ActorID: u.ID, Action: models.AuditRegister})
 
 	case err == nil:
-		// Usuário já existe
-		if strings.TrimSpace(u.PasswordHash) == "" {
+		// Usuário já existe. Contas sem senha criadas por login externo (OIDC) não podem
+		// receber senha por aqui: isso permitiria tomar a conta sem provar a posse do e-mail.
+		linked, err := dbClient.CountUserIdentities(r.Context(), u.ID)
+		if err != nil {
+			errorJSON(w, http.StatusInternalServerError, "Falha ao consultar usuário")
+			return
+		}
+		if strings.TrimSpace(u.PasswordHash) == "" && linked == 0 {
 			hash, err := HashPassword(p.Password)
 			if err != nil {
 				errorJSON(w, http.StatusInternalServerError, "Falha ao processar senha")
@@ -210,6 +279,7 @@ func HandleRegisterWithDB(w http.ResponseWriter, r *http.Request, dbClient *db.C
 				return
 			}
 			u, _ = dbClient.GetUserByEmail(r.Context(), p.Email)
+			Audit(r, dbClient, models.AuditEvent{ActorID: u.ID, Action: models.AuditPasswordChanged, Metadata: map[string]any{"via": "register"}})
 		} else {
 			errorJSON(w, http.StatusConflict, "Email já cadastrado")
 			return
@@ -220,30 +290,61 @@ func HandleRegisterWithDB(w http.ResponseWriter, r *http.Request, dbClient *db.C
 		return
 	}
 
-	token, err := GenerateToken(u.ID, u.Email, 24*time.Hour)
+	// Sem o registro, o fluxo de novo consentimento é exigido no primeiro uso
+	if err := RecordConsents(r, dbClient, u.ID, consents); err != nil {
+		log.Printf("AVISO: Falha ao registrar consentimentos de %s: %v", u.ID, err)
+	}
+
+	if !u.EmailVerified {
+		if err := SendEmailVerification(r.Context(), dbClient, m, u, u.Email); err != nil {
+			log.Printf("AVISO: Falha ao iniciar verificação de e-mail para %s: %v", u.ID, err)
+		}
+	}
+
+	tokens, err := issueSession(r, dbClient, u, "register")
 	if err != nil {
 		errorJSON(w, http.StatusInternalServerError, "Falha ao gerar token")
 		return
 	}
-	okJSON(w, http.StatusCreated, map[string]string{
-		"message": "Usuário registrado com sucesso",
-		"token":   token,
-		"user_id": u.ID,
+	okJSON(w, http.StatusCreated, map[string]any{
+		"message":       "Usuário registrado com sucesso",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+		"user_id":       u.ID,
 	})
 }
 
-// Login: exige email + senha, valida bcrypt e retorna token
-func HandleLoginWithDB(w http.ResponseWriter, r *http.Request, dbClient *db.Client) {
+// Login: exige email + senha, valida o hash (argon2id ou bcrypt) e abre uma nova sessão (access + refresh token).
+// Falhas são contabilizadas por conta e por IP (atraso progressivo e bloqueio temporário)
+// e sempre respondem com a mesma mensagem, para não revelar quais contas existem.
+func HandleLoginWithDB(w http.ResponseWriter, r *http.Request, dbClient db.Store, guard *LoginGuard) {
 	var p AuthPayload
 	if err := json.NewDecoder(r.Body).Decode(&p); err != nil || strings.TrimSpace(p.Email) == "" || strings.TrimSpace(p.Password) == "" {
 		errorJSON(w, http.StatusBadRequest, "Email e senha são obrigatórios.")
 		return
 	}
 
+	ip := ClientIP(r)
+	wait, err := guard.Check(r.Context(), p.Email, ip)
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao validar tentativas de login")
+		return
+	}
+	if wait > 0 {
+		tooManyAttempts(w, wait)
+		return
+	}
+
+	const invalidCredentials = "Email ou senha inválidos"
+
 	u, err := dbClient.GetUserByEmail(r.Context(), p.Email)
 	switch {
 	case errors.Is(err, pgx.ErrNoRows):
-		errorJSON(w, http.StatusUnauthorized, "Usuário não encontrado")
+		_ = CheckPassword(p.Password, dummyHash)
+		guard.RegisterFailure(r.Context(), p.Email, ip, "")
+		auditLoginFailure(r, dbClient, "", "password", "unknown_account")
+		errorJSON(w, http.StatusUnauthorized, invalidCredentials)
 		return
 	case err != nil:
 		errorJSON(w, http.StatusInternalServerError, "Falha ao consultar usuário")
@@ -251,23 +352,51 @@ func HandleLoginWithDB(w http.ResponseWriter, r *http.Request, dbClient *db.Clie
 	}
 
 	if strings.TrimSpace(u.PasswordHash) == "" {
-		errorJSON(w, http.StatusUnauthorized, "Conta sem senha definida. Registre-se para definir a senha.")
+		_ = CheckPassword(p.Password, dummyHash)
+		guard.RegisterFailure(r.Context(), p.Email, ip, u.ID)
+		auditLoginFailure(r, dbClient, u.ID, "password", "no_password")
+		errorJSON(w, http.StatusUnauthorized, invalidCredentials)
 		return
 	}
 
 	if err := CheckPassword(p.Password, u.PasswordHash); err != nil {
-		errorJSON(w, http.StatusUnauthorized, "Senha inválida")
+		guard.RegisterFailure(r.Context(), p.Email, ip, u.ID)
+		auditLoginFailure(r, dbClient, u.ID, "password", "wrong_password")
+		errorJSON(w, http.StatusUnauthorized, invalidCredentials)
+		return
+	}
+	guard.RegisterSuccess(r.Context(), p.Email)
+
+	// Hash com algoritmo/parâmetros antigos: regrava com os atuais (a senha está em mãos só agora)
+	if NeedsRehash(u.PasswordHash) {
+		if hash, err := HashPassword(p.Password); err != nil {
+			log.Printf("AVISO: Falha ao recalcular hash de senha de %s: %v", u.ID, err)
+		} else if err := dbClient.ReplacePasswordHash(r.Context(), u.ID, u.PasswordHash, hash); err != nil {
+			log.Printf("AVISO: %v", err)
+		}
+	}
+
+	// 2FA ativo (TOTP ou passkey): a sessão só é aberta após /auth/login/mfa*
+	methods, err := secondFactors(r.Context(), dbClient, u)
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao consultar 2FA")
+		return
+	}
+	if len(methods) > 0 {
+		respondMFARequired(w, u.ID, methods)
 		return
 	}
 
-	token, err := GenerateToken(u.ID, u.Email, 24*time.Hour)
+	tokens, err := issueSession(r, dbClient, u, "password")
 	if err != nil {
 		errorJSON(w, http.StatusInternalServerError, "Falha ao gerar token")
 		return
 	}
-	okJSON(w, http.StatusOK, map[string]string{
-		"message": "Login realizado com sucesso",
-		"token":   token,
-		"user_id": u.ID,
+	okJSON(w, http.StatusOK, map[string]any{
+		"message":       "Login realizado com sucesso",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+		"user_id":       u.ID,
 	})
 }
@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Consentimento (dados de saúde, LGPD) =====
+//
+// Cada consentimento vale para a versão dos termos em que foi dado: ao publicar uma
+// nova versão (TERMS_VERSION), os recursos protegidos por RequireConsent passam a
+// responder "consent_required" até o usuário aceitar de novo em PUT /user/consents.
+
+var (
+	TermsVersion = getEnv("TERMS_VERSION", "1")
+	termsURL     = getEnv("TERMS_URL", "http://localhost:4200/termos")
+)
+
+// consentPurposeInfo descreve as finalidades para a tela de consentimento.
+var consentPurposeInfo = map[string]struct {
+	required    bool
+	description string
+}{
+	models.ConsentCoreTracking:   {true, "Registrar e armazenar seus hábitos, medicações e progresso."},
+	models.ConsentSupportSharing: {false, "Compartilhar alertas e progresso com os contatos da sua rede de apoio."},
+	models.ConsentAnalytics:      {false, "Usar dados de uso anonimizados para melhorar o aplicativo."},
+	models.ConsentMarketing:      {false, "Receber novidades e comunicações promocionais."},
+}
+
+// IsRequiredConsent informa se a finalidade é obrigatória para usar o aplicativo.
+func IsRequiredConsent(purpose string) bool {
+	return consentPurposeInfo[purpose].required
+}
+
+// RecordConsents grava concessões/revogações na versão atual dos termos, com IP e
+// user agent da requisição, e audita cada mudança.
+func RecordConsents(r *http.Request, dbClient db.Store, userID string, choices map[string]bool) error {
+	records := make([]models.ConsentRecord, 0, len(choices))
+	for _, purpose := range models.ConsentPurposes {
+		granted, ok := choices[purpose]
+		if !ok {
+			continue
+		}
+		records = append(records, models.ConsentRecord{
+			UserID:       userID,
+			Purpose:      purpose,
+			Granted:      granted,
+			TermsVersion: TermsVersion,
+			IP:           ClientIP(r),
+			UserAgent:    r.UserAgent(),
+		})
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	if err := dbClient.RecordConsents(r.Context(), records); err != nil {
+		return err
+	}
+	for _, rec := range records {
+		action := models.AuditConsentGranted
+		if !rec.Granted {
+			action = models.AuditConsentRevoked
+		}
+		Audit(r, dbClient, models.AuditEvent{
+			UserID:     userID,
+			Action:     action,
+			TargetType: "consent",
+			TargetID:   rec.Purpose,
+			Metadata:   map[string]any{"terms_version": rec.TermsVersion},
+		})
+	}
+	return nil
+}
+
+// ConsentStatuses monta a situação atual de cada finalidade a partir dos registros mais recentes.
+// Concessões dadas em versões anteriores dos termos não contam como concedidas.
+func ConsentStatuses(current map[string]models.ConsentRecord) ([]models.ConsentStatus, bool) {
+	out := make([]models.ConsentStatus, 0, len(models.ConsentPurposes))
+	reconsent := false
+	for _, purpose := range models.ConsentPurposes {
+		st := models.ConsentStatus{Purpose: purpose, Required: IsRequiredConsent(purpose)}
+		if rec, ok := current[purpose]; ok {
+			createdAt := rec.CreatedAt
+			st.Granted = rec.Granted && rec.TermsVersion == TermsVersion
+			st.TermsVersion, st.UpdatedAt = rec.TermsVersion, &createdAt
+		}
+		if st.Required && !st.Granted {
+			reconsent = true
+		}
+		out = append(out, st)
+	}
+	return out, reconsent
+}
+
+// RequireConsent libera a rota apenas se o usuário consentiu com a finalidade na versão
+// atual dos termos. Deve ser usado depois da autenticação (JWT ou chave de API).
+func RequireConsent(dbClient db.Store, purpose string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, err := GetUserIDFromContext(r)
+			if err != nil {
+				errorJSON(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+				return
+			}
+			ok, err := dbClient.HasConsent(r.Context(), userID, purpose, TermsVersion)
+			if err != nil {
+				errorJSON(w, http.StatusInternalServerError, "Falha ao consultar consentimento")
+				return
+			}
+			if !ok {
+				okJSON(w, http.StatusForbidden, map[string]string{
+					"error":         fmt.Sprintf("Consentimento necessário para este recurso (%s). Revise os termos em PUT /user/consents.", purpose),
+					"code":          "consent_required",
+					"purpose":       purpose,
+					"terms_version": TermsVersion,
+					"terms_url":     termsURL,
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GET /api/v1/consents/terms — versão vigente dos termos e finalidades (público)
+func HandleGetTerms(w http.ResponseWriter, _ *http.Request) {
+	type purpose struct {
+		Purpose     string `json:"purpose"`
+		Required    bool   `json:"required"`
+		Description string `json:"description"`
+	}
+	purposes := make([]purpose, 0, len(models.ConsentPurposes))
+	for _, p := range models.ConsentPurposes {
+		info := consentPurposeInfo[p]
+		purposes = append(purposes, purpose{Purpose: p, Required: info.required, Description: info.description})
+	}
+	okJSON(w, http.StatusOK, map[string]any{
+		"terms_version": TermsVersion,
+		"terms_url":     termsURL,
+		"purposes":      purposes,
+	})
+}
+
+// registrationConsents valida o aceite dos termos no cadastro e devolve as escolhas a gravar:
+// a finalidade obrigatória e as opcionais marcadas.
+func registrationConsents(acceptTerms bool, termsVersion string, optional []string) (map[string]bool, error) {
+	if !acceptTerms || strings.TrimSpace(termsVersion) != TermsVersion {
+		return nil, fmt.Errorf("é necessário aceitar a versão atual dos termos (%s)", TermsVersion)
+	}
+	choices := map[string]bool{}
+	for _, p := range models.ConsentPurposes {
+		if IsRequiredConsent(p) {
+			choices[p] = true
+		}
+	}
+	for _, p := range optional {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if !models.IsValidConsentPurpose(p) {
+			return nil, fmt.Errorf("finalidade de consentimento inválida: %q", p)
+		}
+		choices[p] = true
+	}
+	return choices, nil
+}
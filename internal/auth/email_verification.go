@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/internal/platforms/mailer"
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Configuração da verificação de e-mail =====
+
+var (
+	emailVerificationTTL = getEnvDuration("EMAIL_VERIFICATION_TTL", 48*time.Hour)
+	emailVerificationURL = getEnv("EMAIL_VERIFICATION_URL", "http://localhost:4200/verify-email")
+)
+
+// deliverAsync envia o e-mail em background, apenas registrando falhas no log.
+func deliverAsync(m mailer.Mailer, msg mailer.Message, purpose string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := m.Send(ctx, msg); err != nil {
+			log.Printf("AVISO: Falha ao enviar e-mail de %s: %v", purpose, err)
+		}
+	}()
+}
+
+// SendEmailVerification gera um token de verificação para o endereço informado
+// (e-mail atual no cadastro ou e-mail pendente na troca) e envia o link para ele.
+func SendEmailVerification(ctx context.Context, dbClient db.Store, m mailer.Mailer, u models.User, email string) error {
+	token, err := newOpaqueToken()
+	if err != nil {
+		return err
+	}
+	if err := dbClient.CreateEmailVerificationToken(ctx, u.ID, email, hashToken(token), time.Now().Add(emailVerificationTTL)); err != nil {
+		return err
+	}
+	deliverAsync(m, mailer.Message{
+		To:      email,
+		Subject: "Guardião da Saúde — confirme seu e-mail",
+		Body: fmt.Sprintf(
+			"Olá%s,\n\nConfirme que este endereço pertence a você acessando o link abaixo (válido por %s):\n\n%s\n\nSe você não reconhece este pedido, ignore este e-mail.\n",
+			greetingName(u.Name), emailVerificationTTL, buildLink(emailVerificationURL, token),
+		),
+	}, "verificação")
+	return nil
+}
+
+// ===== Middleware =====
+
+// RequireVerifiedEmail bloqueia recursos restritos a contas com e-mail confirmado.
+// Deve ser usado depois de JWTAuthMiddleware.
+func RequireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if verified, _ := r.Context().Value(emailVerifiedKey).(bool); !verified {
+			errorJSON(w, http.StatusForbidden, "Confirme seu e-mail para usar este recurso.")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ===== Handlers (com DB) =====
+
+// Verificar e-mail: consome o token; se for de troca de e-mail, efetiva o novo endereço.
+func HandleVerifyEmailWithDB(w http.ResponseWriter, r *http.Request, dbClient db.Store) {
+	var p struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil || strings.TrimSpace(p.Token) == "" {
+		errorJSON(w, http.StatusBadRequest, "Token é obrigatório.")
+		return
+	}
+
+	userID, previous, email, err := dbClient.VerifyEmailWithToken(r.Context(), hashToken(strings.TrimSpace(p.Token)))
+	switch {
+	case errors.Is(err, db.ErrVerificationTokenInvalid):
+		errorJSON(w, http.StatusBadRequest, "Token inválido ou expirado")
+		return
+	case errors.Is(err, db.ErrEmailTaken):
+		errorJSON(w, http.StatusConflict, "Email já cadastrado")
+		return
+	case err != nil:
+		errorJSON(w, http.StatusInternalServerError, "Falha ao confirmar e-mail")
+		return
+	}
+
+	if !strings.EqualFold(previous, email) {
+		Audit(r, dbClient, models.AuditEvent{
+			ActorID: userID,
+			Action:  models.AuditEmailChanged,
+			Changes: AuditRedacted("email"),
+		})
+	}
+
+	// Os claims só refletem a verificação após o próximo /auth/refresh.
+	okJSON(w, http.StatusOK, map[string]string{
+		"message": "E-mail confirmado com sucesso. Renove o token para liberar todos os recursos.",
+		"user_id": userID,
+		"email":   email,
+	})
+}
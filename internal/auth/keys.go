@@ -0,0 +1,288 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ===== Chaves de assinatura (RS256 / EdDSA) =====
+
+// signingKey é uma chave com identificador (kid) publicada no JWKS.
+type signingKey struct {
+	kid    string
+	method jwt.SigningMethod
+	public crypto.PublicKey
+}
+
+// KeySet guarda a chave privada ativa e todas as chaves públicas aceitas na verificação.
+// Para rotacionar: gere a nova chave privada e mantenha a pública antiga em
+// JWT_PUBLIC_KEY_FILES até que os tokens emitidos com ela expirem.
+type KeySet struct {
+	active  signingKey
+	private crypto.Signer
+	verify  map[string]signingKey
+}
+
+var (
+	keysMu sync.RWMutex
+	keys   *KeySet
+)
+
+// LoadSigningKeys carrega as chaves do ambiente:
+//   - JWT_PRIVATE_KEY_FILE ou JWT_PRIVATE_KEY: chave privada PEM (RSA ou Ed25519, PKCS#8/PKCS#1)
+//   - JWT_KEY_ID: kid da chave ativa (padrão: thumbprint RFC 7638)
+//   - JWT_PUBLIC_KEY_FILES (separados por vírgula) ou JWT_PUBLIC_KEYS: chaves públicas PEM
+//     adicionais aceitas na verificação (chaves anteriores em rotação)
+//
+// Sem chave configurada, em produção (GO_ENV=production) retorna erro; fora dela
+// gera uma chave Ed25519 efêmera (tokens deixam de valer a cada reinício).
+func LoadSigningKeys() error {
+	pemData, err := readKeySource("JWT_PRIVATE_KEY_FILE", "JWT_PRIVATE_KEY")
+	if err != nil {
+		return err
+	}
+
+	var private crypto.Signer
+	if len(pemData) == 0 {
+		if strings.EqualFold(getEnv("GO_ENV", "development"), "production") {
+			return errors.New("nenhuma chave de assinatura JWT configurada (defina JWT_PRIVATE_KEY_FILE ou JWT_PRIVATE_KEY)")
+		}
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return fmt.Errorf("falha ao gerar chave efêmera: %w", err)
+		}
+		log.Println("⚠️ JWT: nenhuma chave configurada; usando chave Ed25519 efêmera (apenas para desenvolvimento).")
+		private = priv
+	} else {
+		if private, err = parsePrivateKey(pemData); err != nil {
+			return err
+		}
+	}
+
+	active, err := newSigningKey(private.Public(), getEnv("JWT_KEY_ID", ""))
+	if err != nil {
+		return err
+	}
+	set := &KeySet{active: active, private: private, verify: map[string]signingKey{active.kid: active}}
+
+	var extra [][]byte
+	for _, path := range strings.Split(getEnv("JWT_PUBLIC_KEY_FILES", ""), ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("falha ao ler chave pública %s: %w", path, err)
+		}
+		extra = append(extra, b)
+	}
+	if v := getEnv("JWT_PUBLIC_KEYS", ""); v != "" {
+		extra = append(extra, []byte(v))
+	}
+	for _, data := range extra {
+		pubs, err := parsePublicKeys(data)
+		if err != nil {
+			return err
+		}
+		for _, pub := range pubs {
+			k, err := newSigningKey(pub, "")
+			if err != nil {
+				return err
+			}
+			if _, exists := set.verify[k.kid]; !exists {
+				set.verify[k.kid] = k
+			}
+		}
+	}
+
+	keysMu.Lock()
+	keys = set
+	keysMu.Unlock()
+	log.Printf("JWT: chave ativa kid=%s alg=%s (%d chave(s) de verificação).", active.kid, active.method.Alg(), len(set.verify))
+	return nil
+}
+
+func currentKeys() (*KeySet, error) {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	if keys == nil {
+		return nil, errors.New("chaves JWT não carregadas")
+	}
+	return keys, nil
+}
+
+func readKeySource(fileEnv, valueEnv string) ([]byte, error) {
+	if path := getEnv(fileEnv, ""); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("falha ao ler %s (%s): %w", fileEnv, path, err)
+		}
+		return b, nil
+	}
+	// Permite "\n" literal em variáveis de ambiente de uma linha só
+	return []byte(strings.ReplaceAll(getEnv(valueEnv, ""), `\n`, "\n")), nil
+}
+
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("chave privada JWT não está em formato PEM")
+	}
+	if k, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		switch key := k.(type) {
+		case *rsa.PrivateKey:
+			return key, nil
+		case ed25519.PrivateKey:
+			return key, nil
+		}
+		return nil, errors.New("tipo de chave privada não suportado (use RSA ou Ed25519)")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("falha ao interpretar a chave privada JWT")
+}
+
+func parsePublicKeys(data []byte) ([]crypto.PublicKey, error) {
+	var pubs []crypto.PublicKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if k, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+			pubs = append(pubs, k)
+			continue
+		}
+		if k, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+			pubs = append(pubs, k)
+			continue
+		}
+		return nil, fmt.Errorf("falha ao interpretar chave pública PEM (%s)", block.Type)
+	}
+	return pubs, nil
+}
+
+// newSigningKey define o algoritmo pelo tipo da chave e calcula o kid (thumbprint) se não informado.
+func newSigningKey(pub crypto.PublicKey, kid string) (signingKey, error) {
+	k := signingKey{kid: kid, public: pub}
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		k.method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		k.method = jwt.SigningMethodEdDSA
+	default:
+		return signingKey{}, errors.New("tipo de chave pública não suportado (use RSA ou Ed25519)")
+	}
+	if k.kid == "" {
+		k.kid = thumbprint(k.jwk())
+	}
+	return k, nil
+}
+
+// ===== JWKS =====
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// OKP (Ed25519) / EC
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"` // apenas EC (chaves de provedores OIDC)
+}
+
+func (k signingKey) jwk() jwk {
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		return jwk{Kty: "RSA", N: b64(pub.N.Bytes()), E: b64(big.NewInt(int64(pub.E)).Bytes())}
+	case ed25519.PublicKey:
+		return jwk{Kty: "OKP", Crv: "Ed25519", X: b64(pub)}
+	}
+	return jwk{}
+}
+
+// thumbprint implementa o JWK Thumbprint (RFC 7638) com SHA-256.
+func thumbprint(j jwk) string {
+	var canonical string
+	switch j.Kty {
+	case "RSA":
+		canonical = fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, j.E, j.N)
+	case "OKP":
+		canonical = fmt.Sprintf(`{"crv":"%s","kty":"OKP","x":"%s"}`, j.Crv, j.X)
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// HandleJWKS publica as chaves públicas de verificação em /.well-known/jwks.json.
+func HandleJWKS(w http.ResponseWriter, _ *http.Request) {
+	set, err := currentKeys()
+	if err != nil {
+		errorJSON(w, http.StatusServiceUnavailable, "Chaves indisponíveis")
+		return
+	}
+	out := make([]jwk, 0, len(set.verify))
+	for _, k := range set.verify {
+		j := k.jwk()
+		j.Kid, j.Use, j.Alg = k.kid, "sig", k.method.Alg()
+		out = append(out, j)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Kid < out[j].Kid })
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	okJSON(w, http.StatusOK, map[string]any{"keys": out})
+}
+
+// ===== Assinatura e verificação =====
+
+// signClaims assina os claims com a chave ativa, incluindo o kid no header.
+func signClaims(claims jwt.Claims) (string, error) {
+	set, err := currentKeys()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(set.active.method, claims)
+	token.Header["kid"] = set.active.kid
+	return token.SignedString(set.private)
+}
+
+// verificationKey escolhe a chave pública pelo kid do header e confere o algoritmo.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	set, err := currentKeys()
+	if err != nil {
+		return nil, err
+	}
+	kid, _ := token.Header["kid"].(string)
+	k, ok := set.verify[kid]
+	if !ok {
+		return nil, fmt.Errorf("kid desconhecido: %q", kid)
+	}
+	if token.Method.Alg() != k.method.Alg() {
+		return nil, fmt.Errorf("método de assinatura inválido")
+	}
+	return k.public, nil
+}
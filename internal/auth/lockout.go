@@ -0,0 +1,273 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"go-guardiao-api/internal/platforms/cache"
+	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Política de tentativas de login =====
+
+const (
+	scopeAccount = "account"
+	scopeIP      = "ip"
+)
+
+var (
+	loginAttemptWindow    = getEnvDuration("LOGIN_ATTEMPT_WINDOW", 15*time.Minute)
+	loginLockoutDuration  = getEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute)
+	loginMaxAccountFails  = getEnvInt("LOGIN_MAX_ATTEMPTS", 5)
+	loginMaxIPFails       = getEnvInt("LOGIN_MAX_ATTEMPTS_IP", 20)
+	loginDelayAfter       = getEnvInt("LOGIN_DELAY_AFTER", 3) // falhas antes de começar o atraso progressivo
+	loginMaxDelay         = 30 * time.Second
+	loginHistoryRetention = getEnvDuration("LOGIN_HISTORY_RETENTION", 30*24*time.Hour)
+	trustProxyHeaders     = getEnv("TRUST_PROXY_HEADERS", "false") == "true"
+)
+
+func getEnvInt(k string, fallback int) int {
+	n, err := strconv.Atoi(getEnv(k, ""))
+	if err != nil || n <= 0 {
+		return fallback
+	}
+	return n
+}
+
+// ClientIP retorna o IP do cliente. X-Forwarded-For só é considerado com TRUST_PROXY_HEADERS=true.
+func ClientIP(r *http.Request) string {
+	if trustProxyHeaders {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			return strings.TrimSpace(strings.Split(xff, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// progressiveDelay: 1s, 2s, 4s... a partir de loginDelayAfter falhas, limitado a loginMaxDelay.
+func progressiveDelay(failures int) time.Duration {
+	if failures < loginDelayAfter {
+		return 0
+	}
+	d := time.Second * time.Duration(math.Pow(2, float64(failures-loginDelayAfter)))
+	if d > loginMaxDelay || d <= 0 {
+		return loginMaxDelay
+	}
+	return d
+}
+
+// ===== LoginGuard =====
+
+// LoginGuard controla falhas de login por conta e por IP.
+// Usa o Redis quando disponível e recorre ao Postgres quando o cache falha. A conta é
+// identificada pelo índice cego do e-mail (db.LoginAttemptRepository.LoginAccountKey),
+// nunca pelo e-mail em si.
+type LoginGuard struct {
+	dbClient    db.Store
+	cacheClient cache.Cache
+}
+
+func NewLoginGuard(dbClient db.Store, cacheClient cache.Cache) *LoginGuard {
+	return &LoginGuard{dbClient: dbClient, cacheClient: cacheClient}
+}
+
+func (g *LoginGuard) get(ctx context.Context, scope, key string) (models.LoginAttempts, error) {
+	a, err := g.cacheClient.GetLoginAttempts(ctx, scope, key)
+	if err == nil {
+		return a, nil
+	}
+	return g.dbClient.GetLoginAttempts(ctx, scope, key, loginAttemptWindow)
+}
+
+func (g *LoginGuard) registerFailure(ctx context.Context, scope, key string) (models.LoginAttempts, error) {
+	a, err := g.cacheClient.RegisterLoginFailure(ctx, scope, key, loginAttemptWindow)
+	if err == nil {
+		return a, nil
+	}
+	return g.dbClient.RegisterLoginFailure(ctx, scope, key, loginAttemptWindow)
+}
+
+func (g *LoginGuard) lock(ctx context.Context, scope, key string, until time.Time) error {
+	if err := g.cacheClient.LockLogin(ctx, scope, key, until); err == nil {
+		return nil
+	}
+	return g.dbClient.LockLogin(ctx, scope, key, until)
+}
+
+// reset limpa os dois backends: o Redis pode ter voltado depois de uma falha registrada no Postgres.
+func (g *LoginGuard) reset(ctx context.Context, scope, key string) error {
+	_ = g.cacheClient.ResetLoginAttempts(ctx, scope, key)
+	return g.dbClient.ResetLoginAttempts(ctx, scope, key)
+}
+
+// Check informa por quanto tempo o login ainda está barrado para a conta/IP (0 = liberado).
+func (g *LoginGuard) Check(ctx context.Context, email, ip string) (time.Duration, error) {
+	var wait time.Duration
+	for _, k := range []struct{ scope, key string }{{scopeAccount, g.dbClient.LoginAccountKey(email)}, {scopeIP, ip}} {
+		a, err := g.get(ctx, k.scope, k.key)
+		if err != nil {
+			return 0, err
+		}
+		if until := time.Until(a.LockedUntil); until > wait {
+			wait = until
+		}
+		if !a.LastFailure.IsZero() {
+			if until := time.Until(a.LastFailure.Add(progressiveDelay(a.Failures))); until > wait {
+				wait = until
+			}
+		}
+	}
+	return wait, nil
+}
+
+// RegisterFailure contabiliza a falha e aplica (e registra) o bloqueio ao atingir o limite.
+func (g *LoginGuard) RegisterFailure(ctx context.Context, email, ip, userID string) {
+	limits := []struct {
+		scope, key string
+		max        int
+	}{
+		{scopeAccount, g.dbClient.LoginAccountKey(email), loginMaxAccountFails},
+		{scopeIP, ip, loginMaxIPFails},
+	}
+	for _, l := range limits {
+		a, err := g.registerFailure(ctx, l.scope, l.key)
+		if err != nil {
+			log.Printf("AVISO: Falha ao registrar tentativa de login (%s): %v", l.scope, err)
+			continue
+		}
+		if a.Failures < l.max || time.Now().Before(a.LockedUntil) {
+			continue
+		}
+		until := time.Now().Add(loginLockoutDuration)
+		if err := g.lock(ctx, l.scope, l.key, until); err != nil {
+			log.Printf("AVISO: Falha ao bloquear login (%s): %v", l.scope, err)
+			continue
+		}
+		lockout := models.LoginLockout{Scope: l.scope, Key: l.key, IP: ip, Failures: a.Failures, LockedUntil: until}
+		if l.scope == scopeAccount {
+			lockout.UserID = userID
+		}
+		if err := g.dbClient.RecordLockout(ctx, lockout); err != nil {
+			log.Printf("AVISO: Falha ao registrar evento de bloqueio: %v", err)
+		}
+	}
+}
+
+// RegisterSuccess zera o contador da conta (o do IP expira sozinho).
+func (g *LoginGuard) RegisterSuccess(ctx context.Context, email string) {
+	if err := g.reset(ctx, scopeAccount, g.dbClient.LoginAccountKey(email)); err != nil {
+		log.Printf("AVISO: Falha ao zerar tentativas de login: %v", err)
+	}
+}
+
+// Run apaga, a cada intervalo, contadores e eventos de bloqueio mais antigos que
+// LOGIN_HISTORY_RETENTION (padrão 30 dias), até o contexto ser cancelado.
+func (g *LoginGuard) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := g.dbClient.PurgeLoginHistory(ctx, time.Now().Add(-loginHistoryRetention)); err != nil {
+			log.Printf("AVISO: Falha ao apagar histórico de login antigo: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tooManyAttempts responde 429 com Retry-After.
+func tooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	errorJSON(w, http.StatusTooManyRequests, "Muitas tentativas de login. Tente novamente mais tarde.")
+}
+
+// ===== Handlers administrativos =====
+
+// GET /admin/lockouts?active=true&limit=50&offset=0 — lista eventos de bloqueio.
+func (g *LoginGuard) HandleListLockouts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit := 50
+	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+	offset := 0
+	if o, err := strconv.Atoi(q.Get("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	lockouts, err := g.dbClient.ListLockouts(r.Context(), q.Get("active") == "true", limit, offset)
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao listar bloqueios: %v", err))
+		return
+	}
+	if lockouts == nil {
+		lockouts = []models.LoginLockout{}
+	}
+	// A chave do escopo de conta não diz nada ao admin: a conta está em user_id
+	for i := range lockouts {
+		if lockouts[i].Scope == scopeAccount {
+			lockouts[i].Key = ""
+		}
+	}
+	okJSON(w, http.StatusOK, lockouts)
+}
+
+// POST /admin/lockouts/{lockoutId}/unlock — desbloqueia a conta/IP do evento.
+func (g *LoginGuard) HandleUnlock(w http.ResponseWriter, r *http.Request) {
+	adminID, err := GetUserIDFromContext(r)
+	if err != nil {
+		errorJSON(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["lockoutId"], 10, 64)
+	if err != nil {
+		errorJSON(w, http.StatusBadRequest, "lockoutId inválido.")
+		return
+	}
+
+	lockout, err := g.dbClient.GetLockout(r.Context(), id)
+	if err != nil {
+		errorJSON(w, http.StatusNotFound, "Bloqueio não encontrado.")
+		return
+	}
+
+	if err := g.reset(r.Context(), lockout.Scope, lockout.Key); err != nil {
+		errorJSON(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao desbloquear: %v", err))
+		return
+	}
+	if err := g.dbClient.MarkLockoutUnlocked(r.Context(), id, adminID); err != nil {
+		errorJSON(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao registrar desbloqueio: %v", err))
+		return
+	}
+	// A chave do escopo de conta fica fora da trilha (a conta está em UserID)
+	metadata := map[string]any{"scope": lockout.Scope}
+	if lockout.Scope == scopeIP {
+		metadata["key"] = lockout.Key
+	}
+	Audit(r, g.dbClient, models.AuditEvent{
+		UserID:     lockout.UserID,
+		Action:     models.AuditLockoutUnlocked,
+		TargetType: "lockout",
+		TargetID:   strconv.FormatInt(id, 10),
+		Metadata:   metadata,
+	})
+
+	okJSON(w, http.StatusOK, map[string]string{"message": "Login desbloqueado."})
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-guardiao-api/internal/platforms/cache"
+	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/pkg/models"
+)
+
+func TestAccountLockoutKeyIsNotTheEmail(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemoryStore()
+	g := NewLoginGuard(store, cache.NewMemory(0))
+	const email = "Ana@Exemplo.com"
+
+	for i := 0; i < loginMaxAccountFails; i++ {
+		g.RegisterFailure(ctx, email, "10.0.0.1", "")
+	}
+	if wait, err := g.Check(ctx, " ana@exemplo.com ", "10.0.0.2"); err != nil || wait <= 0 {
+		t.Fatalf("conta deveria estar bloqueada em qualquer grafia do e-mail: %v, %v", wait, err)
+	}
+	lockouts, err := store.ListLockouts(ctx, true, 10, 0)
+	if err != nil || len(lockouts) != 1 || lockouts[0].Scope != scopeAccount {
+		t.Fatalf("bloqueios: %+v, %v", lockouts, err)
+	}
+	if key := lockouts[0].Key; key != store.LoginAccountKey(email) || strings.Contains(strings.ToLower(key), "exemplo") {
+		t.Fatalf("chave do escopo de conta: %q", key)
+	}
+
+	rec := httptest.NewRecorder()
+	g.HandleListLockouts(rec, httptest.NewRequest("GET", "/admin/lockouts", nil))
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), `"key"`) {
+		t.Fatalf("GET /admin/lockouts não deveria expor a chave da conta: %d %s", rec.Code, rec.Body.String())
+	}
+	var listed []models.LoginLockout
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil || len(listed) != 1 {
+		t.Fatalf("resposta: %v", err)
+	}
+
+	g.RegisterSuccess(ctx, "ANA@exemplo.com")
+	if a, _ := store.GetLoginAttempts(ctx, scopeAccount, store.LoginAccountKey(email), loginAttemptWindow); a.Failures != 0 || !a.LockedUntil.IsZero() {
+		t.Fatalf("login bem-sucedido deveria zerar a conta: %+v", a)
+	}
+}
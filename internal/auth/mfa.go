@@ -0,0 +1,309 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+
+	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Desafio de 2FA no login =====
+
+const (
+	mfaPurpose        = "mfa"
+	recoveryCodeCount = 10
+)
+
+// Segundos fatores aceitos em /auth/login/mfa*.
+const (
+	mfaMethodTOTP    = "totp"
+	mfaMethodPasskey = "passkey"
+)
+
+var mfaChallengeTTL = getEnvDuration("MFA_CHALLENGE_TTL", 5*time.Minute)
+
+// generateMFAChallenge emite um token curto que só serve para concluir o login em /auth/login/mfa.
+func generateMFAChallenge(userID string) (string, error) {
+	claims := &Claims{
+		UserID:  userID,
+		Purpose: mfaPurpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(mfaChallengeTTL)),
+		},
+	}
+	return signClaims(claims)
+}
+
+// secondFactors lista os segundos fatores ativos do usuário (vazio = login sem 2FA).
+// Qualquer passkey cadastrada também passa a ser exigida após a senha.
+func secondFactors(ctx context.Context, dbClient db.Store, u models.User) ([]string, error) {
+	var methods []string
+	if u.TOTPEnabled {
+		methods = append(methods, mfaMethodTOTP)
+	}
+	n, err := dbClient.CountWebAuthnCredentials(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		methods = append(methods, mfaMethodPasskey)
+	}
+	return methods, nil
+}
+
+// respondMFARequired devolve o desafio de 2FA no lugar da sessão.
+func respondMFARequired(w http.ResponseWriter, userID string, methods []string) {
+	challenge, err := generateMFAChallenge(userID)
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao gerar desafio 2FA")
+		return
+	}
+	okJSON(w, http.StatusOK, map[string]any{
+		"message":      "Confirme o login com o segundo fator.",
+		"mfa_required": true,
+		"mfa_methods":  methods,
+		"mfa_token":    challenge,
+		"expires_in":   int(mfaChallengeTTL.Seconds()),
+	})
+}
+
+// verifySecondFactor aceita um código TOTP (uma única vez por passo) ou um código de recuperação.
+func verifySecondFactor(ctx context.Context, dbClient db.Store, userID, secret, code string) (bool, error) {
+	if step, ok := ValidateTOTP(secret, code, time.Now()); ok {
+		return dbClient.UseTOTPStep(ctx, userID, step)
+	}
+	if normalized := normalizeRecoveryCode(code); normalized != "" {
+		return dbClient.ConsumeRecoveryCode(ctx, userID, hashToken(normalized))
+	}
+	return false, nil
+}
+
+// ===== Handlers públicos =====
+
+// Login (2ª etapa): troca o mfa_token + código TOTP (ou de recuperação) pela sessão.
+func HandleLoginMFAWithDB(w http.ResponseWriter, r *http.Request, dbClient db.Store, guard *LoginGuard) {
+	var p struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil || strings.TrimSpace(p.MFAToken) == "" || strings.TrimSpace(p.Code) == "" {
+		errorJSON(w, http.StatusBadRequest, "mfa_token e code são obrigatórios.")
+		return
+	}
+
+	claims, err := parseToken(strings.TrimSpace(p.MFAToken))
+	if err != nil || claims.Purpose != mfaPurpose {
+		errorJSON(w, http.StatusUnauthorized, "Desafio 2FA inválido ou expirado")
+		return
+	}
+
+	u, err := dbClient.GetUserByID(r.Context(), claims.UserID)
+	if err != nil {
+		errorJSON(w, http.StatusUnauthorized, "Desafio 2FA inválido ou expirado")
+		return
+	}
+
+	ip := ClientIP(r)
+	wait, err := guard.Check(r.Context(), u.Email, ip)
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao validar tentativas de login")
+		return
+	}
+	if wait > 0 {
+		tooManyAttempts(w, wait)
+		return
+	}
+
+	state, err := dbClient.GetTOTPState(r.Context(), u.ID)
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao consultar 2FA")
+		return
+	}
+	if !state.Enabled {
+		errorJSON(w, http.StatusUnauthorized, "Desafio 2FA inválido ou expirado")
+		return
+	}
+
+	ok, err := verifySecondFactor(r.Context(), dbClient, u.ID, state.Secret, p.Code)
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao validar código")
+		return
+	}
+	if !ok {
+		guard.RegisterFailure(r.Context(), u.Email, ip, u.ID)
+		auditLoginFailure(r, dbClient, u.ID, mfaMethodTOTP, "invalid_code")
+		errorJSON(w, http.StatusUnauthorized, "Código inválido")
+		return
+	}
+	guard.RegisterSuccess(r.Context(), u.Email)
+
+	tokens, err := issueSession(r, dbClient, u, "mfa:"+mfaMethodTOTP)
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao gerar token")
+		return
+	}
+	okJSON(w, http.StatusOK, map[string]any{
+		"message":       "Login realizado com sucesso",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+		"user_id":       u.ID,
+	})
+}
+
+// ===== Handlers protegidos (JWT) =====
+
+// POST /user/2fa/enroll — gera um novo segredo (pendente) e devolve o otpauth URI.
+func HandleTOTPEnrollWithDB(w http.ResponseWriter, r *http.Request, dbClient db.Store) {
+	userID, err := GetUserIDFromContext(r)
+	if err != nil {
+		errorJSON(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+
+	u, err := dbClient.GetUserByID(r.Context(), userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		errorJSON(w, http.StatusNotFound, "Usuário não encontrado.")
+		return
+	} else if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao consultar usuário")
+		return
+	}
+	if u.TOTPEnabled {
+		errorJSON(w, http.StatusConflict, "2FA já está ativo.")
+		return
+	}
+
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao gerar segredo")
+		return
+	}
+	if err := dbClient.SetPendingTOTPSecret(r.Context(), userID, secret); err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao registrar segredo")
+		return
+	}
+
+	okJSON(w, http.StatusOK, map[string]string{
+		"message":     "Escaneie o QR code e confirme com um código para ativar o 2FA.",
+		"secret":      secret,
+		"otpauth_uri": TOTPURI(secret, u.Email),
+	})
+}
+
+// POST /user/2fa/confirm { "code": "123456" } — ativa o 2FA e devolve os códigos de recuperação (exibidos uma única vez).
+func HandleTOTPConfirmWithDB(w http.ResponseWriter, r *http.Request, dbClient db.Store) {
+	userID, err := GetUserIDFromContext(r)
+	if err != nil {
+		errorJSON(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+
+	var p struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil || strings.TrimSpace(p.Code) == "" {
+		errorJSON(w, http.StatusBadRequest, "Código é obrigatório.")
+		return
+	}
+
+	state, err := dbClient.GetTOTPState(r.Context(), userID)
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao consultar 2FA")
+		return
+	}
+	if state.PendingSecret == "" {
+		errorJSON(w, http.StatusBadRequest, "Nenhum cadastro de 2FA pendente.")
+		return
+	}
+
+	step, ok := ValidateTOTP(state.PendingSecret, p.Code, time.Now())
+	if !ok {
+		errorJSON(w, http.StatusBadRequest, "Código inválido")
+		return
+	}
+
+	codes, err := generateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao gerar códigos de recuperação")
+		return
+	}
+	hashes := make([]string, 0, len(codes))
+	for _, c := range codes {
+		hashes = append(hashes, hashToken(normalizeRecoveryCode(c)))
+	}
+
+	if err := dbClient.EnableTOTP(r.Context(), userID, step, hashes); err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao ativar 2FA")
+		return
+	}
+	Audit(r, dbClient, models.AuditEvent{Action: models.AuditTOTPEnabled, Changes: AuditDiff("totp_enabled", false, true)})
+
+	okJSON(w, http.StatusOK, map[string]any{
+		"message":        "2FA ativado. Guarde os códigos de recuperação em local seguro.",
+		"recovery_codes": codes,
+	})
+}
+
+// POST /user/2fa/disable { "password": "...", "code": "123456" } — exige senha atual e código válido.
+func HandleTOTPDisableWithDB(w http.ResponseWriter, r *http.Request, dbClient db.Store) {
+	userID, err := GetUserIDFromContext(r)
+	if err != nil {
+		errorJSON(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+
+	var p struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil || strings.TrimSpace(p.Password) == "" || strings.TrimSpace(p.Code) == "" {
+		errorJSON(w, http.StatusBadRequest, "Senha e código são obrigatórios.")
+		return
+	}
+
+	hash, err := dbClient.GetUserPasswordHash(r.Context(), userID)
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao buscar senha")
+		return
+	}
+	if err := CheckPassword(p.Password, hash); err != nil {
+		errorJSON(w, http.StatusUnauthorized, "Senha atual incorreta.")
+		return
+	}
+
+	state, err := dbClient.GetTOTPState(r.Context(), userID)
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao consultar 2FA")
+		return
+	}
+	if !state.Enabled {
+		errorJSON(w, http.StatusBadRequest, "2FA não está ativo.")
+		return
+	}
+
+	ok, err := verifySecondFactor(r.Context(), dbClient, userID, state.Secret, p.Code)
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao validar código")
+		return
+	}
+	if !ok {
+		errorJSON(w, http.StatusUnauthorized, "Código inválido")
+		return
+	}
+
+	if err := dbClient.DisableTOTP(r.Context(), userID); err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao desativar 2FA")
+		return
+	}
+	Audit(r, dbClient, models.AuditEvent{Action: models.AuditTOTPDisabled, Changes: AuditDiff("totp_enabled", true, false)})
+	okJSON(w, http.StatusOK, map[string]string{"message": "2FA desativado."})
+}
@@ -0,0 +1,352 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ===== Provedores OpenID Connect (authorization code + PKCE) =====
+
+// Emissores conhecidos; qualquer outro provedor (ex.: IdP local de testes, ver cmd/oidc_stub)
+// precisa de OIDC_<NOME>_ISSUER.
+var knownIssuers = map[string]string{
+	"google":    "https://accounts.google.com",
+	"microsoft": "https://login.microsoftonline.com/common/v2.0",
+}
+
+const (
+	oidcMetadataTTL   = time.Hour
+	oidcJWKSMinReload = time.Minute
+)
+
+// oidcProvider é um provedor configurado. Metadados (discovery) e chaves (JWKS) ficam em cache.
+type oidcProvider struct {
+	name         string
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       []string
+	httpClient   *http.Client
+
+	mu          sync.Mutex
+	meta        *oidcMetadata
+	metaFetched time.Time
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+}
+
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// idTokenClaims são os claims do ID token que usamos.
+type idTokenClaims struct {
+	Email         string   `json:"email"`
+	EmailVerified flexBool `json:"email_verified"`
+	Name          string   `json:"name"`
+	Nonce         string   `json:"nonce"`
+	TenantID      string   `json:"tid"` // Microsoft (emissor multi-tenant)
+	jwt.RegisteredClaims
+}
+
+// flexBool aceita true/false e "true"/"false" (alguns provedores enviam string).
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	*b = flexBool(strings.EqualFold(s, "true"))
+	return nil
+}
+
+// loadOIDCProviders lê OIDC_PROVIDERS (ex.: "google,microsoft") e, para cada nome:
+//   - OIDC_<NOME>_CLIENT_ID (obrigatório) e OIDC_<NOME>_CLIENT_SECRET (opcional em clientes públicos)
+//   - OIDC_<NOME>_ISSUER (padrão para google/microsoft)
+//   - OIDC_<NOME>_REDIRECT_URL (padrão: OIDC_REDIRECT_URL)
+func loadOIDCProviders() (map[string]*oidcProvider, error) {
+	providers := map[string]*oidcProvider{}
+	defaultRedirect := getEnv("OIDC_REDIRECT_URL", "http://localhost:4200/auth/callback")
+	for _, name := range strings.Split(getEnv("OIDC_PROVIDERS", ""), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		p := &oidcProvider{
+			name:         name,
+			issuer:       strings.TrimRight(getEnv(prefix+"ISSUER", knownIssuers[name]), "/"),
+			clientID:     getEnv(prefix+"CLIENT_ID", ""),
+			clientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			redirectURL:  getEnv(prefix+"REDIRECT_URL", defaultRedirect),
+			scopes:       []string{"openid", "email", "profile"},
+			httpClient:   &http.Client{Timeout: 10 * time.Second},
+		}
+		if p.issuer == "" {
+			return nil, fmt.Errorf("provedor OIDC %q sem %sISSUER", name, prefix)
+		}
+		if p.clientID == "" {
+			return nil, fmt.Errorf("provedor OIDC %q sem %sCLIENT_ID", name, prefix)
+		}
+		providers[name] = p
+	}
+	return providers, nil
+}
+
+func (p *oidcProvider) getJSON(ctx context.Context, endpoint string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
+
+// metadata busca (e guarda em cache) o documento de discovery do emissor.
+func (p *oidcProvider) metadata(ctx context.Context) (*oidcMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil && time.Since(p.metaFetched) < oidcMetadataTTL {
+		return p.meta, nil
+	}
+	var m oidcMetadata
+	if err := p.getJSON(ctx, p.issuer+"/.well-known/openid-configuration", &m); err != nil {
+		return nil, fmt.Errorf("falha no discovery OIDC de %s: %w", p.name, err)
+	}
+	if m.AuthorizationEndpoint == "" || m.TokenEndpoint == "" || m.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery OIDC de %s incompleto", p.name)
+	}
+	// Emissores multi-tenant (Microsoft "common") publicam o issuer como template
+	if !strings.Contains(m.Issuer, "{tenantid}") && strings.TrimRight(m.Issuer, "/") != p.issuer {
+		return nil, fmt.Errorf("issuer do discovery (%s) difere do configurado (%s)", m.Issuer, p.issuer)
+	}
+	p.meta, p.metaFetched = &m, time.Now()
+	return p.meta, nil
+}
+
+// authCodeURL monta a URL de autorização com state, nonce e desafio PKCE (S256).
+func (p *oidcProvider) authCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	m, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(m.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("authorization_endpoint inválido: %w", err)
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.clientID)
+	q.Set("redirect_uri", p.redirectURL)
+	q.Set("scope", strings.Join(p.scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", pkceChallenge(verifier))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// exchange troca o authorization code (com o code_verifier) pelo ID token.
+func (p *oidcProvider) exchange(ctx context.Context, code, verifier string) (string, error) {
+	m, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.redirectURL},
+		"client_id":     {p.clientID},
+		"code_verifier": {verifier},
+	}
+	if p.clientSecret != "" {
+		form.Set("client_secret", p.clientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("falha ao chamar token endpoint de %s: %w", p.name, err)
+	}
+	defer resp.Body.Close()
+
+	var out struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&out); err != nil {
+		return "", fmt.Errorf("resposta inválida do token endpoint de %s: %w", p.name, err)
+	}
+	if resp.StatusCode != http.StatusOK || out.Error != "" {
+		return "", fmt.Errorf("token endpoint de %s recusou o código: %s %s", p.name, out.Error, out.ErrorDescription)
+	}
+	if out.IDToken == "" {
+		return "", fmt.Errorf("token endpoint de %s não devolveu id_token", p.name)
+	}
+	return out.IDToken, nil
+}
+
+// verifyIDToken valida assinatura (JWKS do provedor), issuer, audience, expiração e nonce.
+func (p *oidcProvider) verifyIDToken(ctx context.Context, raw, nonce string) (*idTokenClaims, error) {
+	m, err := p.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	claims := &idTokenClaims{}
+	_, err = jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.publicKey(ctx, m.JWKSURI, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+		jwt.WithAudience(p.clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("id_token inválido: %w", err)
+	}
+
+	expectedIssuer := strings.ReplaceAll(m.Issuer, "{tenantid}", claims.TenantID)
+	if claims.Issuer != expectedIssuer {
+		return nil, fmt.Errorf("id_token com issuer inesperado: %s", claims.Issuer)
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("id_token sem sub")
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, errors.New("id_token com nonce inválido")
+	}
+	return claims, nil
+}
+
+// publicKey devolve a chave do JWKS pelo kid, recarregando o JWKS (com limite de frequência)
+// quando o kid é desconhecido — o provedor pode ter rotacionado as chaves.
+func (p *oidcProvider) publicKey(ctx context.Context, jwksURI, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	lookup := func() (crypto.PublicKey, bool) {
+		if kid == "" && len(p.keys) == 1 {
+			for _, k := range p.keys {
+				return k, true
+			}
+		}
+		k, ok := p.keys[kid]
+		return k, ok
+	}
+	if k, ok := lookup(); ok {
+		return k, nil
+	}
+	if time.Since(p.keysFetched) < oidcJWKSMinReload {
+		return nil, fmt.Errorf("kid desconhecido: %q", kid)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.getJSON(ctx, jwksURI, &set); err != nil {
+		return nil, fmt.Errorf("falha ao obter JWKS de %s: %w", p.name, err)
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, j := range set.Keys {
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+		if k, err := j.publicKey(); err == nil {
+			keys[j.Kid] = k
+		}
+	}
+	p.keys, p.keysFetched = keys, time.Now()
+
+	if k, ok := lookup(); ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("kid desconhecido: %q", kid)
+}
+
+// publicKey converte a JWK (RSA, EC P-256 ou Ed25519) em chave pública.
+func (j jwk) publicKey() (crypto.PublicKey, error) {
+	dec := base64.RawURLEncoding.DecodeString
+	switch j.Kty {
+	case "RSA":
+		n, err := dec(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := dec(j.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if j.Crv != "P-256" {
+			return nil, fmt.Errorf("curva não suportada: %s", j.Crv)
+		}
+		x, err := dec(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := dec(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		x, err := dec(j.X)
+		if err != nil || j.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("chave OKP inválida")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("tipo de chave não suportado: %s", j.Kty)
+}
+
+// pkceChallenge calcula o code_challenge S256 (RFC 7636).
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func sortedProviderNames(providers map[string]*oidcProvider) []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,299 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+
+	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Login com provedores externos (OIDC) =====
+
+var oidcStateTTL = getEnvDuration("OIDC_STATE_TTL", 10*time.Minute)
+
+// OIDCLogin agrupa os provedores configurados e os handlers do fluxo.
+//
+// Fluxo: o frontend chama /auth/oidc/{provider}/start, redireciona o navegador para
+// authorization_url e, ao receber ?code&state no OIDC_REDIRECT_URL, envia ambos para
+// /auth/oidc/{provider}/callback, que devolve o nosso token (ou o desafio 2FA).
+type OIDCLogin struct {
+	dbClient  db.Store
+	providers map[string]*oidcProvider
+}
+
+// NewOIDCLogin carrega os provedores de OIDC_PROVIDERS (nenhum = login externo desativado).
+func NewOIDCLogin(dbClient db.Store) (*OIDCLogin, error) {
+	providers, err := loadOIDCProviders()
+	if err != nil {
+		return nil, err
+	}
+	return &OIDCLogin{dbClient: dbClient, providers: providers}, nil
+}
+
+func (o *OIDCLogin) provider(w http.ResponseWriter, r *http.Request) (*oidcProvider, bool) {
+	p, ok := o.providers[strings.ToLower(mux.Vars(r)["provider"])]
+	if !ok {
+		errorJSON(w, http.StatusNotFound, "Provedor não suportado.")
+	}
+	return p, ok
+}
+
+// begin registra state/nonce/PKCE e devolve a URL de autorização do provedor.
+func (o *OIDCLogin) begin(w http.ResponseWriter, r *http.Request, p *oidcProvider, linkUserID string) {
+	state, err := newOpaqueToken()
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao iniciar login externo")
+		return
+	}
+	nonce, err := newOpaqueToken()
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao iniciar login externo")
+		return
+	}
+	verifier, err := newOpaqueToken()
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao iniciar login externo")
+		return
+	}
+
+	authURL, err := p.authCodeURL(r.Context(), state, nonce, verifier)
+	if err != nil {
+		log.Printf("AVISO: %v", err)
+		errorJSON(w, http.StatusBadGateway, "Provedor indisponível")
+		return
+	}
+
+	st := models.OIDCLoginState{
+		Provider:     p.name,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		LinkUserID:   linkUserID,
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	}
+	if err := o.dbClient.CreateOIDCState(r.Context(), hashToken(state), st); err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao iniciar login externo")
+		return
+	}
+
+	okJSON(w, http.StatusOK, map[string]any{
+		"authorization_url": authURL,
+		"state":             state,
+		"expires_in":        int(oidcStateTTL.Seconds()),
+	})
+}
+
+// GET /auth/oidc/providers — provedores disponíveis para o botão "Entrar com..."
+func (o *OIDCLogin) HandleListProviders(w http.ResponseWriter, _ *http.Request) {
+	okJSON(w, http.StatusOK, map[string]any{"providers": sortedProviderNames(o.providers)})
+}
+
+// GET /auth/oidc/{provider}/start — inicia o login externo.
+func (o *OIDCLogin) HandleStart(w http.ResponseWriter, r *http.Request) {
+	p, ok := o.provider(w, r)
+	if !ok {
+		return
+	}
+	o.begin(w, r, p, "")
+}
+
+// POST /user/identities/{provider} — (protegido) inicia a vinculação do provedor à conta logada.
+func (o *OIDCLogin) HandleLinkStart(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r)
+	if err != nil {
+		errorJSON(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+	p, ok := o.provider(w, r)
+	if !ok {
+		return
+	}
+	o.begin(w, r, p, userID)
+}
+
+// POST /auth/oidc/{provider}/callback { "code": "...", "state": "..." }
+// Conclui o fluxo: vincula a conta (fluxo iniciado por usuário logado) ou faz o login,
+// localizando o usuário pela identidade, pelo e-mail verificado ou criando a conta.
+func (o *OIDCLogin) HandleCallback(w http.ResponseWriter, r *http.Request) {
+	p, ok := o.provider(w, r)
+	if !ok {
+		return
+	}
+	var payload struct {
+		Code  string `json:"code"`
+		State string `json:"state"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || strings.TrimSpace(payload.Code) == "" || strings.TrimSpace(payload.State) == "" {
+		errorJSON(w, http.StatusBadRequest, "code e state são obrigatórios.")
+		return
+	}
+
+	st, err := o.dbClient.ConsumeOIDCState(r.Context(), hashToken(strings.TrimSpace(payload.State)))
+	if errors.Is(err, db.ErrOIDCStateInvalid) || (err == nil && st.Provider != p.name) {
+		errorJSON(w, http.StatusBadRequest, "Login externo inválido ou expirado. Tente novamente.")
+		return
+	} else if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao validar login externo")
+		return
+	}
+
+	rawIDToken, err := p.exchange(r.Context(), strings.TrimSpace(payload.Code), st.CodeVerifier)
+	if err != nil {
+		log.Printf("AVISO: %v", err)
+		errorJSON(w, http.StatusUnauthorized, "Falha na autenticação com o provedor")
+		return
+	}
+	claims, err := p.verifyIDToken(r.Context(), rawIDToken, st.Nonce)
+	if err != nil {
+		log.Printf("AVISO: OIDC %s: %v", p.name, err)
+		errorJSON(w, http.StatusUnauthorized, "Falha na autenticação com o provedor")
+		return
+	}
+
+	identity := models.UserIdentity{Provider: p.name, Subject: claims.Subject, Email: claims.Email}
+	if st.LinkUserID != "" {
+		o.completeLink(w, r, st.LinkUserID, identity)
+		return
+	}
+
+	u, status, msg := o.resolveUser(r, identity, claims)
+	if status != 0 {
+		errorJSON(w, status, msg)
+		return
+	}
+
+	methods, err := secondFactors(r.Context(), o.dbClient, u)
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao consultar 2FA")
+		return
+	}
+	if len(methods) > 0 {
+		respondMFARequired(w, u.ID, methods)
+		return
+	}
+
+	tokens, err := issueSession(r, o.dbClient, u, "oidc:"+p.name)
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao gerar token")
+		return
+	}
+	okJSON(w, http.StatusOK, map[string]any{
+		"message":       "Login realizado com sucesso",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+		"user_id":       u.ID,
+	})
+}
+
+// resolveUser encontra (ou cria) o usuário da identidade externa.
+// Em caso de erro devolve o status HTTP e a mensagem.
+func (o *OIDCLogin) resolveUser(r *http.Request, identity models.UserIdentity, claims *idTokenClaims) (models.User, int, string) {
+	ctx := r.Context()
+
+	existing, err := o.dbClient.GetIdentity(ctx, identity.Provider, identity.Subject)
+	if err == nil {
+		if err := o.dbClient.TouchIdentity(ctx, identity.Provider, identity.Subject, identity.Email); err != nil {
+			log.Printf("AVISO: %v", err)
+		}
+		u, err := o.dbClient.GetUserByID(ctx, existing.UserID)
+		if err != nil {
+			return models.User{}, http.StatusInternalServerError, "Falha ao consultar usuário"
+		}
+		return u, 0, ""
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return models.User{}, http.StatusInternalServerError, "Falha ao consultar identidade"
+	}
+
+	// Primeiro acesso por este provedor: só confiamos em e-mail verificado pelo provedor
+	const linkHint = "Já existe uma conta com este e-mail. Entre com sua senha e vincule o provedor no seu perfil."
+	if strings.TrimSpace(claims.Email) == "" || !bool(claims.EmailVerified) {
+		return models.User{}, http.StatusForbidden, "O provedor não confirmou o e-mail desta conta."
+	}
+
+	u, err := o.dbClient.GetUserByEmail(ctx, claims.Email)
+	switch {
+	case err == nil:
+		// Vincula pelo e-mail apenas se a conta local também o confirmou;
+		// senão quem cadastrou o e-mail sem confirmá-lo poderia herdar o acesso.
+		if !u.EmailVerified {
+			return models.User{}, http.StatusConflict, linkHint
+		}
+		identity.UserID = u.ID
+		if err := o.dbClient.LinkIdentity(ctx, identity); errors.Is(err, db.ErrIdentityLinked) {
+			return models.User{}, http.StatusConflict, linkHint
+		} else if err != nil {
+			return models.User{}, http.StatusInternalServerError, "Falha ao vincular identidade"
+		}
+		Audit(r, o.dbClient, models.AuditEvent{
+			ActorID:    u.ID,
+			Action:     models.AuditIdentityLinked,
+			TargetType: "identity",
+			TargetID:   identity.Provider,
+			Metadata:   map[string]any{"via": "login"},
+		})
+		return u, 0, ""
+
+	case errors.Is(err, pgx.ErrNoRows):
+		newUser := models.User{
+			ID:            uuid.New().String(),
+			Email:         claims.Email,
+			Name:          claims.Name,
+			EmailVerified: true,
+		}
+		if err := o.dbClient.CreateUserWithIdentity(ctx, newUser, identity); errors.Is(err, db.ErrEmailTaken) || errors.Is(err, db.ErrIdentityLinked) {
+			return models.User{}, http.StatusConflict, linkHint
+		} else if err != nil {
+			return models.User{}, http.StatusInternalServerError, "Falha ao criar usuário"
+		}
+		u, err := o.dbClient.GetUserByID(ctx, newUser.ID)
+		if err != nil {
+			return models.User{}, http.StatusInternalServerError, "Falha ao consultar usuário"
+		}
+		Audit(r, o.dbClient, models.AuditEvent{ActorID: u.ID, Action: models.AuditRegister, Metadata: map[string]any{"provider": identity.Provider}})
+		return u, 0, ""
+
+	default:
+		return models.User{}, http.StatusInternalServerError, "Falha ao consultar usuário"
+	}
+}
+
+// completeLink vincula a identidade ao usuário que iniciou o fluxo logado.
+func (o *OIDCLogin) completeLink(w http.ResponseWriter, r *http.Request, userID string, identity models.UserIdentity) {
+	existing, err := o.dbClient.GetIdentity(r.Context(), identity.Provider, identity.Subject)
+	switch {
+	case err == nil && existing.UserID == userID:
+		okJSON(w, http.StatusOK, map[string]string{"message": "Conta externa já vinculada.", "provider": identity.Provider})
+		return
+	case err == nil:
+		errorJSON(w, http.StatusConflict, "Esta conta externa já está vinculada a outro usuário.")
+		return
+	case !errors.Is(err, pgx.ErrNoRows):
+		errorJSON(w, http.StatusInternalServerError, "Falha ao consultar identidade")
+		return
+	}
+
+	identity.UserID = userID
+	if err := o.dbClient.LinkIdentity(r.Context(), identity); errors.Is(err, db.ErrIdentityLinked) {
+		errorJSON(w, http.StatusConflict, "Você já vinculou outra conta deste provedor.")
+		return
+	} else if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao vincular identidade")
+		return
+	}
+	Audit(r, o.dbClient, models.AuditEvent{
+		ActorID:    userID,
+		Action:     models.AuditIdentityLinked,
+		TargetType: "identity",
+		TargetID:   identity.Provider,
+	})
+	okJSON(w, http.StatusOK, map[string]string{"message": "Conta externa vinculada.", "provider": identity.Provider})
+}
@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ===== Hash de senhas (argon2id / bcrypt) =====
+//
+// Hashes novos usam o formato PHC, que registra algoritmo e parâmetros:
+//   $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+// Hashes bcrypt ($2a$/$2b$/$2y$) continuam aceitos e são migrados no próximo login.
+
+const (
+	hashArgon2id = "argon2id"
+	hashBcrypt   = "bcrypt"
+)
+
+var errUnknownHashFormat = errors.New("formato de hash de senha desconhecido")
+
+// argon2Params são os parâmetros do argon2id (memória em KiB).
+type argon2Params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	keyLen  uint32
+	saltLen int
+}
+
+var (
+	passwordHashAlgorithm = strings.ToLower(getEnv("PASSWORD_HASH_ALGORITHM", hashArgon2id))
+	currentArgon2         = argon2Params{
+		memory:  uint32(getEnvInt("ARGON2_MEMORY_KIB", 64*1024)),
+		time:    uint32(getEnvInt("ARGON2_TIME", 3)),
+		threads: uint8(getEnvInt("ARGON2_THREADS", 2)),
+		keyLen:  32,
+		saltLen: 16,
+	}
+	bcryptCost = getEnvInt("BCRYPT_COST", bcrypt.DefaultCost)
+)
+
+// HashPassword gera o hash com o algoritmo e os parâmetros atuais.
+func HashPassword(pw string) (string, error) {
+	if passwordHashAlgorithm == hashBcrypt {
+		b, err := bcrypt.GenerateFromPassword([]byte(pw), bcryptCost)
+		return string(b), err
+	}
+	salt := make([]byte, currentArgon2.saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	p := currentArgon2
+	key := argon2.IDKey([]byte(pw), salt, p.time, p.memory, p.threads, p.keyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.memory, p.time, p.threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// CheckPassword compara a senha com o hash (argon2id ou bcrypt).
+func CheckPassword(pw, hash string) error {
+	if isBcryptHash(hash) {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(pw))
+	}
+	p, salt, key, err := decodeArgon2Hash(hash)
+	if err != nil {
+		return err
+	}
+	got := argon2.IDKey([]byte(pw), salt, p.time, p.memory, p.threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(got, key) != 1 {
+		return bcrypt.ErrMismatchedHashAndPassword
+	}
+	return nil
+}
+
+// NeedsRehash informa se o hash foi gerado com outro algoritmo ou parâmetros desatualizados.
+func NeedsRehash(hash string) bool {
+	if isBcryptHash(hash) {
+		if passwordHashAlgorithm != hashBcrypt {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost < bcryptCost
+	}
+	p, salt, key, err := decodeArgon2Hash(hash)
+	if err != nil {
+		return true
+	}
+	if passwordHashAlgorithm != hashArgon2id {
+		return true
+	}
+	c := currentArgon2
+	return p.memory != c.memory || p.time != c.time || p.threads != c.threads ||
+		uint32(len(key)) != c.keyLen || len(salt) != c.saltLen
+}
+
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// decodeArgon2Hash interpreta "$argon2id$v=19$m=...,t=...,p=...$salt$hash".
+func decodeArgon2Hash(hash string) (argon2Params, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != hashArgon2id {
+		return argon2Params{}, nil, nil, errUnknownHashFormat
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return argon2Params{}, nil, nil, errUnknownHashFormat
+	}
+	var p argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads); err != nil || p.time == 0 || p.threads == 0 {
+		return argon2Params{}, nil, nil, errUnknownHashFormat
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return argon2Params{}, nil, nil, errUnknownHashFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return argon2Params{}, nil, nil, errUnknownHashFormat
+	}
+	p.keyLen, p.saltLen = uint32(len(key)), len(salt)
+	return p, salt, key, nil
+}
+
+// ===== Senhas vazadas ou comuns =====
+
+// commonPasswords são senhas populares que passam nas regras de complexidade.
+var commonPasswords = []string{
+	"Password@1", "Password@123", "P@ssw0rd", "P@ssw0rd1", "P@ssword1", "Passw0rd!",
+	"Senha@123", "Senha@1234", "Senha123!", "Mudar@123", "Admin@123", "Qwerty@123",
+	"Abc@1234", "Brasil@123", "Welcome@1", "Teste@123",
+}
+
+var reSHA1Line = regexp.MustCompile(`^[0-9A-Fa-f]{40}(:\d+)?$`)
+
+// breachedPasswords guarda as senhas bloqueadas em minúsculas e os SHA-1 (hex maiúsculo)
+// de listas vazadas. Preenchido na inicialização por LoadPasswordBlocklist.
+var breachedPasswords = newBlocklist(commonPasswords)
+
+func newBlocklist(passwords []string) map[string]struct{} {
+	m := make(map[string]struct{}, len(passwords))
+	for _, pw := range passwords {
+		m[strings.ToLower(pw)] = struct{}{}
+	}
+	return m
+}
+
+// LoadPasswordBlocklist carrega PASSWORD_BLOCKLIST_FILE (opcional): uma senha por linha ou
+// SHA-1 em hex no formato dos downloads do Have I Been Pwned ("HASH:contagem").
+// Linhas vazias e iniciadas por "#" são ignoradas.
+func LoadPasswordBlocklist() error {
+	path := getEnv("PASSWORD_BLOCKLIST_FILE", "")
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("falha ao abrir lista de senhas bloqueadas: %w", err)
+	}
+	defer f.Close()
+
+	list := newBlocklist(commonPasswords)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case reSHA1Line.MatchString(line):
+			hash, _, _ := strings.Cut(line, ":")
+			list[strings.ToUpper(hash)] = struct{}{}
+		default:
+			list[strings.ToLower(line)] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("falha ao ler lista de senhas bloqueadas: %w", err)
+	}
+	breachedPasswords = list
+	log.Printf("Lista de senhas bloqueadas carregada (%d entradas).", len(list))
+	return nil
+}
+
+// isBreachedPassword informa se a senha está na lista de senhas vazadas ou comuns.
+func isBreachedPassword(pw string) bool {
+	if _, ok := breachedPasswords[strings.ToLower(pw)]; ok {
+		return true
+	}
+	sum := sha1.Sum([]byte(pw))
+	_, ok := breachedPasswords[strings.ToUpper(hex.EncodeToString(sum[:]))]
+	return ok
+}
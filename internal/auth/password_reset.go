@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/internal/platforms/mailer"
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Configuração do reset de senha =====
+
+var (
+	passwordResetTTL = getEnvDuration("PASSWORD_RESET_TTL", time.Hour)
+	passwordResetURL = getEnv("PASSWORD_RESET_URL", "http://localhost:4200/reset-password")
+)
+
+// buildLink anexa o token como query string ao link do frontend.
+func buildLink(base, token string) string {
+	sep := "?"
+	if strings.Contains(base, "?") {
+		sep = "&"
+	}
+	return base + sep + "token=" + url.QueryEscape(token)
+}
+
+// ===== Handlers (com DB) =====
+
+// Esqueci a senha: sempre responde 202 (não revela se o e-mail existe).
+// Se o usuário existir, gera um token de uso único e envia o link por e-mail.
+func HandleForgotPasswordWithDB(w http.ResponseWriter, r *http.Request, dbClient db.Store, m mailer.Mailer) {
+	var p AuthPayload
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil || strings.TrimSpace(p.Email) == "" {
+		errorJSON(w, http.StatusBadRequest, "Email é obrigatório.")
+		return
+	}
+
+	accepted := map[string]string{
+		"message": "Se o e-mail estiver cadastrado, você receberá as instruções para redefinir a senha.",
+	}
+
+	u, err := dbClient.GetUserByEmail(r.Context(), p.Email)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		okJSON(w, http.StatusAccepted, accepted)
+		return
+	case err != nil:
+		errorJSON(w, http.StatusInternalServerError, "Falha ao consultar usuário")
+		return
+	}
+
+	token, err := newOpaqueToken()
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao gerar token")
+		return
+	}
+	if err := dbClient.CreatePasswordResetToken(r.Context(), u.ID, hashToken(token), time.Now().Add(passwordResetTTL)); err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao registrar solicitação")
+		return
+	}
+
+	msg := mailer.Message{
+		To:      u.Email,
+		Subject: "Guardião da Saúde — redefinição de senha",
+		Body: fmt.Sprintf(
+			"Olá%s,\n\nRecebemos um pedido para redefinir a sua senha.\nUse o link abaixo (válido por %s):\n\n%s\n\nSe você não fez este pedido, ignore este e-mail.\n",
+			greetingName(u.Name), passwordResetTTL, buildLink(passwordResetURL, token),
+		),
+	}
+	// Envio assíncrono: o tempo de resposta não deve indicar se a conta existe.
+	deliverAsync(m, msg, "redefinição de senha")
+
+	okJSON(w, http.StatusAccepted, accepted)
+}
+
+func greetingName(name string) string {
+	if strings.TrimSpace(name) == "" {
+		return ""
+	}
+	return " " + strings.TrimSpace(name)
+}
+
+// Redefinir senha: consome o token, grava a nova senha e encerra todas as sessões do usuário.
+func HandleResetPasswordWithDB(w http.ResponseWriter, r *http.Request, dbClient db.Store) {
+	var p struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil || strings.TrimSpace(p.Token) == "" {
+		errorJSON(w, http.StatusBadRequest, "Token é obrigatório.")
+		return
+	}
+	if err := ValidatePassword(p.Password); err != nil {
+		errorJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hash, err := HashPassword(p.Password)
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao processar senha")
+		return
+	}
+
+	userID, err := dbClient.ResetPasswordWithToken(r.Context(), hashToken(strings.TrimSpace(p.Token)), hash)
+	if errors.Is(err, db.ErrResetTokenInvalid) {
+		errorJSON(w, http.StatusBadRequest, "Token inválido ou expirado")
+		return
+	} else if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao redefinir senha")
+		return
+	}
+	Audit(r, dbClient, models.AuditEvent{ActorID: userID, Action: models.AuditPasswordReset})
+
+	okJSON(w, http.StatusOK, map[string]string{
+		"message": "Senha redefinida com sucesso. Faça login novamente.",
+	})
+}
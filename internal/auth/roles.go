@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"net/http"
+	"slices"
+)
+
+// GetRolesFromContext retorna os papéis carregados do token pelo JWTAuthMiddleware.
+func GetRolesFromContext(r *http.Request) []string {
+	roles, _ := r.Context().Value(rolesKey).([]string)
+	return roles
+}
+
+// HasRole informa se o usuário autenticado possui algum dos papéis informados.
+func HasRole(r *http.Request, roles ...string) bool {
+	userRoles := GetRolesFromContext(r)
+	for _, role := range roles {
+		if slices.Contains(userRoles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireRole libera a rota apenas para quem tiver algum dos papéis informados.
+// Deve ser usado depois de JWTAuthMiddleware (ex.: subrouter.Use(auth.RequireRole(models.RoleAdmin))).
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !HasRole(r, roles...) {
+				errorJSON(w, http.StatusForbidden, "Acesso negado: permissão insuficiente.")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Token TTLs =====
+
+var (
+	accessTokenTTL  = getEnvDuration("JWT_ACCESS_TTL", 15*time.Minute)
+	refreshTokenTTL = getEnvDuration("JWT_REFRESH_TTL", 30*24*time.Hour)
+)
+
+func getEnvDuration(k string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(getEnv(k, ""))
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+// ===== Refresh tokens opacos =====
+
+// TokenPair é o par de tokens devolvido ao cliente em login, registro e refresh.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int // segundos até o access token expirar
+}
+
+// newOpaqueToken gera um token aleatório (256 bits) em base64url.
+func newOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken retorna o SHA-256 (hex) do token; só o hash é persistido.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueSession abre uma nova sessão (registrando user agent e IP do dispositivo),
+// emite o primeiro par de tokens e audita o login com o método usado.
+func issueSession(r *http.Request, dbClient db.Store, u models.User, method string) (TokenPair, error) {
+	ctx := r.Context()
+	roles, err := dbClient.GetUserRoles(ctx, u.ID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	u.Roles = roles
+	sessionID, err := dbClient.CreateSession(ctx, u.ID, r.UserAgent(), ClientIP(r))
+	if err != nil {
+		return TokenPair{}, err
+	}
+	refresh, err := newOpaqueToken()
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if err := dbClient.CreateRefreshToken(ctx, models.RefreshToken{
+		SessionID: sessionID,
+		UserID:    u.ID,
+		TokenHash: hashToken(refresh),
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}); err != nil {
+		return TokenPair{}, err
+	}
+	access, err := GenerateToken(u, sessionID, accessTokenTTL)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	Audit(r, dbClient, models.AuditEvent{
+		ActorID:    u.ID,
+		Action:     models.AuditLogin,
+		TargetType: "session",
+		TargetID:   sessionID,
+		Metadata:   map[string]any{"method": method},
+	})
+	return TokenPair{AccessToken: access, RefreshToken: refresh, ExpiresIn: int(accessTokenTTL.Seconds())}, nil
+}
+
+type refreshPayload struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// ===== Handlers (com DB) =====
+
+// Refresh: troca um refresh token válido por um novo par (rotação a cada uso).
+// Se um token já rotacionado for reapresentado, toda a sessão (família) é revogada.
+func HandleRefreshWithDB(w http.ResponseWriter, r *http.Request, dbClient db.Store) {
+	var p refreshPayload
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil || strings.TrimSpace(p.RefreshToken) == "" {
+		errorJSON(w, http.StatusBadRequest, "refresh_token é obrigatório.")
+		return
+	}
+
+	current, err := dbClient.GetRefreshTokenByHash(r.Context(), hashToken(strings.TrimSpace(p.RefreshToken)))
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		errorJSON(w, http.StatusUnauthorized, "Refresh token inválido")
+		return
+	case err != nil:
+		errorJSON(w, http.StatusInternalServerError, "Falha ao consultar refresh token")
+		return
+	}
+
+	active, err := dbClient.IsSessionActive(r.Context(), current.SessionID)
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao validar sessão")
+		return
+	}
+	if !active {
+		errorJSON(w, http.StatusUnauthorized, "Sessão encerrada. Faça login novamente.")
+		return
+	}
+
+	if current.UsedAt != nil {
+		revokeOnReuse(r.Context(), w, dbClient, current.SessionID)
+		return
+	}
+	if time.Now().After(current.ExpiresAt) {
+		errorJSON(w, http.StatusUnauthorized, "Refresh token expirado")
+		return
+	}
+
+	u, err := dbClient.GetUserByID(r.Context(), current.UserID)
+	if err != nil {
+		errorJSON(w, http.StatusUnauthorized, "Usuário não encontrado")
+		return
+	}
+	// Papéis são relidos a cada refresh: alterações valem no próximo access token
+	if u.Roles, err = dbClient.GetUserRoles(r.Context(), u.ID); err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao consultar papéis do usuário")
+		return
+	}
+
+	if err := dbClient.TouchSession(r.Context(), current.SessionID, ClientIP(r)); err != nil {
+		log.Printf("AVISO: %v", err)
+	}
+
+	refresh, err := newOpaqueToken()
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao gerar token")
+		return
+	}
+	next := models.RefreshToken{
+		SessionID: current.SessionID,
+		UserID:    current.UserID,
+		TokenHash: hashToken(refresh),
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}
+	if err := dbClient.RotateRefreshToken(r.Context(), current.ID, next); errors.Is(err, db.ErrRefreshTokenReused) {
+		revokeOnReuse(r.Context(), w, dbClient, current.SessionID)
+		return
+	} else if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao rotacionar refresh token")
+		return
+	}
+
+	access, err := GenerateToken(u, current.SessionID, accessTokenTTL)
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao gerar token")
+		return
+	}
+	okJSON(w, http.StatusOK, map[string]any{
+		"token":         access,
+		"refresh_token": refresh,
+		"expires_in":    int(accessTokenTTL.Seconds()),
+		"user_id":       u.ID,
+	})
+}
+
+// revokeOnReuse revoga a família inteira quando um refresh token é reutilizado.
+func revokeOnReuse(ctx context.Context, w http.ResponseWriter, dbClient db.Store, sessionID string) {
+	if err := dbClient.RevokeSession(ctx, sessionID); err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao revogar sessão")
+		return
+	}
+	errorJSON(w, http.StatusUnauthorized, "Refresh token reutilizado. Sessão revogada por segurança.")
+}
+
+// Logout: revoga a sessão associada ao refresh token informado.
+func HandleLogoutWithDB(w http.ResponseWriter, r *http.Request, dbClient db.Store) {
+	var p refreshPayload
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil || strings.TrimSpace(p.RefreshToken) == "" {
+		errorJSON(w, http.StatusBadRequest, "refresh_token é obrigatório.")
+		return
+	}
+
+	current, err := dbClient.GetRefreshTokenByHash(r.Context(), hashToken(strings.TrimSpace(p.RefreshToken)))
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		// Idempotente: token desconhecido não revela nada ao cliente
+		okJSON(w, http.StatusOK, map[string]string{"message": "Logout realizado"})
+		return
+	case err != nil:
+		errorJSON(w, http.StatusInternalServerError, "Falha ao consultar refresh token")
+		return
+	}
+
+	if err := dbClient.RevokeSession(r.Context(), current.SessionID); err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao revogar sessão")
+		return
+	}
+	okJSON(w, http.StatusOK, map[string]string{"message": "Logout realizado"})
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/pkg/models"
+)
+
+func TestMain(m *testing.M) {
+	if err := LoadSigningKeys(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// refresh troca o refresh token e devolve o status e o novo par (vazio em caso de erro).
+func refresh(t *testing.T, store db.Store, token string) (int, string, string) {
+	t.Helper()
+	body, _ := json.Marshal(refreshPayload{RefreshToken: token})
+	rec := httptest.NewRecorder()
+	HandleRefreshWithDB(rec, httptest.NewRequest("POST", "/api/v1/auth/refresh", strings.NewReader(string(body))), store)
+	var out struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &out)
+	return rec.Code, out.Token, out.RefreshToken
+}
+
+// authorized informa se o access token passa pelo JWTAuthMiddleware.
+func authorized(store db.Store, access string) bool {
+	h := JWTAuthMiddleware(store)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	req := httptest.NewRequest("GET", "/user/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+access)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code == http.StatusNoContent
+}
+
+func TestRefreshRotationRevokesFamilyOnReuse(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemoryStore()
+	u := models.User{ID: uuid.New().String(), Email: "ana@exemplo.com", Name: "Ana", EmailVerified: true}
+	if err := store.CreateUser(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+	first, err := issueSession(httptest.NewRequest("POST", "/api/v1/auth/login", nil), store, u, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !authorized(store, first.AccessToken) {
+		t.Fatal("access token da sessão nova deveria valer")
+	}
+
+	code, access, second := refresh(t, store, first.RefreshToken)
+	if code != http.StatusOK || second == "" || second == first.RefreshToken {
+		t.Fatalf("rotação: %d, novo refresh %q", code, second)
+	}
+	if !authorized(store, access) {
+		t.Fatal("access token rotacionado deveria valer")
+	}
+
+	// Uma segunda rotação do mesmo token (duas requisições simultâneas) é detectada no store
+	old, err := store.GetRefreshTokenByHash(ctx, hashToken(first.RefreshToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+	racing := models.RefreshToken{SessionID: old.SessionID, UserID: u.ID, TokenHash: hashToken("outro"), ExpiresAt: old.ExpiresAt}
+	if err := store.RotateRefreshToken(ctx, old.ID, racing); !errors.Is(err, db.ErrRefreshTokenReused) {
+		t.Fatalf("segunda rotação: esperado ErrRefreshTokenReused, veio %v", err)
+	}
+
+	// Reapresentar o token já rotacionado revoga a sessão inteira
+	if code, _, _ := refresh(t, store, first.RefreshToken); code != http.StatusUnauthorized {
+		t.Fatalf("reuso do token antigo: esperado 401, veio %d", code)
+	}
+	if code, _, _ := refresh(t, store, second); code != http.StatusUnauthorized {
+		t.Fatalf("token mais novo da família revogada: esperado 401, veio %d", code)
+	}
+	for _, token := range []string{first.AccessToken, access} {
+		if authorized(store, token) {
+			t.Fatal("access token de sessão revogada não pode passar pelo middleware")
+		}
+	}
+	if code, _, _ := refresh(t, store, "inexistente"); code != http.StatusUnauthorized {
+		t.Fatalf("token desconhecido: esperado 401, veio %d", code)
+	}
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ===== TOTP (RFC 6238: HMAC-SHA1, 6 dígitos, passo de 30s) =====
+
+const (
+	totpDigits = 6
+	totpPeriod = 30
+	totpSkew   = 1 // aceita um passo antes/depois para tolerar relógios desalinhados
+)
+
+var (
+	totpIssuer = getEnv("TOTP_ISSUER", "Guardião da Saúde")
+	b32NoPad   = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// GenerateTOTPSecret gera um segredo de 160 bits em base32 (sem padding).
+func GenerateTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return b32NoPad.EncodeToString(b), nil
+}
+
+// TOTPURI monta o otpauth:// URI usado pelos apps autenticadores (QR code).
+func TOTPURI(secret, account string) string {
+	label := url.PathEscape(totpIssuer) + ":" + url.PathEscape(account)
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", totpIssuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(totpPeriod))
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// totpCode calcula o código HOTP (RFC 4226) para o contador informado.
+func totpCode(key []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", bin%1000000)
+}
+
+// ValidateTOTP confere o código no instante informado e retorna o passo (time step) aceito,
+// para que o chamador impeça a reutilização do mesmo código.
+func ValidateTOTP(secret, code string, at time.Time) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	key, err := b32NoPad.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return 0, false
+	}
+	step := at.Unix() / totpPeriod
+	for i := -totpSkew; i <= totpSkew; i++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step+int64(i))), []byte(code)) == 1 {
+			return step + int64(i), true
+		}
+	}
+	return 0, false
+}
+
+// generateRecoveryCodes gera n códigos de recuperação no formato xxxxx-xxxxx.
+func generateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		s := strings.ToLower(b32NoPad.EncodeToString(b))[:10]
+		codes = append(codes, s[:5]+"-"+s[5:])
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode ignora caixa, espaços e o hífen ao comparar códigos de recuperação.
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(code)))
+}
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/pkg/models"
+)
+
+// rfc6238Secret é a chave SHA-1 dos vetores de teste da RFC 6238 ("12345678901234567890").
+var rfc6238Secret = b32NoPad.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// Vetores do apêndice B (8 dígitos); com 6 dígitos valem os 6 últimos
+	for _, tc := range []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	} {
+		if got := totpCode([]byte("12345678901234567890"), tc.unix/totpPeriod); got != tc.want {
+			t.Errorf("T=%d: esperado %s, veio %s", tc.unix, tc.want, got)
+		}
+		step, ok := ValidateTOTP(rfc6238Secret, tc.want, time.Unix(tc.unix, 0))
+		if !ok || step != tc.unix/totpPeriod {
+			t.Errorf("ValidateTOTP(T=%d): passo %d, %v", tc.unix, step, ok)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	key := []byte("12345678901234567890")
+	at := time.Unix(1234567890, 0)
+	step := at.Unix() / totpPeriod
+
+	for offset, accepted := range map[int64]bool{-2: false, -1: true, 0: true, 1: true, 2: false} {
+		got, ok := ValidateTOTP(rfc6238Secret, totpCode(key, step+offset), at)
+		if ok != accepted || (ok && got != step+offset) {
+			t.Errorf("passo %+d: aceito=%v passo=%d", offset, ok, got)
+		}
+	}
+	for _, code := range []string{"", "00592", "0059245", "abcdef"} {
+		if _, ok := ValidateTOTP(rfc6238Secret, code, at); ok {
+			t.Errorf("código %q não deveria ser aceito", code)
+		}
+	}
+	if _, ok := ValidateTOTP(rfc6238Secret, "005 924", at); !ok {
+		t.Error("espaços no código devem ser ignorados")
+	}
+}
+
+// enrollTOTP cria uma conta com 2FA ativo e os códigos de recuperação informados.
+func enrollTOTP(t *testing.T, store *db.MemoryStore, recovery []string) string {
+	t.Helper()
+	ctx := context.Background()
+	userID := uuid.New().String()
+	if err := store.CreateUser(ctx, models.User{ID: userID, Email: "ana@exemplo.com", Name: "Ana"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetPendingTOTPSecret(ctx, userID, rfc6238Secret); err != nil {
+		t.Fatal(err)
+	}
+	var hashes []string
+	for _, c := range recovery {
+		hashes = append(hashes, hashToken(normalizeRecoveryCode(c)))
+	}
+	if err := store.EnableTOTP(ctx, userID, 0, hashes); err != nil {
+		t.Fatal(err)
+	}
+	return userID
+}
+
+func TestVerifySecondFactorRejectsReusedStep(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemoryStore()
+	userID := enrollTOTP(t, store, nil)
+
+	code := totpCode([]byte("12345678901234567890"), time.Now().Unix()/totpPeriod)
+	if ok, err := verifySecondFactor(ctx, store, userID, rfc6238Secret, code); err != nil || !ok {
+		t.Fatalf("primeiro uso do código: %v, %v", ok, err)
+	}
+	if ok, err := verifySecondFactor(ctx, store, userID, rfc6238Secret, code); err != nil || ok {
+		t.Fatalf("o mesmo passo não pode ser usado de novo: %v, %v", ok, err)
+	}
+	if ok, _ := verifySecondFactor(ctx, store, userID, rfc6238Secret, "000000"); ok {
+		t.Fatal("código errado aceito")
+	}
+}
+
+func TestRecoveryCodesAreSingleUse(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemoryStore()
+	codes, err := generateRecoveryCodes(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID := enrollTOTP(t, store, codes)
+
+	typed := " " + strings.ToUpper(codes[0]) + " "
+	if ok, err := verifySecondFactor(ctx, store, userID, rfc6238Secret, typed); err != nil || !ok {
+		t.Fatalf("código de recuperação (caixa e espaços ignorados): %v, %v", ok, err)
+	}
+	if ok, _ := verifySecondFactor(ctx, store, userID, rfc6238Secret, codes[0]); ok {
+		t.Fatal("código de recuperação usado duas vezes")
+	}
+	if n, _ := store.CountRecoveryCodes(ctx, userID); n != 1 {
+		t.Fatalf("códigos restantes: esperado 1, veio %d", n)
+	}
+	if ok, _ := verifySecondFactor(ctx, store, userID, rfc6238Secret, codes[1]); !ok {
+		t.Fatal("o outro código continua válido")
+	}
+}
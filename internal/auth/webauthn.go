@@ -0,0 +1,401 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+)
+
+// ===== WebAuthn (verificação das cerimônias) =====
+//
+// Implementação enxuta do necessário para passkeys: attestation "none" (o formato do
+// atestado é aceito sem verificar a cadeia do fabricante), chaves ES256, EdDSA e RS256.
+
+var (
+	webauthnRPID    = getEnv("WEBAUTHN_RP_ID", "localhost")
+	webauthnRPName  = getEnv("WEBAUTHN_RP_NAME", "Guardião da Saúde")
+	webauthnOrigins = strings.Split(getEnv("WEBAUTHN_ORIGINS", "http://localhost:4200"), ",")
+)
+
+// Algoritmos COSE suportados.
+const (
+	coseES256 = -7
+	coseEdDSA = -8
+	coseRS256 = -257
+)
+
+// Flags do authenticatorData.
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagBackupElig   = 0x08
+	flagBackupState  = 0x10
+	flagAttested     = 0x40
+)
+
+var errWebAuthn = errors.New("credencial WebAuthn inválida")
+
+func webauthnErr(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", errWebAuthn, fmt.Sprintf(format, args...))
+}
+
+// b64url aceita base64url com ou sem padding (navegadores variam).
+func b64url(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// ===== clientDataJSON =====
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// verifyClientData confere tipo da cerimônia, desafio e origem.
+func verifyClientData(raw []byte, ceremony, challenge string) error {
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return webauthnErr("clientDataJSON ilegível")
+	}
+	if cd.Type != ceremony {
+		return webauthnErr("tipo de cerimônia inesperado: %s", cd.Type)
+	}
+	if subtle.ConstantTimeCompare([]byte(strings.TrimRight(cd.Challenge, "=")), []byte(challenge)) != 1 {
+		return webauthnErr("desafio não confere")
+	}
+	if cd.CrossOrigin || !slices.Contains(webauthnOrigins, cd.Origin) {
+		return webauthnErr("origem não permitida: %s", cd.Origin)
+	}
+	return nil
+}
+
+// ===== authenticatorData =====
+
+type authenticatorData struct {
+	raw          []byte
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte // chave COSE (CBOR)
+}
+
+func parseAuthenticatorData(raw []byte) (authenticatorData, error) {
+	if len(raw) < 37 {
+		return authenticatorData{}, webauthnErr("authenticatorData curto")
+	}
+	ad := authenticatorData{raw: raw, flags: raw[32], signCount: binary.BigEndian.Uint32(raw[33:37])}
+
+	rpIDHash := sha256.Sum256([]byte(webauthnRPID))
+	if subtle.ConstantTimeCompare(raw[:32], rpIDHash[:]) != 1 {
+		return authenticatorData{}, webauthnErr("rpId não confere")
+	}
+	if ad.flags&flagUserPresent == 0 {
+		return authenticatorData{}, webauthnErr("presença do usuário não confirmada")
+	}
+
+	if ad.flags&flagAttested != 0 {
+		rest := raw[37:]
+		if len(rest) < 18 {
+			return authenticatorData{}, webauthnErr("attestedCredentialData curto")
+		}
+		ad.aaguid = rest[:16]
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLen == 0 || idLen > 1023 || len(rest) < idLen {
+			return authenticatorData{}, webauthnErr("credentialId inválido")
+		}
+		ad.credentialID = rest[:idLen]
+		_, n, err := cborDecode(rest[idLen:], 0)
+		if err != nil {
+			return authenticatorData{}, webauthnErr("chave pública ilegível")
+		}
+		ad.publicKey = rest[idLen : idLen+n]
+	}
+	return ad, nil
+}
+
+// ===== Registro (navigator.credentials.create) =====
+
+// registeredCredential é o resultado de um registro válido.
+type registeredCredential struct {
+	credentialID   []byte
+	publicKey      []byte
+	algorithm      int
+	signCount      uint32
+	aaguid         []byte
+	backupEligible bool
+	backupState    bool
+}
+
+// verifyRegistration valida clientDataJSON e attestationObject do registro.
+func verifyRegistration(clientDataJSON, attestationObject []byte, challenge string) (registeredCredential, error) {
+	if err := verifyClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return registeredCredential{}, err
+	}
+
+	obj, _, err := cborDecode(attestationObject, 0)
+	if err != nil {
+		return registeredCredential{}, webauthnErr("attestationObject ilegível")
+	}
+	att, ok := obj.(map[any]any)
+	if !ok {
+		return registeredCredential{}, webauthnErr("attestationObject inválido")
+	}
+	rawAuthData, ok := att["authData"].([]byte)
+	if !ok {
+		return registeredCredential{}, webauthnErr("authData ausente")
+	}
+
+	ad, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return registeredCredential{}, err
+	}
+	if ad.credentialID == nil {
+		return registeredCredential{}, webauthnErr("credencial não incluída no registro")
+	}
+	_, alg, err := parseCOSEKey(ad.publicKey)
+	if err != nil {
+		return registeredCredential{}, err
+	}
+
+	return registeredCredential{
+		credentialID:   ad.credentialID,
+		publicKey:      ad.publicKey,
+		algorithm:      alg,
+		signCount:      ad.signCount,
+		aaguid:         ad.aaguid,
+		backupEligible: ad.flags&flagBackupElig != 0,
+		backupState:    ad.flags&flagBackupState != 0,
+	}, nil
+}
+
+// ===== Autenticação (navigator.credentials.get) =====
+
+// verifyAssertion valida a asserção com a chave armazenada e devolve o novo contador.
+func verifyAssertion(clientDataJSON, rawAuthData, signature, publicKey []byte, challenge string, requireUV bool) (authenticatorData, error) {
+	if err := verifyClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return authenticatorData{}, err
+	}
+	ad, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return authenticatorData{}, err
+	}
+	if requireUV && ad.flags&flagUserVerified == 0 {
+		return authenticatorData{}, webauthnErr("verificação do usuário (biometria/PIN) obrigatória")
+	}
+
+	key, alg, err := parseCOSEKey(publicKey)
+	if err != nil {
+		return authenticatorData{}, err
+	}
+	clientHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, rawAuthData...), clientHash[:]...)
+	if !verifyCOSESignature(key, alg, signed, signature) {
+		return authenticatorData{}, webauthnErr("assinatura inválida")
+	}
+	return ad, nil
+}
+
+// signCountValid aplica a regra de detecção de clones: se algum dos contadores
+// for diferente de zero, o novo precisa ser maior que o armazenado.
+func signCountValid(stored, received uint32) bool {
+	if stored == 0 && received == 0 {
+		return true
+	}
+	return received > stored
+}
+
+// ===== Chaves COSE =====
+
+func parseCOSEKey(raw []byte) (crypto.PublicKey, int, error) {
+	v, _, err := cborDecode(raw, 0)
+	if err != nil {
+		return nil, 0, webauthnErr("chave COSE ilegível")
+	}
+	m, ok := v.(map[any]any)
+	if !ok {
+		return nil, 0, webauthnErr("chave COSE inválida")
+	}
+	intParam := func(k int64) (int64, bool) {
+		n, ok := m[k].(int64)
+		return n, ok
+	}
+	bytesParam := func(k int64) []byte {
+		b, _ := m[k].([]byte)
+		return b
+	}
+
+	kty, _ := intParam(1)
+	alg, _ := intParam(3)
+	switch {
+	case kty == 2 && alg == coseES256:
+		if crv, _ := intParam(-1); crv != 1 {
+			return nil, 0, webauthnErr("curva EC não suportada")
+		}
+		x, y := bytesParam(-2), bytesParam(-3)
+		if len(x) != 32 || len(y) != 32 {
+			return nil, 0, webauthnErr("chave EC inválida")
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, 0, webauthnErr("ponto fora da curva")
+		}
+		return pub, coseES256, nil
+	case kty == 1 && alg == coseEdDSA:
+		if crv, _ := intParam(-1); crv != 6 {
+			return nil, 0, webauthnErr("curva OKP não suportada")
+		}
+		x := bytesParam(-2)
+		if len(x) != ed25519.PublicKeySize {
+			return nil, 0, webauthnErr("chave Ed25519 inválida")
+		}
+		return ed25519.PublicKey(x), coseEdDSA, nil
+	case kty == 3 && alg == coseRS256:
+		n, e := bytesParam(-1), bytesParam(-2)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, webauthnErr("chave RSA inválida")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, coseRS256, nil
+	}
+	return nil, 0, webauthnErr("algoritmo não suportado (kty=%d alg=%d)", kty, alg)
+}
+
+func verifyCOSESignature(key crypto.PublicKey, alg int, message, sig []byte) bool {
+	switch alg {
+	case coseES256:
+		digest := sha256.Sum256(message)
+		return ecdsa.VerifyASN1(key.(*ecdsa.PublicKey), digest[:], sig)
+	case coseRS256:
+		digest := sha256.Sum256(message)
+		return rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), crypto.SHA256, digest[:], sig) == nil
+	case coseEdDSA:
+		return ed25519.Verify(key.(ed25519.PublicKey), message, sig)
+	}
+	return false
+}
+
+// ===== CBOR (subconjunto usado pelo WebAuthn) =====
+
+const cborMaxDepth = 16
+
+// cborDecode decodifica um item CBOR e informa quantos bytes consumiu.
+// Mapas viram map[any]any (chaves int64 ou string); inteiros viram int64.
+func cborDecode(data []byte, depth int) (any, int, error) {
+	if depth > cborMaxDepth {
+		return nil, 0, errors.New("cbor: aninhamento excessivo")
+	}
+	if len(data) == 0 {
+		return nil, 0, errors.New("cbor: fim inesperado")
+	}
+	major, info := data[0]>>5, data[0]&0x1f
+	arg, n, err := cborArgument(data, info)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch major {
+	case 0: // inteiro sem sinal
+		if arg > 1<<63-1 {
+			return nil, 0, errors.New("cbor: inteiro grande demais")
+		}
+		return int64(arg), n, nil
+	case 1: // inteiro negativo
+		if arg > 1<<63-1 {
+			return nil, 0, errors.New("cbor: inteiro grande demais")
+		}
+		return -1 - int64(arg), n, nil
+	case 2, 3: // bytes / texto
+		if arg > uint64(len(data)-n) {
+			return nil, 0, errors.New("cbor: fim inesperado")
+		}
+		end := n + int(arg)
+		if major == 2 {
+			return bytes.Clone(data[n:end]), end, nil
+		}
+		return string(data[n:end]), end, nil
+	case 4: // array
+		if arg > uint64(len(data)) {
+			return nil, 0, errors.New("cbor: array inválido")
+		}
+		out := make([]any, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			v, m, err := cborDecode(data[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			out = append(out, v)
+			n += m
+		}
+		return out, n, nil
+	case 5: // mapa
+		if arg > uint64(len(data)) {
+			return nil, 0, errors.New("cbor: mapa inválido")
+		}
+		out := make(map[any]any, arg)
+		for i := uint64(0); i < arg; i++ {
+			k, m, err := cborDecode(data[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += m
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, 0, errors.New("cbor: chave de mapa não suportada")
+			}
+			v, m, err := cborDecode(data[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += m
+			out[k] = v
+		}
+		return out, n, nil
+	case 7: // simples (false/true/null)
+		switch info {
+		case 20:
+			return false, n, nil
+		case 21:
+			return true, n, nil
+		case 22, 23:
+			return nil, n, nil
+		}
+		return nil, 0, errors.New("cbor: valor simples não suportado")
+	}
+	return nil, 0, errors.New("cbor: tipo não suportado")
+}
+
+// cborArgument lê o argumento do cabeçalho (tamanho/valor) e o total de bytes do cabeçalho.
+func cborArgument(data []byte, info byte) (uint64, int, error) {
+	switch {
+	case info < 24:
+		return uint64(info), 1, nil
+	case info <= 27:
+		size := 1 << (info - 24)
+		if len(data) < 1+size {
+			return 0, 0, errors.New("cbor: fim inesperado")
+		}
+		var v uint64
+		for _, b := range data[1 : 1+size] {
+			v = v<<8 | uint64(b)
+		}
+		return v, 1 + size, nil
+	}
+	return 0, 0, errors.New("cbor: tamanho indefinido não suportado")
+}
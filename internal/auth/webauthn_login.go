@@ -0,0 +1,484 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Passkeys (WebAuthn): cerimônias =====
+//
+// Fluxo: o frontend pede as opções em .../start, repassa-as para
+// navigator.credentials.create/get e envia a credencial resultante para .../finish.
+// Os campos binários trafegam em base64url, nos dois sentidos.
+
+var webauthnChallengeTTL = getEnvDuration("WEBAUTHN_CHALLENGE_TTL", 5*time.Minute)
+
+// webauthnCredentialPayload é o PublicKeyCredential serializado pelo frontend.
+type webauthnCredentialPayload struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AttestationObject string `json:"attestationObject,omitempty"` // registro
+		AuthenticatorData string `json:"authenticatorData,omitempty"` // autenticação
+		Signature         string `json:"signature,omitempty"`         // autenticação
+		UserHandle        string `json:"userHandle,omitempty"`        // autenticação
+	} `json:"response"`
+}
+
+func (p webauthnCredentialPayload) credentialID() ([]byte, error) {
+	id := p.RawID
+	if id == "" {
+		id = p.ID
+	}
+	return b64url(id)
+}
+
+type webauthnCredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+func credentialDescriptors(creds []models.WebAuthnCredential) []webauthnCredentialDescriptor {
+	out := make([]webauthnCredentialDescriptor, 0, len(creds))
+	for _, c := range creds {
+		out = append(out, webauthnCredentialDescriptor{Type: "public-key", ID: base64.RawURLEncoding.EncodeToString(c.CredentialID)})
+	}
+	return out
+}
+
+// newWebAuthnChallenge gera e registra o desafio da cerimônia.
+func newWebAuthnChallenge(ctx context.Context, dbClient db.Store, ceremony, userID string) (string, error) {
+	challenge, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	ch := models.WebAuthnChallenge{Ceremony: ceremony, UserID: userID, ExpiresAt: time.Now().Add(webauthnChallengeTTL)}
+	if err := dbClient.CreateWebAuthnChallenge(ctx, hashToken(challenge), ch); err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+// consumeWebAuthnChallenge lê o desafio do clientDataJSON e o consome (uso único).
+// O desafio precisa ser da cerimônia esperada; a comparação com o clientDataJSON
+// completo (tipo, origem) fica para verifyRegistration/verifyAssertion.
+func consumeWebAuthnChallenge(ctx context.Context, dbClient db.Store, clientDataJSON []byte, ceremony string) (string, models.WebAuthnChallenge, error) {
+	var cd clientData
+	if err := json.Unmarshal(clientDataJSON, &cd); err != nil || cd.Challenge == "" {
+		return "", models.WebAuthnChallenge{}, db.ErrWebAuthnChallengeInvalid
+	}
+	challenge := strings.TrimRight(cd.Challenge, "=")
+	ch, err := dbClient.ConsumeWebAuthnChallenge(ctx, hashToken(challenge))
+	if err != nil {
+		return "", models.WebAuthnChallenge{}, err
+	}
+	if ch.Ceremony != ceremony {
+		return "", models.WebAuthnChallenge{}, db.ErrWebAuthnChallengeInvalid
+	}
+	return challenge, ch, nil
+}
+
+// requestOptions monta as opções de navigator.credentials.get.
+func requestOptions(challenge, userVerification string, allow []models.WebAuthnCredential) map[string]any {
+	return map[string]any{
+		"challenge":        challenge,
+		"rpId":             webauthnRPID,
+		"timeout":          webauthnChallengeTTL.Milliseconds(),
+		"userVerification": userVerification,
+		"allowCredentials": credentialDescriptors(allow),
+	}
+}
+
+// assertPasskey valida a asserção da credencial e atualiza o contador de assinaturas.
+// Devolve a passkey usada; em caso de erro, o status HTTP e a mensagem.
+func assertPasskey(ctx context.Context, dbClient db.Store, p webauthnCredentialPayload, challenge string, requireUV bool) (models.WebAuthnCredential, int, string) {
+	const invalid = "Passkey inválida"
+
+	credID, err := p.credentialID()
+	if err != nil {
+		return models.WebAuthnCredential{}, http.StatusBadRequest, "Credencial malformada."
+	}
+	clientDataJSON, err1 := b64url(p.Response.ClientDataJSON)
+	authData, err2 := b64url(p.Response.AuthenticatorData)
+	signature, err3 := b64url(p.Response.Signature)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return models.WebAuthnCredential{}, http.StatusBadRequest, "Credencial malformada."
+	}
+
+	cred, err := dbClient.GetWebAuthnCredential(ctx, credID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.WebAuthnCredential{}, http.StatusUnauthorized, invalid
+	} else if err != nil {
+		return models.WebAuthnCredential{}, http.StatusInternalServerError, "Falha ao consultar passkey"
+	}
+	// userHandle (passkeys descobertas) precisa apontar para o dono da credencial
+	if p.Response.UserHandle != "" {
+		if handle, err := b64url(p.Response.UserHandle); err != nil || string(handle) != cred.UserID {
+			return models.WebAuthnCredential{}, http.StatusUnauthorized, invalid
+		}
+	}
+
+	ad, err := verifyAssertion(clientDataJSON, authData, signature, cred.PublicKey, challenge, requireUV)
+	if err != nil {
+		log.Printf("AVISO: asserção WebAuthn recusada (passkey %s): %v", cred.ID, err)
+		return models.WebAuthnCredential{}, http.StatusUnauthorized, invalid
+	}
+	if !signCountValid(cred.SignCount, ad.signCount) {
+		log.Printf("AVISO: contador de assinaturas regrediu na passkey %s (%d -> %d); possível clone", cred.ID, cred.SignCount, ad.signCount)
+		return models.WebAuthnCredential{}, http.StatusUnauthorized, invalid
+	}
+	ok, err := dbClient.UseWebAuthnCredential(ctx, cred.ID, cred.SignCount, ad.signCount, ad.flags&flagBackupState != 0)
+	if err != nil {
+		return models.WebAuthnCredential{}, http.StatusInternalServerError, "Falha ao atualizar passkey"
+	}
+	if !ok {
+		return models.WebAuthnCredential{}, http.StatusUnauthorized, invalid
+	}
+	return cred, 0, ""
+}
+
+// ===== Handlers públicos =====
+
+// POST /auth/login/passkey/start { "email": "..." } — opções para login sem senha.
+// O e-mail é opcional: sem ele o navegador oferece as passkeys salvas para o site.
+func HandlePasskeyLoginStartWithDB(w http.ResponseWriter, r *http.Request, dbClient db.Store) {
+	var p struct {
+		Email string `json:"email"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			errorJSON(w, http.StatusBadRequest, "Requisição inválida.")
+			return
+		}
+	}
+
+	var allow []models.WebAuthnCredential
+	if email := strings.TrimSpace(p.Email); email != "" {
+		u, err := dbClient.GetUserByEmail(r.Context(), email)
+		switch {
+		case err == nil:
+			if allow, err = dbClient.ListWebAuthnCredentials(r.Context(), u.ID); err != nil {
+				errorJSON(w, http.StatusInternalServerError, "Falha ao consultar passkeys")
+				return
+			}
+		case !errors.Is(err, pgx.ErrNoRows):
+			errorJSON(w, http.StatusInternalServerError, "Falha ao consultar usuário")
+			return
+		}
+	}
+
+	challenge, err := newWebAuthnChallenge(r.Context(), dbClient, models.WebAuthnLogin, "")
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao gerar desafio")
+		return
+	}
+	okJSON(w, http.StatusOK, map[string]any{"publicKey": requestOptions(challenge, "required", allow)})
+}
+
+// POST /auth/login/passkey/finish { "credential": {...} } — login sem senha.
+// Exige verificação do usuário (biometria/PIN), por isso dispensa o 2FA.
+func HandlePasskeyLoginFinishWithDB(w http.ResponseWriter, r *http.Request, dbClient db.Store) {
+	var p struct {
+		Credential webauthnCredentialPayload `json:"credential"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil || p.Credential.Response.ClientDataJSON == "" {
+		errorJSON(w, http.StatusBadRequest, "credential é obrigatório.")
+		return
+	}
+	clientDataJSON, err := b64url(p.Credential.Response.ClientDataJSON)
+	if err != nil {
+		errorJSON(w, http.StatusBadRequest, "Credencial malformada.")
+		return
+	}
+
+	challenge, _, err := consumeWebAuthnChallenge(r.Context(), dbClient, clientDataJSON, models.WebAuthnLogin)
+	if errors.Is(err, db.ErrWebAuthnChallengeInvalid) {
+		errorJSON(w, http.StatusUnauthorized, "Desafio inválido ou expirado. Tente novamente.")
+		return
+	} else if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao validar desafio")
+		return
+	}
+
+	cred, status, msg := assertPasskey(r.Context(), dbClient, p.Credential, challenge, true)
+	if status != 0 {
+		errorJSON(w, status, msg)
+		return
+	}
+
+	u, err := dbClient.GetUserByID(r.Context(), cred.UserID)
+	if err != nil {
+		errorJSON(w, http.StatusUnauthorized, "Passkey inválida")
+		return
+	}
+	tokens, err := issueSession(r, dbClient, u, mfaMethodPasskey)
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao gerar token")
+		return
+	}
+	okJSON(w, http.StatusOK, map[string]any{
+		"message":       "Login realizado com sucesso",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+		"user_id":       u.ID,
+	})
+}
+
+// POST /auth/login/mfa/passkey/start { "mfa_token": "..." } — opções para usar a passkey como 2º fator.
+func HandlePasskeyMFAStartWithDB(w http.ResponseWriter, r *http.Request, dbClient db.Store) {
+	var p struct {
+		MFAToken string `json:"mfa_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil || strings.TrimSpace(p.MFAToken) == "" {
+		errorJSON(w, http.StatusBadRequest, "mfa_token é obrigatório.")
+		return
+	}
+	claims, err := parseToken(strings.TrimSpace(p.MFAToken))
+	if err != nil || claims.Purpose != mfaPurpose {
+		errorJSON(w, http.StatusUnauthorized, "Desafio 2FA inválido ou expirado")
+		return
+	}
+
+	creds, err := dbClient.ListWebAuthnCredentials(r.Context(), claims.UserID)
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao consultar passkeys")
+		return
+	}
+	if len(creds) == 0 {
+		errorJSON(w, http.StatusBadRequest, "Nenhuma passkey cadastrada.")
+		return
+	}
+
+	challenge, err := newWebAuthnChallenge(r.Context(), dbClient, models.WebAuthnMFA, claims.UserID)
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao gerar desafio")
+		return
+	}
+	okJSON(w, http.StatusOK, map[string]any{"publicKey": requestOptions(challenge, "discouraged", creds)})
+}
+
+// POST /auth/login/mfa/passkey/finish { "mfa_token": "...", "credential": {...} }
+// Conclui o login (2ª etapa) com uma passkey no lugar do código TOTP.
+func HandlePasskeyMFAFinishWithDB(w http.ResponseWriter, r *http.Request, dbClient db.Store, guard *LoginGuard) {
+	var p struct {
+		MFAToken   string                    `json:"mfa_token"`
+		Credential webauthnCredentialPayload `json:"credential"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil || strings.TrimSpace(p.MFAToken) == "" || p.Credential.Response.ClientDataJSON == "" {
+		errorJSON(w, http.StatusBadRequest, "mfa_token e credential são obrigatórios.")
+		return
+	}
+
+	claims, err := parseToken(strings.TrimSpace(p.MFAToken))
+	if err != nil || claims.Purpose != mfaPurpose {
+		errorJSON(w, http.StatusUnauthorized, "Desafio 2FA inválido ou expirado")
+		return
+	}
+	u, err := dbClient.GetUserByID(r.Context(), claims.UserID)
+	if err != nil {
+		errorJSON(w, http.StatusUnauthorized, "Desafio 2FA inválido ou expirado")
+		return
+	}
+
+	ip := ClientIP(r)
+	wait, err := guard.Check(r.Context(), u.Email, ip)
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao validar tentativas de login")
+		return
+	}
+	if wait > 0 {
+		tooManyAttempts(w, wait)
+		return
+	}
+
+	clientDataJSON, err := b64url(p.Credential.Response.ClientDataJSON)
+	if err != nil {
+		errorJSON(w, http.StatusBadRequest, "Credencial malformada.")
+		return
+	}
+	challenge, ch, err := consumeWebAuthnChallenge(r.Context(), dbClient, clientDataJSON, models.WebAuthnMFA)
+	if errors.Is(err, db.ErrWebAuthnChallengeInvalid) || (err == nil && ch.UserID != u.ID) {
+		errorJSON(w, http.StatusUnauthorized, "Desafio inválido ou expirado. Tente novamente.")
+		return
+	} else if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao validar desafio")
+		return
+	}
+
+	cred, status, msg := assertPasskey(r.Context(), dbClient, p.Credential, challenge, false)
+	if status == 0 && cred.UserID != u.ID {
+		status, msg = http.StatusUnauthorized, "Passkey inválida"
+	}
+	if status != 0 {
+		if status == http.StatusUnauthorized {
+			guard.RegisterFailure(r.Context(), u.Email, ip, u.ID)
+			auditLoginFailure(r, dbClient, u.ID, mfaMethodPasskey, "invalid_passkey")
+		}
+		errorJSON(w, status, msg)
+		return
+	}
+	guard.RegisterSuccess(r.Context(), u.Email)
+
+	tokens, err := issueSession(r, dbClient, u, "mfa:"+mfaMethodPasskey)
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao gerar token")
+		return
+	}
+	okJSON(w, http.StatusOK, map[string]any{
+		"message":       "Login realizado com sucesso",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+		"user_id":       u.ID,
+	})
+}
+
+// ===== Handlers protegidos (JWT) =====
+
+// POST /user/passkeys/register/start — opções para navigator.credentials.create.
+func HandlePasskeyRegisterStartWithDB(w http.ResponseWriter, r *http.Request, dbClient db.Store) {
+	userID, err := GetUserIDFromContext(r)
+	if err != nil {
+		errorJSON(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+
+	u, err := dbClient.GetUserByID(r.Context(), userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		errorJSON(w, http.StatusNotFound, "Usuário não encontrado.")
+		return
+	} else if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao consultar usuário")
+		return
+	}
+	existing, err := dbClient.ListWebAuthnCredentials(r.Context(), userID)
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao consultar passkeys")
+		return
+	}
+
+	challenge, err := newWebAuthnChallenge(r.Context(), dbClient, models.WebAuthnRegister, userID)
+	if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao gerar desafio")
+		return
+	}
+
+	displayName := u.Name
+	if strings.TrimSpace(displayName) == "" {
+		displayName = u.Email
+	}
+	okJSON(w, http.StatusOK, map[string]any{"publicKey": map[string]any{
+		"challenge": challenge,
+		"rp":        map[string]string{"id": webauthnRPID, "name": webauthnRPName},
+		"user": map[string]string{
+			"id":          base64.RawURLEncoding.EncodeToString([]byte(u.ID)),
+			"name":        u.Email,
+			"displayName": displayName,
+		},
+		"pubKeyCredParams": []map[string]any{
+			{"type": "public-key", "alg": coseES256},
+			{"type": "public-key", "alg": coseEdDSA},
+			{"type": "public-key", "alg": coseRS256},
+		},
+		"timeout":            webauthnChallengeTTL.Milliseconds(),
+		"attestation":        "none",
+		"excludeCredentials": credentialDescriptors(existing),
+		"authenticatorSelection": map[string]any{
+			"residentKey":      "preferred",
+			"userVerification": "preferred",
+		},
+	}})
+}
+
+// POST /user/passkeys/register/finish { "name": "iPhone", "credential": {...} } — grava a passkey.
+func HandlePasskeyRegisterFinishWithDB(w http.ResponseWriter, r *http.Request, dbClient db.Store) {
+	userID, err := GetUserIDFromContext(r)
+	if err != nil {
+		errorJSON(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+
+	var p struct {
+		Name       string                    `json:"name"`
+		Credential webauthnCredentialPayload `json:"credential"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil || p.Credential.Response.ClientDataJSON == "" || p.Credential.Response.AttestationObject == "" {
+		errorJSON(w, http.StatusBadRequest, "credential é obrigatório.")
+		return
+	}
+	name := strings.TrimSpace(p.Name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if len(name) > 100 {
+		errorJSON(w, http.StatusBadRequest, "Nome deve ter até 100 caracteres.")
+		return
+	}
+
+	clientDataJSON, err1 := b64url(p.Credential.Response.ClientDataJSON)
+	attestationObject, err2 := b64url(p.Credential.Response.AttestationObject)
+	if err1 != nil || err2 != nil {
+		errorJSON(w, http.StatusBadRequest, "Credencial malformada.")
+		return
+	}
+
+	challenge, ch, err := consumeWebAuthnChallenge(r.Context(), dbClient, clientDataJSON, models.WebAuthnRegister)
+	if errors.Is(err, db.ErrWebAuthnChallengeInvalid) || (err == nil && ch.UserID != userID) {
+		errorJSON(w, http.StatusBadRequest, "Desafio inválido ou expirado. Tente novamente.")
+		return
+	} else if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao validar desafio")
+		return
+	}
+
+	reg, err := verifyRegistration(clientDataJSON, attestationObject, challenge)
+	if err != nil {
+		errorJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cred := models.WebAuthnCredential{
+		UserID:         userID,
+		Name:           name,
+		CredentialID:   reg.credentialID,
+		PublicKey:      reg.publicKey,
+		Algorithm:      reg.algorithm,
+		SignCount:      reg.signCount,
+		AAGUID:         reg.aaguid,
+		BackupEligible: reg.backupEligible,
+		BackupState:    reg.backupState,
+	}
+	if cred.ID, err = dbClient.CreateWebAuthnCredential(r.Context(), cred); errors.Is(err, db.ErrWebAuthnCredentialExists) {
+		errorJSON(w, http.StatusConflict, "Esta passkey já está cadastrada.")
+		return
+	} else if err != nil {
+		errorJSON(w, http.StatusInternalServerError, "Falha ao registrar passkey")
+		return
+	}
+	Audit(r, dbClient, models.AuditEvent{
+		Action:     models.AuditPasskeyAdded,
+		TargetType: "passkey",
+		TargetID:   cred.ID,
+		Metadata:   map[string]any{"name": cred.Name},
+	})
+
+	okJSON(w, http.StatusCreated, map[string]any{
+		"message": "Passkey cadastrada.",
+		"id":      cred.ID,
+		"name":    cred.Name,
+	})
+}
@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/pkg/models"
+)
+
+// testAuthenticator simula um autenticador ES256 para montar registros e asserções.
+type testAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+}
+
+func newTestAuthenticator(t *testing.T) *testAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testAuthenticator{key: key, credentialID: []byte(uuid.New().String())}
+}
+
+// withID reaproveita o credentialId de outro autenticador (chave diferente).
+func (a *testAuthenticator) withID(id []byte) *testAuthenticator {
+	a.credentialID = id
+	return a
+}
+
+// cborHead codifica o cabeçalho CBOR (tipo maior + argumento).
+func cborHead(major byte, n int) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n < 256:
+		return []byte{major<<5 | 24, byte(n)}
+	}
+	return []byte{major<<5 | 25, byte(n >> 8), byte(n)}
+}
+
+func cborBytes(b []byte) []byte { return append(cborHead(2, len(b)), b...) }
+func cborText(s string) []byte  { return append(cborHead(3, len(s)), s...) }
+func b64(b []byte) string       { return base64.RawURLEncoding.EncodeToString(b) }
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+// coseKey devolve a chave pública no formato COSE (kty=EC2, alg=ES256, crv=P-256).
+func (a *testAuthenticator) coseKey() []byte {
+	x, y := make([]byte, 32), make([]byte, 32)
+	a.key.X.FillBytes(x)
+	a.key.Y.FillBytes(y)
+	return concat([]byte{0xa5, 0x01, 0x02, 0x03, 0x26, 0x20, 0x01, 0x21}, cborBytes(x), []byte{0x22}, cborBytes(y))
+}
+
+func (a *testAuthenticator) authData(flags byte, signCount uint32, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(webauthnRPID))
+	out := append(rpIDHash[:], flags, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(out[33:], signCount)
+	if attested {
+		out = append(out, make([]byte, 16)...) // AAGUID zerado
+		out = binary.BigEndian.AppendUint16(out, uint16(len(a.credentialID)))
+		out = append(append(out, a.credentialID...), a.coseKey()...)
+	}
+	return out
+}
+
+func clientDataFor(ceremony, challenge string) []byte {
+	raw, _ := json.Marshal(clientData{Type: ceremony, Challenge: challenge, Origin: webauthnOrigins[0]})
+	return raw
+}
+
+// assertion assina a asserção como o autenticador faria.
+func (a *testAuthenticator) assertion(t *testing.T, challenge string, flags byte, signCount uint32, userHandle string) webauthnCredentialPayload {
+	t.Helper()
+	cd := clientDataFor("webauthn.get", challenge)
+	ad := a.authData(flags, signCount, false)
+	clientHash := sha256.Sum256(cd)
+	digest := sha256.Sum256(concat(ad, clientHash[:]))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p webauthnCredentialPayload
+	p.RawID = b64(a.credentialID)
+	p.Type = "public-key"
+	p.Response.ClientDataJSON = b64(cd)
+	p.Response.AuthenticatorData = b64(ad)
+	p.Response.Signature = b64(sig)
+	if userHandle != "" {
+		p.Response.UserHandle = b64([]byte(userHandle))
+	}
+	return p
+}
+
+// enrollPasskey cria a conta e registra a passkey pelo caminho de verifyRegistration.
+func enrollPasskey(t *testing.T, store *db.MemoryStore, a *testAuthenticator, signCount uint32) models.User {
+	t.Helper()
+	ctx := context.Background()
+	u := models.User{ID: uuid.New().String(), Email: uuid.New().String() + "@exemplo.com", Name: "Ana", EmailVerified: true}
+	if err := store.CreateUser(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+	attestation := concat(
+		[]byte{0xa3}, cborText("fmt"), cborText("none"),
+		cborText("attStmt"), []byte{0xa0},
+		cborText("authData"), cborBytes(a.authData(flagUserPresent|flagUserVerified|flagAttested, signCount, true)),
+	)
+	reg, err := verifyRegistration(clientDataFor("webauthn.create", "registro"), attestation, "registro")
+	if err != nil {
+		t.Fatalf("verifyRegistration: %v", err)
+	}
+	if !bytes.Equal(reg.credentialID, a.credentialID) || reg.algorithm != coseES256 || reg.signCount != signCount {
+		t.Fatalf("credencial registrada: %+v", reg)
+	}
+	cred := models.WebAuthnCredential{UserID: u.ID, Name: "Chave", CredentialID: reg.credentialID, PublicKey: reg.publicKey, Algorithm: reg.algorithm, SignCount: reg.signCount}
+	if _, err := store.CreateWebAuthnCredential(ctx, cred); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestSignCountValid(t *testing.T) {
+	for _, tc := range []struct {
+		stored, received uint32
+		want             bool
+	}{
+		{0, 0, true}, // autenticadores sem contador
+		{0, 1, true},
+		{5, 6, true},
+		{5, 5, false},
+		{5, 4, false},
+		{5, 0, false},
+	} {
+		if got := signCountValid(tc.stored, tc.received); got != tc.want {
+			t.Errorf("signCountValid(%d, %d) = %v", tc.stored, tc.received, got)
+		}
+	}
+}
+
+func TestAssertPasskey(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemoryStore()
+	a := newTestAuthenticator(t)
+	u := enrollPasskey(t, store, a, 5)
+	uv := byte(flagUserPresent | flagUserVerified)
+
+	cred, status, msg := assertPasskey(ctx, store, a.assertion(t, "desafio", uv, 6, u.ID), "desafio", true)
+	if status != 0 || cred.UserID != u.ID {
+		t.Fatalf("asserção válida: %d %s", status, msg)
+	}
+
+	for name, p := range map[string]webauthnCredentialPayload{
+		"contador repetido (clone)":  a.assertion(t, "desafio", uv, 6, ""),
+		"contador regrediu":          a.assertion(t, "desafio", uv, 3, ""),
+		"userHandle de outra conta":  a.assertion(t, "desafio", uv, 7, uuid.New().String()),
+		"desafio diferente":          a.assertion(t, "outro", uv, 7, ""),
+		"sem verificação do usuário": a.assertion(t, "desafio", flagUserPresent, 7, ""),
+		"assinatura de outra chave":  newTestAuthenticator(t).withID(a.credentialID).assertion(t, "desafio", uv, 7, ""),
+	} {
+		if _, status, _ := assertPasskey(ctx, store, p, "desafio", true); status != http.StatusUnauthorized {
+			t.Errorf("%s: esperado 401, veio %d", name, status)
+		}
+	}
+
+	// Como 2º fator a verificação do usuário é dispensada; o contador segue de onde parou
+	if _, status, msg := assertPasskey(ctx, store, a.assertion(t, "desafio", flagUserPresent, 7, ""), "desafio", false); status != 0 {
+		t.Fatalf("asserção sem UV como 2º fator: %d %s", status, msg)
+	}
+	if creds, _ := store.ListWebAuthnCredentials(ctx, u.ID); len(creds) != 1 || creds[0].SignCount != 7 || creds[0].LastUsedAt == nil {
+		t.Fatalf("contador após uso: %+v", creds)
+	}
+}
+
+func TestPasskeyLoginRequiresUserVerification(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemoryStore()
+	a := newTestAuthenticator(t)
+	enrollPasskey(t, store, a, 0)
+
+	login := func(flags byte) int {
+		challenge, err := newWebAuthnChallenge(ctx, store, models.WebAuthnLogin, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := json.Marshal(map[string]any{"credential": a.assertion(t, challenge, flags, 0, "")})
+		rec := httptest.NewRecorder()
+		HandlePasskeyLoginFinishWithDB(rec, httptest.NewRequest("POST", "/api/v1/auth/login/passkey/finish", strings.NewReader(string(body))), store)
+		return rec.Code
+	}
+	if code := login(flagUserPresent); code != http.StatusUnauthorized {
+		t.Fatalf("login sem biometria/PIN: esperado 401, veio %d", code)
+	}
+	if code := login(flagUserPresent | flagUserVerified); code != http.StatusOK {
+		t.Fatalf("login com verificação do usuário: %d", code)
+	}
+}
@@ -0,0 +1,237 @@
+package exports
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+
+	"go-guardiao-api/internal/auth"
+	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/internal/platforms/storage"
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Exportação de dados pessoais (LGPD, portabilidade) =====
+//
+// O pedido é enfileirado e processado pelo Worker. Quando pronto, o status traz um
+// link de download assinado (HMAC) e temporário, que dispensa o token de acesso.
+
+var (
+	linkTTL    = getEnvDuration("DATA_EXPORT_LINK_TTL", 15*time.Minute)
+	retention  = getEnvDuration("DATA_EXPORT_RETENTION", 7*24*time.Hour)
+	publicBase = strings.TrimRight(os.Getenv("API_URL"), "/")
+)
+
+func getEnvDuration(k string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(k))
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+// Service representa o serviço de exportação de dados.
+type Service struct {
+	DBClient db.Store
+	Storage  storage.Storage
+
+	signingKey []byte
+	notify     chan struct{} // acorda o Worker quando há pedido novo
+}
+
+// NewService usa DATA_EXPORT_SIGNING_KEY para assinar os links. Sem a variável, em produção
+// (GO_ENV=production) retorna erro; fora dela gera uma chave aleatória (links deixam de valer
+// ao reiniciar e não servem entre instâncias).
+func NewService(dbClient db.Store, store storage.Storage) (*Service, error) {
+	key := []byte(os.Getenv("DATA_EXPORT_SIGNING_KEY"))
+	if len(key) == 0 {
+		if strings.EqualFold(os.Getenv("GO_ENV"), "production") {
+			return nil, errors.New("DATA_EXPORT_SIGNING_KEY é obrigatória em produção")
+		}
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("falha ao gerar chave dos links de exportação: %w", err)
+		}
+		log.Println("⚠️ DATA_EXPORT_SIGNING_KEY não definida: usando chave temporária para os links de exportação.")
+	} else if len(key) < 32 {
+		return nil, errors.New("DATA_EXPORT_SIGNING_KEY deve ter ao menos 32 caracteres")
+	}
+	return &Service{DBClient: dbClient, Storage: store, signingKey: key, notify: make(chan struct{}, 1)}, nil
+}
+
+// --- Helpers para respostas padronizadas ---
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// --- Links assinados ---
+
+func (s *Service) sign(exportID string, expires int64) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	fmt.Fprintf(mac, "%s|%d", exportID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// downloadURL monta o link assinado, válido por linkTTL.
+func (s *Service) downloadURL(exportID string) (string, time.Time) {
+	expiresAt := time.Now().Add(linkTTL)
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	q.Set("signature", s.sign(exportID, expiresAt.Unix()))
+	return fmt.Sprintf("%s/api/v1/exports/%s/download?%s", publicBase, url.PathEscape(exportID), q.Encode()), expiresAt
+}
+
+func (s *Service) validSignature(exportID, expires, signature string) bool {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	return hmac.Equal([]byte(s.sign(exportID, exp)), []byte(signature))
+}
+
+// --- Handlers de API ---
+
+type exportResponse struct {
+	models.DataExport
+	DownloadURL       string     `json:"download_url,omitempty"`
+	DownloadExpiresAt *time.Time `json:"download_expires_at,omitempty"`
+}
+
+func (s *Service) toResponse(e models.DataExport) exportResponse {
+	resp := exportResponse{DataExport: e}
+	if e.Status == models.ExportReady {
+		link, expiresAt := s.downloadURL(e.ID)
+		if e.ExpiresAt != nil && e.ExpiresAt.Before(expiresAt) {
+			expiresAt = *e.ExpiresAt
+		}
+		resp.DownloadURL, resp.DownloadExpiresAt = link, &expiresAt
+	}
+	return resp
+}
+
+// POST /user/data-export — enfileira a exportação e devolve o ID do job
+func (s *Service) HandleRequestExport(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+
+	export, err := s.DBClient.CreateDataExport(r.Context(), userID)
+	if errors.Is(err, db.ErrExportInProgress) {
+		writeError(w, http.StatusConflict, "Já existe uma exportação em andamento. Acompanhe em GET /user/data-export.")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao solicitar exportação: %v", err))
+		return
+	}
+
+	auth.Audit(r, s.DBClient, models.AuditEvent{Action: models.AuditDataExportRequested, TargetType: "data_export", TargetID: export.ID})
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	writeJSON(w, http.StatusAccepted, s.toResponse(export))
+}
+
+// GET /user/data-export — lista as exportações do usuário
+func (s *Service) HandleListExports(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+
+	list, err := s.DBClient.ListDataExports(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao listar exportações: %v", err))
+		return
+	}
+	out := make([]exportResponse, 0, len(list))
+	for _, e := range list {
+		out = append(out, s.toResponse(e))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// GET /user/data-export/{exportId} — status do job; quando pronto, inclui o link de download
+func (s *Service) HandleGetExport(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+
+	export, err := s.DBClient.GetDataExport(r.Context(), mux.Vars(r)["exportId"])
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && export.UserID != userID) {
+		writeError(w, http.StatusNotFound, "Exportação não encontrada.")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao buscar exportação: %v", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, s.toResponse(export))
+}
+
+// GET /api/v1/exports/{exportId}/download?expires=...&signature=... — público; vale pela assinatura
+func (s *Service) HandleDownload(w http.ResponseWriter, r *http.Request) {
+	exportID := mux.Vars(r)["exportId"]
+	q := r.URL.Query()
+	if !s.validSignature(exportID, q.Get("expires"), q.Get("signature")) {
+		writeError(w, http.StatusForbidden, "Link de download inválido ou expirado.")
+		return
+	}
+
+	export, err := s.DBClient.GetDataExport(r.Context(), exportID)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && (export.Status != models.ExportReady || export.UserID == "")) {
+		writeError(w, http.StatusNotFound, "Exportação não encontrada.")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao buscar exportação: %v", err))
+		return
+	}
+	if export.ExpiresAt != nil && time.Now().After(*export.ExpiresAt) {
+		writeError(w, http.StatusGone, "Exportação expirada. Solicite uma nova.")
+		return
+	}
+
+	f, err := s.Storage.Open(r.Context(), export.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		writeError(w, http.StatusGone, "Arquivo da exportação não está mais disponível. Solicite uma nova.")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao abrir exportação: %v", err))
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="guardiao-dados-%s.zip"`, export.CreatedAt.Format("2006-01-02")))
+	w.Header().Set("Cache-Control", "no-store")
+	if _, err := io.Copy(w, f); err != nil {
+		log.Printf("AVISO: Download da exportação %s interrompido: %v", exportID, err)
+	}
+}
@@ -0,0 +1,67 @@
+package exports
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go-guardiao-api/internal/platforms/db"
+)
+
+const testSigningKey = "chave-de-teste-com-32-caracteres!"
+
+func TestNewServiceSigningKey(t *testing.T) {
+	for _, tc := range []struct {
+		env, key string
+		ok       bool
+	}{
+		{"production", "", false},
+		{"production", "curta", false},
+		{"production", testSigningKey, true},
+		{"development", "", true}, // chave temporária
+		{"development", "curta", false},
+	} {
+		t.Setenv("GO_ENV", tc.env)
+		t.Setenv("DATA_EXPORT_SIGNING_KEY", tc.key)
+		if _, err := NewService(db.NewMemoryStore(), nil); (err == nil) != tc.ok {
+			t.Errorf("GO_ENV=%s, chave %q: erro %v", tc.env, tc.key, err)
+		}
+	}
+}
+
+func TestSignedDownloadLink(t *testing.T) {
+	t.Setenv("GO_ENV", "production")
+	t.Setenv("DATA_EXPORT_SIGNING_KEY", testSigningKey)
+	issuer, err := NewService(db.NewMemoryStore(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Outra instância (ou a mesma após reiniciar) com a mesma chave aceita o link
+	other, err := NewService(db.NewMemoryStore(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link, _ := issuer.downloadURL("exp-1")
+	u, err := url.Parse(link)
+	if err != nil || !strings.HasSuffix(u.Path, "/api/v1/exports/exp-1/download") {
+		t.Fatalf("link: %q, %v", link, err)
+	}
+	expires, signature := u.Query().Get("expires"), u.Query().Get("signature")
+	if !other.validSignature("exp-1", expires, signature) {
+		t.Fatal("link assinado com a mesma chave deveria valer em outra instância")
+	}
+	if other.validSignature("exp-2", expires, signature) {
+		t.Fatal("assinatura não pode valer para outra exportação")
+	}
+	later := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	if other.validSignature("exp-1", later, signature) {
+		t.Fatal("prazo alterado não pode manter a assinatura")
+	}
+	past := time.Now().Add(-time.Minute).Unix()
+	if other.validSignature("exp-1", strconv.FormatInt(past, 10), issuer.sign("exp-1", past)) {
+		t.Fatal("link vencido não pode valer")
+	}
+}
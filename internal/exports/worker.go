@@ -0,0 +1,253 @@
+package exports
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"go-guardiao-api/internal/platforms/db"
+)
+
+const (
+	staleRunningAfter = time.Hour // exportação "running" há mais tempo volta para a fila
+	cleanupBatch      = 100
+	maxExportedEvents = 10000 // eventos de segurança mais recentes incluídos no arquivo
+)
+
+// ===== Job de exportação =====
+
+// Run processa a fila a cada intervalo (ou assim que chega um pedido) e apaga os arquivos
+// vencidos, até o contexto ser cancelado. Várias instâncias podem rodar ao mesmo tempo.
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.ProcessPending(ctx)
+		s.CleanupExpired(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.notify:
+		}
+	}
+}
+
+// ProcessPending gera todas as exportações pendentes.
+func (s *Service) ProcessPending(ctx context.Context) {
+	for ctx.Err() == nil {
+		export, err := s.DBClient.ClaimDataExport(ctx, staleRunningAfter)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return
+		} else if err != nil {
+			log.Printf("AVISO: Falha ao buscar exportações pendentes: %v", err)
+			return
+		}
+
+		key := fmt.Sprintf("exports/%s/%s.zip", export.UserID, export.ID)
+		if err := s.generate(ctx, export.UserID, key); err != nil {
+			log.Printf("ERRO: Falha ao gerar exportação %s: %v", export.ID, err)
+			if err := s.DBClient.FailDataExport(ctx, export.ID, err.Error()); err != nil {
+				log.Printf("ERRO: %v", err)
+			}
+			continue
+		}
+		if err := s.DBClient.CompleteDataExport(ctx, export.ID, key, time.Now().Add(retention)); err != nil {
+			log.Printf("ERRO: %v", err)
+			continue
+		}
+		log.Printf("Exportação %s concluída.", export.ID)
+	}
+}
+
+// CleanupExpired apaga arquivos e registros de exportações vencidas ou de contas excluídas.
+func (s *Service) CleanupExpired(ctx context.Context) {
+	list, err := s.DBClient.ListStaleDataExports(ctx, cleanupBatch)
+	if err != nil {
+		log.Printf("AVISO: Falha ao listar exportações vencidas: %v", err)
+		return
+	}
+	for _, e := range list {
+		if e.StorageKey != "" {
+			if err := s.Storage.Delete(ctx, e.StorageKey); err != nil {
+				log.Printf("AVISO: Falha ao apagar arquivo da exportação %s: %v", e.ID, err)
+				continue
+			}
+		}
+		if err := s.DBClient.DeleteDataExport(ctx, e.ID); err != nil {
+			log.Printf("AVISO: %v", err)
+		}
+	}
+}
+
+// generate coleta os dados do usuário e grava o ZIP no storage.
+func (s *Service) generate(ctx context.Context, userID, key string) error {
+	profile, err := s.DBClient.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("perfil: %w", err)
+	}
+	habits, err := s.DBClient.GetHabitsByUserID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("hábitos: %w", err)
+	}
+	logs, err := s.DBClient.ListHabitLogsByUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("registros de hábitos: %w", err)
+	}
+	freezes, err := s.DBClient.ListStreakFreezesByUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("proteções de sequência: %w", err)
+	}
+	mana, err := s.DBClient.ListManaTransactions(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("extrato de mana: %w", err)
+	}
+	contacts, err := s.DBClient.GetSupportContactsByUserID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("rede de apoio: %w", err)
+	}
+	sessions, err := s.DBClient.ListAllSessions(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("sessões: %w", err)
+	}
+	consents, err := s.DBClient.ListConsentHistory(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("consentimentos: %w", err)
+	}
+	events, err := s.DBClient.ListAuditEvents(ctx, db.AuditEventFilter{UserID: userID}, maxExportedEvents, 0)
+	if err != nil {
+		return fmt.Errorf("eventos de segurança: %w", err)
+	}
+
+	var buf bytes.Buffer
+	a := &archive{zw: zip.NewWriter(&buf)}
+
+	a.json("profile.json", profile)
+
+	a.json("habits.json", habits)
+	habitRows := make([][]string, 0, len(habits))
+	for _, h := range habits {
+		var target, unit, aggregation string
+		if h.Goal != nil {
+			target, unit, aggregation = strconv.Itoa(h.Goal.Target), h.Goal.Unit, h.Goal.Aggregation
+		}
+		habitRows = append(habitRows, []string{h.ID, h.Name, h.GoalType, h.Frequency, target, unit, aggregation, h.Status, formatTime(h.CreatedAt)})
+	}
+	a.csv("habits.csv", []string{"id", "name", "goal_type", "frequency", "goal_target", "goal_unit", "goal_aggregation", "status", "created_at"}, habitRows)
+
+	a.json("habit_logs.json", logs)
+	logRows := make([][]string, 0, len(logs))
+	for _, l := range logs {
+		logRows = append(logRows, []string{l.ID, l.HabitID, strconv.Itoa(l.Value), formatTime(l.Timestamp)})
+	}
+	a.csv("habit_logs.csv", []string{"id", "habit_id", "value", "log_date"}, logRows)
+
+	a.json("streak_freezes.json", freezes)
+	freezeRows := make([][]string, 0, len(freezes))
+	for _, f := range freezes {
+		freezeRows = append(freezeRows, []string{f.HabitID, f.Day, strconv.Itoa(f.ManaCost), formatTime(f.CreatedAt)})
+	}
+	a.csv("streak_freezes.csv", []string{"habit_id", "day", "mana_cost", "created_at"}, freezeRows)
+
+	a.json("mana_transactions.json", mana)
+	manaRows := make([][]string, 0, len(mana))
+	for _, t := range mana {
+		manaRows = append(manaRows, []string{t.ID, string(t.Type), strconv.Itoa(t.Amount), t.ReferenceID, formatTime(t.CreatedAt)})
+	}
+	a.csv("mana_transactions.csv", []string{"id", "type", "amount", "reference_id", "created_at"}, manaRows)
+
+	a.json("support_contacts.json", contacts)
+	contactRows := make([][]string, 0, len(contacts))
+	for _, c := range contacts {
+		contactRows = append(contactRows, []string{c.ContactID, c.ContactEmail, c.Phone, c.Nickname, c.NotificationPreference})
+	}
+	a.csv("support_contacts.csv", []string{"contact_id", "contact_email", "phone", "nickname", "notification_preference"}, contactRows)
+
+	a.json("sessions.json", sessions)
+	sessionRows := make([][]string, 0, len(sessions))
+	for _, ses := range sessions {
+		revoked := ""
+		if ses.RevokedAt != nil {
+			revoked = formatTime(*ses.RevokedAt)
+		}
+		sessionRows = append(sessionRows, []string{ses.ID, ses.UserAgent, ses.IP, formatTime(ses.CreatedAt), formatTime(ses.LastSeenAt), revoked})
+	}
+	a.csv("sessions.csv", []string{"id", "user_agent", "ip", "created_at", "last_seen_at", "revoked_at"}, sessionRows)
+
+	a.json("consents.json", consents)
+	consentRows := make([][]string, 0, len(consents))
+	for _, c := range consents {
+		consentRows = append(consentRows, []string{c.Purpose, strconv.FormatBool(c.Granted), c.TermsVersion, c.IP, formatTime(c.CreatedAt)})
+	}
+	a.csv("consents.csv", []string{"purpose", "granted", "terms_version", "ip", "created_at"}, consentRows)
+
+	a.json("security_events.json", events)
+
+	if a.err != nil {
+		return a.err
+	}
+	if err := a.zw.Close(); err != nil {
+		return err
+	}
+	return s.Storage.Put(ctx, key, &buf)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// archive escreve arquivos no ZIP guardando o primeiro erro.
+type archive struct {
+	zw  *zip.Writer
+	err error
+}
+
+func (a *archive) create(name string) io.Writer {
+	if a.err != nil {
+		return nil
+	}
+	f, err := a.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		a.err = err
+		return nil
+	}
+	return f
+}
+
+func (a *archive) json(name string, v any) {
+	f := a.create(name)
+	if f == nil {
+		return
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		a.err = fmt.Errorf("%s: %w", name, err)
+	}
+}
+
+func (a *archive) csv(name string, header []string, rows [][]string) {
+	f := a.create(name)
+	if f == nil {
+		return
+	}
+	cw := csv.NewWriter(f)
+	_ = cw.Write(header)
+	_ = cw.WriteAll(rows) // WriteAll faz o Flush
+	if err := cw.Error(); err != nil {
+		a.err = fmt.Errorf("%s: %w", name, err)
+	}
+}
@@ -15,11 +15,11 @@ import (
 
 // Service representa o serviço de Gamificação.
 type Service struct {
-	DBClient    *db.Client
-	CacheClient *cache.Client
+	DBClient    db.ManaRepository
+	CacheClient cache.Cache
 }
 
-func NewService(dbClient *db.Client, cacheClient *cache.Client) *Service {
+func NewService(dbClient db.ManaRepository, cacheClient cache.Cache) *Service {
 	return &Service{
 		DBClient:    dbClient,
 		CacheClient: cacheClient,
@@ -0,0 +1,119 @@
+package gamification
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"go-guardiao-api/internal/apitest"
+	"go-guardiao-api/internal/platforms/cache"
+	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/pkg/models"
+)
+
+func newTestAPI(t *testing.T) *apitest.API {
+	return apitest.New(t, func(router *mux.Router, store *db.MemoryStore, c *cache.Memory) {
+		s := NewService(store, c)
+		router.HandleFunc("/mana/balance", s.HandleGetManaBalance).Methods("GET")
+		router.HandleFunc("/mana/redeem", s.HandleRedeemReward).Methods("POST")
+		router.HandleFunc("/leaderboard", s.HandleGetLeaderboard).Methods("GET")
+	})
+}
+
+// signIn cria uma conta com saldo inicial de mana e sessão ativa.
+func signIn(t *testing.T, a *apitest.API, name string, mana int) (string, string) {
+	t.Helper()
+	id, token := a.SignIn(t, models.User{Email: strings.ToLower(name) + "@exemplo.com", Name: name})
+	if mana != 0 {
+		grant := models.ManaTransaction{UserID: id, Type: models.ManaTypeHabitCompletion, Amount: mana}
+		if err := a.Store.UpdateManaBalance(context.Background(), grant); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return id, token
+}
+
+func TestManaBalance(t *testing.T) {
+	a := newTestAPI(t)
+	userID, token := signIn(t, a, "Ana", 120)
+
+	var balance models.UserMana
+	if code := a.Do(t, "GET", "/mana/balance", token, "", &balance); code != http.StatusOK {
+		t.Fatalf("GET /mana/balance: %d", code)
+	}
+	if balance.UserID != userID || balance.Balance != 120 {
+		t.Fatalf("saldo: %+v", balance)
+	}
+	// O saldo lido do banco fica no cache
+	if cached, err := a.Cache.GetManaBalance(context.Background(), userID); err != nil || cached != 120 {
+		t.Fatalf("cache após consulta: %d, %v", cached, err)
+	}
+	if code := a.Do(t, "GET", "/mana/balance", "", "", nil); code != http.StatusUnauthorized {
+		t.Fatalf("sem token: esperado 401, veio %d", code)
+	}
+}
+
+func TestRedeemReward(t *testing.T) {
+	a := newTestAPI(t)
+	userID, token := signIn(t, a, "Ana", 700)
+
+	var redeemed struct {
+		NewBalance string `json:"new_balance"`
+	}
+	if code := a.Do(t, "POST", "/mana/redeem", token, `{"reward_id":"r1"}`, &redeemed); code != http.StatusOK || redeemed.NewBalance != "200" {
+		t.Fatalf("POST /mana/redeem: %d, saldo %q", code, redeemed.NewBalance)
+	}
+	if code := a.Do(t, "POST", "/mana/redeem", token, `{`, nil); code != http.StatusBadRequest {
+		t.Fatalf("corpo inválido: esperado 400, veio %d", code)
+	}
+
+	txs, err := a.Store.ListManaTransactions(context.Background(), userID)
+	if err != nil || len(txs) != 2 || txs[1].Type != "REWARD_REDEEM" || txs[1].Amount != -500 || txs[1].ReferenceID != "r1" {
+		t.Fatalf("extrato após resgate: %+v, %v", txs, err)
+	}
+	var balance models.UserMana
+	if a.Do(t, "GET", "/mana/balance", token, "", &balance); balance.Balance != 200 {
+		t.Fatalf("saldo em cache após resgate: %+v", balance)
+	}
+}
+
+func TestLeaderboard(t *testing.T) {
+	a := newTestAPI(t)
+	_, token := signIn(t, a, "Ana", 50)
+	signIn(t, a, "Bia", 300)
+	signIn(t, a, "Caio", 100)
+
+	var entries []models.LeaderboardEntry
+	if code := a.Do(t, "GET", "/leaderboard?limit=2", token, "", &entries); code != http.StatusOK {
+		t.Fatalf("GET /leaderboard: %d", code)
+	}
+	if len(entries) != 2 || entries[0].UserName != "Bia" || entries[0].Mana != 300 || entries[1].UserName != "Caio" {
+		t.Fatalf("leaderboard: %+v", entries)
+	}
+}
+
+func TestHabitLogMana(t *testing.T) {
+	steps := &models.HabitGoal{Target: 8000, Unit: models.UnitSteps}
+	for _, tc := range []struct {
+		goal  *models.HabitGoal
+		value int
+		want  int
+	}{
+		{nil, 1, 25},
+		{nil, 5, 25},
+		{nil, 0, 0},
+		{steps, 8000, 25},
+		{steps, 20000, 25},
+		{steps, 4000, 12},
+		{steps, 100, 0},
+		{steps, -8000, 0},
+		{&models.HabitGoal{Target: 2, Unit: models.UnitCount}, 1, 12},
+	} {
+		if got := HabitLogMana(tc.goal, models.HabitLog{Value: tc.value}); got != tc.want {
+			t.Errorf("HabitLogMana(%+v, %d) = %d, esperado %d", tc.goal, tc.value, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package gamification
+
+import "go-guardiao-api/pkg/models"
+
+// manaPerGoal é a mana de um registro que cobre sozinho a meta diária do hábito.
+const manaPerGoal = 25
+
+// HabitLogMana calcula quanta mana um registro de hábito rende: manaPerGoal proporcional à
+// parte da meta diária (goal.Target, na unidade da meta) que o valor cobre, limitada à meta e
+// arredondada para baixo. Sem meta (goal nil) vale a implícita: 1 por dia. Valores zerados ou
+// negativos não rendem nada. É a regra usada ao registrar, ao editar e pelo worker.
+func HabitLogMana(goal *models.HabitGoal, l models.HabitLog) int {
+	target := 1
+	if goal != nil && goal.Target > 0 {
+		target = goal.Target
+	}
+	if l.Value <= 0 {
+		return 0
+	}
+	return manaPerGoal * min(l.Value, target) / target
+}
@@ -0,0 +1,178 @@
+package habits
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Metas e progresso =====
+//
+// Os registros são agregados por dia (no fuso da agenda) com a regra da meta, e o dia é
+// cumprido quando o valor agregado atinge o alvo. Sem meta, vale a meta implícita
+// defaultGoal: um registro de valor positivo no dia. Uma ocorrência diária é cumprida
+// junto com o seu dia; a semanal ("N vezes por semana"), quando N dias dela são cumpridos.
+
+// maxGoalTarget limita o alvo (valores de registro são inteiros).
+const maxGoalTarget = 1_000_000_000
+
+var defaultGoal = models.HabitGoal{Target: 1, Unit: models.UnitCount, Aggregation: models.AggregateSum}
+
+// NormalizeGoal valida a meta e aplica a agregação padrão (sum).
+func NormalizeGoal(g *models.HabitGoal) error {
+	if g.Target < 1 || g.Target > maxGoalTarget {
+		return fmt.Errorf("target deve estar entre 1 e %d", maxGoalTarget)
+	}
+	g.Unit = strings.ToLower(strings.TrimSpace(g.Unit))
+	switch g.Unit {
+	case models.UnitSteps, models.UnitML, models.UnitMinutes, models.UnitCount, models.UnitMG:
+	case "":
+		return errors.New("unit é obrigatório (steps, ml, minutes, count ou mg)")
+	default:
+		return fmt.Errorf("unit inválida: %q (use steps, ml, minutes, count ou mg)", g.Unit)
+	}
+	g.Aggregation = strings.ToLower(strings.TrimSpace(g.Aggregation))
+	switch g.Aggregation {
+	case "":
+		g.Aggregation = models.AggregateSum
+	case models.AggregateSum, models.AggregateMax, models.AggregateLast:
+	default:
+		return fmt.Errorf("aggregation inválida: %q (use sum, max ou last)", g.Aggregation)
+	}
+	return nil
+}
+
+// goalOf devolve a meta do hábito ou a implícita.
+func goalOf(h models.Habit) models.HabitGoal {
+	if h.Goal != nil {
+		return *h.Goal
+	}
+	return defaultGoal
+}
+
+// dayTotal é o valor agregado de um dia e o horário do último registro que contou.
+type dayTotal struct {
+	value  int
+	last   time.Time
+	logged bool
+}
+
+// aggregateDays agrupa os registros até now por dia (AAAA-MM-DD no fuso loc) com a regra da meta.
+func aggregateDays(logs []models.HabitLog, goal models.HabitGoal, loc *time.Location, now time.Time) map[string]dayTotal {
+	days := map[string]dayTotal{}
+	for _, l := range logs {
+		if l.Timestamp.After(now) {
+			continue
+		}
+		key := l.Timestamp.In(loc).Format(dateLayout)
+		d := days[key]
+		switch goal.Aggregation {
+		case models.AggregateMax:
+			if !d.logged || l.Value > d.value {
+				d.value = l.Value
+			}
+		case models.AggregateLast:
+			if !d.logged || !l.Timestamp.Before(d.last) {
+				d.value = l.Value
+			}
+		default:
+			d.value += l.Value
+		}
+		if !d.logged || l.Timestamp.After(d.last) {
+			d.last = l.Timestamp
+		}
+		d.logged = true
+		days[key] = d
+	}
+	return days
+}
+
+// PeriodProgress é o progresso de uma ocorrência. Nas diárias, Value e Target estão na
+// unidade da meta; nas semanais, contam dias cumpridos (Unit "days").
+type PeriodProgress struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Value   int       `json:"value"`
+	Target  int       `json:"target"`
+	Unit    string    `json:"unit"`
+	Percent float64   `json:"percent"` // pode passar de 100
+	Met     bool      `json:"met"`
+
+	lastCompletion time.Time // último registro de um dia cumprido
+}
+
+// evaluate calcula o progresso da ocorrência a partir dos totais diários.
+func evaluate(kind string, occ Occurrence, days map[string]dayTotal, goal models.HabitGoal) PeriodProgress {
+	p := PeriodProgress{Start: occ.Start, End: occ.End}
+	if kind != models.ScheduleTimesPerWeek {
+		d := days[occ.Start.Format(dateLayout)]
+		p.Value, p.Target, p.Unit = d.value, goal.Target, goal.Unit
+		if p.Met = d.logged && d.value >= goal.Target; p.Met {
+			p.lastCompletion = d.last
+		}
+	} else {
+		p.Target, p.Unit = occ.Target, "days"
+		for day := occ.Start; day.Before(occ.End); day = day.AddDate(0, 0, 1) {
+			if d := days[day.Format(dateLayout)]; d.logged && d.value >= goal.Target {
+				p.Value++
+				p.lastCompletion = d.last
+			}
+		}
+		p.Met = p.Value >= p.Target
+	}
+	p.Percent = percent(p.Value, p.Target)
+	return p
+}
+
+func percent(value, target int) float64 {
+	if target <= 0 || value <= 0 {
+		return 0
+	}
+	return float64(int(float64(value)/float64(target)*1000+0.5)) / 10 // uma casa decimal
+}
+
+// Progress é o progresso de um hábito em um intervalo.
+type Progress struct {
+	HabitID string           `json:"habit_id"`
+	Goal    models.HabitGoal `json:"goal"`
+	From    time.Time        `json:"from"`
+	To      time.Time        `json:"to"`
+	Percent *float64         `json:"percent"` // média das ocorrências (cada uma limitada a 100); nulo se nenhuma era devida
+	Met     int              `json:"met"`     // ocorrências cumpridas
+	Periods []PeriodProgress `json:"periods"`
+}
+
+// ComputeProgress calcula o progresso do hábito nas ocorrências que se sobrepõem a [from, to).
+func ComputeProgress(h models.Habit, logs []models.HabitLog, from, to, now time.Time) (Progress, error) {
+	goal := goalOf(h)
+	p := Progress{HabitID: h.ID, Goal: goal, From: from, To: to, Periods: []PeriodProgress{}}
+	if h.Schedule == nil {
+		return p, nil
+	}
+	loc, err := time.LoadLocation(h.Schedule.Timezone)
+	if err != nil {
+		return p, err
+	}
+	occurrences, err := DueOccurrences(*h.Schedule, from, to)
+	if err != nil {
+		return p, err
+	}
+	days := aggregateDays(logs, goal, loc, now)
+	total := 0.0
+	for _, occ := range occurrences {
+		period := evaluate(h.Schedule.Kind, occ, days, goal)
+		p.Periods = append(p.Periods, period)
+		total += min(period.Percent, 100)
+		if period.Met {
+			p.Met++
+		}
+	}
+	if len(p.Periods) > 0 {
+		avg := float64(int(total/float64(len(p.Periods))*10+0.5)) / 10
+		p.Percent = &avg
+	}
+	return p, nil
+}
@@ -0,0 +1,141 @@
+package habits
+
+import (
+	"testing"
+	"time"
+
+	"go-guardiao-api/pkg/models"
+)
+
+func TestNormalizeGoal(t *testing.T) {
+	for _, tc := range []struct {
+		in   models.HabitGoal
+		want models.HabitGoal
+	}{
+		{models.HabitGoal{Target: 8000, Unit: "steps"}, models.HabitGoal{Target: 8000, Unit: "steps", Aggregation: "sum"}},
+		{models.HabitGoal{Target: 2000, Unit: " ML "}, models.HabitGoal{Target: 2000, Unit: "ml", Aggregation: "sum"}},
+		{models.HabitGoal{Target: 30, Unit: "minutes", Aggregation: "MAX"}, models.HabitGoal{Target: 30, Unit: "minutes", Aggregation: "max"}},
+		{models.HabitGoal{Target: 500, Unit: "mg", Aggregation: "last"}, models.HabitGoal{Target: 500, Unit: "mg", Aggregation: "last"}},
+	} {
+		got := tc.in
+		if err := NormalizeGoal(&got); err != nil || got != tc.want {
+			t.Errorf("NormalizeGoal(%+v) = %+v, %v", tc.in, got, err)
+		}
+	}
+
+	for name, g := range map[string]models.HabitGoal{
+		"alvo zero":            {Target: 0, Unit: "count"},
+		"alvo negativo":        {Target: -5, Unit: "count"},
+		"alvo acima do limite": {Target: maxGoalTarget + 1, Unit: "steps"},
+		"sem unidade":          {Target: 1},
+		"unidade desconhecida": {Target: 5, Unit: "km"},
+		"agregação inválida":   {Target: 5, Unit: "count", Aggregation: "avg"},
+	} {
+		if err := NormalizeGoal(&g); err == nil {
+			t.Errorf("%s: deveria ser recusada (%+v)", name, g)
+		}
+	}
+}
+
+func TestAggregateDays(t *testing.T) {
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(day, hour int) time.Time { return time.Date(2024, 3, day, hour, 0, 0, 0, time.UTC) }
+	now := at(6, 18)
+	logs := []models.HabitLog{
+		{Timestamp: at(6, 9), Value: 3000},
+		{Timestamp: at(6, 15), Value: 6000}, // último do dia
+		{Timestamp: at(6, 12), Value: 8500},
+		{Timestamp: at(6, 20), Value: 9999}, // depois de now
+		{Timestamp: at(7, 2), Value: 100},   // dia 6 em São Paulo, mas no futuro
+		{Timestamp: at(5, 1), Value: 700},   // dia 4 em São Paulo
+	}
+
+	for aggregation, want := range map[string]int{models.AggregateSum: 17500, models.AggregateMax: 8500, models.AggregateLast: 6000} {
+		days := aggregateDays(logs, models.HabitGoal{Target: 8000, Unit: models.UnitSteps, Aggregation: aggregation}, time.UTC, now)
+		d := days["2024-03-06"]
+		if !d.logged || d.value != want || !d.last.Equal(at(6, 15)) {
+			t.Errorf("%s: %+v, esperado %d", aggregation, d, want)
+		}
+	}
+
+	days := aggregateDays(logs, defaultGoal, saoPaulo, now)
+	if d := days["2024-03-04"]; d.value != 700 {
+		t.Errorf("registro da madrugada em UTC conta no dia anterior em São Paulo: %+v", days)
+	}
+	if _, ok := days["2024-03-05"]; ok {
+		t.Errorf("nenhum registro cai em 05/03 em São Paulo: %+v", days)
+	}
+}
+
+func TestComputeProgress(t *testing.T) {
+	schedule := mustSchedule(t, models.HabitSchedule{Kind: models.ScheduleDaily, StartDate: "2024-03-01"})
+	habit := models.Habit{ID: "h1", Schedule: &schedule, Goal: &models.HabitGoal{Target: 8000, Unit: models.UnitSteps, Aggregation: models.AggregateSum}}
+	at := func(day, hour int) time.Time { return time.Date(2024, 3, day, hour, 0, 0, 0, time.UTC) }
+	now := at(6, 18) // quarta-feira
+	logs := []models.HabitLog{
+		{Timestamp: at(2, 10), Value: 16000}, // 200%, conta 100% na média
+		{Timestamp: at(4, 10), Value: 8000},
+		{Timestamp: at(5, 10), Value: 4000},
+		{Timestamp: at(6, 8), Value: 4000},
+		{Timestamp: at(6, 12), Value: 3000},
+		{Timestamp: at(7, 10), Value: 9000}, // futuro
+	}
+	day := today(now, time.UTC)
+	week := weekStart(day)
+
+	for _, tc := range []struct {
+		name     string
+		from, to time.Time
+		periods  []float64
+		percent  float64
+		met      int
+	}{
+		{"hoje", day, day.AddDate(0, 0, 1), []float64{87.5}, 87.5, 0},
+		{"semana atual", week, week.AddDate(0, 0, 7), []float64{100, 50, 87.5, 0, 0, 0, 0}, 33.9, 1},
+		{"intervalo", at(1, 0), at(6, 0), []float64{0, 200, 0, 100, 50}, 50, 2},
+	} {
+		p, err := ComputeProgress(habit, logs, tc.from, tc.to, now)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if len(p.Periods) != len(tc.periods) || p.Percent == nil || *p.Percent != tc.percent || p.Met != tc.met {
+			t.Errorf("%s: %+v", tc.name, p)
+			continue
+		}
+		for i, want := range tc.periods {
+			if got := p.Periods[i]; got.Percent != want || got.Unit != models.UnitSteps || got.Target != 8000 {
+				t.Errorf("%s, período %d: %+v, esperado %v%%", tc.name, i, got, want)
+			}
+		}
+	}
+
+	if p, err := ComputeProgress(habit, nil, day, day.AddDate(0, 0, 1), now); err != nil || p.Percent == nil || *p.Percent != 0 || p.Met != 0 {
+		t.Errorf("sem registros: %+v, %v", p, err)
+	}
+	if p, err := ComputeProgress(habit, logs, at(1, 0).AddDate(0, 0, -7), at(1, 0), now); err != nil || p.Percent != nil || len(p.Periods) != 0 {
+		t.Errorf("antes do início não há ocorrências nem percentual: %+v, %v", p, err)
+	}
+	if p, err := ComputeProgress(models.Habit{ID: "h2"}, logs, day, day.AddDate(0, 0, 1), now); err != nil || p.Percent != nil || p.Goal != defaultGoal {
+		t.Errorf("hábito sem agenda: %+v, %v", p, err)
+	}
+}
+
+func TestComputeProgressTimesPerWeek(t *testing.T) {
+	schedule := mustSchedule(t, models.HabitSchedule{Kind: models.ScheduleTimesPerWeek, Times: 3, StartDate: "2024-03-04"})
+	habit := models.Habit{ID: "h1", Schedule: &schedule}
+	logs := marchLogs(time.UTC, 4, 4, 6)
+	now := time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC)
+
+	p, err := ComputeProgress(habit, logs, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC), now)
+	if err != nil || len(p.Periods) != 1 {
+		t.Fatalf("semana: %+v, %v", p, err)
+	}
+	// Dois registros no mesmo dia contam um dia cumprido
+	if got := p.Periods[0]; got.Value != 2 || got.Target != 3 || got.Unit != "days" || got.Percent != 66.7 || got.Met {
+		t.Fatalf("progresso da semana: %+v", got)
+	}
+}
@@ -2,23 +2,32 @@ package habits
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"time"
 
 	"go-guardiao-api/internal/auth"
+	"go-guardiao-api/internal/gamification"
+	"go-guardiao-api/internal/platforms/cache"
 	"go-guardiao-api/internal/platforms/db"
 	"go-guardiao-api/pkg/models"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
 )
 
 // Service representa o serviço de Hábitos.
 type Service struct {
-	DBClient *db.Client
+	DBClient    db.HabitRepository
+	CacheClient cache.Cache // saldo de mana e leaderboard após comprar proteções
 }
 
-func NewService(dbClient *db.Client) *Service {
-	return &Service{DBClient: dbClient}
+func NewService(dbClient db.HabitRepository, cacheClient cache.Cache) *Service {
+	return &Service{DBClient: dbClient, CacheClient: cacheClient}
 }
 
 // --- Helpers para respostas padronizadas ---
@@ -50,6 +59,23 @@ func (s *Service) HandleCreateHabit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	newHabit.UserID = userID
+	if newHabit.Status == "" {
+		newHabit.Status = models.HabitActive
+	}
+	if !editableStatus(newHabit.Status) {
+		writeError(w, http.StatusBadRequest, "Situação inválida (use active ou paused).")
+		return
+	}
+	if newHabit.Schedule, err = resolveSchedule(newHabit.Schedule, newHabit.Frequency); err != nil {
+		writeError(w, http.StatusBadRequest, "Agenda inválida: "+err.Error())
+		return
+	}
+	if newHabit.Goal != nil {
+		if err := NormalizeGoal(newHabit.Goal); err != nil {
+			writeError(w, http.StatusBadRequest, "Meta inválida: "+err.Error())
+			return
+		}
+	}
 	habitID, err := s.DBClient.CreateHabit(r.Context(), newHabit)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao criar hábito: %v", err))
@@ -62,7 +88,8 @@ func (s *Service) HandleCreateHabit(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// HandleGetHabits busca todos os hábitos de um usuário.
+// HandleGetHabits busca os hábitos de um usuário. Por padrão lista os ativos e pausados;
+// ?status=active|paused|archived filtra por situação e ?status=all inclui os arquivados.
 func (s *Service) HandleGetHabits(w http.ResponseWriter, r *http.Request) {
 	userID, err := auth.GetUserIDFromContext(r)
 	if err != nil {
@@ -70,30 +97,57 @@ func (s *Service) HandleGetHabits(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", "all", models.HabitActive, models.HabitPaused, models.HabitArchived:
+	default:
+		writeError(w, http.StatusBadRequest, "Situação inválida (use active, paused, archived ou all).")
+		return
+	}
+
 	habits, err := s.DBClient.GetHabitsByUserID(r.Context(), userID)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Erro ao buscar hábitos.")
 		return
 	}
 
-	writeJSON(w, http.StatusOK, habits)
+	filtered := make([]models.Habit, 0, len(habits))
+	for _, h := range habits {
+		switch {
+		case status == "all", h.Status == status:
+		case status == "" && h.Status != models.HabitArchived:
+		default:
+			continue
+		}
+		filtered = append(filtered, h)
+	}
+	if err := s.attachStreaks(r, userID, filtered); err != nil {
+		writeError(w, http.StatusInternalServerError, "Erro ao calcular sequências.")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, filtered)
 }
 
 // HandleGetHabitById busca um único hábito (Necessário para o cache do Angular).
 func (s *Service) HandleGetHabitById(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	habitID := vars["habitId"]
-
-	habit, err := s.DBClient.GetHabitById(r.Context(), habitID)
+	userID, err := auth.GetUserIDFromContext(r)
 	if err != nil {
-		writeError(w, http.StatusNotFound, "Hábito não encontrado.")
+		writeError(w, http.StatusUnauthorized, "Acesso negado.")
+		return
+	}
+
+	habit, ok := s.ownedHabit(w, r, userID, mux.Vars(r)["habitId"])
+	if !ok {
 		return
 	}
 
 	writeJSON(w, http.StatusOK, habit)
 }
 
-// HandleLogHabit registra um progresso (Log) em um hábito.
+// HandleLogHabit registra um progresso (Log) em um hábito. log_date é opcional (padrão: agora)
+// e pode voltar até HABIT_LOG_BACKDATE_WINDOW. A mana do registro (gamification.HabitLogMana,
+// pela meta do hábito) é creditada junto, com o registro como reference_id.
 func (s *Service) HandleLogHabit(w http.ResponseWriter, r *http.Request) {
 	userID, err := auth.GetUserIDFromContext(r)
 	if err != nil {
@@ -104,26 +158,59 @@ func (s *Service) HandleLogHabit(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	habitID := vars["habitId"]
 
+	habit, ok := s.ownedHabit(w, r, userID, habitID)
+	if !ok {
+		return
+	}
+	if !habit.Active() {
+		writeError(w, http.StatusConflict, "Hábito pausado ou arquivado: reative-o para registrar progresso.")
+		return
+	}
+
 	var logData models.HabitLog
-	_ = json.NewDecoder(r.Body).Decode(&logData)
+	if err := json.NewDecoder(r.Body).Decode(&logData); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "Requisição inválida.")
+		return
+	}
+	now := time.Now()
+	if logData.Timestamp.IsZero() {
+		logData.Timestamp = now
+	} else if err := checkLogTime(logData.Timestamp, now); err != nil {
+		writeError(w, http.StatusBadRequest, "Data inválida: "+err.Error())
+		return
+	}
 
 	logData.UserID = userID
 	logData.HabitID = habitID
 
-	if err := s.DBClient.LogHabit(r.Context(), logData); err != nil {
+	stored, grant, err := s.DBClient.LogHabit(r.Context(), logData, gamification.HabitLogMana(habit.Goal, logData))
+	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Erro ao registrar log.")
 		return
 	}
+	s.refreshMana(r, userID, grant)
 
-	writeJSON(w, http.StatusOK, map[string]string{"message": "Log registrado."})
+	writeJSON(w, http.StatusOK, map[string]any{
+		"message": "Log registrado.",
+		"log":     stored,
+		"mana":    manaAdjustment(grant),
+	})
 }
 
 // HandleGetHabitLogs busca o histórico de um hábito.
+// Responde 404 para hábitos de outra conta.
 func (s *Service) HandleGetHabitLogs(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	habitID := vars["habitId"]
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado.")
+		return
+	}
 
-	logs, err := s.DBClient.GetHabitLogs(r.Context(), habitID)
+	habit, ok := s.ownedHabit(w, r, userID, mux.Vars(r)["habitId"])
+	if !ok {
+		return
+	}
+	logs, err := s.DBClient.GetHabitLogs(r.Context(), habit.ID)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Erro ao buscar histórico.")
 		return
@@ -150,3 +237,318 @@ func (s *Service) HandleDeleteHabit(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, http.StatusOK, map[string]string{"message": "Hábito excluído."})
 }
+
+// --- Edição e ciclo de vida ---
+
+// editableStatus informa se a situação pode ser definida na criação ou edição
+// (arquivar e desarquivar têm rotas próprias).
+func editableStatus(status string) bool {
+	return status == models.HabitActive || status == models.HabitPaused
+}
+
+// resolveSchedule valida a agenda enviada ou, sem ela, deriva uma da frequência livre.
+func resolveSchedule(schedule *models.HabitSchedule, frequency string) (*models.HabitSchedule, error) {
+	if schedule == nil {
+		return ScheduleFromFrequency(frequency, time.Now())
+	}
+	if err := NormalizeSchedule(schedule, time.Now()); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// ownedHabit carrega o hábito do usuário, respondendo 404 se ele não existir ou for de outra conta.
+func (s *Service) ownedHabit(w http.ResponseWriter, r *http.Request, userID, habitID string) (models.Habit, bool) {
+	if _, err := uuid.Parse(habitID); err != nil {
+		writeError(w, http.StatusNotFound, "Hábito não encontrado.")
+		return models.Habit{}, false
+	}
+	habit, err := s.DBClient.GetHabitById(r.Context(), habitID)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && habit.UserID != userID) {
+		writeError(w, http.StatusNotFound, "Hábito não encontrado.")
+		return models.Habit{}, false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Erro ao buscar hábito.")
+		return models.Habit{}, false
+	}
+	return habit, true
+}
+
+// habitUpdateRequest é o corpo de PUT/PATCH /habits/{habitId}. No PATCH, campos ausentes
+// mantêm o valor atual; no PUT, o nome é obrigatório, tipo, frequência e meta ausentes são limpos e,
+// sem schedule, a agenda atual é mantida (ou derivada da frequência, se não houver).
+// No PATCH, "goal": null remove a meta.
+type habitUpdateRequest struct {
+	Name      *string               `json:"name"`
+	GoalType  *string               `json:"goal_type"`
+	Frequency *string               `json:"frequency"`
+	Schedule  *models.HabitSchedule `json:"schedule"`
+	Goal      json.RawMessage       `json:"goal"`
+	Status    *string               `json:"status"`
+}
+
+// HandleUpdateHabit edita nome, tipo de meta, frequência, agenda, meta e situação (ativo/pausado) de um hábito.
+// Hábitos arquivados precisam ser desarquivados antes.
+func (s *Service) HandleUpdateHabit(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado.")
+		return
+	}
+
+	var req habitUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Requisição inválida.")
+		return
+	}
+	replace := r.Method == http.MethodPut
+	if replace && req.Name == nil {
+		writeError(w, http.StatusBadRequest, "O nome do hábito é obrigatório.")
+		return
+	}
+
+	habit, ok := s.ownedHabit(w, r, userID, mux.Vars(r)["habitId"])
+	if !ok {
+		return
+	}
+	if habit.Status == models.HabitArchived {
+		writeError(w, http.StatusConflict, "Hábito arquivado: desarquive-o para editar.")
+		return
+	}
+
+	if req.Name != nil {
+		habit.Name = strings.TrimSpace(*req.Name)
+		if habit.Name == "" {
+			writeError(w, http.StatusBadRequest, "O nome do hábito é obrigatório.")
+			return
+		}
+	}
+	if req.GoalType != nil || replace {
+		habit.GoalType = strings.TrimSpace(deref(req.GoalType))
+	}
+	if req.Frequency != nil || replace {
+		habit.Frequency = strings.TrimSpace(deref(req.Frequency))
+	}
+	if req.Schedule != nil || (replace && habit.Schedule == nil) {
+		if habit.Schedule, err = resolveSchedule(req.Schedule, habit.Frequency); err != nil {
+			writeError(w, http.StatusBadRequest, "Agenda inválida: "+err.Error())
+			return
+		}
+	}
+	if req.Goal != nil || replace {
+		if habit.Goal, err = parseGoal(req.Goal); err != nil {
+			writeError(w, http.StatusBadRequest, "Meta inválida: "+err.Error())
+			return
+		}
+	}
+	if req.Status != nil {
+		if !editableStatus(*req.Status) {
+			writeError(w, http.StatusBadRequest, "Situação inválida (use active ou paused; para arquivar, use /archive).")
+			return
+		}
+		habit.Status = *req.Status
+	}
+
+	updated, err := s.DBClient.UpdateHabit(r.Context(), habit)
+	switch {
+	case errors.Is(err, db.ErrHabitArchived):
+		writeError(w, http.StatusConflict, "Hábito arquivado: desarquive-o para editar.")
+		return
+	case errors.Is(err, pgx.ErrNoRows):
+		writeError(w, http.StatusNotFound, "Hábito não encontrado.")
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "Erro ao atualizar hábito.")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// HandleArchiveHabit arquiva o hábito: ele sai da lista padrão e das regras, mas o histórico é mantido.
+func (s *Service) HandleArchiveHabit(w http.ResponseWriter, r *http.Request) {
+	s.setStatus(w, r, models.HabitArchived)
+}
+
+// HandleUnarchiveHabit devolve um hábito arquivado à situação ativa.
+func (s *Service) HandleUnarchiveHabit(w http.ResponseWriter, r *http.Request) {
+	s.setStatus(w, r, models.HabitActive)
+}
+
+func (s *Service) setStatus(w http.ResponseWriter, r *http.Request, status string) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado.")
+		return
+	}
+
+	habit, ok := s.ownedHabit(w, r, userID, mux.Vars(r)["habitId"])
+	if !ok {
+		return
+	}
+	if status == models.HabitActive && habit.Status != models.HabitArchived {
+		writeError(w, http.StatusConflict, "Hábito não está arquivado.")
+		return
+	}
+
+	updated, err := s.DBClient.SetHabitStatus(r.Context(), habit.ID, userID, status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Hábito não encontrado.")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Erro ao atualizar hábito.")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// parseGoal interpreta o campo goal da edição: ausente ou null remove a meta.
+func parseGoal(raw json.RawMessage) (*models.HabitGoal, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var goal models.HabitGoal
+	if err := json.Unmarshal(raw, &goal); err != nil {
+		return nil, errors.New("formato inválido")
+	}
+	if err := NormalizeGoal(&goal); err != nil {
+		return nil, err
+	}
+	return &goal, nil
+}
+
+func deref(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
+
+// maxOccurrenceDays limita o intervalo de GET /habits/{habitId}/occurrences.
+const maxOccurrenceDays = 366
+
+// HandleGetHabitOccurrences lista as ocorrências devidas do hábito em [from, to).
+// from e to aceitam AAAA-MM-DD (no fuso da agenda) ou RFC 3339; o padrão são os últimos 7 dias.
+func (s *Service) HandleGetHabitOccurrences(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado.")
+		return
+	}
+
+	habit, ok := s.ownedHabit(w, r, userID, mux.Vars(r)["habitId"])
+	if !ok {
+		return
+	}
+	if habit.Schedule == nil {
+		writeJSON(w, http.StatusOK, []Occurrence{})
+		return
+	}
+	loc, err := time.LoadLocation(habit.Schedule.Timezone)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Agenda do hábito com fuso inválido.")
+		return
+	}
+
+	to := today(time.Now(), loc).AddDate(0, 0, 1)
+	from, to, ok := queryRange(w, r, loc, to.AddDate(0, 0, -7), to)
+	if !ok {
+		return
+	}
+
+	occurrences, err := DueOccurrences(*habit.Schedule, from, to)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Erro ao calcular ocorrências.")
+		return
+	}
+	writeJSON(w, http.StatusOK, occurrences)
+}
+
+// queryRange lê from e to da query string (com os padrões informados) e valida o intervalo.
+func queryRange(w http.ResponseWriter, r *http.Request, loc *time.Location, from, to time.Time) (time.Time, time.Time, bool) {
+	var err error
+	if v := r.URL.Query().Get("from"); v != "" {
+		if from, err = parseQueryTime(v, loc); err != nil {
+			writeError(w, http.StatusBadRequest, "Parâmetro 'from' inválido (use AAAA-MM-DD ou RFC 3339).")
+			return from, to, false
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		if to, err = parseQueryTime(v, loc); err != nil {
+			writeError(w, http.StatusBadRequest, "Parâmetro 'to' inválido (use AAAA-MM-DD ou RFC 3339).")
+			return from, to, false
+		}
+	}
+	if !to.After(from) {
+		writeError(w, http.StatusBadRequest, "'to' deve ser posterior a 'from'.")
+		return from, to, false
+	}
+	if to.Sub(from) > maxOccurrenceDays*24*time.Hour {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Intervalo máximo: %d dias.", maxOccurrenceDays))
+		return from, to, false
+	}
+	return from, to, true
+}
+
+// HandleGetHabitProgress mostra o progresso do hábito em relação à meta.
+// ?period=today (padrão) ou week (semana atual, de segunda a domingo), ou um intervalo com
+// from e to (mesmo formato de /occurrences).
+func (s *Service) HandleGetHabitProgress(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado.")
+		return
+	}
+
+	habit, ok := s.ownedHabit(w, r, userID, mux.Vars(r)["habitId"])
+	if !ok {
+		return
+	}
+	tz := "UTC"
+	if habit.Schedule != nil {
+		tz = habit.Schedule.Timezone
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Agenda do hábito com fuso inválido.")
+		return
+	}
+
+	now := time.Now()
+	from := today(now, loc)
+	to := from.AddDate(0, 0, 1)
+	switch r.URL.Query().Get("period") {
+	case "", "today":
+	case "week":
+		from = weekStart(from)
+		to = from.AddDate(0, 0, 7)
+	default:
+		writeError(w, http.StatusBadRequest, "Período inválido (use today ou week, ou informe from e to).")
+		return
+	}
+	if from, to, ok = queryRange(w, r, loc, from, to); !ok {
+		return
+	}
+
+	logs, err := s.DBClient.GetHabitLogs(r.Context(), habit.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Erro ao buscar histórico.")
+		return
+	}
+	progress, err := ComputeProgress(habit, logs, from, to, now)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Erro ao calcular progresso.")
+		return
+	}
+	writeJSON(w, http.StatusOK, progress)
+}
+
+func parseQueryTime(v string, loc *time.Location) (time.Time, error) {
+	if t, err := time.ParseInLocation(dateLayout, v, loc); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
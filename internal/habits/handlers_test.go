@@ -0,0 +1,273 @@
+package habits
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"go-guardiao-api/internal/apitest"
+	"go-guardiao-api/internal/platforms/cache"
+	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/pkg/models"
+)
+
+func newTestAPI(t *testing.T) *apitest.API {
+	return apitest.New(t, func(router *mux.Router, store *db.MemoryStore, c *cache.Memory) {
+		s := NewService(store, c)
+		router.HandleFunc("/habits", s.HandleCreateHabit).Methods("POST")
+		router.HandleFunc("/habits", s.HandleGetHabits).Methods("GET")
+		router.HandleFunc("/habits/{habitId}/log", s.HandleLogHabit).Methods("POST")
+		router.HandleFunc("/habits/{habitId}/logs", s.HandleGetHabitLogs).Methods("GET")
+		router.HandleFunc("/habits/{habitId}/logs/{logId}", s.HandleUpdateHabitLog).Methods("PUT")
+		router.HandleFunc("/habits/{habitId}/logs/{logId}", s.HandleDeleteHabitLog).Methods("DELETE")
+		router.HandleFunc("/habits/{habitId}/streak-freezes", s.HandleBuyStreakFreeze).Methods("POST")
+		router.HandleFunc("/habits/{habitId}/archive", s.HandleArchiveHabit).Methods("POST")
+		router.HandleFunc("/habits/{habitId}/progress", s.HandleGetHabitProgress).Methods("GET")
+	})
+}
+
+func createHabit(t *testing.T, a *apitest.API, token string) string {
+	t.Helper()
+	var created struct {
+		HabitID string `json:"habit_id"`
+	}
+	if code := a.Do(t, "POST", "/habits", token, `{"name":"Água","frequency":"daily"}`, &created); code != http.StatusCreated {
+		t.Fatalf("POST /habits: %d", code)
+	}
+	return created.HabitID
+}
+
+func TestHabitsRequireToken(t *testing.T) {
+	a := newTestAPI(t)
+	if code := a.Do(t, "GET", "/habits", "invalido", "", nil); code != http.StatusUnauthorized {
+		t.Fatalf("sem token válido: esperado 401, veio %d", code)
+	}
+}
+
+func TestCreateAndLogHabit(t *testing.T) {
+	a := newTestAPI(t)
+	_, token := a.SignIn(t, models.User{Email: "ana@exemplo.com"})
+	habitID := createHabit(t, a, token)
+
+	if code := a.Do(t, "POST", "/habits/"+habitID+"/log", token, `{"value":1}`, nil); code != http.StatusOK {
+		t.Fatalf("POST log: %d", code)
+	}
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+	if code := a.Do(t, "POST", "/habits/"+habitID+"/log", token, `{"value":1,"log_date":"`+future+`"}`, nil); code != http.StatusBadRequest {
+		t.Fatalf("registro no futuro: esperado 400, veio %d", code)
+	}
+
+	var logs []models.HabitLog
+	if code := a.Do(t, "GET", "/habits/"+habitID+"/logs", token, "", &logs); code != http.StatusOK || len(logs) != 1 {
+		t.Fatalf("GET logs: %d, %+v", code, logs)
+	}
+
+	var habits []models.Habit
+	if code := a.Do(t, "GET", "/habits", token, "", &habits); code != http.StatusOK || len(habits) != 1 {
+		t.Fatalf("GET /habits: %d, %+v", code, habits)
+	}
+	if habits[0].Streak == nil || habits[0].Streak.Current != 1 {
+		t.Fatalf("sequência após registro de hoje: %+v", habits[0].Streak)
+	}
+}
+
+func TestHabitsOfAnotherAccountAreHidden(t *testing.T) {
+	a := newTestAPI(t)
+	_, owner := a.SignIn(t, models.User{Email: "ana@exemplo.com"})
+	_, other := a.SignIn(t, models.User{Email: "bia@exemplo.com"})
+	habitID := createHabit(t, a, owner)
+
+	for _, req := range []struct{ method, path, body string }{
+		{"GET", "/habits/" + habitID + "/logs", ""},
+		{"POST", "/habits/" + habitID + "/log", `{"value":1}`},
+		{"POST", "/habits/" + habitID + "/archive", ""},
+	} {
+		if code := a.Do(t, req.method, req.path, other, req.body, nil); code != http.StatusNotFound {
+			t.Errorf("%s %s por outra conta: esperado 404, veio %d", req.method, req.path, code)
+		}
+	}
+}
+
+func TestEditOldHabitLogIsRejected(t *testing.T) {
+	a := newTestAPI(t)
+	userID, token := a.SignIn(t, models.User{Email: "ana@exemplo.com"})
+	habitID := createHabit(t, a, token)
+	old, _, err := a.Store.LogHabit(context.Background(), models.HabitLog{
+		HabitID: habitID, UserID: userID, Value: 1, Timestamp: time.Now().Add(-logBackdateWindow - time.Hour),
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := "/habits/" + habitID + "/logs/" + old.ID
+	if code := a.Do(t, "PUT", path, token, `{"value":5}`, nil); code != http.StatusConflict {
+		t.Fatalf("PUT só com value em registro antigo: esperado 409, veio %d", code)
+	}
+	if code := a.Do(t, "DELETE", path, token, "", nil); code != http.StatusConflict {
+		t.Fatalf("DELETE de registro antigo: esperado 409, veio %d", code)
+	}
+	if code := a.Do(t, "PUT", "/habits/"+habitID+"/logs/999999", token, `{"value":5}`, nil); code != http.StatusNotFound {
+		t.Fatalf("registro inexistente: esperado 404, veio %d", code)
+	}
+
+	recent, _, err := a.Store.LogHabit(context.Background(), models.HabitLog{HabitID: habitID, UserID: userID, Value: 1}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var updated struct {
+		Log models.HabitLog `json:"log"`
+	}
+	if code := a.Do(t, "PUT", "/habits/"+habitID+"/logs/"+recent.ID, token, `{"value":5}`, &updated); code != http.StatusOK || updated.Log.Value != 5 {
+		t.Fatalf("PUT em registro recente: %d, %+v", code, updated.Log)
+	}
+}
+
+func TestBuyStreakFreeze(t *testing.T) {
+	a := newTestAPI(t)
+	userID, token := a.SignIn(t, models.User{Email: "ana@exemplo.com"})
+	habitID := createHabit(t, a, token)
+	path := "/habits/" + habitID + "/streak-freezes"
+	body := `{"day":"` + time.Now().UTC().Format(dateLayout) + `"}` // hoje, ainda não cumprido (agenda em UTC)
+
+	var refused struct {
+		Error string `json:"error"`
+	}
+	if code := a.Do(t, "POST", path, token, body, &refused); code != http.StatusConflict || !strings.Contains(refused.Error, "Mana insuficiente") {
+		t.Fatalf("sem mana: esperado 409 de saldo, veio %d %q", code, refused.Error)
+	}
+
+	grant := models.ManaTransaction{UserID: userID, Type: models.ManaTypeHabitCompletion, Amount: streakFreezeCost}
+	if err := a.Store.UpdateManaBalance(context.Background(), grant); err != nil {
+		t.Fatal(err)
+	}
+	var bought struct {
+		NewBalance int `json:"new_balance"`
+	}
+	if code := a.Do(t, "POST", path, token, body, &bought); code != http.StatusCreated || bought.NewBalance != 0 {
+		t.Fatalf("compra com saldo: %d, saldo %d", code, bought.NewBalance)
+	}
+	if code := a.Do(t, "POST", path, token, `{"day":"ontem"}`, nil); code != http.StatusBadRequest {
+		t.Fatalf("dia inválido: esperado 400, veio %d", code)
+	}
+}
+
+func TestHabitLogManaFollowsEdits(t *testing.T) {
+	a := newTestAPI(t)
+	userID, token := a.SignIn(t, models.User{Email: "ana@exemplo.com"})
+	var created struct {
+		HabitID string `json:"habit_id"`
+	}
+	body := `{"name":"Água","frequency":"daily","goal":{"target":8,"unit":"count"}}`
+	if code := a.Do(t, "POST", "/habits", token, body, &created); code != http.StatusCreated {
+		t.Fatalf("POST /habits: %d", code)
+	}
+
+	type manaDelta struct {
+		Amount     int `json:"amount"`
+		NewBalance int `json:"new_balance"`
+	}
+	var logged struct {
+		Log  models.HabitLog `json:"log"`
+		Mana *manaDelta      `json:"mana"`
+	}
+	if code := a.Do(t, "POST", "/habits/"+created.HabitID+"/log", token, `{"value":4}`, &logged); code != http.StatusOK {
+		t.Fatalf("POST log: %d", code)
+	}
+	if logged.Mana == nil || logged.Mana.Amount != 12 || logged.Mana.NewBalance != 12 {
+		t.Fatalf("metade da meta deveria render 12: %+v", logged.Mana)
+	}
+
+	path := "/habits/" + created.HabitID + "/logs/" + logged.Log.ID
+	var edited struct {
+		Adj *manaDelta `json:"mana_adjustment"`
+	}
+	if code := a.Do(t, "PUT", path, token, `{"value":8}`, &edited); code != http.StatusOK {
+		t.Fatalf("PUT log: %d", code)
+	}
+	if edited.Adj == nil || edited.Adj.Amount != 13 || edited.Adj.NewBalance != 25 {
+		t.Fatalf("meta cumprida após edição: %+v", edited.Adj)
+	}
+	var deleted struct {
+		Adj *manaDelta `json:"mana_adjustment"`
+	}
+	if code := a.Do(t, "DELETE", path, token, "", &deleted); code != http.StatusOK {
+		t.Fatalf("DELETE log: %d", code)
+	}
+	if deleted.Adj == nil || deleted.Adj.Amount != -25 || deleted.Adj.NewBalance != 0 {
+		t.Fatalf("estorno após exclusão: %+v", deleted.Adj)
+	}
+
+	txs, err := a.Store.ListManaTransactions(context.Background(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		kind   models.ManaTransactionType
+		amount int
+	}{
+		{models.ManaTypeHabitCompletion, 12},
+		{models.ManaTypeHabitLogAdjustment, 13},
+		{models.ManaTypeHabitLogAdjustment, -25},
+	}
+	if len(txs) != len(want) {
+		t.Fatalf("extrato: %+v", txs)
+	}
+	for i, w := range want {
+		if txs[i].Type != w.kind || txs[i].Amount != w.amount || txs[i].ReferenceID != logged.Log.ID {
+			t.Errorf("transação %d: esperado %s %d, veio %+v", i, w.kind, w.amount, txs[i])
+		}
+	}
+	if balance, _ := a.Store.GetManaBalance(context.Background(), userID); balance != 0 {
+		t.Fatalf("saldo final: %d", balance)
+	}
+	if cached, err := a.Cache.GetManaBalance(context.Background(), userID); err != nil || cached != 0 {
+		t.Fatalf("saldo em cache: %d, %v", cached, err)
+	}
+}
+
+func TestHabitProgressPeriods(t *testing.T) {
+	a := newTestAPI(t)
+	_, token := a.SignIn(t, models.User{Email: "ana@exemplo.com"})
+	var created struct {
+		HabitID string `json:"habit_id"`
+	}
+	body := `{"name":"Caminhada","frequency":"daily","goal":{"target":8000,"unit":"steps"}}`
+	if code := a.Do(t, "POST", "/habits", token, body, &created); code != http.StatusCreated {
+		t.Fatalf("POST /habits: %d", code)
+	}
+	path := "/habits/" + created.HabitID
+	if code := a.Do(t, "POST", path+"/log", token, `{"value":6000}`, nil); code != http.StatusOK {
+		t.Fatalf("POST log: %d", code)
+	}
+
+	var progress struct {
+		Percent *float64 `json:"percent"`
+		Periods []struct {
+			Value int    `json:"value"`
+			Unit  string `json:"unit"`
+		} `json:"periods"`
+	}
+	if code := a.Do(t, "GET", path+"/progress", token, "", &progress); code != http.StatusOK {
+		t.Fatalf("GET progress: %d", code)
+	}
+	if progress.Percent == nil || *progress.Percent != 75 || len(progress.Periods) != 1 || progress.Periods[0].Unit != "steps" {
+		t.Fatalf("progresso de hoje: %+v", progress)
+	}
+
+	for query, want := range map[string]int{
+		"?period=week":                   http.StatusOK,
+		"?period=month":                  http.StatusBadRequest,
+		"?from=2024-03-10&to=2024-03-01": http.StatusBadRequest,
+		"?from=2024-01-01&to=2025-06-01": http.StatusBadRequest,
+		"?from=ontem":                    http.StatusBadRequest,
+		"?from=2024-03-01&to=2024-03-08": http.StatusOK,
+	} {
+		if code := a.Do(t, "GET", path+"/progress"+query, token, "", nil); code != want {
+			t.Errorf("GET progress%s: esperado %d, veio %d", query, want, code)
+		}
+	}
+}
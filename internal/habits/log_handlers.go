@@ -0,0 +1,183 @@
+package habits
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+
+	"go-guardiao-api/internal/auth"
+	"go-guardiao-api/internal/gamification"
+	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Registros retroativos, edição e exclusão =====
+
+// logBackdateWindow limita quanto tempo para trás um registro pode ser datado.
+var logBackdateWindow = getEnvDuration("HABIT_LOG_BACKDATE_WINDOW", 7*24*time.Hour)
+
+// logClockSkew tolera relógios de cliente um pouco adiantados.
+const logClockSkew = time.Minute
+
+func getEnvDuration(k string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(k))
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+// checkLogTime recusa horários no futuro ou fora da janela de registros retroativos.
+func checkLogTime(t, now time.Time) error {
+	if t.After(now.Add(logClockSkew)) {
+		return errors.New("log_date não pode estar no futuro")
+	}
+	if t.Before(editableSince(now)) {
+		return fmt.Errorf("log_date pode voltar no máximo %s", logBackdateWindow)
+	}
+	return nil
+}
+
+// editableSince é o horário mais antigo de um registro que ainda pode ser editado ou excluído:
+// a mesma janela dos registros retroativos.
+func editableSince(now time.Time) time.Time {
+	return now.Add(-logBackdateWindow)
+}
+
+// logWriteOK responde aos erros de registro inexistente ou antigo demais.
+func logWriteOK(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		writeError(w, http.StatusNotFound, "Registro não encontrado.")
+		return false
+	case errors.Is(err, db.ErrHabitLogTooOld):
+		writeError(w, http.StatusConflict, fmt.Sprintf("Só é possível alterar registros dos últimos %s.", logBackdateWindow))
+		return false
+	}
+	return true
+}
+
+// editableLog carrega o hábito do registro a editar ou excluir: hábitos arquivados precisam
+// ser desarquivados antes.
+func (s *Service) editableLog(w http.ResponseWriter, r *http.Request, userID string) (models.Habit, bool) {
+	habit, ok := s.ownedHabit(w, r, userID, mux.Vars(r)["habitId"])
+	if !ok {
+		return habit, false
+	}
+	if habit.Status == models.HabitArchived {
+		writeError(w, http.StatusConflict, "Hábito arquivado: desarquive-o para alterar registros.")
+		return habit, false
+	}
+	return habit, true
+}
+
+// HandleUpdateHabitLog corrige valor e, opcionalmente, data de um registro da janela de
+// HABIT_LOG_BACKDATE_WINDOW (registros mais antigos ficam fixos).
+// Corpo: { "value": 3, "log_date": "RFC 3339" }. A mana é recalculada pela meta do hábito e a
+// diferença para o que o registro já rendeu é lançada como HABIT_LOG_ADJUSTMENT.
+func (s *Service) HandleUpdateHabitLog(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado.")
+		return
+	}
+
+	var req struct {
+		Value     *int       `json:"value"`
+		Timestamp *time.Time `json:"log_date"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Requisição inválida.")
+		return
+	}
+	if req.Value == nil {
+		writeError(w, http.StatusBadRequest, "O valor do registro é obrigatório.")
+		return
+	}
+	logData := models.HabitLog{ID: mux.Vars(r)["logId"], UserID: userID, Value: *req.Value}
+	if req.Timestamp != nil {
+		if err := checkLogTime(*req.Timestamp, time.Now()); err != nil {
+			writeError(w, http.StatusBadRequest, "Data inválida: "+err.Error())
+			return
+		}
+		logData.Timestamp = *req.Timestamp
+	}
+
+	habit, ok := s.editableLog(w, r, userID)
+	if !ok {
+		return
+	}
+	logData.HabitID = habit.ID
+
+	updated, adj, err := s.DBClient.UpdateHabitLog(r.Context(), logData, gamification.HabitLogMana(habit.Goal, logData), editableSince(time.Now()))
+	if !logWriteOK(w, err) {
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Erro ao atualizar registro.")
+		return
+	}
+	s.refreshMana(r, userID, adj)
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"log":             updated,
+		"mana_adjustment": manaAdjustment(adj),
+	})
+}
+
+// HandleDeleteHabitLog apaga um registro da janela de HABIT_LOG_BACKDATE_WINDOW e estorna a
+// mana que ele rendeu.
+func (s *Service) HandleDeleteHabitLog(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado.")
+		return
+	}
+
+	habit, ok := s.editableLog(w, r, userID)
+	if !ok {
+		return
+	}
+	adj, err := s.DBClient.DeleteHabitLog(r.Context(), habit.ID, mux.Vars(r)["logId"], userID, editableSince(time.Now()))
+	if !logWriteOK(w, err) {
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Erro ao excluir registro.")
+		return
+	}
+	s.refreshMana(r, userID, adj)
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"message":         "Registro excluído.",
+		"mana_adjustment": manaAdjustment(adj),
+	})
+}
+
+// manaAdjustment monta o trecho da resposta com a compensação de mana (nulo se não houve).
+func manaAdjustment(adj *db.LogManaAdjustment) map[string]int {
+	if adj == nil {
+		return nil
+	}
+	return map[string]int{"amount": adj.Amount, "new_balance": adj.Balance}
+}
+
+// refreshMana atualiza saldo e leaderboard no cache após um crédito ou compensação (não críticos).
+func (s *Service) refreshMana(r *http.Request, userID string, adj *db.LogManaAdjustment) {
+	if adj == nil {
+		return
+	}
+	if err := s.CacheClient.SetManaBalance(r.Context(), userID, adj.Balance); err != nil {
+		log.Printf("AVISO: Falha ao atualizar cache de Mana: %v", err)
+	}
+	if err := s.CacheClient.UpdateLeaderboard(r.Context(), userID, adj.Balance); err != nil {
+		log.Printf("AVISO: Falha ao atualizar leaderboard: %v", err)
+	}
+}
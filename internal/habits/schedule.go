@@ -0,0 +1,366 @@
+package habits
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Agendas de recorrência =====
+//
+// Uma agenda diz em quais dias (no fuso do usuário) o hábito é devido. Cada dia devido é
+// uma ocorrência com meta de 1 registro; em "N vezes por semana" a ocorrência é a semana
+// (segunda a domingo), com meta de N registros. As semanas começam na segunda, como o
+// WKST padrão da RFC 5545.
+
+const dateLayout = "2006-01-02"
+
+// maxOccurrenceRange limita o intervalo consultado em DueOccurrences.
+const maxOccurrenceRange = 5 * 366 * 24 * time.Hour
+
+var weekdayCodes = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// Occurrence é um período em que o hábito era devido.
+type Occurrence struct {
+	Start  time.Time `json:"start"`  // meia-noite do dia (ou da segunda-feira) no fuso da agenda
+	End    time.Time `json:"end"`    // exclusivo
+	Target int       `json:"target"` // dias cumpridos esperados no período
+}
+
+// NormalizeSchedule valida a agenda e a deixa na forma canônica: converte RRule nos campos
+// estruturados, aplica os padrões (intervalo 1, fuso UTC, início hoje) e ordena as listas.
+// Agendas que não puderem ser interpretadas retornam erro com o motivo.
+func NormalizeSchedule(s *models.HabitSchedule, now time.Time) error {
+	s.Timezone = strings.TrimSpace(s.Timezone)
+	if s.Timezone == "" {
+		s.Timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return fmt.Errorf("fuso horário desconhecido: %q", s.Timezone)
+	}
+
+	start := today(now, loc)
+	if s.StartDate = strings.TrimSpace(s.StartDate); s.StartDate != "" {
+		if start, err = time.ParseInLocation(dateLayout, s.StartDate, loc); err != nil {
+			return errors.New("start_date deve estar no formato AAAA-MM-DD")
+		}
+	}
+	s.StartDate = start.Format(dateLayout)
+
+	if s.RRule = strings.TrimSpace(s.RRule); s.RRule != "" {
+		if s.Kind != "" {
+			return errors.New("envie rrule ou kind, não os dois")
+		}
+		if err := parseRRule(s, start, loc); err != nil {
+			return err
+		}
+		s.RRule = ""
+	}
+
+	if s.EndDate = strings.TrimSpace(s.EndDate); s.EndDate != "" {
+		end, err := time.ParseInLocation(dateLayout, s.EndDate, loc)
+		if err != nil {
+			return errors.New("end_date deve estar no formato AAAA-MM-DD")
+		}
+		if end.Before(start) {
+			return errors.New("end_date não pode ser anterior a start_date")
+		}
+	}
+
+	if s.Interval == 0 {
+		s.Interval = 1
+	}
+	if s.Interval < 1 || s.Interval > 365 {
+		return errors.New("interval deve estar entre 1 e 365")
+	}
+
+	switch s.Kind {
+	case models.ScheduleDaily:
+		s.Weekdays, s.Times, s.MonthDays = nil, 0, nil
+	case models.ScheduleWeekdays:
+		if len(s.Weekdays) == 0 {
+			return errors.New("weekdays é obrigatório (ex: [\"MO\", \"WE\", \"FR\"])")
+		}
+		days := make([]string, 0, len(s.Weekdays))
+		for _, d := range s.Weekdays {
+			d = strings.ToUpper(strings.TrimSpace(d))
+			if _, ok := weekdayCodes[d]; !ok {
+				return fmt.Errorf("dia da semana inválido: %q (use MO, TU, WE, TH, FR, SA ou SU)", d)
+			}
+			if !slices.Contains(days, d) {
+				days = append(days, d)
+			}
+		}
+		slices.SortFunc(days, func(a, b string) int { return isoWeekday(weekdayCodes[a]) - isoWeekday(weekdayCodes[b]) })
+		s.Weekdays, s.Times, s.MonthDays = days, 0, nil
+	case models.ScheduleTimesPerWeek:
+		if s.Times < 1 || s.Times > 7 {
+			return errors.New("times deve estar entre 1 e 7")
+		}
+		if s.Interval != 1 {
+			return errors.New("interval não se aplica a times_per_week")
+		}
+		s.Weekdays, s.MonthDays = nil, nil
+	case models.ScheduleMonthly:
+		if len(s.MonthDays) == 0 {
+			return errors.New("month_days é obrigatório (ex: [1, 15, -1])")
+		}
+		days := make([]int, 0, len(s.MonthDays))
+		for _, d := range s.MonthDays {
+			if d == 0 || d < -31 || d > 31 {
+				return fmt.Errorf("dia do mês inválido: %d (use 1 a 31 ou -1 a -31)", d)
+			}
+			if !slices.Contains(days, d) {
+				days = append(days, d)
+			}
+		}
+		slices.Sort(days)
+		s.MonthDays, s.Weekdays, s.Times = days, nil, 0
+	case "":
+		return errors.New("kind ou rrule é obrigatório")
+	default:
+		return fmt.Errorf("kind inválido: %q (use daily, weekdays, times_per_week ou monthly)", s.Kind)
+	}
+	return nil
+}
+
+// ScheduleFromFrequency cria a agenda equivalente às frequências livres antigas
+// ("" ou "Daily" = todo dia, "Weekly" = uma vez por semana), começando hoje em UTC.
+func ScheduleFromFrequency(frequency string, now time.Time) (*models.HabitSchedule, error) {
+	s := &models.HabitSchedule{}
+	switch strings.ToLower(strings.TrimSpace(frequency)) {
+	case "", "daily":
+		s.Kind = models.ScheduleDaily
+	case "weekly":
+		s.Kind, s.Times = models.ScheduleTimesPerWeek, 1
+	default:
+		return nil, fmt.Errorf("frequência %q não reconhecida: envie schedule", frequency)
+	}
+	if err := NormalizeSchedule(s, now); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// parseRRule converte o subconjunto suportado da RFC 5545 nos campos estruturados:
+// FREQ=DAILY|WEEKLY|MONTHLY, INTERVAL, BYDAY (só WEEKLY, sem ordinal), BYMONTHDAY (só
+// MONTHLY), UNTIL e WKST=MO. O início vem de start_date (DTSTART não é aceito).
+func parseRRule(s *models.HabitSchedule, start time.Time, loc *time.Location) error {
+	rule := strings.TrimPrefix(strings.ToUpper(s.RRule), "RRULE:")
+	parts := map[string]string{}
+	for _, part := range strings.Split(rule, ";") {
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return fmt.Errorf("rrule inválida: %q", part)
+		}
+		if _, dup := parts[key]; dup {
+			return fmt.Errorf("rrule inválida: %s repetido", key)
+		}
+		parts[key] = value
+	}
+
+	for key, value := range parts {
+		switch key {
+		case "FREQ", "BYDAY", "BYMONTHDAY":
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return fmt.Errorf("rrule inválida: INTERVAL=%s", value)
+			}
+			s.Interval = n
+		case "UNTIL":
+			until, err := parseRRuleDate(value, loc)
+			if err != nil {
+				return err
+			}
+			s.EndDate = until.Format(dateLayout)
+		case "WKST":
+			if value != "MO" {
+				return errors.New("rrule: apenas WKST=MO é suportado")
+			}
+		default:
+			return fmt.Errorf("rrule: %s não é suportado", key)
+		}
+	}
+
+	switch parts["FREQ"] {
+	case "DAILY":
+		if parts["BYDAY"] != "" || parts["BYMONTHDAY"] != "" {
+			return errors.New("rrule: FREQ=DAILY não aceita BYDAY nem BYMONTHDAY")
+		}
+		s.Kind = models.ScheduleDaily
+	case "WEEKLY":
+		if parts["BYMONTHDAY"] != "" {
+			return errors.New("rrule: FREQ=WEEKLY não aceita BYMONTHDAY")
+		}
+		s.Kind = models.ScheduleWeekdays
+		s.Weekdays = nil
+		if byDay := parts["BYDAY"]; byDay != "" {
+			s.Weekdays = strings.Split(byDay, ",")
+		} else {
+			s.Weekdays = []string{weekdayCode(start.Weekday())}
+		}
+	case "MONTHLY":
+		if parts["BYDAY"] != "" {
+			return errors.New("rrule: FREQ=MONTHLY só aceita BYMONTHDAY")
+		}
+		s.Kind = models.ScheduleMonthly
+		s.MonthDays = nil
+		if byMonthDay := parts["BYMONTHDAY"]; byMonthDay != "" {
+			for _, v := range strings.Split(byMonthDay, ",") {
+				d, err := strconv.Atoi(v)
+				if err != nil {
+					return fmt.Errorf("rrule inválida: BYMONTHDAY=%s", byMonthDay)
+				}
+				s.MonthDays = append(s.MonthDays, d)
+			}
+		} else {
+			s.MonthDays = []int{start.Day()}
+		}
+	case "":
+		return errors.New("rrule: FREQ é obrigatório")
+	default:
+		return fmt.Errorf("rrule: FREQ=%s não é suportado (use DAILY, WEEKLY ou MONTHLY)", parts["FREQ"])
+	}
+	return nil
+}
+
+// parseRRuleDate lê UNTIL como data (AAAAMMDD) ou instante UTC (AAAAMMDDTHHMMSSZ).
+func parseRRuleDate(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.ParseInLocation("20060102", value, loc); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t.In(loc), nil
+	}
+	return time.Time{}, fmt.Errorf("rrule inválida: UNTIL=%s", value)
+}
+
+// DueOccurrences lista, em ordem, as ocorrências da agenda que se sobrepõem a [from, to).
+// A agenda deve ter passado por NormalizeSchedule.
+func DueOccurrences(s models.HabitSchedule, from, to time.Time) ([]Occurrence, error) {
+	if !to.After(from) {
+		return []Occurrence{}, nil
+	}
+	if to.Sub(from) > maxOccurrenceRange {
+		return nil, errors.New("intervalo longo demais")
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("fuso horário desconhecido: %q", s.Timezone)
+	}
+	start, err := time.ParseInLocation(dateLayout, s.StartDate, loc)
+	if err != nil {
+		return nil, fmt.Errorf("start_date inválida: %q", s.StartDate)
+	}
+	var end time.Time // exclusivo; zero = sem fim
+	if s.EndDate != "" {
+		if end, err = time.ParseInLocation(dateLayout, s.EndDate, loc); err != nil {
+			return nil, fmt.Errorf("end_date inválida: %q", s.EndDate)
+		}
+		end = end.AddDate(0, 0, 1)
+	}
+	interval := max(s.Interval, 1)
+
+	// Começa uma semana antes para pegar a ocorrência semanal que já estava em curso em from.
+	day := today(from, loc).AddDate(0, 0, -7)
+	if day.Before(start) {
+		day = start
+	}
+	out := []Occurrence{}
+	for ; day.Before(to) && (end.IsZero() || day.Before(end)); day = day.AddDate(0, 0, 1) {
+		occ, ok := occurrenceOn(s, interval, start, day)
+		if !ok {
+			continue
+		}
+		if !end.IsZero() && occ.End.After(end) {
+			occ.End = end
+		}
+		if occ.End.After(from) {
+			out = append(out, occ)
+		}
+	}
+	return out, nil
+}
+
+// occurrenceOn informa se alguma ocorrência começa no dia (meia-noite local).
+func occurrenceOn(s models.HabitSchedule, interval int, start, day time.Time) (Occurrence, bool) {
+	next := day.AddDate(0, 0, 1)
+	switch s.Kind {
+	case models.ScheduleDaily:
+		return Occurrence{day, next, 1}, daysBetween(start, day)%interval == 0
+	case models.ScheduleWeekdays:
+		if daysBetween(weekStart(start), weekStart(day))/7%interval != 0 {
+			return Occurrence{}, false
+		}
+		return Occurrence{day, next, 1}, slices.Contains(s.Weekdays, weekdayCode(day.Weekday()))
+	case models.ScheduleTimesPerWeek:
+		// A primeira semana começa em start_date, mesmo que não seja segunda.
+		if day.Weekday() != time.Monday && !day.Equal(start) {
+			return Occurrence{}, false
+		}
+		return Occurrence{day, weekStart(day).AddDate(0, 0, 7), s.Times}, true
+	case models.ScheduleMonthly:
+		months := (day.Year()-start.Year())*12 + int(day.Month()-start.Month())
+		if months%interval != 0 {
+			return Occurrence{}, false
+		}
+		last := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		for _, d := range s.MonthDays {
+			if d < 0 {
+				d = last + d + 1
+			}
+			if d == day.Day() {
+				return Occurrence{day, next, 1}, true
+			}
+		}
+	}
+	return Occurrence{}, false
+}
+
+// today devolve a meia-noite local do dia de t.
+func today(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// daysBetween conta os dias de calendário de a até b (imune ao horário de verão).
+func daysBetween(a, b time.Time) int {
+	ua := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	ub := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(ub.Sub(ua).Hours() / 24)
+}
+
+// weekStart devolve a segunda-feira da semana do dia.
+func weekStart(day time.Time) time.Time {
+	return day.AddDate(0, 0, 1-isoWeekday(day.Weekday()))
+}
+
+// isoWeekday numera os dias de 1 (segunda) a 7 (domingo).
+func isoWeekday(d time.Weekday) int {
+	if d == time.Sunday {
+		return 7
+	}
+	return int(d)
+}
+
+func weekdayCode(d time.Weekday) string {
+	for code, wd := range weekdayCodes {
+		if wd == d {
+			return code
+		}
+	}
+	return ""
+}
@@ -0,0 +1,279 @@
+package habits
+
+import (
+	"slices"
+	"testing"
+	"time"
+	_ "time/tzdata" // fusos dos casos de horário de verão, como em cmd/api
+
+	"go-guardiao-api/pkg/models"
+)
+
+// scheduleNow é o "agora" dos testes de agenda: quarta-feira, 6 de março de 2024.
+var scheduleNow = time.Date(2024, 3, 6, 12, 0, 0, 0, time.UTC)
+
+// mustSchedule normaliza a agenda, falhando o teste se ela for recusada.
+func mustSchedule(t *testing.T, s models.HabitSchedule) models.HabitSchedule {
+	t.Helper()
+	if err := NormalizeSchedule(&s, scheduleNow); err != nil {
+		t.Fatalf("NormalizeSchedule(%+v): %v", s, err)
+	}
+	return s
+}
+
+func mustDate(t *testing.T, s models.HabitSchedule, date string) time.Time {
+	t.Helper()
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := time.ParseInLocation(dateLayout, date, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// startDays devolve o dia de início de cada ocorrência.
+func startDays(occs []Occurrence) []string {
+	out := make([]string, 0, len(occs))
+	for _, o := range occs {
+		out = append(out, o.Start.Format(dateLayout))
+	}
+	return out
+}
+
+func TestNormalizeSchedule(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   models.HabitSchedule
+		want models.HabitSchedule
+	}{
+		{
+			"padrões",
+			models.HabitSchedule{Kind: models.ScheduleDaily, Weekdays: []string{"MO"}},
+			models.HabitSchedule{Kind: models.ScheduleDaily, Interval: 1, StartDate: "2024-03-06", Timezone: "UTC"},
+		},
+		{
+			"dias da semana ordenados e sem repetição",
+			models.HabitSchedule{Kind: models.ScheduleWeekdays, Weekdays: []string{"fr", " mo", "MO", "WE"}, StartDate: "2024-03-04"},
+			models.HabitSchedule{Kind: models.ScheduleWeekdays, Interval: 1, Weekdays: []string{"MO", "WE", "FR"}, StartDate: "2024-03-04", Timezone: "UTC"},
+		},
+		{
+			"N vezes por semana",
+			models.HabitSchedule{Kind: models.ScheduleTimesPerWeek, Times: 3, Timezone: "America/Sao_Paulo"},
+			models.HabitSchedule{Kind: models.ScheduleTimesPerWeek, Interval: 1, Times: 3, StartDate: "2024-03-06", Timezone: "America/Sao_Paulo"},
+		},
+		{
+			"dias do mês ordenados",
+			models.HabitSchedule{Kind: models.ScheduleMonthly, MonthDays: []int{31, -1, 1, 31}},
+			models.HabitSchedule{Kind: models.ScheduleMonthly, Interval: 1, MonthDays: []int{-1, 1, 31}, StartDate: "2024-03-06", Timezone: "UTC"},
+		},
+		{
+			"RRULE semanal com intervalo",
+			models.HabitSchedule{RRule: "RRULE:FREQ=WEEKLY;BYDAY=WE,MO;INTERVAL=2;WKST=MO"},
+			models.HabitSchedule{Kind: models.ScheduleWeekdays, Interval: 2, Weekdays: []string{"MO", "WE"}, StartDate: "2024-03-06", Timezone: "UTC"},
+		},
+		{
+			"RRULE semanal sem BYDAY usa o dia do início",
+			models.HabitSchedule{RRule: "FREQ=WEEKLY", StartDate: "2024-03-08"},
+			models.HabitSchedule{Kind: models.ScheduleWeekdays, Interval: 1, Weekdays: []string{"FR"}, StartDate: "2024-03-08", Timezone: "UTC"},
+		},
+		{
+			"RRULE mensal sem BYMONTHDAY usa o dia do início",
+			models.HabitSchedule{RRule: "freq=monthly", StartDate: "2024-01-31"},
+			models.HabitSchedule{Kind: models.ScheduleMonthly, Interval: 1, MonthDays: []int{31}, StartDate: "2024-01-31", Timezone: "UTC"},
+		},
+		{
+			"RRULE diária com UNTIL em data",
+			models.HabitSchedule{RRule: "FREQ=DAILY;INTERVAL=3;UNTIL=20240331"},
+			models.HabitSchedule{Kind: models.ScheduleDaily, Interval: 3, StartDate: "2024-03-06", EndDate: "2024-03-31", Timezone: "UTC"},
+		},
+		{
+			"UNTIL em UTC vira a data no fuso da agenda",
+			models.HabitSchedule{RRule: "FREQ=DAILY;UNTIL=20240401T020000Z", Timezone: "America/Sao_Paulo"},
+			models.HabitSchedule{Kind: models.ScheduleDaily, Interval: 1, StartDate: "2024-03-06", EndDate: "2024-03-31", Timezone: "America/Sao_Paulo"},
+		},
+	} {
+		got := mustSchedule(t, tc.in)
+		if got.Kind != tc.want.Kind || got.Interval != tc.want.Interval || got.Times != tc.want.Times ||
+			!slices.Equal(got.Weekdays, tc.want.Weekdays) || !slices.Equal(got.MonthDays, tc.want.MonthDays) ||
+			got.StartDate != tc.want.StartDate || got.EndDate != tc.want.EndDate || got.Timezone != tc.want.Timezone || got.RRule != "" {
+			t.Errorf("%s:\n veio     %+v\n esperado %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestNormalizeScheduleRejects(t *testing.T) {
+	for name, s := range map[string]models.HabitSchedule{
+		"sem kind nem rrule":            {},
+		"kind desconhecido":             {Kind: "hourly"},
+		"weekdays vazio":                {Kind: models.ScheduleWeekdays},
+		"dia da semana inválido":        {Kind: models.ScheduleWeekdays, Weekdays: []string{"XX"}},
+		"times zero":                    {Kind: models.ScheduleTimesPerWeek},
+		"times acima de 7":              {Kind: models.ScheduleTimesPerWeek, Times: 8},
+		"intervalo em times_per_week":   {Kind: models.ScheduleTimesPerWeek, Times: 2, Interval: 2},
+		"month_days vazio":              {Kind: models.ScheduleMonthly},
+		"dia do mês zero":               {Kind: models.ScheduleMonthly, MonthDays: []int{0}},
+		"dia do mês 32":                 {Kind: models.ScheduleMonthly, MonthDays: []int{32}},
+		"intervalo acima de 365":        {Kind: models.ScheduleDaily, Interval: 366},
+		"intervalo negativo":            {Kind: models.ScheduleDaily, Interval: -1},
+		"fuso desconhecido":             {Kind: models.ScheduleDaily, Timezone: "Marte/Olympus"},
+		"start_date fora do formato":    {Kind: models.ScheduleDaily, StartDate: "06/03/2024"},
+		"end_date fora do formato":      {Kind: models.ScheduleDaily, EndDate: "31/03/2024"},
+		"end_date antes do início":      {Kind: models.ScheduleDaily, StartDate: "2024-03-06", EndDate: "2024-03-05"},
+		"rrule e kind juntos":           {Kind: models.ScheduleDaily, RRule: "FREQ=DAILY"},
+		"rrule sem FREQ":                {RRule: "BYDAY=MO"},
+		"rrule FREQ não suportada":      {RRule: "FREQ=HOURLY"},
+		"rrule COUNT":                   {RRule: "FREQ=DAILY;COUNT=3"},
+		"rrule WKST diferente de MO":    {RRule: "FREQ=WEEKLY;WKST=SU"},
+		"rrule parte repetida":          {RRule: "FREQ=DAILY;FREQ=WEEKLY"},
+		"rrule INTERVAL zero":           {RRule: "FREQ=DAILY;INTERVAL=0"},
+		"rrule UNTIL inválido":          {RRule: "FREQ=DAILY;UNTIL=amanha"},
+		"rrule parte sem valor":         {RRule: "FREQ=DAILY;INTERVAL"},
+		"rrule diária com BYDAY":        {RRule: "FREQ=DAILY;BYDAY=MO"},
+		"rrule semanal com BYMONTHDAY":  {RRule: "FREQ=WEEKLY;BYMONTHDAY=1"},
+		"rrule mensal com BYDAY":        {RRule: "FREQ=MONTHLY;BYDAY=1MO"},
+		"rrule BYDAY com ordinal":       {RRule: "FREQ=WEEKLY;BYDAY=1MO"},
+		"rrule BYMONTHDAY não numérico": {RRule: "FREQ=MONTHLY;BYMONTHDAY=primeiro"},
+	} {
+		if err := NormalizeSchedule(&s, scheduleNow); err == nil {
+			t.Errorf("%s: deveria ser recusada (%+v)", name, s)
+		}
+	}
+}
+
+func TestDueOccurrences(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		schedule models.HabitSchedule
+		from, to string
+		want     []string
+	}{
+		{
+			"a cada 2 dias",
+			models.HabitSchedule{Kind: models.ScheduleDaily, Interval: 2, StartDate: "2024-03-01"},
+			"2024-02-20", "2024-03-08",
+			[]string{"2024-03-01", "2024-03-03", "2024-03-05", "2024-03-07"},
+		},
+		{
+			"segunda, quarta e sexta em semanas alternadas",
+			models.HabitSchedule{RRule: "FREQ=WEEKLY;BYDAY=MO,WE,FR;INTERVAL=2", StartDate: "2024-03-06"},
+			"2024-03-04", "2024-03-25",
+			[]string{"2024-03-06", "2024-03-08", "2024-03-18", "2024-03-20", "2024-03-22"},
+		},
+		{
+			"dia 31 pula os meses curtos",
+			models.HabitSchedule{Kind: models.ScheduleMonthly, MonthDays: []int{31}, StartDate: "2024-01-01"},
+			"2024-01-01", "2024-06-01",
+			[]string{"2024-01-31", "2024-03-31", "2024-05-31"},
+		},
+		{
+			"último dia do mês",
+			models.HabitSchedule{Kind: models.ScheduleMonthly, MonthDays: []int{-1}, StartDate: "2024-01-01"},
+			"2024-01-01", "2024-05-01",
+			[]string{"2024-01-31", "2024-02-29", "2024-03-31", "2024-04-30"},
+		},
+		{
+			"bimestral conta os meses a partir do início",
+			models.HabitSchedule{RRule: "FREQ=MONTHLY;BYMONTHDAY=15;INTERVAL=2", StartDate: "2024-01-10"},
+			"2024-01-01", "2024-07-01",
+			[]string{"2024-01-15", "2024-03-15", "2024-05-15"},
+		},
+		{
+			"end_date inclusiva",
+			models.HabitSchedule{Kind: models.ScheduleDaily, StartDate: "2024-03-01", EndDate: "2024-03-03"},
+			"2024-03-01", "2024-03-10",
+			[]string{"2024-03-01", "2024-03-02", "2024-03-03"},
+		},
+		{
+			"UNTIL da RRULE",
+			models.HabitSchedule{RRule: "FREQ=WEEKLY;BYDAY=TU;UNTIL=20240319", StartDate: "2024-03-01"},
+			"2024-03-01", "2024-04-01",
+			[]string{"2024-03-05", "2024-03-12", "2024-03-19"},
+		},
+		{
+			"N vezes por semana: a primeira semana começa no início",
+			models.HabitSchedule{Kind: models.ScheduleTimesPerWeek, Times: 3, StartDate: "2024-03-06"},
+			"2024-03-01", "2024-03-19",
+			[]string{"2024-03-06", "2024-03-11", "2024-03-18"},
+		},
+		{
+			"N vezes por semana: semana em curso em from",
+			models.HabitSchedule{Kind: models.ScheduleTimesPerWeek, Times: 2, StartDate: "2024-03-06"},
+			"2024-03-13", "2024-03-14",
+			[]string{"2024-03-11"},
+		},
+	} {
+		s := mustSchedule(t, tc.schedule)
+		occs, err := DueOccurrences(s, mustDate(t, s, tc.from), mustDate(t, s, tc.to))
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if got := startDays(occs); !slices.Equal(got, tc.want) {
+			t.Errorf("%s:\n veio     %v\n esperado %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestDueOccurrencesTimesPerWeekTargets(t *testing.T) {
+	s := mustSchedule(t, models.HabitSchedule{Kind: models.ScheduleTimesPerWeek, Times: 3, StartDate: "2024-03-06", EndDate: "2024-03-13"})
+	occs, err := DueOccurrences(s, mustDate(t, s, "2024-03-01"), mustDate(t, s, "2024-03-31"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ start, end string }{
+		{"2024-03-06", "2024-03-11"}, // de quarta até a segunda seguinte
+		{"2024-03-11", "2024-03-14"}, // cortada pela end_date
+	}
+	if len(occs) != len(want) {
+		t.Fatalf("ocorrências: %+v", occs)
+	}
+	for i, w := range want {
+		if occs[i].Start.Format(dateLayout) != w.start || occs[i].End.Format(dateLayout) != w.end || occs[i].Target != 3 {
+			t.Errorf("semana %d: %+v, esperado %s a %s com meta 3", i, occs[i], w.start, w.end)
+		}
+	}
+}
+
+func TestDueOccurrencesInUserTimezone(t *testing.T) {
+	// Horário de verão em Nova York: 10/03/2024 tem 23 horas e 03/11/2024 tem 25
+	s := mustSchedule(t, models.HabitSchedule{Kind: models.ScheduleDaily, StartDate: "2024-01-01", Timezone: "America/New_York"})
+	for day, hours := range map[string]float64{"2024-03-09": 24, "2024-03-10": 23, "2024-11-03": 25} {
+		start := mustDate(t, s, day)
+		occs, err := DueOccurrences(s, start, start.Add(time.Hour))
+		if err != nil || len(occs) != 1 {
+			t.Fatalf("%s: %+v, %v", day, occs, err)
+		}
+		o := occs[0]
+		if !o.Start.Equal(start) || o.End.Sub(o.Start).Hours() != hours || o.End.In(start.Location()).Hour() != 0 {
+			t.Errorf("%s: ocorrência de %s a %s, esperado %v horas entre meias-noites locais", day, o.Start, o.End, hours)
+		}
+	}
+
+	// Segunda às 22h em São Paulo já é terça em UTC: vale o dia do fuso da agenda
+	monday := mustSchedule(t, models.HabitSchedule{Kind: models.ScheduleWeekdays, Weekdays: []string{"MO"}, StartDate: "2024-03-01", Timezone: "America/Sao_Paulo"})
+	at := time.Date(2024, 3, 5, 1, 0, 0, 0, time.UTC)
+	occs, err := DueOccurrences(monday, at, at.Add(time.Minute))
+	if err != nil || len(occs) != 1 || occs[0].Start.Format(dateLayout) != "2024-03-04" {
+		t.Fatalf("ocorrência no fuso do usuário: %+v, %v", occs, err)
+	}
+	if occs[0].Start.UTC().Hour() != 3 {
+		t.Fatalf("a meia-noite de São Paulo é 03h UTC: %s", occs[0].Start.UTC())
+	}
+}
+
+func TestDueOccurrencesRange(t *testing.T) {
+	s := mustSchedule(t, models.HabitSchedule{Kind: models.ScheduleDaily, StartDate: "2024-03-01"})
+	from := mustDate(t, s, "2024-03-10")
+	if occs, err := DueOccurrences(s, from, from); err != nil || len(occs) != 0 {
+		t.Fatalf("intervalo vazio: %+v, %v", occs, err)
+	}
+	if occs, err := DueOccurrences(s, mustDate(t, s, "2024-01-01"), mustDate(t, s, "2024-03-01")); err != nil || len(occs) != 0 {
+		t.Fatalf("antes do início: %+v, %v", occs, err)
+	}
+	if _, err := DueOccurrences(s, from, from.AddDate(6, 0, 0)); err == nil {
+		t.Fatal("intervalos acima de 5 anos devem ser recusados")
+	}
+}
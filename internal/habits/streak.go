@@ -0,0 +1,114 @@
+package habits
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Sequências =====
+//
+// A sequência conta as ocorrências cumpridas seguidas da agenda (veja goals.go para quando um
+// dia e uma ocorrência estão cumpridos). Uma ocorrência perdida zera a sequência, a menos que
+// um dos seus dias esteja protegido (streak freeze) ou ela caia em um período em que o hábito
+// esteve pausado ou arquivado: nesses casos ela não soma, mas também não quebra. A ocorrência
+// em curso só quebra a sequência quando acabar.
+
+// streakLookbackYears limita o histórico considerado (o mesmo limite de DueOccurrences).
+const streakLookbackYears = 5
+
+// ComputeStreak calcula a sequência do hábito em now a partir dos registros e proteções.
+// A agenda deve ter passado por NormalizeSchedule.
+func ComputeStreak(s models.HabitSchedule, goal models.HabitGoal, logs []models.HabitLog, freezes []models.StreakFreeze, pauses []models.HabitPause, now time.Time) (models.HabitStreak, error) {
+	var streak models.HabitStreak
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return streak, err
+	}
+
+	frozen := make([]time.Time, 0, len(freezes))
+	for _, f := range freezes {
+		if day, err := time.ParseInLocation(dateLayout, f.Day, loc); err == nil {
+			frozen = append(frozen, day)
+		}
+	}
+	slices.SortFunc(frozen, time.Time.Compare)
+
+	to := today(now, loc).AddDate(0, 0, 1)
+	occurrences, err := DueOccurrences(s, now.AddDate(-streakLookbackYears, 0, 0), to)
+	if err != nil {
+		return streak, err
+	}
+	days := aggregateDays(logs, goal, loc, now)
+
+	run := 0
+	for i, occ := range occurrences {
+		p := evaluate(s.Kind, occ, days, goal)
+		protected := countWithin(frozen, occ.Start, occ.End) > 0 || pausedDuring(pauses, occ.Start, occ.End)
+		inProgress := occ.End.After(now)
+		switch {
+		case p.Met:
+			run++
+			if streak.LastCompletion == nil || p.lastCompletion.After(*streak.LastCompletion) {
+				t := p.lastCompletion
+				streak.LastCompletion = &t
+			}
+		case protected, inProgress:
+			// não soma nem quebra
+		default:
+			run = 0
+		}
+		streak.Longest = max(streak.Longest, run)
+		if i == len(occurrences)-1 {
+			streak.AtRisk = inProgress && !p.Met && !protected && run > 0
+		}
+	}
+	streak.Current = run
+	return streak, nil
+}
+
+// freezeCovers informa se o dia (AAAA-MM-DD) cai em uma ocorrência da agenda que já acabou
+// (ou está em curso) sem ter sido cumprida: só esses dias podem ser protegidos.
+func freezeCovers(s models.HabitSchedule, goal models.HabitGoal, logs []models.HabitLog, day string, now time.Time) (bool, error) {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return false, err
+	}
+	start, err := time.ParseInLocation(dateLayout, day, loc)
+	if err != nil {
+		return false, err
+	}
+	occurrences, err := DueOccurrences(s, start, start.AddDate(0, 0, 1))
+	if err != nil || len(occurrences) == 0 {
+		return false, err
+	}
+	days := aggregateDays(logs, goal, loc, now)
+	for _, occ := range occurrences {
+		if occ.Start.After(now) {
+			continue
+		}
+		if !evaluate(s.Kind, occ, days, goal).Met {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// pausedDuring informa se algum período de pausa se sobrepõe a [start, end).
+func pausedDuring(pauses []models.HabitPause, start, end time.Time) bool {
+	for _, p := range pauses {
+		if p.PausedAt.Before(end) && (p.ResumedAt == nil || p.ResumedAt.After(start)) {
+			return true
+		}
+	}
+	return false
+}
+
+// countWithin conta os instantes (em ordem crescente) que caem em [start, end).
+func countWithin(sorted []time.Time, start, end time.Time) int {
+	i := sort.Search(len(sorted), func(k int) bool { return !sorted[k].Before(start) })
+	j := sort.Search(len(sorted), func(k int) bool { return !sorted[k].Before(end) })
+	return j - i
+}
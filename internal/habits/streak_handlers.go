@@ -0,0 +1,234 @@
+package habits
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"go-guardiao-api/internal/auth"
+	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Sequências e proteções (streak freeze) =====
+
+var streakFreezeCost = getEnvInt("STREAK_FREEZE_MANA_COST", 100)
+
+// streakFreezeMaxAgeDays limita quantos dias para trás uma proteção pode cobrir.
+const streakFreezeMaxAgeDays = 7
+
+func getEnvInt(k string, fallback int) int {
+	n, err := strconv.Atoi(os.Getenv(k))
+	if err != nil || n <= 0 {
+		return fallback
+	}
+	return n
+}
+
+// tracksStreak informa se o hábito tem sequência: pausados, arquivados e sem agenda ficam de fora.
+func tracksStreak(h models.Habit) bool {
+	return h.Active() && h.Schedule != nil
+}
+
+// attachStreaks calcula a sequência dos hábitos ativos da lista (uma leitura de registros,
+// proteções e pausas para todos).
+func (s *Service) attachStreaks(r *http.Request, userID string, habits []models.Habit) error {
+	tracked := false
+	for _, h := range habits {
+		tracked = tracked || tracksStreak(h)
+	}
+	if !tracked {
+		return nil
+	}
+	logs, err := s.DBClient.ListHabitLogsByUser(r.Context(), userID)
+	if err != nil {
+		return err
+	}
+	freezes, err := s.DBClient.ListStreakFreezesByUser(r.Context(), userID)
+	if err != nil {
+		return err
+	}
+	pauses, err := s.DBClient.ListHabitPausesByUser(r.Context(), userID)
+	if err != nil {
+		return err
+	}
+	logsByHabit := map[string][]models.HabitLog{}
+	for _, l := range logs {
+		logsByHabit[l.HabitID] = append(logsByHabit[l.HabitID], l)
+	}
+	freezesByHabit := map[string][]models.StreakFreeze{}
+	for _, f := range freezes {
+		freezesByHabit[f.HabitID] = append(freezesByHabit[f.HabitID], f)
+	}
+	pausesByHabit := map[string][]models.HabitPause{}
+	for _, p := range pauses {
+		pausesByHabit[p.HabitID] = append(pausesByHabit[p.HabitID], p)
+	}
+
+	now := time.Now()
+	for i, h := range habits {
+		if !tracksStreak(h) {
+			continue
+		}
+		streak, err := ComputeStreak(*h.Schedule, goalOf(h), logsByHabit[h.ID], freezesByHabit[h.ID], pausesByHabit[h.ID], now)
+		if err != nil {
+			return err
+		}
+		habits[i].Streak = &streak
+	}
+	return nil
+}
+
+// streakResponse é a resposta de GET /habits/{habitId}/streak.
+type streakResponse struct {
+	HabitID string `json:"habit_id"`
+	*models.HabitStreak
+	Freezes    []models.StreakFreeze `json:"freezes"`
+	FreezeCost int                   `json:"freeze_cost"`
+}
+
+// HandleGetHabitStreak mostra a sequência do hábito e as proteções já usadas.
+func (s *Service) HandleGetHabitStreak(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado.")
+		return
+	}
+
+	habit, ok := s.ownedHabit(w, r, userID, mux.Vars(r)["habitId"])
+	if !ok {
+		return
+	}
+	resp, err := s.streakOf(r, habit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Erro ao calcular sequência.")
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// streakOf calcula a sequência de um hábito (sem sequência se não for acompanhado).
+func (s *Service) streakOf(r *http.Request, habit models.Habit) (streakResponse, error) {
+	resp := streakResponse{HabitID: habit.ID, FreezeCost: streakFreezeCost}
+	freezes, err := s.DBClient.ListStreakFreezes(r.Context(), habit.ID)
+	if err != nil {
+		return resp, err
+	}
+	resp.Freezes = freezes
+	if !tracksStreak(habit) {
+		return resp, nil
+	}
+	logs, err := s.DBClient.GetHabitLogs(r.Context(), habit.ID)
+	if err != nil {
+		return resp, err
+	}
+	pauses, err := s.DBClient.ListHabitPauses(r.Context(), habit.ID)
+	if err != nil {
+		return resp, err
+	}
+	streak, err := ComputeStreak(*habit.Schedule, goalOf(habit), logs, freezes, pauses, time.Now())
+	if err != nil {
+		return resp, err
+	}
+	resp.HabitStreak = &streak
+	return resp, nil
+}
+
+// HandleBuyStreakFreeze gasta mana para proteger um dia perdido (ou o de hoje, ainda não
+// cumprido) de um hábito ativo, sem quebrar a sequência.
+// Corpo: { "day": "AAAA-MM-DD" } no fuso da agenda; até streakFreezeMaxAgeDays dias atrás.
+func (s *Service) HandleBuyStreakFreeze(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado.")
+		return
+	}
+
+	var req struct {
+		Day string `json:"day"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Day) == "" {
+		writeError(w, http.StatusBadRequest, "Informe o dia a proteger (day: AAAA-MM-DD).")
+		return
+	}
+
+	habit, ok := s.ownedHabit(w, r, userID, mux.Vars(r)["habitId"])
+	if !ok {
+		return
+	}
+	if !tracksStreak(habit) {
+		writeError(w, http.StatusConflict, "Só hábitos ativos e com agenda têm sequência para proteger.")
+		return
+	}
+	loc, err := time.LoadLocation(habit.Schedule.Timezone)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Agenda do hábito com fuso inválido.")
+		return
+	}
+	day, err := time.ParseInLocation(dateLayout, strings.TrimSpace(req.Day), loc)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Dia inválido (use AAAA-MM-DD).")
+		return
+	}
+	now := time.Now()
+	if day.After(today(now, loc)) || day.Before(today(now, loc).AddDate(0, 0, -streakFreezeMaxAgeDays)) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Só é possível proteger hoje ou um dos últimos %d dias.", streakFreezeMaxAgeDays))
+		return
+	}
+
+	logs, err := s.DBClient.GetHabitLogs(r.Context(), habit.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Erro ao buscar histórico.")
+		return
+	}
+	covers, err := freezeCovers(*habit.Schedule, goalOf(habit), logs, day.Format(dateLayout), now)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Erro ao calcular ocorrências.")
+		return
+	}
+	if !covers {
+		writeError(w, http.StatusConflict, "Não há ocorrência pendente ou perdida nesse dia para proteger.")
+		return
+	}
+
+	freeze := models.StreakFreeze{HabitID: habit.ID, UserID: userID, Day: day.Format(dateLayout), ManaCost: streakFreezeCost}
+	balance, err := s.DBClient.BuyStreakFreeze(r.Context(), freeze)
+	switch {
+	case errors.Is(err, db.ErrInsufficientMana):
+		writeError(w, http.StatusConflict, "Mana insuficiente para comprar a proteção.")
+		return
+	case errors.Is(err, db.ErrStreakFreezeExists):
+		writeError(w, http.StatusConflict, "Esse dia já está protegido.")
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "Falha ao comprar a proteção.")
+		return
+	}
+
+	// Atualiza cache/leaderboard (não críticos)
+	if setErr := s.CacheClient.SetManaBalance(r.Context(), userID, balance); setErr != nil {
+		log.Printf("AVISO: Falha ao atualizar cache de Mana: %v", setErr)
+	}
+	if lbErr := s.CacheClient.UpdateLeaderboard(r.Context(), userID, balance); lbErr != nil {
+		log.Printf("AVISO: Falha ao atualizar leaderboard: %v", lbErr)
+	}
+
+	resp, err := s.streakOf(r, habit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Proteção comprada, mas houve erro ao recalcular a sequência.")
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"message":     "Proteção comprada.",
+		"new_balance": balance,
+		"streak":      resp,
+	})
+}
@@ -0,0 +1,170 @@
+package habits
+
+import (
+	"testing"
+	"time"
+
+	"go-guardiao-api/pkg/models"
+)
+
+// marchLogs gera um registro de valor 1 ao meio-dia (no fuso loc) de cada dia de março de 2024 informado.
+func marchLogs(loc *time.Location, days ...int) []models.HabitLog {
+	logs := make([]models.HabitLog, 0, len(days))
+	for _, d := range days {
+		logs = append(logs, models.HabitLog{Timestamp: time.Date(2024, 3, d, 12, 0, 0, 0, loc), Value: 1})
+	}
+	return logs
+}
+
+func span(from, to int) []int {
+	var days []int
+	for d := from; d <= to; d++ {
+		days = append(days, d)
+	}
+	return days
+}
+
+func TestComputeStreak(t *testing.T) {
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	daily := models.HabitSchedule{Kind: models.ScheduleDaily, StartDate: "2024-03-01"}
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	at := func(month time.Month, day, hour int) *time.Time {
+		t := time.Date(2024, month, day, hour, 0, 0, 0, time.UTC)
+		return &t
+	}
+	// Registros ao meio-dia de 1 a 8 de março em São Paulo e um às 02h UTC de 10/03, que
+	// lá ainda é dia 9
+	lateNight := append(marchLogs(saoPaulo, span(1, 8)...), models.HabitLog{Timestamp: *at(3, 10, 2), Value: 1})
+
+	for _, tc := range []struct {
+		name     string
+		schedule models.HabitSchedule
+		goal     models.HabitGoal
+		logs     []models.HabitLog
+		freezes  []string
+		pauses   []models.HabitPause
+		now      time.Time
+		current  int
+		longest  int
+		atRisk   bool
+	}{
+		{name: "todos os dias cumpridos", schedule: daily, logs: marchLogs(time.UTC, span(1, 10)...), current: 10, longest: 10},
+		{name: "hoje ainda em aberto", schedule: daily, logs: marchLogs(time.UTC, span(1, 9)...), current: 9, longest: 9, atRisk: true},
+		{
+			name: "dia perdido quebra a sequência", schedule: daily,
+			logs:    marchLogs(time.UTC, 1, 2, 3, 4, 5, 7, 8, 9, 10),
+			current: 4, longest: 5,
+		},
+		{
+			name: "dia perdido protegido por freeze", schedule: daily,
+			logs:    marchLogs(time.UTC, 1, 2, 3, 4, 5, 7, 8, 9, 10),
+			freezes: []string{"2024-03-06"},
+			current: 9, longest: 9,
+		},
+		{
+			name: "freeze em outro dia não protege", schedule: daily,
+			logs:    marchLogs(time.UTC, 1, 2, 3, 4, 5, 7, 8, 9, 10),
+			freezes: []string{"2024-03-05"},
+			current: 4, longest: 5,
+		},
+		{
+			name: "lacuna dentro de uma pausa", schedule: daily,
+			logs:    marchLogs(time.UTC, 1, 2, 3, 4, 8, 9, 10),
+			pauses:  []models.HabitPause{{PausedAt: *at(3, 5, 9), ResumedAt: at(3, 7, 18)}},
+			current: 7, longest: 7,
+		},
+		{
+			name: "pausa que ainda dura", schedule: daily,
+			logs:    marchLogs(time.UTC, span(1, 6)...),
+			pauses:  []models.HabitPause{{PausedAt: *at(3, 7, 8)}},
+			current: 6, longest: 6,
+		},
+		{
+			name: "dia do registro no fuso do usuário", schedule: models.HabitSchedule{Kind: models.ScheduleDaily, StartDate: "2024-03-01", Timezone: "America/Sao_Paulo"},
+			logs: lateNight, current: 9, longest: 9, atRisk: true,
+		},
+		{
+			name: "o mesmo registro em UTC cai no dia seguinte", schedule: daily,
+			logs: lateNight, current: 1, longest: 8,
+		},
+		{
+			name: "registros abaixo da meta não contam", schedule: daily,
+			goal: models.HabitGoal{Target: 2, Unit: models.UnitCount, Aggregation: models.AggregateSum},
+			logs: marchLogs(time.UTC, span(1, 10)...),
+		},
+		{
+			name: "dias não devidos não quebram", schedule: models.HabitSchedule{Kind: models.ScheduleWeekdays, Weekdays: []string{"MO", "WE", "FR"}, StartDate: "2024-03-01"},
+			logs:    marchLogs(time.UTC, 1, 4, 6, 8),
+			current: 4, longest: 4, // domingo, 10/03, não é devido
+		},
+		{
+			name: "N vezes por semana", schedule: models.HabitSchedule{Kind: models.ScheduleTimesPerWeek, Times: 2, StartDate: "2024-03-04"},
+			logs: marchLogs(time.UTC, 4, 6, 11, 12), now: time.Date(2024, 3, 13, 12, 0, 0, 0, time.UTC),
+			current: 2, longest: 2,
+		},
+		{
+			name: "semana sem a meta quebra", schedule: models.HabitSchedule{Kind: models.ScheduleTimesPerWeek, Times: 2, StartDate: "2024-03-04"},
+			logs: marchLogs(time.UTC, 4, 11), now: time.Date(2024, 3, 13, 12, 0, 0, 0, time.UTC),
+			atRisk: false, // a semana em curso não tem sequência a perder
+		},
+	} {
+		s := mustSchedule(t, tc.schedule)
+		goal := tc.goal
+		if goal.Target == 0 {
+			goal = defaultGoal
+		}
+		var freezes []models.StreakFreeze
+		for _, day := range tc.freezes {
+			freezes = append(freezes, models.StreakFreeze{Day: day})
+		}
+		when := tc.now
+		if when.IsZero() {
+			when = now
+		}
+		got, err := ComputeStreak(s, goal, tc.logs, freezes, tc.pauses, when)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if got.Current != tc.current || got.Longest != tc.longest || got.AtRisk != tc.atRisk {
+			t.Errorf("%s: atual %d, maior %d, em risco %v; esperado %d, %d, %v",
+				tc.name, got.Current, got.Longest, got.AtRisk, tc.current, tc.longest, tc.atRisk)
+		}
+	}
+}
+
+func TestComputeStreakLastCompletion(t *testing.T) {
+	s := mustSchedule(t, models.HabitSchedule{Kind: models.ScheduleDaily, StartDate: "2024-03-01"})
+	logs := marchLogs(time.UTC, 1, 2)
+	logs = append(logs, models.HabitLog{Timestamp: time.Date(2024, 3, 2, 20, 0, 0, 0, time.UTC), Value: 1})
+	got, err := ComputeStreak(s, defaultGoal, logs, nil, nil, time.Date(2024, 3, 3, 8, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.LastCompletion == nil || !got.LastCompletion.Equal(logs[2].Timestamp) {
+		t.Fatalf("último cumprimento: %v", got.LastCompletion)
+	}
+}
+
+func TestFreezeCovers(t *testing.T) {
+	s := mustSchedule(t, models.HabitSchedule{Kind: models.ScheduleWeekdays, Weekdays: []string{"MO", "WE", "FR"}, StartDate: "2024-03-01"})
+	logs := marchLogs(time.UTC, 4)
+	now := time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC)
+
+	for day, want := range map[string]bool{
+		"2024-03-06": true,  // quarta perdida
+		"2024-03-08": true,  // hoje, ainda não cumprido
+		"2024-03-04": false, // cumprido
+		"2024-03-05": false, // terça não é devida
+		"2024-03-11": false, // futuro
+		"2024-02-28": false, // antes do início
+	} {
+		got, err := freezeCovers(s, defaultGoal, logs, day, now)
+		if err != nil || got != want {
+			t.Errorf("%s: %v, %v; esperado %v", day, got, err, want)
+		}
+	}
+}
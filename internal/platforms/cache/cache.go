@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go-guardiao-api/pkg/models"
+)
+
+// ErrUnavailable indica que o dado só existe no Redis e ele está fora do ar.
+var ErrUnavailable = errors.New("redis indisponível")
+
+// Cache é o cache usado pelos serviços. Implementações: Client (Redis), Memory (LRU em
+// memória, por instância) e Fallback (Redis com Memory como reserva).
+type Cache interface {
+	GetManaBalance(ctx context.Context, userID string) (int, error)
+	SetManaBalance(ctx context.Context, userID string, balance int) error
+	UpdateLeaderboard(ctx context.Context, userID string, mana int) error
+	UpdateLeaderboardBatch(ctx context.Context, entries []models.LeaderboardEntry, ttlSeconds int) error
+	GetLeaderboard(ctx context.Context, limit int64) ([]models.LeaderboardEntry, error)
+	RemoveUser(ctx context.Context, userID string) error
+
+	GetLoginAttempts(ctx context.Context, scope, key string) (models.LoginAttempts, error)
+	RegisterLoginFailure(ctx context.Context, scope, key string, window time.Duration) (models.LoginAttempts, error)
+	LockLogin(ctx context.Context, scope, key string, until time.Time) error
+	ResetLoginAttempts(ctx context.Context, scope, key string) error
+
+	Close()
+}
+
+// Chaves compartilhadas pelas implementações.
+const leaderboardKey = "global_leaderboard"
+
+func manaKey(userID string) string {
+	return "mana:" + userID
+}
+
+var (
+	_ Cache = (*Client)(nil)
+	_ Cache = (*Memory)(nil)
+	_ Cache = (*Fallback)(nil)
+)
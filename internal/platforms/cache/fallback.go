@@ -0,0 +1,284 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"go-guardiao-api/pkg/models"
+)
+
+// DefaultReconnectInterval é o intervalo padrão entre tentativas de reconectar ao Redis.
+const DefaultReconnectInterval = 30 * time.Second
+
+// Fallback usa o Redis enquanto ele responde e passa para um cache em memória quando ele
+// cai (na subida ou no meio de uma operação), tentando reconectar em segundo plano.
+//
+// Saldos e leaderboard alterados durante a queda são apagados do Redis na volta, para que
+// ele não devolva valores anteriores à queda. As tentativas de login não vão para a memória:
+// sem Redis elas retornam ErrUnavailable e o LoginGuard usa o Postgres, que é compartilhado
+// entre as instâncias.
+type Fallback struct {
+	connect  func() (remoteCache, error) // nova conexão com o Redis, usada na reconexão
+	interval time.Duration
+	local    *Memory
+
+	mu     sync.RWMutex
+	remote remoteCache // nil enquanto o Redis está fora
+
+	dirtyMu sync.Mutex
+	dirty   map[string]struct{} // usuários com saldo alterado só na memória
+
+	reconnecting bool // protegido por mu
+	stop         chan struct{}
+	stopOnce     sync.Once
+}
+
+// remoteCache é a parte do Client usada pelo Fallback (os testes usam um Redis falso).
+type remoteCache interface {
+	Cache
+	invalidate(ctx context.Context, userIDs []string) error
+}
+
+// NewFallback conecta ao Redis em addr; se ele não responder, começa pela memória.
+func NewFallback(addr, password string, maxEntries int, interval time.Duration) *Fallback {
+	connect := func() (remoteCache, error) {
+		c, err := dial(addr, password)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	remote, err := NewCacheClient(addr, password)
+	if err != nil {
+		return newFallback(nil, connect, maxEntries, interval)
+	}
+	return newFallback(remote, connect, maxEntries, interval)
+}
+
+// newFallback começa pelo remote informado ou, se ele for nil, pela memória.
+func newFallback(remote remoteCache, connect func() (remoteCache, error), maxEntries int, interval time.Duration) *Fallback {
+	if interval <= 0 {
+		interval = DefaultReconnectInterval
+	}
+	f := &Fallback{
+		connect:  connect,
+		interval: interval,
+		local:    NewMemory(maxEntries),
+		dirty:    map[string]struct{}{},
+		stop:     make(chan struct{}),
+		remote:   remote,
+	}
+	if remote == nil {
+		log.Printf("⚠️ Usando cache em memória até o Redis voltar (nova tentativa a cada %s).", interval)
+		f.mu.Lock()
+		f.startReconnectLocked()
+		f.mu.Unlock()
+	}
+	return f
+}
+
+// Close encerra a reconexão em segundo plano e a conexão com o Redis.
+func (f *Fallback) Close() {
+	f.stopOnce.Do(func() { close(f.stop) })
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.remote != nil {
+		f.remote.Close()
+		f.remote = nil
+	}
+}
+
+// Online informa se as operações estão indo para o Redis.
+func (f *Fallback) Online() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.remote != nil
+}
+
+// startReconnectLocked dispara o laço de reconexão, se ainda não estiver rodando. Chamar com f.mu travado.
+func (f *Fallback) startReconnectLocked() {
+	if f.reconnecting {
+		return
+	}
+	f.reconnecting = true
+	go f.reconnectLoop()
+}
+
+func (f *Fallback) reconnectLoop() {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+		}
+		remote, err := f.connect()
+		if err != nil {
+			continue
+		}
+		if f.promote(remote) {
+			return
+		}
+	}
+}
+
+// promote volta a usar o Redis depois de apagar nele o que mudou durante a queda.
+// Segura f.mu durante a limpeza para que nenhuma escrita caia na memória no meio da troca.
+func (f *Fallback) promote(remote remoteCache) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	select {
+	case <-f.stop:
+		remote.Close()
+		return true
+	default:
+	}
+
+	f.dirtyMu.Lock()
+	ids := make([]string, 0, len(f.dirty))
+	for id := range f.dirty {
+		ids = append(ids, id)
+	}
+	f.dirtyMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := remote.invalidate(ctx, ids); err != nil {
+		log.Printf("AVISO: Redis respondeu, mas a limpeza pós-queda falhou: %v", err)
+		remote.Close()
+		return false
+	}
+
+	f.dirtyMu.Lock()
+	f.dirty = map[string]struct{}{}
+	f.dirtyMu.Unlock()
+	f.local.Flush()
+	f.remote = remote
+	f.reconnecting = false
+	log.Println("Conexão com Redis restabelecida; cache em memória descartado.")
+	return true
+}
+
+// demote passa para a memória após uma falha do Redis (se ninguém já tiver feito isso).
+func (f *Fallback) demote(failed remoteCache, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.remote != failed {
+		return
+	}
+	log.Printf("⚠️ Redis falhou (%v); usando cache em memória até ele voltar.", err)
+	f.remote = nil
+	failed.Close()
+	f.startReconnectLocked()
+}
+
+func (f *Fallback) markDirty(userID string) {
+	f.dirtyMu.Lock()
+	f.dirty[userID] = struct{}{}
+	f.dirtyMu.Unlock()
+}
+
+// redisFailed informa se o erro indica Redis fora do ar. Ausência da chave não conta, nem
+// erros de uma requisição cancelada ou com prazo vencido (ctx.Err() != nil): o problema
+// é do chamador, não do Redis.
+func redisFailed(ctx context.Context, err error) bool {
+	return err != nil && !errors.Is(err, ErrCacheMiss) && ctx.Err() == nil
+}
+
+// run executa onRemote no Redis ou, se ele estiver (ou cair) fora do ar, onLocal na memória.
+// ctx é o contexto da operação, usado para separar falhas do Redis de requisições canceladas.
+func (f *Fallback) run(ctx context.Context, onRemote func(remoteCache) error, onLocal func() error) error {
+	f.mu.RLock()
+	remote := f.remote
+	if remote == nil {
+		defer f.mu.RUnlock()
+		return onLocal()
+	}
+	f.mu.RUnlock()
+
+	err := onRemote(remote)
+	if !redisFailed(ctx, err) {
+		return err
+	}
+	f.demote(remote, err)
+	return f.run(ctx, onRemote, onLocal)
+}
+
+func (f *Fallback) GetManaBalance(ctx context.Context, userID string) (int, error) {
+	var balance int
+	err := f.run(ctx,
+		func(c remoteCache) (err error) { balance, err = c.GetManaBalance(ctx, userID); return err },
+		func() (err error) { balance, err = f.local.GetManaBalance(ctx, userID); return err },
+	)
+	return balance, err
+}
+
+func (f *Fallback) SetManaBalance(ctx context.Context, userID string, balance int) error {
+	return f.run(ctx,
+		func(c remoteCache) error { return c.SetManaBalance(ctx, userID, balance) },
+		func() error { f.markDirty(userID); return f.local.SetManaBalance(ctx, userID, balance) },
+	)
+}
+
+// As escritas no leaderboard não precisam marcar nada: a volta do Redis sempre apaga o placar.
+
+func (f *Fallback) UpdateLeaderboard(ctx context.Context, userID string, mana int) error {
+	return f.run(ctx,
+		func(c remoteCache) error { return c.UpdateLeaderboard(ctx, userID, mana) },
+		func() error { return f.local.UpdateLeaderboard(ctx, userID, mana) },
+	)
+}
+
+func (f *Fallback) UpdateLeaderboardBatch(ctx context.Context, entries []models.LeaderboardEntry, ttlSeconds int) error {
+	return f.run(ctx,
+		func(c remoteCache) error { return c.UpdateLeaderboardBatch(ctx, entries, ttlSeconds) },
+		func() error { return f.local.UpdateLeaderboardBatch(ctx, entries, ttlSeconds) },
+	)
+}
+
+func (f *Fallback) GetLeaderboard(ctx context.Context, limit int64) ([]models.LeaderboardEntry, error) {
+	var entries []models.LeaderboardEntry
+	err := f.run(ctx,
+		func(c remoteCache) (err error) { entries, err = c.GetLeaderboard(ctx, limit); return err },
+		func() (err error) { entries, err = f.local.GetLeaderboard(ctx, limit); return err },
+	)
+	return entries, err
+}
+
+func (f *Fallback) RemoveUser(ctx context.Context, userID string) error {
+	return f.run(ctx,
+		func(c remoteCache) error { return c.RemoveUser(ctx, userID) },
+		func() error { f.markDirty(userID); return f.local.RemoveUser(ctx, userID) },
+	)
+}
+
+// ===== Tentativas de login: só Redis =====
+
+// remoteOnly executa fn no Redis; sem ele, retorna ErrUnavailable.
+func (f *Fallback) remoteOnly(ctx context.Context, fn func(remoteCache) error) error {
+	return f.run(ctx, fn, func() error { return ErrUnavailable })
+}
+
+func (f *Fallback) GetLoginAttempts(ctx context.Context, scope, key string) (models.LoginAttempts, error) {
+	var a models.LoginAttempts
+	err := f.remoteOnly(ctx, func(c remoteCache) (err error) { a, err = c.GetLoginAttempts(ctx, scope, key); return err })
+	return a, err
+}
+
+func (f *Fallback) RegisterLoginFailure(ctx context.Context, scope, key string, window time.Duration) (models.LoginAttempts, error) {
+	var a models.LoginAttempts
+	err := f.remoteOnly(ctx, func(c remoteCache) (err error) { a, err = c.RegisterLoginFailure(ctx, scope, key, window); return err })
+	return a, err
+}
+
+func (f *Fallback) LockLogin(ctx context.Context, scope, key string, until time.Time) error {
+	return f.remoteOnly(ctx, func(c remoteCache) error { return c.LockLogin(ctx, scope, key, until) })
+}
+
+func (f *Fallback) ResetLoginAttempts(ctx context.Context, scope, key string) error {
+	return f.remoteOnly(ctx, func(c remoteCache) error { return c.ResetLoginAttempts(ctx, scope, key) })
+}
@@ -0,0 +1,270 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"go-guardiao-api/pkg/models"
+)
+
+var errRedisDown = errors.New("dial tcp: connection refused")
+
+// fakeRedis faz o papel do Client: guarda os dados num Memory e, com down ligado,
+// falha como um Redis fora do ar. Operações com o contexto cancelado falham com ctx.Err().
+type fakeRedis struct {
+	*Memory
+
+	mu          sync.Mutex
+	down        bool
+	closed      bool
+	invalidated []string
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{Memory: NewMemory(0)}
+}
+
+func (r *fakeRedis) fail(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.down {
+		return errRedisDown
+	}
+	return nil
+}
+
+func (r *fakeRedis) setDown(down bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.down = down
+}
+
+func (r *fakeRedis) GetManaBalance(ctx context.Context, userID string) (int, error) {
+	if err := r.fail(ctx); err != nil {
+		return 0, err
+	}
+	return r.Memory.GetManaBalance(ctx, userID)
+}
+
+func (r *fakeRedis) SetManaBalance(ctx context.Context, userID string, balance int) error {
+	if err := r.fail(ctx); err != nil {
+		return err
+	}
+	return r.Memory.SetManaBalance(ctx, userID, balance)
+}
+
+func (r *fakeRedis) GetLeaderboard(ctx context.Context, limit int64) ([]models.LeaderboardEntry, error) {
+	if err := r.fail(ctx); err != nil {
+		return nil, err
+	}
+	return r.Memory.GetLeaderboard(ctx, limit)
+}
+
+func (r *fakeRedis) RegisterLoginFailure(ctx context.Context, scope, key string, window time.Duration) (models.LoginAttempts, error) {
+	if err := r.fail(ctx); err != nil {
+		return models.LoginAttempts{}, err
+	}
+	return r.Memory.RegisterLoginFailure(ctx, scope, key, window)
+}
+
+func (r *fakeRedis) invalidate(ctx context.Context, userIDs []string) error {
+	if err := r.fail(ctx); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.invalidated = append(r.invalidated, userIDs...)
+	r.mu.Unlock()
+	for _, id := range userIDs {
+		_ = r.Memory.RemoveUser(ctx, id)
+	}
+	r.Memory.mu.Lock()
+	r.Memory.del(leaderboardKey)
+	r.Memory.mu.Unlock()
+	return nil
+}
+
+func (r *fakeRedis) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+}
+
+// offline devolve um connect que sempre falha.
+func offline() (remoteCache, error) { return nil, errRedisDown }
+
+func TestFallbackUsesMemoryWhenRedisFails(t *testing.T) {
+	ctx := context.Background()
+	redis := newFakeRedis()
+	f := newFallback(redis, offline, 0, time.Hour)
+	defer f.Close()
+
+	if err := f.SetManaBalance(ctx, "u1", 10); err != nil || !f.Online() {
+		t.Fatalf("com o Redis no ar: %v, online=%v", err, f.Online())
+	}
+	if got, _ := redis.Memory.GetManaBalance(ctx, "u1"); got != 10 {
+		t.Fatalf("saldo deveria ir para o Redis: %d", got)
+	}
+
+	redis.setDown(true)
+	if err := f.SetManaBalance(ctx, "u1", 20); err != nil {
+		t.Fatalf("a queda no meio da operação deve cair para a memória: %v", err)
+	}
+	if f.Online() || !redis.closed {
+		t.Fatal("após a falha o Redis deve ser fechado e o Fallback ficar na memória")
+	}
+	if got, err := f.GetManaBalance(ctx, "u1"); err != nil || got != 20 {
+		t.Fatalf("saldo lido da memória: %d, %v", got, err)
+	}
+	if _, ok := f.dirty["u1"]; !ok {
+		t.Fatal("saldo alterado na memória deve ser marcado para limpeza")
+	}
+	if _, err := f.RegisterLoginFailure(ctx, "ip", "10.0.0.1", time.Minute); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("tentativas de login sem Redis: esperado ErrUnavailable, veio %v", err)
+	}
+}
+
+func TestFallbackIgnoresCanceledContext(t *testing.T) {
+	redis := newFakeRedis()
+	f := newFallback(redis, offline, 0, time.Hour)
+	defer f.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := f.GetManaBalance(ctx, "u1"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("esperado context.Canceled, veio %v", err)
+	}
+	expired, stop := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer stop()
+	if err := f.SetManaBalance(expired, "u1", 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("esperado context.DeadlineExceeded, veio %v", err)
+	}
+	if !f.Online() || redis.closed {
+		t.Fatal("requisição cancelada não pode derrubar o Redis")
+	}
+}
+
+func TestFallbackPromoteInvalidatesDirtyKeys(t *testing.T) {
+	ctx := context.Background()
+	redis := newFakeRedis()
+	// Valores anteriores à queda, que o Redis devolveria desatualizados
+	_ = redis.Memory.SetManaBalance(ctx, "u1", 100)
+	_ = redis.Memory.SetManaBalance(ctx, "u2", 50)
+	_ = redis.Memory.UpdateLeaderboard(ctx, "u1", 100)
+
+	f := newFallback(nil, offline, 0, time.Hour)
+	defer f.Close()
+	if f.Online() {
+		t.Fatal("sem Redis na subida, o Fallback começa pela memória")
+	}
+	_ = f.SetManaBalance(ctx, "u1", 130)
+	_ = f.UpdateLeaderboard(ctx, "u1", 130)
+
+	redis.setDown(true)
+	if f.promote(redis) || f.Online() {
+		t.Fatal("se a limpeza falha, o Redis não pode voltar a ser usado")
+	}
+	if _, ok := f.dirty["u1"]; !ok {
+		t.Fatal("a limpeza que falhou deve ser refeita na próxima tentativa")
+	}
+
+	redis.setDown(false)
+	if !f.promote(redis) || !f.Online() {
+		t.Fatal("promote deveria voltar a usar o Redis")
+	}
+	if !slices.Equal(redis.invalidated, []string{"u1"}) {
+		t.Fatalf("só o saldo alterado na queda deve ser apagado: %v", redis.invalidated)
+	}
+	if _, err := f.GetManaBalance(ctx, "u1"); !errors.Is(err, ErrCacheMiss) {
+		t.Fatalf("saldo anterior à queda não pode voltar do Redis: %v", err)
+	}
+	if got, err := f.GetManaBalance(ctx, "u2"); err != nil || got != 50 {
+		t.Fatalf("saldo não alterado na queda continua no Redis: %d, %v", got, err)
+	}
+	if entries, _ := f.GetLeaderboard(ctx, 10); len(entries) != 0 {
+		t.Fatalf("o leaderboard é sempre apagado na volta: %+v", entries)
+	}
+	if f.local.Len() != 0 || len(f.dirty) != 0 {
+		t.Fatal("a memória é descartada depois da volta do Redis")
+	}
+}
+
+func TestFallbackReconnects(t *testing.T) {
+	redis := newFakeRedis()
+	connected := make(chan struct{}, 1)
+	f := newFallback(nil, func() (remoteCache, error) {
+		connected <- struct{}{}
+		return redis, nil
+	}, 0, 10*time.Millisecond)
+	defer f.Close()
+
+	select {
+	case <-connected:
+	case <-time.After(time.Second):
+		t.Fatal("o laço de reconexão não tentou conectar")
+	}
+	deadline := time.Now().Add(time.Second)
+	for !f.Online() {
+		if time.Now().After(deadline) {
+			t.Fatal("o Fallback não voltou a usar o Redis")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMemoryEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(2)
+	_ = m.SetManaBalance(ctx, "u1", 1)
+	_ = m.SetManaBalance(ctx, "u2", 2)
+	if _, err := m.GetManaBalance(ctx, "u1"); err != nil { // u1 passa a ser a mais recente
+		t.Fatal(err)
+	}
+	_ = m.SetManaBalance(ctx, "u3", 3)
+
+	if _, err := m.GetManaBalance(ctx, "u2"); !errors.Is(err, ErrCacheMiss) {
+		t.Fatalf("u2 era a menos usada e deveria ter saído: %v", err)
+	}
+	for id, want := range map[string]int{"u1": 1, "u3": 3} {
+		if got, err := m.GetManaBalance(ctx, id); err != nil || got != want {
+			t.Errorf("%s: %d, %v", id, got, err)
+		}
+	}
+	if m.Len() != 2 {
+		t.Fatalf("capacidade: %d chaves", m.Len())
+	}
+}
+
+func TestMemoryExpiresKeys(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(0)
+	m.mu.Lock()
+	m.set(manaKey("u1"), 10, time.Now().Add(-time.Second))
+	m.set(manaKey("u2"), 20, time.Time{})
+	m.mu.Unlock()
+
+	if _, err := m.GetManaBalance(ctx, "u1"); !errors.Is(err, ErrCacheMiss) {
+		t.Fatalf("chave vencida: esperado ErrCacheMiss, veio %v", err)
+	}
+	if got, err := m.GetManaBalance(ctx, "u2"); err != nil || got != 20 {
+		t.Fatalf("chave sem validade: %d, %v", got, err)
+	}
+	if m.Len() != 1 {
+		t.Fatalf("a chave vencida deve sair ao ser lida: %d chaves", m.Len())
+	}
+
+	a, err := m.RegisterLoginFailure(ctx, "ip", "10.0.0.1", 20*time.Millisecond)
+	if err != nil || a.Failures != 1 {
+		t.Fatalf("RegisterLoginFailure: %+v, %v", a, err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if a, _ := m.GetLoginAttempts(ctx, "ip", "10.0.0.1"); a.Failures != 0 {
+		t.Fatalf("falhas fora da janela deveriam expirar: %+v", a)
+	}
+}
@@ -0,0 +1,248 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go-guardiao-api/pkg/models"
+)
+
+// DefaultMemoryEntries é a capacidade padrão do cache em memória.
+const DefaultMemoryEntries = 10000
+
+// Memory é um cache LRU com expiração por chave, local ao processo e seguro para uso
+// concorrente. Guarda as mesmas chaves e validades do Client; ao atingir a capacidade,
+// descarta a chave usada há mais tempo.
+type Memory struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List // frente = uso mais recente
+	items      map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key       string
+	value     any
+	expiresAt time.Time // zero = sem expiração
+}
+
+// loginState espelha o hash login_attempts:* do Redis.
+type loginState struct {
+	failures    int
+	lastFailure int64
+	lockedUntil int64
+}
+
+// NewMemory cria o cache com no máximo maxEntries chaves (DefaultMemoryEntries se <= 0).
+func NewMemory(maxEntries int) *Memory {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMemoryEntries
+	}
+	return &Memory{maxEntries: maxEntries, order: list.New(), items: map[string]*list.Element{}}
+}
+
+func (m *Memory) Close() {}
+
+// Flush descarta todas as chaves.
+func (m *Memory) Flush() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.order.Init()
+	m.items = map[string]*list.Element{}
+}
+
+// Len informa quantas chaves estão guardadas (incluindo expiradas ainda não lidas).
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.items)
+}
+
+// get devolve a entrada viva da chave e a marca como usada. Chamar com m.mu travado.
+func (m *Memory) get(key string) (*memoryEntry, bool) {
+	el, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*memoryEntry)
+	if !e.expiresAt.IsZero() && !time.Now().Before(e.expiresAt) {
+		m.order.Remove(el)
+		delete(m.items, key)
+		return nil, false
+	}
+	m.order.MoveToFront(el)
+	return e, true
+}
+
+// set grava a chave com a validade informada, descartando a menos usada se preciso.
+// Chamar com m.mu travado.
+func (m *Memory) set(key string, value any, expiresAt time.Time) {
+	if el, ok := m.items[key]; ok {
+		e := el.Value.(*memoryEntry)
+		e.value, e.expiresAt = value, expiresAt
+		m.order.MoveToFront(el)
+		return
+	}
+	m.items[key] = m.order.PushFront(&memoryEntry{key: key, value: value, expiresAt: expiresAt})
+	for len(m.items) > m.maxEntries {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.items, oldest.Value.(*memoryEntry).key)
+	}
+}
+
+func (m *Memory) del(key string) {
+	if el, ok := m.items[key]; ok {
+		m.order.Remove(el)
+		delete(m.items, key)
+	}
+}
+
+func (m *Memory) GetManaBalance(ctx context.Context, userID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.get(manaKey(userID))
+	if !ok {
+		return 0, ErrCacheMiss
+	}
+	return e.value.(int), nil
+}
+
+func (m *Memory) SetManaBalance(ctx context.Context, userID string, balance int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.set(manaKey(userID), balance, time.Now().Add(manaTTL))
+	return nil
+}
+
+// leaderboard devolve o placar guardado (ou um novo, sem expiração, como o ZADD do Redis).
+func (m *Memory) leaderboard() map[string]float64 {
+	if e, ok := m.get(leaderboardKey); ok {
+		return e.value.(map[string]float64)
+	}
+	scores := map[string]float64{}
+	m.set(leaderboardKey, scores, time.Time{})
+	return scores
+}
+
+func (m *Memory) UpdateLeaderboard(ctx context.Context, userID string, mana int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.leaderboard()[userID] = float64(mana)
+	return nil
+}
+
+func (m *Memory) UpdateLeaderboardBatch(ctx context.Context, entries []models.LeaderboardEntry, ttlSeconds int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	scores := m.leaderboard()
+	for _, entry := range entries {
+		scores[entry.UserID] = float64(entry.Mana)
+	}
+	m.set(leaderboardKey, scores, time.Now().Add(time.Duration(ttlSeconds)*time.Second))
+	return nil
+}
+
+func (m *Memory) GetLeaderboard(ctx context.Context, limit int64) ([]models.LeaderboardEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.get(leaderboardKey)
+	if !ok {
+		return []models.LeaderboardEntry{}, nil
+	}
+	scores := e.value.(map[string]float64)
+	ids := make([]string, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	// Mesma ordem do ZREVRANGE: maior pontuação primeiro, empate pelo membro em ordem inversa
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] > ids[j]
+	})
+	if limit >= 0 && int64(len(ids)) > limit {
+		ids = ids[:limit]
+	}
+	entries := make([]models.LeaderboardEntry, 0, len(ids))
+	for _, id := range ids {
+		entries = append(entries, models.LeaderboardEntry{
+			UserID:   id,
+			UserName: fmt.Sprintf("User-%s", id), // Mock do nome, como no Redis
+			Mana:     int(scores[id]),
+		})
+	}
+	return entries, nil
+}
+
+func (m *Memory) RemoveUser(ctx context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.del(manaKey(userID))
+	if e, ok := m.get(leaderboardKey); ok {
+		delete(e.value.(map[string]float64), userID)
+	}
+	return nil
+}
+
+func (s loginState) view() models.LoginAttempts {
+	var a models.LoginAttempts
+	a.Failures = s.failures
+	if s.lastFailure != 0 {
+		a.LastFailure = time.Unix(s.lastFailure, 0)
+	}
+	if s.lockedUntil != 0 {
+		a.LockedUntil = time.Unix(s.lockedUntil, 0)
+	}
+	return a
+}
+
+func (m *Memory) GetLoginAttempts(ctx context.Context, scope, key string) (models.LoginAttempts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.get(loginAttemptsKey(scope, key))
+	if !ok {
+		return models.LoginAttempts{}, nil
+	}
+	return e.value.(*loginState).view(), nil
+}
+
+func (m *Memory) RegisterLoginFailure(ctx context.Context, scope, key string, window time.Duration) (models.LoginAttempts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := loginAttemptsKey(scope, key)
+	s := &loginState{}
+	if e, ok := m.get(k); ok {
+		s = e.value.(*loginState)
+	}
+	now := time.Now()
+	s.failures++
+	s.lastFailure = now.Unix()
+	m.set(k, s, now.Add(window))
+	return s.view(), nil
+}
+
+func (m *Memory) LockLogin(ctx context.Context, scope, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := loginAttemptsKey(scope, key)
+	s := &loginState{}
+	if e, ok := m.get(k); ok {
+		s = e.value.(*loginState)
+	}
+	s.lockedUntil = until.Unix()
+	m.set(k, s, until)
+	return nil
+}
+
+func (m *Memory) ResetLoginAttempts(ctx context.Context, scope, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.del(loginAttemptsKey(scope, key))
+	return nil
+}
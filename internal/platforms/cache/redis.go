@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strconv"
 	"time"
 
 	"go-guardiao-api/pkg/models"
@@ -14,11 +15,25 @@ import (
 
 var ErrCacheMiss = errors.New("cache miss")
 
+// manaTTL é a validade do saldo de mana em cache.
+const manaTTL = time.Hour
+
 type Client struct {
 	rdb *redis.Client
 }
 
 func NewCacheClient(addr, password string) (*Client, error) {
+	c, err := dial(addr, password)
+	if err != nil {
+		log.Printf("AVISO: Falha ao conectar ao Redis (Cache está desativado): %v", err)
+		return nil, err
+	}
+	log.Println("Conexão com Redis estabelecida com sucesso.")
+	return c, nil
+}
+
+// dial conecta e confirma com um PING, sem registrar logs (usado também na reconexão).
+func dial(addr, password string) (*Client, error) {
 	rdb := redis.NewClient(&redis.Options{
 		Addr:     addr,
 		Password: password,
@@ -29,11 +44,9 @@ func NewCacheClient(addr, password string) (*Client, error) {
 	defer cancel()
 
 	if _, err := rdb.Ping(ctx).Result(); err != nil {
-		log.Printf("AVISO: Falha ao conectar ao Redis (Cache está desativado): %v", err)
+		_ = rdb.Close()
 		return nil, err
 	}
-
-	log.Println("Conexão com Redis estabelecida com sucesso.")
 	return &Client{rdb: rdb}, nil
 }
 
@@ -51,8 +64,7 @@ func (c *Client) GetManaBalance(ctx context.Context, userID string) (int, error)
 	if c == nil || c.rdb == nil {
 		return 0, errors.New("redis client não está conectado")
 	}
-	key := fmt.Sprintf("mana:%s", userID)
-	val, err := c.rdb.Get(ctx, key).Int()
+	val, err := c.rdb.Get(ctx, manaKey(userID)).Int()
 	if errors.Is(err, redis.Nil) {
 		return 0, ErrCacheMiss
 	}
@@ -66,8 +78,7 @@ func (c *Client) SetManaBalance(ctx context.Context, userID string, balance int)
 	if c == nil || c.rdb == nil {
 		return errors.New("redis client não está conectado")
 	}
-	key := fmt.Sprintf("mana:%s", userID)
-	return c.rdb.Set(ctx, key, balance, 1*time.Hour).Err()
+	return c.rdb.Set(ctx, manaKey(userID), balance, manaTTL).Err()
 }
 
 // UpdateLeaderboard Atualiza ou adiciona um único usuário ao leaderboard (usado em updates individuais)
@@ -75,8 +86,7 @@ func (c *Client) UpdateLeaderboard(ctx context.Context, userID string, mana int)
 	if c == nil || c.rdb == nil {
 		return fmt.Errorf("redis client não está conectado")
 	}
-	key := "global_leaderboard"
-	return c.rdb.ZAdd(ctx, key, redis.Z{
+	return c.rdb.ZAdd(ctx, leaderboardKey, redis.Z{
 		Score:  float64(mana),
 		Member: userID,
 	}).Err()
@@ -87,7 +97,6 @@ func (c *Client) UpdateLeaderboardBatch(ctx context.Context, entries []models.Le
 	if c == nil || c.rdb == nil {
 		return fmt.Errorf("redis client não está conectado")
 	}
-	key := "global_leaderboard"
 	zs := make([]redis.Z, len(entries))
 	for i, entry := range entries {
 		zs[i] = redis.Z{
@@ -96,8 +105,8 @@ func (c *Client) UpdateLeaderboardBatch(ctx context.Context, entries []models.Le
 		}
 	}
 	pipe := c.rdb.Pipeline()
-	pipe.ZAdd(ctx, key, zs...)
-	pipe.Expire(ctx, key, time.Duration(ttlSeconds)*time.Second)
+	pipe.ZAdd(ctx, leaderboardKey, zs...)
+	pipe.Expire(ctx, leaderboardKey, time.Duration(ttlSeconds)*time.Second)
 	_, err := pipe.Exec(ctx)
 	return err
 }
@@ -106,8 +115,7 @@ func (c *Client) GetLeaderboard(ctx context.Context, limit int64) ([]models.Lead
 	if c == nil || c.rdb == nil {
 		return nil, errors.New("redis client não está conectado")
 	}
-	key := "global_leaderboard"
-	results, err := c.rdb.ZRevRangeWithScores(ctx, key, 0, limit-1).Result()
+	results, err := c.rdb.ZRevRangeWithScores(ctx, leaderboardKey, 0, limit-1).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -125,3 +133,97 @@ func (c *Client) GetLeaderboard(ctx context.Context, limit int64) ([]models.Lead
 	}
 	return entries, nil
 }
+
+// RemoveUser apaga o saldo em cache e a posição do usuário no leaderboard (exclusão de conta).
+func (c *Client) RemoveUser(ctx context.Context, userID string) error {
+	if c == nil || c.rdb == nil {
+		return errors.New("redis client não está conectado")
+	}
+	pipe := c.rdb.Pipeline()
+	pipe.Del(ctx, manaKey(userID))
+	pipe.ZRem(ctx, leaderboardKey, userID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// invalidate apaga os saldos informados e o leaderboard (dados que mudaram enquanto o
+// Redis estava fora e que ele guardaria desatualizados).
+func (c *Client) invalidate(ctx context.Context, userIDs []string) error {
+	pipe := c.rdb.Pipeline()
+	for _, id := range userIDs {
+		pipe.Del(ctx, manaKey(id))
+	}
+	pipe.Del(ctx, leaderboardKey)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ===== Tentativas de login (proteção contra força bruta) =====
+
+func loginAttemptsKey(scope, key string) string {
+	return fmt.Sprintf("login_attempts:%s:%s", scope, key)
+}
+
+// GetLoginAttempts lê o contador de falhas e o bloqueio de uma conta/IP.
+func (c *Client) GetLoginAttempts(ctx context.Context, scope, key string) (models.LoginAttempts, error) {
+	if c == nil || c.rdb == nil {
+		return models.LoginAttempts{}, errors.New("redis client não está conectado")
+	}
+	vals, err := c.rdb.HGetAll(ctx, loginAttemptsKey(scope, key)).Result()
+	if err != nil {
+		return models.LoginAttempts{}, err
+	}
+	return parseLoginAttempts(vals), nil
+}
+
+// RegisterLoginFailure incrementa o contador de falhas; o registro expira após window sem novas falhas.
+func (c *Client) RegisterLoginFailure(ctx context.Context, scope, key string, window time.Duration) (models.LoginAttempts, error) {
+	if c == nil || c.rdb == nil {
+		return models.LoginAttempts{}, errors.New("redis client não está conectado")
+	}
+	k := loginAttemptsKey(scope, key)
+	pipe := c.rdb.TxPipeline()
+	pipe.HIncrBy(ctx, k, "failures", 1)
+	pipe.HSet(ctx, k, "last_failure", time.Now().Unix())
+	pipe.Expire(ctx, k, window)
+	all := pipe.HGetAll(ctx, k)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return models.LoginAttempts{}, err
+	}
+	return parseLoginAttempts(all.Val()), nil
+}
+
+// LockLogin bloqueia a conta/IP até o instante informado.
+func (c *Client) LockLogin(ctx context.Context, scope, key string, until time.Time) error {
+	if c == nil || c.rdb == nil {
+		return errors.New("redis client não está conectado")
+	}
+	k := loginAttemptsKey(scope, key)
+	pipe := c.rdb.TxPipeline()
+	pipe.HSet(ctx, k, "locked_until", until.Unix())
+	pipe.ExpireAt(ctx, k, until)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ResetLoginAttempts zera falhas e bloqueio (login bem-sucedido ou desbloqueio manual).
+func (c *Client) ResetLoginAttempts(ctx context.Context, scope, key string) error {
+	if c == nil || c.rdb == nil {
+		return errors.New("redis client não está conectado")
+	}
+	return c.rdb.Del(ctx, loginAttemptsKey(scope, key)).Err()
+}
+
+func parseLoginAttempts(vals map[string]string) models.LoginAttempts {
+	var a models.LoginAttempts
+	if v, err := strconv.Atoi(vals["failures"]); err == nil {
+		a.Failures = v
+	}
+	if v, err := strconv.ParseInt(vals["last_failure"], 10, 64); err == nil {
+		a.LastFailure = time.Unix(v, 0)
+	}
+	if v, err := strconv.ParseInt(vals["locked_until"], 10, 64); err == nil {
+		a.LockedUntil = time.Unix(v, 0)
+	}
+	return a
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ScheduleAccountDeletion agenda a exclusão da conta para o instante informado.
+func (c *Client) ScheduleAccountDeletion(ctx context.Context, userID string, at time.Time) error {
+	const q = `UPDATE users SET deletion_requested_at = NOW(), deletion_scheduled_at = $2 WHERE id = $1`
+	cmdTag, err := c.pool.Exec(ctx, q, userID, at)
+	if err != nil {
+		return fmt.Errorf("falha ao agendar exclusão da conta: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// CancelAccountDeletion desfaz o agendamento. Retorna pgx.ErrNoRows se não houver exclusão pendente.
+func (c *Client) CancelAccountDeletion(ctx context.Context, userID string) error {
+	const q = `
+       UPDATE users SET deletion_requested_at = NULL, deletion_scheduled_at = NULL
+       WHERE id = $1 AND deletion_scheduled_at IS NOT NULL`
+	cmdTag, err := c.pool.Exec(ctx, q, userID)
+	if err != nil {
+		return fmt.Errorf("falha ao cancelar exclusão da conta: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// ListDueAccountDeletions lista as contas cujo prazo de carência já terminou.
+func (c *Client) ListDueAccountDeletions(ctx context.Context, limit int) ([]string, error) {
+	const q = `SELECT id FROM users WHERE deletion_scheduled_at <= NOW() ORDER BY deletion_scheduled_at LIMIT $1`
+	rows, err := c.pool.Query(ctx, q, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// PurgeUser apaga definitivamente a conta cuja exclusão venceu. Hábitos, registros,
+// rede de apoio e demais dados pessoais são removidos; as transações de mana e a trilha de
+// auditoria são mantidas sem vínculo com a pessoa (ver AnonymizeAuditEvents).
+// Retorna pgx.ErrNoRows se a exclusão foi cancelada ou outra instância já a processou.
+func (c *Client) PurgeUser(ctx context.Context, userID string) error {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("falha ao iniciar transação: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	// O índice cego do e-mail é também a chave das tentativas de login da conta
+	var emailIndex string
+	const lock = `SELECT COALESCE(email_bidx, '') FROM users WHERE id = $1 AND deletion_scheduled_at <= NOW() FOR UPDATE SKIP LOCKED`
+	if err = tx.QueryRow(ctx, lock, userID).Scan(&emailIndex); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return pgx.ErrNoRows
+		}
+		return fmt.Errorf("falha ao bloquear conta para exclusão: %w", err)
+	}
+
+	steps := []struct {
+		what, q string
+		arg     any
+	}{
+		{"anonimizar transações de mana", `UPDATE mana_transactions SET user_id = NULL, reference_id = NULL WHERE user_id = $1`, userID},
+		{"remover registros de hábitos", `DELETE FROM habit_logs WHERE user_id = $1 OR habit_id IN (SELECT id FROM habits WHERE user_id = $1)`, userID},
+		{"remover hábitos", `DELETE FROM habits WHERE user_id = $1`, userID},
+		{"remover rede de apoio", `DELETE FROM support_contacts WHERE user_id = $1`, userID},
+		{"remover histórico de bloqueios", `DELETE FROM login_lockouts WHERE user_id = $1`, userID},
+		{"remover tentativas de login", `DELETE FROM login_attempts WHERE scope = 'account' AND key = $1`, emailIndex},
+		{"remover usuário", `DELETE FROM users WHERE id = $1`, userID},
+	}
+	for _, s := range steps {
+		if _, err = tx.Exec(ctx, s.q, s.arg); err != nil {
+			return fmt.Errorf("falha ao %s: %w", s.what, err)
+		}
+	}
+	if _, err = anonymizeAuditEvents(ctx, tx, userID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"go-guardiao-api/pkg/models"
+)
+
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, scopes, created_at, last_used_at, expires_at, revoked_at`
+
+func scanAPIKey(row pgx.Row) (models.APIKey, error) {
+	k := models.APIKey{}
+	err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.KeyHash, &k.Scopes, &k.CreatedAt, &k.LastUsedAt, &k.ExpiresAt, &k.RevokedAt)
+	return k, err
+}
+
+// CreateAPIKey grava a chave (apenas o hash) e devolve o ID gerado.
+func (c *Client) CreateAPIKey(ctx context.Context, k models.APIKey) (string, error) {
+	if strings.TrimSpace(k.ID) == "" {
+		k.ID = uuid.New().String()
+	}
+	const q = `
+       INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, expires_at)
+       VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	if _, err := c.pool.Exec(ctx, q, k.ID, k.UserID, strings.TrimSpace(k.Name), k.Prefix, k.KeyHash, k.Scopes, k.ExpiresAt); err != nil {
+		return "", fmt.Errorf("falha ao criar chave de API: %w", err)
+	}
+	return k.ID, nil
+}
+
+// GetAPIKeyByPrefix busca a chave pelo prefixo. Retorna pgx.ErrNoRows se não existir.
+func (c *Client) GetAPIKeyByPrefix(ctx context.Context, prefix string) (models.APIKey, error) {
+	q := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE prefix = $1`
+	return scanAPIKey(c.pool.QueryRow(ctx, q, prefix))
+}
+
+// ListAPIKeys lista as chaves não revogadas do usuário.
+func (c *Client) ListAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	q := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE user_id = $1 AND revoked_at IS NULL ORDER BY created_at DESC`
+	rows, err := c.pool.Query(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// TouchAPIKey registra o último uso da chave.
+func (c *Client) TouchAPIKey(ctx context.Context, keyID string) error {
+	if _, err := c.pool.Exec(ctx, `UPDATE api_keys SET last_used_at = NOW() WHERE id = $1`, keyID); err != nil {
+		return fmt.Errorf("falha ao atualizar uso da chave de API: %w", err)
+	}
+	return nil
+}
+
+// RevokeAPIKey revoga a chave garantindo que pertence ao userID.
+// Retorna pgx.ErrNoRows se não existir, for de outro usuário ou já estiver revogada.
+func (c *Client) RevokeAPIKey(ctx context.Context, userID, keyID string) error {
+	const q = `UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+	cmdTag, err := c.pool.Exec(ctx, q, keyID, userID)
+	if err != nil {
+		return fmt.Errorf("falha ao revogar chave de API: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"go-guardiao-api/pkg/models"
+)
+
+// AuditEventFilter restringe a consulta da trilha de auditoria. Campos vazios não filtram.
+type AuditEventFilter struct {
+	UserID     string
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	IP         string
+	From       time.Time // inclusive
+	To         time.Time // exclusive
+}
+
+// CreateAuditEvent grava um evento na trilha de auditoria.
+func (c *Client) CreateAuditEvent(ctx context.Context, ev models.AuditEvent) error {
+	var changes, metadata []byte // nil grava NULL
+	var err error
+	if len(ev.Changes) > 0 {
+		if changes, err = json.Marshal(ev.Changes); err != nil {
+			return fmt.Errorf("falha ao serializar alterações do evento: %w", err)
+		}
+	}
+	if len(ev.Metadata) > 0 {
+		if metadata, err = json.Marshal(ev.Metadata); err != nil {
+			return fmt.Errorf("falha ao serializar metadados do evento: %w", err)
+		}
+	}
+	if len(ev.UserAgent) > 512 {
+		ev.UserAgent = ev.UserAgent[:512]
+	}
+	const q = `
+       INSERT INTO audit_events (actor_id, user_id, action, target_type, target_id, ip, user_agent, changes, metadata)
+       VALUES (NULLIF($1, '')::uuid, NULLIF($2, '')::uuid, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), $8, $9)`
+	if _, err := c.pool.Exec(ctx, q, ev.ActorID, ev.UserID, ev.Action, ev.TargetType, ev.TargetID, ev.IP,
+		ev.UserAgent, changes, metadata); err != nil {
+		return fmt.Errorf("falha ao registrar evento de auditoria: %w", err)
+	}
+	return nil
+}
+
+// ListAuditEvents lista os eventos mais recentes que atendem ao filtro.
+func (c *Client) ListAuditEvents(ctx context.Context, f AuditEventFilter, limit, offset int) ([]models.AuditEvent, error) {
+	var (
+		where []string
+		args  []any
+	)
+	add := func(cond string, v any) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if f.UserID != "" {
+		add("user_id = $%d::uuid", f.UserID)
+	}
+	if f.ActorID != "" {
+		add("actor_id = $%d::uuid", f.ActorID)
+	}
+	if f.Action != "" {
+		add("action = $%d", f.Action)
+	}
+	if f.TargetType != "" {
+		add("target_type = $%d", f.TargetType)
+	}
+	if f.TargetID != "" {
+		add("target_id = $%d", f.TargetID)
+	}
+	if f.IP != "" {
+		add("ip = $%d", f.IP)
+	}
+	if !f.From.IsZero() {
+		add("created_at >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("created_at < $%d", f.To)
+	}
+
+	q := `
+       SELECT id, COALESCE(actor_id::text, ''), COALESCE(user_id::text, ''), action, COALESCE(target_type, ''), COALESCE(target_id, ''),
+              COALESCE(ip, ''), COALESCE(user_agent, ''), changes, metadata, created_at, redacted_at
+       FROM audit_events`
+	if len(where) > 0 {
+		q += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	args = append(args, limit, offset)
+	q += fmt.Sprintf(` ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+	rows, err := c.pool.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		ev := models.AuditEvent{}
+		var changes, metadata []byte
+		if err := rows.Scan(&ev.ID, &ev.ActorID, &ev.UserID, &ev.Action, &ev.TargetType, &ev.TargetID,
+			&ev.IP, &ev.UserAgent, &changes, &metadata, &ev.CreatedAt, &ev.RedactedAt); err != nil {
+			return nil, err
+		}
+		if len(changes) > 0 {
+			if err := json.Unmarshal(changes, &ev.Changes); err != nil {
+				return nil, err
+			}
+		}
+		if len(metadata) > 0 {
+			if err := json.Unmarshal(metadata, &ev.Metadata); err != nil {
+				return nil, err
+			}
+		}
+		events = append(events, ev)
+	}
+	return events, rows.Err()
+}
+
+// AnonymizeAuditEvents desvincula da pessoa os eventos em que ela é titular ou autora
+// (ver redact_audit_events nas migrações 0007 e 0011): a trilha só aceita essa alteração,
+// feita pelo papel guardiao_audit_redactor.
+// Retorna quantos eventos foram anonimizados.
+func (c *Client) AnonymizeAuditEvents(ctx context.Context, userID string) (int, error) {
+	return anonymizeAuditEvents(ctx, c.pool, userID)
+}
+
+// anonymizeAuditEvents roda a anonimização no pool ou dentro de uma transação.
+func anonymizeAuditEvents(ctx context.Context, q interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}, userID string) (int, error) {
+	var n int
+	if err := q.QueryRow(ctx, `SELECT redact_audit_events($1::uuid)`, userID).Scan(&n); err != nil {
+		return 0, fmt.Errorf("falha ao anonimizar trilha de auditoria: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"go-guardiao-api/pkg/models"
+)
+
+const consentColumns = `id, user_id, purpose, granted, terms_version, COALESCE(ip, ''), COALESCE(user_agent, ''), created_at`
+
+// RecordConsents grava as concessões/revogações de uma só vez.
+func (c *Client) RecordConsents(ctx context.Context, records []models.ConsentRecord) error {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("falha ao iniciar transação: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	const q = `
+       INSERT INTO consent_records (user_id, purpose, granted, terms_version, ip, user_agent)
+       VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''))`
+	for _, rec := range records {
+		if len(rec.UserAgent) > 512 {
+			rec.UserAgent = rec.UserAgent[:512]
+		}
+		if _, err = tx.Exec(ctx, q, rec.UserID, rec.Purpose, rec.Granted, rec.TermsVersion, rec.IP, rec.UserAgent); err != nil {
+			return fmt.Errorf("falha ao registrar consentimento: %w", err)
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// GetCurrentConsents devolve o registro mais recente de cada finalidade do usuário.
+func (c *Client) GetCurrentConsents(ctx context.Context, userID string) (map[string]models.ConsentRecord, error) {
+	q := `
+       SELECT DISTINCT ON (purpose) ` + consentColumns + `
+       FROM consent_records WHERE user_id = $1
+       ORDER BY purpose, id DESC`
+	records, err := c.queryConsents(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[string]models.ConsentRecord, len(records))
+	for _, rec := range records {
+		current[rec.Purpose] = rec
+	}
+	return current, nil
+}
+
+// HasConsent informa se o registro mais recente da finalidade é uma concessão na versão dos termos informada.
+func (c *Client) HasConsent(ctx context.Context, userID, purpose, termsVersion string) (bool, error) {
+	const q = `
+       SELECT COALESCE((
+          SELECT granted AND terms_version = $3
+          FROM consent_records WHERE user_id = $1 AND purpose = $2
+          ORDER BY id DESC LIMIT 1
+       ), FALSE)`
+	var ok bool
+	if err := c.pool.QueryRow(ctx, q, userID, purpose, termsVersion).Scan(&ok); err != nil {
+		return false, fmt.Errorf("falha ao consultar consentimento: %w", err)
+	}
+	return ok, nil
+}
+
+// ListConsentHistory lista todas as concessões e revogações do usuário, da mais recente para a mais antiga.
+func (c *Client) ListConsentHistory(ctx context.Context, userID string) ([]models.ConsentRecord, error) {
+	q := `SELECT ` + consentColumns + ` FROM consent_records WHERE user_id = $1 ORDER BY id DESC`
+	return c.queryConsents(ctx, q, userID)
+}
+
+func (c *Client) queryConsents(ctx context.Context, q string, args ...any) ([]models.ConsentRecord, error) {
+	rows, err := c.pool.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []models.ConsentRecord
+	for rows.Next() {
+		rec := models.ConsentRecord{}
+		if err := rows.Scan(&rec.ID, &rec.UserID, &rec.Purpose, &rec.Granted, &rec.TermsVersion, &rec.IP, &rec.UserAgent, &rec.CreatedAt); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
@@ -0,0 +1,191 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"go-guardiao-api/pkg/models"
+)
+
+// ErrExportInProgress indica que o usuário já tem uma exportação pendente ou em andamento.
+var ErrExportInProgress = errors.New("exportação já em andamento")
+
+const dataExportColumns = `id, COALESCE(user_id::text, ''), status, COALESCE(storage_key, ''), COALESCE(error, ''), created_at, completed_at, expires_at`
+
+func scanDataExport(row pgx.Row) (models.DataExport, error) {
+	e := models.DataExport{}
+	err := row.Scan(&e.ID, &e.UserID, &e.Status, &e.StorageKey, &e.Error, &e.CreatedAt, &e.CompletedAt, &e.ExpiresAt)
+	return e, err
+}
+
+// CreateDataExport enfileira uma exportação para o usuário.
+// Retorna ErrExportInProgress se já houver uma pendente ou em andamento.
+func (c *Client) CreateDataExport(ctx context.Context, userID string) (models.DataExport, error) {
+	q := `
+       INSERT INTO data_exports (id, user_id, status)
+       SELECT $1, $2, $3
+       WHERE NOT EXISTS (SELECT 1 FROM data_exports WHERE user_id = $2 AND status IN ($3, $4))
+       RETURNING ` + dataExportColumns
+	e, err := scanDataExport(c.pool.QueryRow(ctx, q, uuid.New().String(), userID, models.ExportPending, models.ExportRunning))
+	var pgErr *pgconn.PgError
+	if errors.Is(err, pgx.ErrNoRows) || (errors.As(err, &pgErr) && pgErr.Code == "23505") {
+		return models.DataExport{}, ErrExportInProgress
+	}
+	if err != nil {
+		return models.DataExport{}, fmt.Errorf("falha ao criar exportação: %w", err)
+	}
+	return e, nil
+}
+
+// GetDataExport busca a exportação. Retorna pgx.ErrNoRows se não existir.
+func (c *Client) GetDataExport(ctx context.Context, exportID string) (models.DataExport, error) {
+	q := `SELECT ` + dataExportColumns + ` FROM data_exports WHERE id = $1`
+	return scanDataExport(c.pool.QueryRow(ctx, q, exportID))
+}
+
+// ListDataExports lista as exportações do usuário, da mais recente para a mais antiga.
+func (c *Client) ListDataExports(ctx context.Context, userID string) ([]models.DataExport, error) {
+	q := `SELECT ` + dataExportColumns + ` FROM data_exports WHERE user_id = $1 ORDER BY created_at DESC`
+	return c.queryDataExports(ctx, q, userID)
+}
+
+// ClaimDataExport marca a exportação pendente mais antiga como em andamento e a devolve.
+// Exportações em andamento há mais de staleAfter (instância caiu no meio) voltam para a fila.
+// Retorna pgx.ErrNoRows se a fila estiver vazia.
+func (c *Client) ClaimDataExport(ctx context.Context, staleAfter time.Duration) (models.DataExport, error) {
+	q := `
+       UPDATE data_exports SET status = $1, started_at = NOW()
+       WHERE id = (
+          SELECT id FROM data_exports
+          WHERE user_id IS NOT NULL
+            AND (status = $2 OR (status = $1 AND started_at < $3))
+          ORDER BY created_at LIMIT 1 FOR UPDATE SKIP LOCKED
+       )
+       RETURNING ` + dataExportColumns
+	return scanDataExport(c.pool.QueryRow(ctx, q, models.ExportRunning, models.ExportPending, time.Now().Add(-staleAfter)))
+}
+
+// CompleteDataExport registra o arquivo gerado e até quando ele fica disponível.
+func (c *Client) CompleteDataExport(ctx context.Context, exportID, storageKey string, expiresAt time.Time) error {
+	const q = `UPDATE data_exports SET status = $2, storage_key = $3, completed_at = NOW(), expires_at = $4 WHERE id = $1`
+	if _, err := c.pool.Exec(ctx, q, exportID, models.ExportReady, storageKey, expiresAt); err != nil {
+		return fmt.Errorf("falha ao concluir exportação: %w", err)
+	}
+	return nil
+}
+
+// FailDataExport marca a exportação como falha.
+func (c *Client) FailDataExport(ctx context.Context, exportID, reason string) error {
+	if len(reason) > 255 {
+		reason = reason[:255]
+	}
+	const q = `UPDATE data_exports SET status = $2, error = $3, completed_at = NOW() WHERE id = $1`
+	if _, err := c.pool.Exec(ctx, q, exportID, models.ExportFailed, reason); err != nil {
+		return fmt.Errorf("falha ao registrar erro da exportação: %w", err)
+	}
+	return nil
+}
+
+// ListStaleDataExports lista exportações vencidas ou de contas excluídas, para apagar o arquivo.
+func (c *Client) ListStaleDataExports(ctx context.Context, limit int) ([]models.DataExport, error) {
+	q := `SELECT ` + dataExportColumns + ` FROM data_exports WHERE expires_at < NOW() OR user_id IS NULL ORDER BY created_at LIMIT $1`
+	return c.queryDataExports(ctx, q, limit)
+}
+
+// DeleteDataExport remove o registro da exportação.
+func (c *Client) DeleteDataExport(ctx context.Context, exportID string) error {
+	if _, err := c.pool.Exec(ctx, `DELETE FROM data_exports WHERE id = $1`, exportID); err != nil {
+		return fmt.Errorf("falha ao remover exportação: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) queryDataExports(ctx context.Context, q string, args ...any) ([]models.DataExport, error) {
+	rows, err := c.pool.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.DataExport
+	for rows.Next() {
+		e, err := scanDataExport(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// ===== Coleta dos dados exportados =====
+
+// ListHabitLogsByUser lista todos os registros de hábitos do usuário.
+func (c *Client) ListHabitLogsByUser(ctx context.Context, userID string) ([]models.HabitLog, error) {
+	const q = `SELECT id::text, habit_id, user_id, value, timestamp FROM habit_logs WHERE user_id = $1 ORDER BY timestamp`
+	rows, err := c.pool.Query(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []models.HabitLog
+	for rows.Next() {
+		l := models.HabitLog{}
+		if err := rows.Scan(&l.ID, &l.HabitID, &l.UserID, &l.Value, &l.Timestamp); err != nil {
+			return nil, err
+		}
+		logs = append(logs, l)
+	}
+	return logs, rows.Err()
+}
+
+// ListManaTransactions lista o extrato de mana do usuário.
+func (c *Client) ListManaTransactions(ctx context.Context, userID string) ([]models.ManaTransaction, error) {
+	const q = `
+       SELECT id::text, user_id, type, amount, COALESCE(reference_id, ''), created_at
+       FROM mana_transactions WHERE user_id = $1 ORDER BY created_at`
+	rows, err := c.pool.Query(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var txs []models.ManaTransaction
+	for rows.Next() {
+		t := models.ManaTransaction{}
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Type, &t.Amount, &t.ReferenceID, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		txs = append(txs, t)
+	}
+	return txs, rows.Err()
+}
+
+// ListAllSessions lista todas as sessões do usuário, inclusive encerradas.
+func (c *Client) ListAllSessions(ctx context.Context, userID string) ([]models.Session, error) {
+	const q = `
+       SELECT id, user_id, COALESCE(user_agent, ''), COALESCE(ip, ''), created_at, COALESCE(last_seen_at, created_at), revoked_at
+       FROM auth_sessions WHERE user_id = $1 ORDER BY created_at`
+	rows, err := c.pool.Query(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		s := models.Session{}
+		if err := rows.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.RevokedAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	// ErrVerificationTokenInvalid indica token de verificação inexistente, expirado, usado ou obsoleto.
+	ErrVerificationTokenInvalid = errors.New("token de verificação inválido ou expirado")
+	// ErrEmailTaken indica que o e-mail já pertence a outra conta.
+	ErrEmailTaken = errors.New("e-mail já cadastrado")
+)
+
+// CreateEmailVerificationToken grava o hash de um token que confirma o endereço informado.
+func (c *Client) CreateEmailVerificationToken(ctx context.Context, userID, email, tokenHash string, expiresAt time.Time) error {
+	id := uuid.New().String()
+	sealed, err := c.seal(fieldVerifyEmail, id, strings.ToLower(strings.TrimSpace(email)))
+	if err != nil {
+		return err
+	}
+	const q = `INSERT INTO email_verification_tokens (id, user_id, email, token_hash, expires_at) VALUES ($1, $2, $3, $4, $5)`
+	if _, err := c.pool.Exec(ctx, q, id, userID, sealed, tokenHash, expiresAt); err != nil {
+		return fmt.Errorf("falha ao registrar token de verificação: %w", err)
+	}
+	return nil
+}
+
+// SetPendingEmail guarda o novo e-mail até que a nova caixa confirme a troca.
+func (c *Client) SetPendingEmail(ctx context.Context, userID, email string) error {
+	sealed, err := c.seal(fieldUserPendingEmail, userID, strings.ToLower(strings.TrimSpace(email)))
+	if err != nil {
+		return err
+	}
+	const q = `UPDATE users SET pending_email = $2 WHERE id = $1`
+	cmdTag, err := c.pool.Exec(ctx, q, userID, sealed)
+	if err != nil {
+		return fmt.Errorf("falha ao registrar e-mail pendente: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// VerifyEmailWithToken consome o token e confirma o endereço:
+// - se for o e-mail atual, marca a conta como verificada;
+// - se for o e-mail pendente, efetiva a troca e marca como verificada.
+// Retorna o ID do usuário, o e-mail anterior e o e-mail confirmado (iguais se não houve troca).
+func (c *Client) VerifyEmailWithToken(ctx context.Context, tokenHash string) (string, string, string, error) {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return "", "", "", fmt.Errorf("falha ao iniciar transação: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	var tokenID, userID, email string
+	const consume = `
+       UPDATE email_verification_tokens SET used_at = NOW()
+       WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+       RETURNING id::text, user_id, email`
+	if err = tx.QueryRow(ctx, consume, tokenHash).Scan(&tokenID, &userID, &email); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", "", "", ErrVerificationTokenInvalid
+		}
+		return "", "", "", fmt.Errorf("falha ao consumir token de verificação: %w", err)
+	}
+	if email, err = c.open(fieldVerifyEmail, tokenID, email); err != nil {
+		return "", "", "", err
+	}
+
+	var previous, pending string
+	if err = tx.QueryRow(ctx, `SELECT email, COALESCE(pending_email, '') FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&previous, &pending); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = ErrVerificationTokenInvalid
+			return "", "", "", err
+		}
+		return "", "", "", fmt.Errorf("falha ao buscar usuário: %w", err)
+	}
+	if previous, err = c.open(fieldUserEmail, userID, previous); err != nil {
+		return "", "", "", err
+	}
+	if pending, err = c.open(fieldUserPendingEmail, userID, pending); err != nil {
+		return "", "", "", err
+	}
+
+	// Os endereços são cifrados, então a comparação com o token é feita aqui
+	var cmdTag pgconn.CommandTag
+	switch email {
+	case strings.ToLower(pending):
+		var sealed string
+		if sealed, err = c.seal(fieldUserEmail, userID, email); err != nil {
+			return "", "", "", err
+		}
+		const apply = `UPDATE users SET email = $2, email_bidx = $3, pending_email = NULL, email_verified = TRUE WHERE id = $1`
+		cmdTag, err = tx.Exec(ctx, apply, userID, sealed, c.emailIndex(email))
+	case strings.ToLower(previous):
+		cmdTag, err = tx.Exec(ctx, `UPDATE users SET email_verified = TRUE WHERE id = $1`, userID)
+	}
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			err = ErrEmailTaken
+			return "", "", "", err
+		}
+		return "", "", "", fmt.Errorf("falha ao confirmar e-mail: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		// O endereço do token não é mais o atual nem o pendente (troca posterior)
+		err = ErrVerificationTokenInvalid
+		return "", "", "", err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return "", "", "", err
+	}
+	return userID, previous, email, nil
+}
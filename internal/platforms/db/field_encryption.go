@@ -0,0 +1,272 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Campos cifrados (dados pessoais) =====
+//
+// Nome, e-mail, e-mail pendente e segredos TOTP dos usuários, os dados de contato da rede de
+// apoio e os e-mails das identidades externas e dos tokens de verificação são gravados cifrados
+// (pacote fieldcrypt), amarrados à chave da linha. A busca por e-mail usa o índice cego
+// users.email_bidx, que também garante a unicidade.
+
+const (
+	fieldUserEmail        = "users.email"
+	fieldUserName         = "users.name"
+	fieldUserPendingEmail = "users.pending_email"
+	fieldUserTOTPSecret   = "users.totp_secret"
+	fieldUserTOTPPending  = "users.totp_pending_secret"
+	fieldContactEmail     = "support_contacts.contact_email"
+	fieldContactPhone     = "support_contacts.phone"
+	fieldContactNickname  = "support_contacts.nickname"
+	fieldIdentityEmail    = "user_identities.email"
+	fieldVerifyEmail      = "email_verification_tokens.email"
+)
+
+// encryptedTable descreve as colunas cifradas de uma tabela para a recriptografia. key é a
+// expressão da chave da linha, a mesma passada a seal/open.
+type encryptedTable struct {
+	table   string
+	key     string
+	columns []string
+}
+
+var encryptedTables = []encryptedTable{
+	{table: "users", key: "id", columns: []string{"email", "name", "pending_email", "totp_secret", "totp_pending_secret"}},
+	{table: "support_contacts", key: "contact_id", columns: []string{"contact_email", "phone", "nickname"}},
+	{table: "user_identities", key: "(provider || ':' || subject)", columns: []string{"email"}},
+	{table: "email_verification_tokens", key: "id", columns: []string{"email"}},
+}
+
+// identityRow é a chave da linha de user_identities usada no dado associado.
+func identityRow(provider, subject string) string {
+	return provider + ":" + subject
+}
+
+// seal cifra o valor do campo amarrado à linha row (a chave primária em texto).
+func (c *Client) seal(field, row, value string) (string, error) {
+	out, err := c.fields.Encrypt(field, row, value)
+	if err != nil {
+		return "", fmt.Errorf("falha ao cifrar %s: %w", field, err)
+	}
+	return out, nil
+}
+
+func (c *Client) open(field, row, value string) (string, error) {
+	return c.fields.Decrypt(field, row, value)
+}
+
+// emailIndex normaliza o e-mail e calcula o índice cego usado nas buscas.
+func (c *Client) emailIndex(email string) string {
+	return c.fields.BlindIndex(fieldUserEmail, strings.ToLower(strings.TrimSpace(email)))
+}
+
+// openUser decifra os campos pessoais do usuário lido do banco.
+func (c *Client) openUser(u *models.User) error {
+	var err error
+	if u.Email, err = c.open(fieldUserEmail, u.ID, u.Email); err != nil {
+		return err
+	}
+	if u.Name, err = c.open(fieldUserName, u.ID, u.Name); err != nil {
+		return err
+	}
+	if u.PendingEmail, err = c.open(fieldUserPendingEmail, u.ID, u.PendingEmail); err != nil {
+		return err
+	}
+	return nil
+}
+
+// openContact decifra os dados do contato da rede de apoio lido do banco.
+func (c *Client) openContact(contact *models.SupportContact) error {
+	var err error
+	if contact.ContactEmail, err = c.open(fieldContactEmail, contact.ContactID, contact.ContactEmail); err != nil {
+		return err
+	}
+	if contact.Phone, err = c.open(fieldContactPhone, contact.ContactID, contact.Phone); err != nil {
+		return err
+	}
+	if contact.Nickname, err = c.open(fieldContactNickname, contact.ContactID, contact.Nickname); err != nil {
+		return err
+	}
+	return nil
+}
+
+// openIdentity decifra o e-mail informado pelo provedor da identidade lida do banco.
+func (c *Client) openIdentity(i *models.UserIdentity) error {
+	var err error
+	i.Email, err = c.open(fieldIdentityEmail, identityRow(i.Provider, i.Subject), i.Email)
+	return err
+}
+
+// backfillEmailIndex calcula o índice cego dos usuários gravados antes da criptografia,
+// para que a busca por e-mail e a unicidade valham também para eles.
+func (c *Client) backfillEmailIndex(ctx context.Context) error {
+	rows, err := c.pool.Query(ctx, `SELECT id, email FROM users WHERE email_bidx IS NULL`)
+	if err != nil {
+		return fmt.Errorf("falha ao buscar usuários sem índice de e-mail: %w", err)
+	}
+	type pending struct{ id, email string }
+	var list []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.email); err != nil {
+			rows.Close()
+			return err
+		}
+		list = append(list, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range list {
+		email, err := c.open(fieldUserEmail, p.id, p.email)
+		if err != nil {
+			return err
+		}
+		if _, err := c.pool.Exec(ctx, `UPDATE users SET email_bidx = $2 WHERE id = $1 AND email_bidx IS NULL`, p.id, c.emailIndex(email)); err != nil {
+			return fmt.Errorf("falha ao indexar e-mail do usuário %s: %w", p.id, err)
+		}
+	}
+	if len(list) > 0 {
+		log.Printf("Índice de e-mail calculado para %d usuário(s).", len(list))
+	}
+	return nil
+}
+
+// ReencryptStats resume uma execução de ReencryptFields por tabela.
+type ReencryptStats struct {
+	Table     string
+	Scanned   int
+	Rewritten int
+	Skipped   int // alterados por outra escrita durante a execução; rode de novo
+}
+
+// ReencryptFields regrava, com a chave ativa, os valores em texto puro, no formato v1 (sem a
+// linha no dado associado) ou cifrados com chaves antigas e recalcula o índice cego de e-mail. Percorre as tabelas em lotes e só
+// troca cada linha se ela não mudou desde a leitura, então pode rodar com a API no ar.
+// Com dryRun, apenas conta o que seria regravado.
+func (c *Client) ReencryptFields(ctx context.Context, batch int, dryRun bool) ([]ReencryptStats, error) {
+	var out []ReencryptStats
+	for _, t := range encryptedTables {
+		st, err := c.reencryptTable(ctx, t, batch, dryRun)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+func (c *Client) reencryptTable(ctx context.Context, t encryptedTable, batch int, dryRun bool) (ReencryptStats, error) {
+	st := ReencryptStats{Table: t.table}
+	cols := strings.Join(t.columns, ", ")
+	sel := fmt.Sprintf(`SELECT %[1]s::text, %[2]s FROM %[3]s WHERE %[1]s::text > $1 ORDER BY %[1]s::text LIMIT $2`, t.key, cols, t.table)
+
+	// UPDATE t SET c1 = $2, ... WHERE key = $1 AND c1 IS NOT DISTINCT FROM $n ...
+	n := len(t.columns)
+	sets := make([]string, 0, n+1)
+	conds := []string{fmt.Sprintf("%s = $1", t.key)}
+	for i, col := range t.columns {
+		sets = append(sets, fmt.Sprintf("%s = $%d", col, i+2))
+		conds = append(conds, fmt.Sprintf("%s IS NOT DISTINCT FROM $%d", col, n+i+2))
+	}
+	if t.table == "users" {
+		sets = append(sets, fmt.Sprintf("email_bidx = $%d", 2*n+2))
+	}
+	upd := fmt.Sprintf(`UPDATE %s SET %s WHERE %s`, t.table, strings.Join(sets, ", "), strings.Join(conds, " AND "))
+
+	last := ""
+	for {
+		rows, err := c.pool.Query(ctx, sel, last, batch)
+		if err != nil {
+			return st, fmt.Errorf("falha ao ler %s: %w", t.table, err)
+		}
+		type row struct {
+			key    string
+			values []*string
+		}
+		var page []row
+		for rows.Next() {
+			r := row{values: make([]*string, n)}
+			dest := []any{&r.key}
+			for i := range r.values {
+				dest = append(dest, &r.values[i])
+			}
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
+				return st, err
+			}
+			page = append(page, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return st, err
+		}
+		if len(page) == 0 {
+			return st, nil
+		}
+
+		for _, r := range page {
+			last = r.key
+			st.Scanned++
+			stale := false
+			for _, v := range r.values {
+				if v != nil && c.fields.NeedsRotation(*v) {
+					stale = true
+				}
+			}
+			if !stale {
+				continue
+			}
+			if dryRun {
+				st.Rewritten++
+				continue
+			}
+
+			args := []any{r.key}
+			email := ""
+			for i, v := range r.values {
+				if v == nil {
+					args = append(args, nil)
+					continue
+				}
+				field := t.table + "." + t.columns[i]
+				plain, err := c.open(field, r.key, *v)
+				if err != nil {
+					return st, fmt.Errorf("%s %s: %w", t.table, r.key, err)
+				}
+				if field == fieldUserEmail {
+					email = plain
+				}
+				sealed, err := c.seal(field, r.key, plain)
+				if err != nil {
+					return st, err
+				}
+				args = append(args, sealed)
+			}
+			for _, v := range r.values {
+				args = append(args, v)
+			}
+			if t.table == "users" {
+				args = append(args, c.emailIndex(email))
+			}
+			tag, err := c.pool.Exec(ctx, upd, args...)
+			if err != nil {
+				return st, fmt.Errorf("falha ao regravar %s %s: %w", t.table, r.key, err)
+			}
+			if tag.RowsAffected() == 0 {
+				st.Skipped++
+				continue
+			}
+			st.Rewritten++
+		}
+	}
+}
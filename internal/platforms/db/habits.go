@@ -0,0 +1,334 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"go-guardiao-api/pkg/models"
+)
+
+var (
+	// ErrHabitArchived indica uma edição em hábito arquivado (é preciso desarquivá-lo antes).
+	ErrHabitArchived = errors.New("hábito arquivado")
+	// ErrStreakFreezeExists indica que o dia já está protegido.
+	ErrStreakFreezeExists = errors.New("dia já protegido")
+	// ErrInsufficientMana indica saldo de mana menor que o custo.
+	ErrInsufficientMana = errors.New("mana insuficiente")
+	// ErrHabitLogTooOld indica um registro anterior ao limite de edição.
+	ErrHabitLogTooOld = errors.New("registro antigo demais para alterar")
+)
+
+const habitColumns = `id, user_id, name, COALESCE(goal_type, ''), COALESCE(frequency, ''), schedule,
+       goal_target, COALESCE(goal_unit, ''), COALESCE(goal_aggregation, ''), status, created_at, updated_at`
+
+func scanHabit(row pgx.Row) (models.Habit, error) {
+	h := models.Habit{}
+	var schedule []byte
+	var goal models.HabitGoal
+	var target *int
+	if err := row.Scan(&h.ID, &h.UserID, &h.Name, &h.GoalType, &h.Frequency, &schedule,
+		&target, &goal.Unit, &goal.Aggregation, &h.Status, &h.CreatedAt, &h.UpdatedAt); err != nil {
+		return models.Habit{}, err
+	}
+	if len(schedule) > 0 {
+		h.Schedule = &models.HabitSchedule{}
+		if err := json.Unmarshal(schedule, h.Schedule); err != nil {
+			return models.Habit{}, err
+		}
+	}
+	if target != nil {
+		goal.Target = *target
+		h.Goal = &goal
+	}
+	return h, nil
+}
+
+// goalValues separa a meta nas colunas goal_target, goal_unit e goal_aggregation (nil grava NULL).
+func goalValues(g *models.HabitGoal) (target *int, unit, aggregation *string) {
+	if g == nil {
+		return nil, nil, nil
+	}
+	return &g.Target, &g.Unit, &g.Aggregation
+}
+
+// marshalSchedule converte a agenda para a coluna JSONB (nil grava NULL).
+func marshalSchedule(s *models.HabitSchedule) ([]byte, error) {
+	if s == nil {
+		return nil, nil
+	}
+	return json.Marshal(s)
+}
+
+// UpdateHabit grava nome, tipo de meta, frequência, agenda, meta e situação (ativo/pausado)
+// de um hábito do usuário. Retorna ErrHabitArchived se ele estiver arquivado e pgx.ErrNoRows se não existir.
+func (c *Client) UpdateHabit(ctx context.Context, habit models.Habit) (models.Habit, error) {
+	schedule, err := marshalSchedule(habit.Schedule)
+	if err != nil {
+		return models.Habit{}, err
+	}
+	target, unit, aggregation := goalValues(habit.Goal)
+	q := `
+       UPDATE habits SET name = $3, goal_type = $4, frequency = $5, schedule = $6,
+              goal_target = $7, goal_unit = $8, goal_aggregation = $9, status = $10, updated_at = NOW()
+       WHERE id = $1 AND user_id = $2 AND status <> $11
+       RETURNING ` + habitColumns
+	updated, err := scanHabit(c.pool.QueryRow(ctx, q, habit.ID, habit.UserID, habit.Name, habit.GoalType, habit.Frequency, schedule,
+		target, unit, aggregation, habit.Status, models.HabitArchived))
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return updated, err
+	}
+	var status string
+	if err := c.pool.QueryRow(ctx, `SELECT status FROM habits WHERE id = $1 AND user_id = $2`, habit.ID, habit.UserID).Scan(&status); err != nil {
+		return models.Habit{}, err
+	}
+	if status == models.HabitArchived {
+		return models.Habit{}, ErrHabitArchived
+	}
+	return models.Habit{}, pgx.ErrNoRows
+}
+
+// SetHabitStatus muda a situação de um hábito do usuário (pgx.ErrNoRows se não existir).
+func (c *Client) SetHabitStatus(ctx context.Context, habitID, userID, status string) (models.Habit, error) {
+	q := `
+       UPDATE habits SET status = $3, updated_at = NOW()
+       WHERE id = $1 AND user_id = $2
+       RETURNING ` + habitColumns
+	return scanHabit(c.pool.QueryRow(ctx, q, habitID, userID, status))
+}
+
+// ===== Pausas =====
+
+// Os períodos de pausa são gravados pelo gatilho habits_track_pauses a cada mudança de situação.
+
+func (c *Client) listHabitPauses(ctx context.Context, q string, arg string) ([]models.HabitPause, error) {
+	rows, err := c.pool.Query(ctx, q, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	pauses := []models.HabitPause{}
+	for rows.Next() {
+		var p models.HabitPause
+		if err := rows.Scan(&p.HabitID, &p.PausedAt, &p.ResumedAt); err != nil {
+			return nil, err
+		}
+		pauses = append(pauses, p)
+	}
+	return pauses, rows.Err()
+}
+
+// ListHabitPauses lista os períodos de pausa de um hábito, do mais antigo ao mais recente.
+func (c *Client) ListHabitPauses(ctx context.Context, habitID string) ([]models.HabitPause, error) {
+	return c.listHabitPauses(ctx, `
+       SELECT habit_id, paused_at, resumed_at FROM habit_pauses
+       WHERE habit_id = $1 ORDER BY paused_at`, habitID)
+}
+
+// ListHabitPausesByUser lista os períodos de pausa de todos os hábitos do usuário.
+func (c *Client) ListHabitPausesByUser(ctx context.Context, userID string) ([]models.HabitPause, error) {
+	return c.listHabitPauses(ctx, `
+       SELECT p.habit_id, p.paused_at, p.resumed_at FROM habit_pauses p
+       JOIN habits h ON h.id = p.habit_id
+       WHERE h.user_id = $1 ORDER BY p.paused_at`, userID)
+}
+
+// ===== Proteções de sequência =====
+
+const streakFreezeColumns = `habit_id, user_id, to_char(day, 'YYYY-MM-DD'), mana_cost, created_at`
+
+func (c *Client) listStreakFreezes(ctx context.Context, where string, arg string) ([]models.StreakFreeze, error) {
+	rows, err := c.pool.Query(ctx, `SELECT `+streakFreezeColumns+` FROM habit_streak_freezes WHERE `+where+` ORDER BY day`, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	freezes := []models.StreakFreeze{}
+	for rows.Next() {
+		var f models.StreakFreeze
+		if err := rows.Scan(&f.HabitID, &f.UserID, &f.Day, &f.ManaCost, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		freezes = append(freezes, f)
+	}
+	return freezes, rows.Err()
+}
+
+// ListStreakFreezes lista as proteções de um hábito, por dia.
+func (c *Client) ListStreakFreezes(ctx context.Context, habitID string) ([]models.StreakFreeze, error) {
+	return c.listStreakFreezes(ctx, `habit_id = $1`, habitID)
+}
+
+// ListStreakFreezesByUser lista as proteções de todos os hábitos do usuário, por dia.
+func (c *Client) ListStreakFreezesByUser(ctx context.Context, userID string) ([]models.StreakFreeze, error) {
+	return c.listStreakFreezes(ctx, `user_id = $1`, userID)
+}
+
+// BuyStreakFreeze debita freeze.ManaCost do usuário e grava a proteção na mesma transação,
+// devolvendo o novo saldo. Retorna ErrInsufficientMana ou ErrStreakFreezeExists.
+func (c *Client) BuyStreakFreeze(ctx context.Context, freeze models.StreakFreeze) (int, error) {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("falha ao iniciar transação de mana: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	var balance int
+	err = tx.QueryRow(ctx, `
+       UPDATE user_mana SET balance = balance - $1, updated_at = NOW()
+       WHERE user_id = $2 AND balance >= $1
+       RETURNING balance`, freeze.ManaCost, freeze.UserID).Scan(&balance)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrInsufficientMana
+	}
+	if err != nil {
+		return 0, fmt.Errorf("falha ao debitar mana: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO habit_streak_freezes (habit_id, user_id, day, mana_cost) VALUES ($1, $2, $3::date, $4)`,
+		freeze.HabitID, freeze.UserID, freeze.Day, freeze.ManaCost)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return 0, ErrStreakFreezeExists
+	}
+	if err != nil {
+		return 0, fmt.Errorf("falha ao gravar proteção: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO mana_transactions (user_id, type, amount, reference_id) VALUES ($1, $2, $3, $4)`,
+		freeze.UserID, models.ManaTypeStreakFreeze, -freeze.ManaCost, freeze.HabitID)
+	if err != nil {
+		return 0, fmt.Errorf("falha ao registrar transação de mana: %w", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
+
+// ===== Edição de registros =====
+
+// LogManaAdjustment é a mana creditada ao registrar ou compensada ao editar ou excluir um registro.
+type LogManaAdjustment struct {
+	Amount  int // diferença lançada (negativa quando a mana é estornada)
+	Balance int // saldo após a compensação
+}
+
+// UpdateHabitLog grava o novo valor e, se não vier zerado, o novo horário de um registro do
+// usuário (pgx.ErrNoRows se não existir; ErrHabitLogTooOld se ele for anterior a since) e lança
+// a diferença entre mana (o total que ele deve render agora) e o que já rendeu em uma
+// transação HABIT_LOG_ADJUSTMENT.
+func (c *Client) UpdateHabitLog(ctx context.Context, logData models.HabitLog, mana int, since time.Time) (models.HabitLog, *LogManaAdjustment, error) {
+	var ts *time.Time
+	if !logData.Timestamp.IsZero() {
+		t := logData.Timestamp.UTC()
+		ts = &t
+	}
+	var adj *LogManaAdjustment
+	err := c.inLogTx(ctx, logData, since, func(tx pgx.Tx, key int64) error {
+		err := tx.QueryRow(ctx, `
+       UPDATE habit_logs SET value = $4, timestamp = COALESCE($5, timestamp)
+       WHERE id = $1 AND habit_id = $2 AND user_id = $3
+       RETURNING timestamp`, key, logData.HabitID, logData.UserID, logData.Value, ts).Scan(&logData.Timestamp)
+		if err != nil {
+			return err
+		}
+		adj, err = adjustLogMana(ctx, tx, logData.UserID, logData.ID, mana)
+		return err
+	})
+	if err != nil {
+		return models.HabitLog{}, nil, err
+	}
+	return logData, adj, nil
+}
+
+// DeleteHabitLog apaga um registro do usuário (pgx.ErrNoRows se não existir; ErrHabitLogTooOld
+// se ele for anterior a since) e estorna a mana que ele rendeu.
+func (c *Client) DeleteHabitLog(ctx context.Context, habitID, logID, userID string, since time.Time) (*LogManaAdjustment, error) {
+	logData := models.HabitLog{ID: logID, HabitID: habitID, UserID: userID}
+	var adj *LogManaAdjustment
+	err := c.inLogTx(ctx, logData, since, func(tx pgx.Tx, key int64) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM habit_logs WHERE id = $1`, key); err != nil {
+			return err
+		}
+		var err error
+		adj, err = adjustLogMana(ctx, tx, userID, logID, 0)
+		return err
+	})
+	return adj, err
+}
+
+// inLogTx executa fn em uma transação com o registro bloqueado, para que edições simultâneas
+// do mesmo registro não compensem a mana duas vezes. IDs que não são numéricos dão pgx.ErrNoRows
+// e registros anteriores a since, ErrHabitLogTooOld.
+func (c *Client) inLogTx(ctx context.Context, logData models.HabitLog, since time.Time, fn func(tx pgx.Tx, key int64) error) (err error) {
+	key, err := strconv.ParseInt(logData.ID, 10, 64)
+	if err != nil {
+		return pgx.ErrNoRows
+	}
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("falha ao iniciar transação: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	var stored time.Time
+	err = tx.QueryRow(ctx, `SELECT timestamp FROM habit_logs WHERE id = $1 AND habit_id = $2 AND user_id = $3 FOR UPDATE`,
+		key, logData.HabitID, logData.UserID).Scan(&stored)
+	if err != nil {
+		return err
+	}
+	if stored.Before(since) {
+		err = ErrHabitLogTooOld
+		return err
+	}
+	if err = fn(tx, key); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// adjustLogMana lança a diferença entre mana e o que o registro já rendeu (ganho ao registrar
+// mais compensações anteriores), inclusive quando ele não rendia nada e passa a render. O
+// saldo pode ficar negativo se a mana estornada já tiver sido gasta.
+func adjustLogMana(ctx context.Context, tx pgx.Tx, userID, logID string, mana int) (*LogManaAdjustment, error) {
+	var net int
+	err := tx.QueryRow(ctx, `
+       SELECT COALESCE(SUM(amount), 0)
+       FROM mana_transactions
+       WHERE user_id = $1 AND reference_id = $2 AND type IN ($3, $4)`,
+		userID, logID, models.ManaTypeHabitCompletion, models.ManaTypeHabitLogAdjustment).Scan(&net)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao somar mana do registro: %w", err)
+	}
+	if mana == net {
+		return nil, nil
+	}
+
+	adj := &LogManaAdjustment{Amount: mana - net}
+	err = tx.QueryRow(ctx, `UPDATE user_mana SET balance = balance + $1, updated_at = NOW() WHERE user_id = $2 RETURNING balance`,
+		adj.Amount, userID).Scan(&adj.Balance)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao atualizar saldo: %w", err)
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO mana_transactions (user_id, type, amount, reference_id) VALUES ($1, $2, $3, $4)`,
+		userID, models.ManaTypeHabitLogAdjustment, adj.Amount, logID)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao registrar transação de mana: %w", err)
+	}
+	return adj, nil
+}
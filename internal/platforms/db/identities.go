@@ -0,0 +1,186 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"go-guardiao-api/pkg/models"
+)
+
+var (
+	// ErrIdentityLinked indica que a identidade externa já pertence a outra conta
+	// (ou que a conta já tem outra identidade do mesmo provedor).
+	ErrIdentityLinked = errors.New("identidade já vinculada a outra conta")
+	// ErrOIDCStateInvalid indica state inexistente, expirado ou já utilizado.
+	ErrOIDCStateInvalid = errors.New("state OIDC inválido ou expirado")
+)
+
+// CreateOIDCState registra o state (hash), o code_verifier PKCE e o nonce de um fluxo em andamento.
+func (c *Client) CreateOIDCState(ctx context.Context, stateHash string, s models.OIDCLoginState) error {
+	const q = `
+       INSERT INTO oidc_login_states (state_hash, provider, code_verifier, nonce, link_user_id, expires_at)
+       VALUES ($1, $2, $3, $4, NULLIF($5, '')::uuid, $6)`
+	if _, err := c.pool.Exec(ctx, q, stateHash, s.Provider, s.CodeVerifier, s.Nonce, s.LinkUserID, s.ExpiresAt); err != nil {
+		return fmt.Errorf("falha ao registrar state OIDC: %w", err)
+	}
+	return nil
+}
+
+// ConsumeOIDCState remove e devolve o state (uso único). Expirados também são limpos.
+func (c *Client) ConsumeOIDCState(ctx context.Context, stateHash string) (models.OIDCLoginState, error) {
+	const q = `
+       DELETE FROM oidc_login_states WHERE state_hash = $1
+       RETURNING provider, code_verifier, nonce, COALESCE(link_user_id::text, ''), expires_at, expires_at > NOW()`
+	s := models.OIDCLoginState{}
+	var valid bool
+	err := c.pool.QueryRow(ctx, q, stateHash).Scan(&s.Provider, &s.CodeVerifier, &s.Nonce, &s.LinkUserID, &s.ExpiresAt, &valid)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.OIDCLoginState{}, ErrOIDCStateInvalid
+	}
+	if err != nil {
+		return models.OIDCLoginState{}, fmt.Errorf("falha ao consumir state OIDC: %w", err)
+	}
+	if !valid {
+		return models.OIDCLoginState{}, ErrOIDCStateInvalid
+	}
+	if _, err := c.pool.Exec(ctx, `DELETE FROM oidc_login_states WHERE expires_at < NOW()`); err != nil {
+		return models.OIDCLoginState{}, fmt.Errorf("falha ao limpar states OIDC: %w", err)
+	}
+	return s, nil
+}
+
+// GetIdentity busca a identidade externa (provider, sub). Retorna pgx.ErrNoRows se não existir.
+func (c *Client) GetIdentity(ctx context.Context, provider, subject string) (models.UserIdentity, error) {
+	const q = `
+       SELECT provider, subject, user_id, COALESCE(email, ''), created_at, last_login_at
+       FROM user_identities WHERE provider = $1 AND subject = $2`
+	i := models.UserIdentity{}
+	err := c.pool.QueryRow(ctx, q, provider, subject).Scan(&i.Provider, &i.Subject, &i.UserID, &i.Email, &i.CreatedAt, &i.LastLoginAt)
+	if err != nil {
+		return models.UserIdentity{}, err
+	}
+	if err := c.openIdentity(&i); err != nil {
+		return models.UserIdentity{}, err
+	}
+	return i, nil
+}
+
+// LinkIdentity vincula a identidade externa ao usuário.
+func (c *Client) LinkIdentity(ctx context.Context, i models.UserIdentity) error {
+	email, err := c.sealIdentityEmail(i.Provider, i.Subject, i.Email)
+	if err != nil {
+		return err
+	}
+	const q = `INSERT INTO user_identities (provider, subject, user_id, email, last_login_at) VALUES ($1, $2, $3, $4, NOW())`
+	if _, err := c.pool.Exec(ctx, q, i.Provider, i.Subject, i.UserID, email); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrIdentityLinked
+		}
+		return fmt.Errorf("falha ao vincular identidade: %w", err)
+	}
+	return nil
+}
+
+// CreateUserWithIdentity cria a conta (sem senha) e a identidade externa numa única transação.
+// user.ID deve vir preenchido.
+func (c *Client) CreateUserWithIdentity(ctx context.Context, user models.User, i models.UserIdentity) error {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("falha ao iniciar transação: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+	if err = c.createUserTx(ctx, tx, user); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrEmailTaken
+		}
+		return err
+	}
+	var email string
+	if email, err = c.sealIdentityEmail(i.Provider, i.Subject, i.Email); err != nil {
+		return err
+	}
+	const q = `INSERT INTO user_identities (provider, subject, user_id, email, last_login_at) VALUES ($1, $2, $3, $4, NOW())`
+	if _, err = tx.Exec(ctx, q, i.Provider, i.Subject, user.ID, email); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrIdentityLinked
+		}
+		return fmt.Errorf("falha ao vincular identidade: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// sealIdentityEmail normaliza e cifra o e-mail informado pelo provedor.
+func (c *Client) sealIdentityEmail(provider, subject, email string) (string, error) {
+	return c.seal(fieldIdentityEmail, identityRow(provider, subject), strings.ToLower(strings.TrimSpace(email)))
+}
+
+// CountUserIdentities informa quantas identidades externas o usuário possui.
+func (c *Client) CountUserIdentities(ctx context.Context, userID string) (int, error) {
+	var n int
+	if err := c.pool.QueryRow(ctx, `SELECT COUNT(*) FROM user_identities WHERE user_id = $1`, userID).Scan(&n); err != nil {
+		return 0, fmt.Errorf("falha ao contar identidades: %w", err)
+	}
+	return n, nil
+}
+
+// TouchIdentity registra o último login pela identidade (e o e-mail informado pelo provedor).
+func (c *Client) TouchIdentity(ctx context.Context, provider, subject, email string) error {
+	sealed, err := c.sealIdentityEmail(provider, subject, email)
+	if err != nil {
+		return err
+	}
+	const q = `UPDATE user_identities SET last_login_at = NOW(), email = $3 WHERE provider = $1 AND subject = $2`
+	if _, err := c.pool.Exec(ctx, q, provider, subject, sealed); err != nil {
+		return fmt.Errorf("falha ao atualizar identidade: %w", err)
+	}
+	return nil
+}
+
+// ListUserIdentities lista as identidades externas vinculadas ao usuário.
+func (c *Client) ListUserIdentities(ctx context.Context, userID string) ([]models.UserIdentity, error) {
+	const q = `
+       SELECT provider, subject, user_id, COALESCE(email, ''), created_at, last_login_at
+       FROM user_identities WHERE user_id = $1 ORDER BY created_at`
+	rows, err := c.pool.Query(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.UserIdentity
+	for rows.Next() {
+		i := models.UserIdentity{}
+		if err := rows.Scan(&i.Provider, &i.Subject, &i.UserID, &i.Email, &i.CreatedAt, &i.LastLoginAt); err != nil {
+			return nil, err
+		}
+		if err := c.openIdentity(&i); err != nil {
+			return nil, err
+		}
+		out = append(out, i)
+	}
+	return out, rows.Err()
+}
+
+// UnlinkIdentity remove o vínculo do usuário com o provedor. Retorna pgx.ErrNoRows se não houver vínculo.
+func (c *Client) UnlinkIdentity(ctx context.Context, userID, provider string) error {
+	cmdTag, err := c.pool.Exec(ctx, `DELETE FROM user_identities WHERE user_id = $1 AND provider = $2`, userID, provider)
+	if err != nil {
+		return fmt.Errorf("falha ao desvincular identidade: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-guardiao-api/pkg/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// LoginAccountKey é a chave do escopo de conta: o índice cego do e-mail (o mesmo de
+// users.email_bidx), para que contadores, bloqueios e Redis não guardem o e-mail.
+func (c *Client) LoginAccountKey(email string) string {
+	return c.emailIndex(email)
+}
+
+// GetLoginAttempts lê o contador de falhas de uma conta/IP (fallback quando o Redis está indisponível).
+// Registros cuja última falha é mais antiga que window são tratados como zerados.
+func (c *Client) GetLoginAttempts(ctx context.Context, scope, key string, window time.Duration) (models.LoginAttempts, error) {
+	var a models.LoginAttempts
+	var last, locked *time.Time
+	const q = `SELECT failures, last_failure_at, locked_until FROM login_attempts WHERE scope = $1 AND key = $2`
+	err := c.pool.QueryRow(ctx, q, scope, key).Scan(&a.Failures, &last, &locked)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.LoginAttempts{}, nil
+	}
+	if err != nil {
+		return models.LoginAttempts{}, err
+	}
+	if last != nil {
+		a.LastFailure = *last
+		if time.Since(*last) > window {
+			a.Failures = 0
+		}
+	}
+	if locked != nil {
+		a.LockedUntil = *locked
+	}
+	return a, nil
+}
+
+// RegisterLoginFailure incrementa o contador (reiniciando-o se a última falha saiu da janela).
+func (c *Client) RegisterLoginFailure(ctx context.Context, scope, key string, window time.Duration) (models.LoginAttempts, error) {
+	var a models.LoginAttempts
+	var last, locked *time.Time
+	const q = `
+       INSERT INTO login_attempts (scope, key, failures, last_failure_at) VALUES ($1, $2, 1, NOW())
+       ON CONFLICT (scope, key) DO UPDATE SET
+          failures = CASE WHEN login_attempts.last_failure_at < NOW() - make_interval(secs => $3)
+                          THEN 1 ELSE login_attempts.failures + 1 END,
+          last_failure_at = NOW()
+       RETURNING failures, last_failure_at, locked_until`
+	if err := c.pool.QueryRow(ctx, q, scope, key, window.Seconds()).Scan(&a.Failures, &last, &locked); err != nil {
+		return models.LoginAttempts{}, fmt.Errorf("falha ao registrar tentativa de login: %w", err)
+	}
+	if last != nil {
+		a.LastFailure = *last
+	}
+	if locked != nil {
+		a.LockedUntil = *locked
+	}
+	return a, nil
+}
+
+// LockLogin bloqueia a conta/IP até o instante informado; o contador recomeça após o bloqueio.
+func (c *Client) LockLogin(ctx context.Context, scope, key string, until time.Time) error {
+	const q = `
+       INSERT INTO login_attempts (scope, key, locked_until) VALUES ($1, $2, $3)
+       ON CONFLICT (scope, key) DO UPDATE SET locked_until = EXCLUDED.locked_until, failures = 0`
+	if _, err := c.pool.Exec(ctx, q, scope, key, until); err != nil {
+		return fmt.Errorf("falha ao bloquear login: %w", err)
+	}
+	return nil
+}
+
+// ResetLoginAttempts zera falhas e bloqueio da conta/IP.
+func (c *Client) ResetLoginAttempts(ctx context.Context, scope, key string) error {
+	const q = `DELETE FROM login_attempts WHERE scope = $1 AND key = $2`
+	if _, err := c.pool.Exec(ctx, q, scope, key); err != nil {
+		return fmt.Errorf("falha ao zerar tentativas de login: %w", err)
+	}
+	return nil
+}
+
+// RecordLockout grava o evento de bloqueio para revisão administrativa.
+func (c *Client) RecordLockout(ctx context.Context, l models.LoginLockout) error {
+	var userID *string
+	if l.UserID != "" {
+		userID = &l.UserID
+	}
+	const q = `INSERT INTO login_lockouts (scope, key, user_id, ip, failures, locked_until) VALUES ($1, $2, $3, $4, $5, $6)`
+	if _, err := c.pool.Exec(ctx, q, l.Scope, l.Key, userID, l.IP, l.Failures, l.LockedUntil); err != nil {
+		return fmt.Errorf("falha ao registrar bloqueio: %w", err)
+	}
+	return nil
+}
+
+// ListLockouts lista os bloqueios mais recentes; activeOnly filtra os ainda vigentes e não desbloqueados.
+func (c *Client) ListLockouts(ctx context.Context, activeOnly bool, limit, offset int) ([]models.LoginLockout, error) {
+	const q = `
+       SELECT id, scope, key, COALESCE(user_id::text, ''), COALESCE(ip, ''), failures, locked_until, created_at, unlocked_at, COALESCE(unlocked_by::text, '')
+       FROM login_lockouts
+       WHERE NOT $1 OR (unlocked_at IS NULL AND locked_until > NOW())
+       ORDER BY created_at DESC
+       LIMIT $2 OFFSET $3`
+	rows, err := c.pool.Query(ctx, q, activeOnly, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lockouts []models.LoginLockout
+	for rows.Next() {
+		l, err := scanLockout(rows)
+		if err != nil {
+			return nil, err
+		}
+		lockouts = append(lockouts, l)
+	}
+	return lockouts, rows.Err()
+}
+
+// GetLockout busca um evento de bloqueio pelo ID.
+func (c *Client) GetLockout(ctx context.Context, id int64) (models.LoginLockout, error) {
+	const q = `
+       SELECT id, scope, key, COALESCE(user_id::text, ''), COALESCE(ip, ''), failures, locked_until, created_at, unlocked_at, COALESCE(unlocked_by::text, '')
+       FROM login_lockouts WHERE id = $1`
+	return scanLockout(c.pool.QueryRow(ctx, q, id))
+}
+
+// MarkLockoutUnlocked registra quem desbloqueou manualmente a conta/IP.
+func (c *Client) MarkLockoutUnlocked(ctx context.Context, id int64, adminID string) error {
+	const q = `UPDATE login_lockouts SET unlocked_at = NOW(), unlocked_by = $2 WHERE id = $1 AND unlocked_at IS NULL`
+	if _, err := c.pool.Exec(ctx, q, id, adminID); err != nil {
+		return fmt.Errorf("falha ao registrar desbloqueio: %w", err)
+	}
+	return nil
+}
+
+func scanLockout(row pgx.Row) (models.LoginLockout, error) {
+	var l models.LoginLockout
+	err := row.Scan(&l.ID, &l.Scope, &l.Key, &l.UserID, &l.IP, &l.Failures, &l.LockedUntil, &l.CreatedAt, &l.UnlockedAt, &l.UnlockedBy)
+	return l, err
+}
+
+// PurgeLoginHistory apaga os contadores sem falhas nem bloqueio desde before e os eventos de
+// bloqueio encerrados antes disso, inclusive os de e-mails sem conta (sem user_id).
+func (c *Client) PurgeLoginHistory(ctx context.Context, before time.Time) error {
+	before = before.UTC()
+	const attempts = `
+       DELETE FROM login_attempts
+       WHERE COALESCE(last_failure_at, '-infinity') < $1 AND COALESCE(locked_until, '-infinity') < $1`
+	if _, err := c.pool.Exec(ctx, attempts, before); err != nil {
+		return fmt.Errorf("falha ao apagar tentativas de login antigas: %w", err)
+	}
+	if _, err := c.pool.Exec(ctx, `DELETE FROM login_lockouts WHERE locked_until < $1`, before); err != nil {
+		return fmt.Errorf("falha ao apagar bloqueios antigos: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,2071 @@
+package db
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Armazenamento em memória =====
+//
+// MemoryStore implementa Store sem banco: serve para rodar a API offline (DB_DRIVER=memory)
+// e para testar handlers. Segue a semântica do Client (mesmos erros, ordenações, cascatas
+// da exclusão de conta), mas os dados somem ao encerrar o processo e não são cifrados.
+// Todas as operações são serializadas por um mutex e devolvem cópias.
+
+type memUser struct {
+	models.User
+	totpSecret        string
+	totpPendingSecret string
+	totpLastStep      *int64
+}
+
+type memToken struct {
+	userID    string
+	email     string // só nos tokens de verificação de e-mail
+	expiresAt time.Time
+	usedAt    *time.Time
+}
+
+type memRecoveryCode struct {
+	hash string
+	used bool
+}
+
+type memAttempts struct {
+	failures int
+	last     *time.Time
+	locked   *time.Time
+}
+
+type memAuditEvent struct {
+	ev       models.AuditEvent
+	changes  []byte
+	metadata []byte
+}
+
+type memExport struct {
+	models.DataExport
+	startedAt *time.Time
+}
+
+// MemoryStore é um Store em memória, seguro para uso concorrente.
+type MemoryStore struct {
+	mu  sync.Mutex
+	seq int64
+
+	users         map[string]*memUser
+	roles         map[string]map[string]bool
+	mana          map[string]int
+	manaTxs       []models.ManaTransaction
+	contacts      []models.SupportContact
+	habits        []models.Habit
+	habitLogs     []models.HabitLog
+	freezes       []models.StreakFreeze
+	pauses        []models.HabitPause
+	sessions      []*models.Session
+	refreshTokens []*models.RefreshToken
+	resetTokens   map[string]*memToken
+	verifyTokens  map[string]*memToken
+	recoveryCodes map[string][]memRecoveryCode
+	oidcStates    map[string]models.OIDCLoginState
+	identities    []*models.UserIdentity
+	apiKeys       []*models.APIKey
+	challenges    map[string]models.WebAuthnChallenge
+	credentials   []*models.WebAuthnCredential
+	attempts      map[[2]string]*memAttempts
+	lockouts      []*models.LoginLockout
+	consents      []models.ConsentRecord
+	auditEvents   []memAuditEvent
+	exports       []*memExport
+}
+
+// NewMemoryStore cria um armazenamento em memória vazio.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:         map[string]*memUser{},
+		roles:         map[string]map[string]bool{},
+		mana:          map[string]int{},
+		resetTokens:   map[string]*memToken{},
+		verifyTokens:  map[string]*memToken{},
+		recoveryCodes: map[string][]memRecoveryCode{},
+		oidcStates:    map[string]models.OIDCLoginState{},
+		challenges:    map[string]models.WebAuthnChallenge{},
+		attempts:      map[[2]string]*memAttempts{},
+	}
+}
+
+func (m *MemoryStore) Close() {}
+
+func (m *MemoryStore) nextID() int64 {
+	m.seq++
+	return m.seq
+}
+
+func timePtr(t time.Time) *time.Time { return &t }
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	return timePtr(*t)
+}
+
+// page aplica LIMIT/OFFSET a uma lista já ordenada.
+func page[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit >= 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// ===== Usuários =====
+
+func (m *MemoryStore) userByEmail(email string) *memUser {
+	email = normalizeEmail(email)
+	for _, u := range m.users {
+		if u.Email == email {
+			return u
+		}
+	}
+	return nil
+}
+
+// createUserLocked insere o usuário com saldo de mana zerado e o papel padrão.
+func (m *MemoryStore) createUserLocked(user models.User) (string, error) {
+	id := strings.ToLower(strings.TrimSpace(user.ID))
+	if id == "" {
+		id = uuid.New().String()
+	}
+	if _, exists := m.users[id]; exists {
+		return "", fmt.Errorf("falha ao inserir usuário: id %s já existe", id)
+	}
+	if m.userByEmail(user.Email) != nil {
+		return "", fmt.Errorf("falha ao inserir usuário: %w", ErrEmailTaken)
+	}
+	now := time.Now()
+	m.users[id] = &memUser{User: models.User{
+		ID:            id,
+		Email:         normalizeEmail(user.Email),
+		Name:          strings.TrimSpace(user.Name),
+		Theme:         strings.TrimSpace(user.Theme),
+		PasswordHash:  user.PasswordHash,
+		EmailVerified: user.EmailVerified,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}}
+	m.mana[id] = 0
+	m.roles[id] = map[string]bool{models.RoleUser: true}
+	return id, nil
+}
+
+// userView devolve as colunas lidas por GetUserByID/GetUserByEmail.
+func userView(u *memUser, withHash bool) models.User {
+	out := models.User{
+		ID:                  u.ID,
+		Email:               u.Email,
+		Name:                u.Name,
+		Theme:               u.Theme,
+		EmailVerified:       u.EmailVerified,
+		PendingEmail:        u.PendingEmail,
+		TOTPEnabled:         u.TOTPEnabled,
+		DeletionScheduledAt: cloneTime(u.DeletionScheduledAt),
+	}
+	if withHash {
+		out.PasswordHash = u.PasswordHash
+	}
+	return out
+}
+
+func (m *MemoryStore) CreateUser(ctx context.Context, user models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := m.createUserLocked(user)
+	return err
+}
+
+func (m *MemoryStore) GetUserByID(ctx context.Context, userID string) (models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok {
+		return models.User{}, pgx.ErrNoRows
+	}
+	return userView(u, false), nil
+}
+
+func (m *MemoryStore) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u := m.userByEmail(email)
+	if u == nil {
+		return models.User{}, pgx.ErrNoRows
+	}
+	return userView(u, true), nil
+}
+
+func (m *MemoryStore) GetUserPasswordHash(ctx context.Context, userID string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok {
+		return "", pgx.ErrNoRows
+	}
+	return u.PasswordHash, nil
+}
+
+func (m *MemoryStore) SetUserPassword(ctx context.Context, userID, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok {
+		return errors.New("usuário não encontrado para atualização de senha")
+	}
+	u.PasswordHash = hash
+	return nil
+}
+
+func (m *MemoryStore) ReplacePasswordHash(ctx context.Context, userID, oldHash, newHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if u, ok := m.users[userID]; ok && u.PasswordHash == oldHash {
+		u.PasswordHash = newHash
+	}
+	return nil
+}
+
+func (m *MemoryStore) UpdateUser(ctx context.Context, user models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[user.ID]
+	if !ok {
+		return pgx.ErrNoRows
+	}
+	u.Name = strings.TrimSpace(user.Name)
+	u.Theme = strings.TrimSpace(user.Theme)
+	u.UpdatedAt = time.Now()
+	return nil
+}
+
+func (m *MemoryStore) UpdateUserEmail(ctx context.Context, userID, email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok {
+		return pgx.ErrNoRows
+	}
+	if other := m.userByEmail(email); other != nil && other.ID != userID {
+		return ErrEmailTaken
+	}
+	u.Email = normalizeEmail(email)
+	return nil
+}
+
+func (m *MemoryStore) SetPendingEmail(ctx context.Context, userID, email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok {
+		return pgx.ErrNoRows
+	}
+	u.PendingEmail = normalizeEmail(email)
+	return nil
+}
+
+func (m *MemoryStore) CreateEmailVerificationToken(ctx context.Context, userID, email, tokenHash string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.verifyTokens[tokenHash]; exists {
+		return errors.New("falha ao registrar token de verificação: token duplicado")
+	}
+	m.verifyTokens[tokenHash] = &memToken{userID: userID, email: normalizeEmail(email), expiresAt: expiresAt}
+	return nil
+}
+
+func (m *MemoryStore) VerifyEmailWithToken(ctx context.Context, tokenHash string) (string, string, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.verifyTokens[tokenHash]
+	if !ok || t.usedAt != nil || !t.expiresAt.After(time.Now()) {
+		return "", "", "", ErrVerificationTokenInvalid
+	}
+	u, ok := m.users[t.userID]
+	if !ok {
+		return "", "", "", ErrVerificationTokenInvalid
+	}
+	previous := u.Email
+	switch t.email {
+	case strings.ToLower(u.PendingEmail):
+		if other := m.userByEmail(t.email); other != nil && other.ID != u.ID {
+			return "", "", "", ErrEmailTaken
+		}
+		u.Email = t.email
+		u.PendingEmail = ""
+		u.EmailVerified = true
+	case strings.ToLower(u.Email):
+		u.EmailVerified = true
+	default:
+		// O endereço do token não é mais o atual nem o pendente (troca posterior)
+		return "", "", "", ErrVerificationTokenInvalid
+	}
+	t.usedAt = timePtr(time.Now())
+	return u.ID, previous, t.email, nil
+}
+
+func (m *MemoryStore) CreatePasswordResetToken(ctx context.Context, userID, tokenHash string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.resetTokens[tokenHash]; exists {
+		return errors.New("falha ao registrar token de redefinição: token duplicado")
+	}
+	m.resetTokens[tokenHash] = &memToken{userID: userID, expiresAt: expiresAt}
+	return nil
+}
+
+func (m *MemoryStore) ResetPasswordWithToken(ctx context.Context, tokenHash, passwordHash string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.resetTokens[tokenHash]
+	if !ok || t.usedAt != nil || !t.expiresAt.After(time.Now()) {
+		return "", ErrResetTokenInvalid
+	}
+	now := time.Now()
+	if u, ok := m.users[t.userID]; ok {
+		u.PasswordHash = passwordHash
+	}
+	for _, other := range m.resetTokens {
+		if other.userID == t.userID && other.usedAt == nil {
+			other.usedAt = timePtr(now)
+		}
+	}
+	m.revokeUserSessionsLocked(t.userID, "")
+	return t.userID, nil
+}
+
+func (m *MemoryStore) ScheduleAccountDeletion(ctx context.Context, userID string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok {
+		return pgx.ErrNoRows
+	}
+	u.DeletionScheduledAt = timePtr(at)
+	return nil
+}
+
+func (m *MemoryStore) CancelAccountDeletion(ctx context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok || u.DeletionScheduledAt == nil {
+		return pgx.ErrNoRows
+	}
+	u.DeletionScheduledAt = nil
+	return nil
+}
+
+func (m *MemoryStore) ListDueAccountDeletions(ctx context.Context, limit int) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	var due []*memUser
+	for _, u := range m.users {
+		if u.DeletionScheduledAt != nil && !u.DeletionScheduledAt.After(now) {
+			due = append(due, u)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].DeletionScheduledAt.Before(*due[j].DeletionScheduledAt) })
+	var ids []string
+	for _, u := range page(due, limit, 0) {
+		ids = append(ids, u.ID)
+	}
+	return ids, nil
+}
+
+// PurgeUser segue o Client: anonimiza o extrato de mana e a trilha, apaga os dados pessoais e
+// aplica as mesmas cascatas das chaves estrangeiras do esquema.
+func (m *MemoryStore) PurgeUser(ctx context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok || u.DeletionScheduledAt == nil || u.DeletionScheduledAt.After(time.Now()) {
+		return pgx.ErrNoRows
+	}
+
+	for i := range m.manaTxs {
+		if m.manaTxs[i].UserID == userID {
+			m.manaTxs[i].UserID = ""
+			m.manaTxs[i].ReferenceID = ""
+		}
+	}
+	if _, err := m.anonymizeAuditEvents(userID); err != nil {
+		return err
+	}
+	owned := map[string]bool{}
+	for _, h := range m.habits {
+		if h.UserID == userID {
+			owned[h.ID] = true
+		}
+	}
+	m.habitLogs = slices.DeleteFunc(m.habitLogs, func(l models.HabitLog) bool { return l.UserID == userID || owned[l.HabitID] })
+	m.freezes = slices.DeleteFunc(m.freezes, func(f models.StreakFreeze) bool { return f.UserID == userID || owned[f.HabitID] })
+	m.pauses = slices.DeleteFunc(m.pauses, func(p models.HabitPause) bool { return owned[p.HabitID] })
+	m.habits = slices.DeleteFunc(m.habits, func(h models.Habit) bool { return h.UserID == userID })
+	m.contacts = slices.DeleteFunc(m.contacts, func(c models.SupportContact) bool { return c.UserID == userID })
+	m.lockouts = slices.DeleteFunc(m.lockouts, func(l *models.LoginLockout) bool { return l.UserID == userID })
+	delete(m.attempts, [2]string{"account", m.LoginAccountKey(u.Email)})
+
+	// ON DELETE CASCADE / SET NULL
+	delete(m.users, userID)
+	delete(m.roles, userID)
+	delete(m.mana, userID)
+	delete(m.recoveryCodes, userID)
+	sessions := map[string]bool{}
+	m.sessions = slices.DeleteFunc(m.sessions, func(s *models.Session) bool {
+		if s.UserID == userID {
+			sessions[s.ID] = true
+			return true
+		}
+		return false
+	})
+	m.refreshTokens = slices.DeleteFunc(m.refreshTokens, func(rt *models.RefreshToken) bool {
+		return rt.UserID == userID || sessions[rt.SessionID]
+	})
+	for hash, t := range m.resetTokens {
+		if t.userID == userID {
+			delete(m.resetTokens, hash)
+		}
+	}
+	for hash, t := range m.verifyTokens {
+		if t.userID == userID {
+			delete(m.verifyTokens, hash)
+		}
+	}
+	for hash, s := range m.oidcStates {
+		if s.LinkUserID == userID {
+			delete(m.oidcStates, hash)
+		}
+	}
+	for hash, ch := range m.challenges {
+		if ch.UserID == userID {
+			delete(m.challenges, hash)
+		}
+	}
+	m.identities = slices.DeleteFunc(m.identities, func(i *models.UserIdentity) bool { return i.UserID == userID })
+	m.apiKeys = slices.DeleteFunc(m.apiKeys, func(k *models.APIKey) bool { return k.UserID == userID })
+	m.credentials = slices.DeleteFunc(m.credentials, func(c *models.WebAuthnCredential) bool { return c.UserID == userID })
+	m.consents = slices.DeleteFunc(m.consents, func(r models.ConsentRecord) bool { return r.UserID == userID })
+	for _, e := range m.exports {
+		if e.UserID == userID {
+			e.UserID = ""
+		}
+	}
+	return nil
+}
+
+// ===== Papéis =====
+
+func (m *MemoryStore) GetUserRoles(ctx context.Context, userID string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var roles []string
+	for role := range m.roles[userID] {
+		roles = append(roles, role)
+	}
+	if len(roles) == 0 {
+		return []string{models.RoleUser}, nil
+	}
+	sort.Strings(roles)
+	return roles, nil
+}
+
+func (m *MemoryStore) GrantRole(ctx context.Context, userID, role, grantedBy string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[userID]; !ok {
+		return fmt.Errorf("falha ao atribuir papel: usuário %s não existe", userID)
+	}
+	if m.roles[userID] == nil {
+		m.roles[userID] = map[string]bool{}
+	}
+	m.roles[userID][role] = true
+	return nil
+}
+
+func (m *MemoryStore) SetUserRoles(ctx context.Context, userID string, roles []string, grantedBy string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[userID]; !ok {
+		return fmt.Errorf("falha ao atribuir papel: usuário %s não existe", userID)
+	}
+	set := map[string]bool{models.RoleUser: true}
+	for _, role := range roles {
+		set[role] = true
+	}
+	m.roles[userID] = set
+	return nil
+}
+
+// ===== Rede de apoio =====
+
+func (m *MemoryStore) CreateSupportContact(ctx context.Context, contact models.SupportContact) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if strings.TrimSpace(contact.ContactID) == "" {
+		contact.ContactID = uuid.New().String()
+	}
+	for _, c := range m.contacts {
+		if c.ContactID == contact.ContactID {
+			return fmt.Errorf("contato %s já existe", contact.ContactID)
+		}
+	}
+	m.contacts = append(m.contacts, models.SupportContact{
+		ContactID:              contact.ContactID,
+		UserID:                 contact.UserID,
+		ContactEmail:           strings.TrimSpace(contact.ContactEmail),
+		Phone:                  strings.TrimSpace(contact.Phone),
+		Nickname:               strings.TrimSpace(contact.Nickname),
+		NotificationPreference: strings.TrimSpace(contact.NotificationPreference),
+	})
+	return nil
+}
+
+func (m *MemoryStore) GetSupportContactsByUserID(ctx context.Context, userID string) ([]models.SupportContact, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var contacts []models.SupportContact
+	for _, c := range m.contacts {
+		if c.UserID == userID {
+			contacts = append(contacts, c)
+		}
+	}
+	return contacts, nil
+}
+
+func (m *MemoryStore) deleteContactLocked(match func(models.SupportContact) bool) error {
+	before := len(m.contacts)
+	m.contacts = slices.DeleteFunc(m.contacts, match)
+	if len(m.contacts) == before {
+		return errors.New("contato não encontrado")
+	}
+	return nil
+}
+
+func (m *MemoryStore) DeleteSupportContact(ctx context.Context, contactID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.deleteContactLocked(func(c models.SupportContact) bool { return c.ContactID == contactID })
+}
+
+func (m *MemoryStore) DeleteSupportContactByUser(ctx context.Context, userID, contactID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.deleteContactLocked(func(c models.SupportContact) bool { return c.ContactID == contactID && c.UserID == userID })
+}
+
+// ===== Hábitos =====
+
+func (m *MemoryStore) CreateHabit(ctx context.Context, habit models.Habit) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if strings.TrimSpace(habit.ID) == "" {
+		habit.ID = uuid.New().String()
+	}
+	for _, h := range m.habits {
+		if h.ID == habit.ID {
+			return "", fmt.Errorf("hábito %s já existe", habit.ID)
+		}
+	}
+	status := cmp.Or(habit.Status, models.HabitActive)
+	m.trackPause(habit.ID, models.HabitActive, status)
+	m.habits = append(m.habits, models.Habit{
+		ID:        habit.ID,
+		UserID:    habit.UserID,
+		Name:      strings.TrimSpace(habit.Name),
+		GoalType:  strings.TrimSpace(habit.GoalType),
+		Frequency: strings.TrimSpace(habit.Frequency),
+		Schedule:  cloneSchedule(habit.Schedule),
+		Goal:      cloneGoal(habit.Goal),
+		Status:    status,
+		CreatedAt: time.Now(),
+	})
+	return habit.ID, nil
+}
+
+func (m *MemoryStore) GetHabitsByUserID(ctx context.Context, userID string) ([]models.Habit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var habits []models.Habit
+	for i := len(m.habits) - 1; i >= 0; i-- {
+		if m.habits[i].UserID == userID {
+			habits = append(habits, cloneHabit(m.habits[i]))
+		}
+	}
+	return habits, nil
+}
+
+func (m *MemoryStore) GetHabitById(ctx context.Context, habitID string) (models.Habit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, h := range m.habits {
+		if h.ID == habitID {
+			return cloneHabit(h), nil
+		}
+	}
+	return models.Habit{}, pgx.ErrNoRows
+}
+
+func (m *MemoryStore) DeleteHabit(ctx context.Context, habitID string, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	before := len(m.habits)
+	m.habits = slices.DeleteFunc(m.habits, func(h models.Habit) bool { return h.ID == habitID && h.UserID == userID })
+	if len(m.habits) == before {
+		return errors.New("hábito não encontrado ou permissão negada")
+	}
+	m.habitLogs = slices.DeleteFunc(m.habitLogs, func(l models.HabitLog) bool { return l.HabitID == habitID })
+	m.freezes = slices.DeleteFunc(m.freezes, func(f models.StreakFreeze) bool { return f.HabitID == habitID })
+	m.pauses = slices.DeleteFunc(m.pauses, func(p models.HabitPause) bool { return p.HabitID == habitID })
+	return nil
+}
+
+func (m *MemoryStore) UpdateHabit(ctx context.Context, habit models.Habit) (models.Habit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.habits, func(h models.Habit) bool { return h.ID == habit.ID && h.UserID == habit.UserID })
+	if i < 0 {
+		return models.Habit{}, pgx.ErrNoRows
+	}
+	h := &m.habits[i]
+	if h.Status == models.HabitArchived {
+		return models.Habit{}, ErrHabitArchived
+	}
+	m.trackPause(h.ID, h.Status, habit.Status)
+	h.Name, h.GoalType, h.Frequency, h.Status = habit.Name, habit.GoalType, habit.Frequency, habit.Status
+	h.Schedule = cloneSchedule(habit.Schedule)
+	h.Goal = cloneGoal(habit.Goal)
+	h.UpdatedAt = timePtr(time.Now())
+	return cloneHabit(*h), nil
+}
+
+func (m *MemoryStore) SetHabitStatus(ctx context.Context, habitID, userID, status string) (models.Habit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.habits, func(h models.Habit) bool { return h.ID == habitID && h.UserID == userID })
+	if i < 0 {
+		return models.Habit{}, pgx.ErrNoRows
+	}
+	m.trackPause(habitID, m.habits[i].Status, status)
+	m.habits[i].Status = status
+	m.habits[i].UpdatedAt = timePtr(time.Now())
+	return cloneHabit(m.habits[i]), nil
+}
+
+// trackPause abre ou fecha o período de pausa como o gatilho habits_track_pauses; m.mu deve estar bloqueado.
+func (m *MemoryStore) trackPause(habitID, from, to string) {
+	switch {
+	case from == models.HabitActive && to != models.HabitActive:
+		m.pauses = append(m.pauses, models.HabitPause{HabitID: habitID, PausedAt: time.Now()})
+	case from != models.HabitActive && to == models.HabitActive:
+		for i := range m.pauses {
+			if m.pauses[i].HabitID == habitID && m.pauses[i].ResumedAt == nil {
+				m.pauses[i].ResumedAt = timePtr(time.Now())
+			}
+		}
+	}
+}
+
+func (m *MemoryStore) ListHabitPauses(ctx context.Context, habitID string) ([]models.HabitPause, error) {
+	return m.listHabitPauses(func(p models.HabitPause) bool { return p.HabitID == habitID }), nil
+}
+
+func (m *MemoryStore) ListHabitPausesByUser(ctx context.Context, userID string) ([]models.HabitPause, error) {
+	m.mu.Lock()
+	owned := map[string]bool{}
+	for _, h := range m.habits {
+		if h.UserID == userID {
+			owned[h.ID] = true
+		}
+	}
+	m.mu.Unlock()
+	return m.listHabitPauses(func(p models.HabitPause) bool { return owned[p.HabitID] }), nil
+}
+
+func (m *MemoryStore) listHabitPauses(match func(models.HabitPause) bool) []models.HabitPause {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pauses := []models.HabitPause{}
+	for _, p := range m.pauses {
+		if match(p) {
+			p.ResumedAt = cloneTime(p.ResumedAt)
+			pauses = append(pauses, p)
+		}
+	}
+	return pauses
+}
+
+func cloneHabit(h models.Habit) models.Habit {
+	h.Schedule = cloneSchedule(h.Schedule)
+	h.Goal = cloneGoal(h.Goal)
+	h.UpdatedAt = cloneTime(h.UpdatedAt)
+	return h
+}
+
+func cloneGoal(g *models.HabitGoal) *models.HabitGoal {
+	if g == nil {
+		return nil
+	}
+	c := *g
+	return &c
+}
+
+func cloneSchedule(s *models.HabitSchedule) *models.HabitSchedule {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	c.Weekdays = slices.Clone(s.Weekdays)
+	c.MonthDays = slices.Clone(s.MonthDays)
+	return &c
+}
+
+func (m *MemoryStore) LogHabit(ctx context.Context, logData models.HabitLog, mana int) (models.HabitLog, *LogManaAdjustment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !slices.ContainsFunc(m.habits, func(h models.Habit) bool { return h.ID == logData.HabitID }) {
+		return models.HabitLog{}, nil, fmt.Errorf("hábito %s não existe", logData.HabitID)
+	}
+	stored := models.HabitLog{
+		ID:        strconv.FormatInt(m.nextID(), 10),
+		HabitID:   logData.HabitID,
+		UserID:    logData.UserID,
+		Value:     logData.Value,
+		Timestamp: cmp.Or(logData.Timestamp, time.Now()),
+	}
+	var grant *LogManaAdjustment
+	if mana > 0 {
+		balance, ok := m.mana[logData.UserID]
+		if !ok {
+			return models.HabitLog{}, nil, fmt.Errorf("falha ao atualizar saldo: %w", pgx.ErrNoRows)
+		}
+		grant = &LogManaAdjustment{Amount: mana, Balance: balance + mana}
+		m.mana[logData.UserID] = grant.Balance
+		m.manaTxs = append(m.manaTxs, models.ManaTransaction{
+			ID:          strconv.FormatInt(m.nextID(), 10),
+			UserID:      logData.UserID,
+			Type:        models.ManaTypeHabitCompletion,
+			Amount:      mana,
+			ReferenceID: stored.ID,
+			CreatedAt:   time.Now(),
+		})
+	}
+	m.habitLogs = append(m.habitLogs, stored)
+	return stored, grant, nil
+}
+
+// editableLogIndex acha o registro do usuário no hábito, com os erros de Client.inLogTx.
+func (m *MemoryStore) editableLogIndex(habitID, logID, userID string, since time.Time) (int, error) {
+	i := slices.IndexFunc(m.habitLogs, func(l models.HabitLog) bool {
+		return l.ID == logID && l.HabitID == habitID && l.UserID == userID
+	})
+	switch {
+	case i < 0:
+		return i, pgx.ErrNoRows
+	case m.habitLogs[i].Timestamp.Before(since):
+		return i, ErrHabitLogTooOld
+	}
+	return i, nil
+}
+
+func (m *MemoryStore) UpdateHabitLog(ctx context.Context, logData models.HabitLog, mana int, since time.Time) (models.HabitLog, *LogManaAdjustment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i, err := m.editableLogIndex(logData.HabitID, logData.ID, logData.UserID, since)
+	if err != nil {
+		return models.HabitLog{}, nil, err
+	}
+	adj, err := m.adjustLogMana(logData.UserID, logData.ID, mana)
+	if err != nil {
+		return models.HabitLog{}, nil, err
+	}
+	l := &m.habitLogs[i]
+	l.Value = logData.Value
+	l.Timestamp = cmp.Or(logData.Timestamp, l.Timestamp)
+	return *l, adj, nil
+}
+
+func (m *MemoryStore) DeleteHabitLog(ctx context.Context, habitID, logID, userID string, since time.Time) (*LogManaAdjustment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i, err := m.editableLogIndex(habitID, logID, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	adj, err := m.adjustLogMana(userID, logID, 0)
+	if err != nil {
+		return nil, err
+	}
+	m.habitLogs = slices.Delete(m.habitLogs, i, i+1)
+	return adj, nil
+}
+
+// adjustLogMana segue Client.adjustLogMana; m.mu deve estar bloqueado.
+func (m *MemoryStore) adjustLogMana(userID, logID string, mana int) (*LogManaAdjustment, error) {
+	net := 0
+	for _, t := range m.manaTxs {
+		if t.UserID != userID || t.ReferenceID != logID {
+			continue
+		}
+		if t.Type == models.ManaTypeHabitCompletion || t.Type == models.ManaTypeHabitLogAdjustment {
+			net += t.Amount
+		}
+	}
+	if mana == net {
+		return nil, nil
+	}
+	balance, ok := m.mana[userID]
+	if !ok {
+		return nil, fmt.Errorf("falha ao atualizar saldo: %w", pgx.ErrNoRows)
+	}
+	adj := &LogManaAdjustment{Amount: mana - net, Balance: balance + mana - net}
+	m.mana[userID] = adj.Balance
+	m.manaTxs = append(m.manaTxs, models.ManaTransaction{
+		ID:          strconv.FormatInt(m.nextID(), 10),
+		UserID:      userID,
+		Type:        models.ManaTypeHabitLogAdjustment,
+		Amount:      adj.Amount,
+		ReferenceID: logID,
+		CreatedAt:   time.Now(),
+	})
+	return adj, nil
+}
+
+func (m *MemoryStore) GetHabitLogs(ctx context.Context, habitID string) ([]models.HabitLog, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var logs []models.HabitLog
+	for _, l := range m.habitLogs {
+		if l.HabitID == habitID {
+			logs = append(logs, l)
+		}
+	}
+	slices.SortStableFunc(logs, func(a, b models.HabitLog) int { return b.Timestamp.Compare(a.Timestamp) })
+	return logs, nil
+}
+
+func (m *MemoryStore) ListHabitLogsByUser(ctx context.Context, userID string) ([]models.HabitLog, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var logs []models.HabitLog
+	for _, l := range m.habitLogs {
+		if l.UserID == userID {
+			logs = append(logs, l)
+		}
+	}
+	slices.SortStableFunc(logs, func(a, b models.HabitLog) int { return a.Timestamp.Compare(b.Timestamp) })
+	return logs, nil
+}
+
+func (m *MemoryStore) ListStreakFreezes(ctx context.Context, habitID string) ([]models.StreakFreeze, error) {
+	return m.listStreakFreezes(func(f models.StreakFreeze) bool { return f.HabitID == habitID }), nil
+}
+
+func (m *MemoryStore) ListStreakFreezesByUser(ctx context.Context, userID string) ([]models.StreakFreeze, error) {
+	return m.listStreakFreezes(func(f models.StreakFreeze) bool { return f.UserID == userID }), nil
+}
+
+func (m *MemoryStore) listStreakFreezes(match func(models.StreakFreeze) bool) []models.StreakFreeze {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	freezes := []models.StreakFreeze{}
+	for _, f := range m.freezes {
+		if match(f) {
+			freezes = append(freezes, f)
+		}
+	}
+	sort.SliceStable(freezes, func(i, j int) bool { return freezes[i].Day < freezes[j].Day })
+	return freezes
+}
+
+func (m *MemoryStore) BuyStreakFreeze(ctx context.Context, freeze models.StreakFreeze) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	balance, ok := m.mana[freeze.UserID]
+	if !ok || balance < freeze.ManaCost {
+		return 0, ErrInsufficientMana
+	}
+	if slices.ContainsFunc(m.freezes, func(f models.StreakFreeze) bool { return f.HabitID == freeze.HabitID && f.Day == freeze.Day }) {
+		return 0, ErrStreakFreezeExists
+	}
+	if !slices.ContainsFunc(m.habits, func(h models.Habit) bool { return h.ID == freeze.HabitID }) {
+		return 0, fmt.Errorf("falha ao gravar proteção: hábito %s não existe", freeze.HabitID)
+	}
+	now := time.Now()
+	m.mana[freeze.UserID] = balance - freeze.ManaCost
+	freeze.CreatedAt = now
+	m.freezes = append(m.freezes, freeze)
+	m.manaTxs = append(m.manaTxs, models.ManaTransaction{
+		ID:          strconv.FormatInt(m.nextID(), 10),
+		UserID:      freeze.UserID,
+		Type:        models.ManaTypeStreakFreeze,
+		Amount:      -freeze.ManaCost,
+		ReferenceID: freeze.HabitID,
+		CreatedAt:   now,
+	})
+	return m.mana[freeze.UserID], nil
+}
+
+// ===== Mana =====
+
+func (m *MemoryStore) GetManaBalance(ctx context.Context, userID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mana[userID], nil
+}
+
+func (m *MemoryStore) UpdateManaBalance(ctx context.Context, txData models.ManaTransaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	balance, ok := m.mana[txData.UserID]
+	if !ok {
+		return fmt.Errorf("falha ao atualizar saldo: %w", pgx.ErrNoRows)
+	}
+	m.mana[txData.UserID] = balance + txData.Amount
+	m.manaTxs = append(m.manaTxs, models.ManaTransaction{
+		ID:          strconv.FormatInt(m.nextID(), 10),
+		UserID:      txData.UserID,
+		Type:        txData.Type,
+		Amount:      txData.Amount,
+		ReferenceID: txData.ReferenceID,
+		CreatedAt:   time.Now(),
+	})
+	return nil
+}
+
+func (m *MemoryStore) CreateManaTransaction(ctx context.Context, tx models.ManaTransaction) error {
+	return m.UpdateManaBalance(ctx, tx)
+}
+
+func (m *MemoryStore) GetTopManaUsers(ctx context.Context, limit int) ([]models.LeaderboardEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var entries []models.LeaderboardEntry
+	for id, balance := range m.mana {
+		if u, ok := m.users[id]; ok {
+			entries = append(entries, models.LeaderboardEntry{UserID: id, UserName: u.Name, Mana: balance})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Mana != entries[j].Mana {
+			return entries[i].Mana > entries[j].Mana
+		}
+		return entries[i].UserID < entries[j].UserID
+	})
+	return page(entries, limit, 0), nil
+}
+
+func (m *MemoryStore) ListManaTransactions(ctx context.Context, userID string) ([]models.ManaTransaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var txs []models.ManaTransaction
+	for _, t := range m.manaTxs {
+		if t.UserID == userID {
+			txs = append(txs, t)
+		}
+	}
+	return txs, nil
+}
+
+// ===== Sessões =====
+
+func cloneSession(s *models.Session) models.Session {
+	out := *s
+	out.RevokedAt = cloneTime(s.RevokedAt)
+	return out
+}
+
+func (m *MemoryStore) session(sessionID string) *models.Session {
+	for _, s := range m.sessions {
+		if s.ID == sessionID {
+			return s
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) CreateSession(ctx context.Context, userID, userAgent, ip string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	now := time.Now()
+	s := &models.Session{ID: uuid.New().String(), UserID: userID, UserAgent: userAgent, IP: ip, CreatedAt: now, LastSeenAt: now}
+	m.sessions = append(m.sessions, s)
+	return s.ID, nil
+}
+
+func (m *MemoryStore) GetSession(ctx context.Context, sessionID string) (models.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.session(sessionID)
+	if s == nil {
+		return models.Session{}, pgx.ErrNoRows
+	}
+	return cloneSession(s), nil
+}
+
+func (m *MemoryStore) TouchSession(ctx context.Context, sessionID, ip string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s := m.session(sessionID); s != nil {
+		s.LastSeenAt = time.Now()
+		s.IP = ip
+	}
+	return nil
+}
+
+func (m *MemoryStore) ListActiveSessions(ctx context.Context, userID string) ([]models.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	live := map[string]bool{}
+	for _, rt := range m.refreshTokens {
+		if rt.UsedAt == nil && rt.ExpiresAt.After(now) {
+			live[rt.SessionID] = true
+		}
+	}
+	var sessions []models.Session
+	for _, s := range m.sessions {
+		if s.UserID == userID && s.RevokedAt == nil && live[s.ID] {
+			sessions = append(sessions, cloneSession(s))
+		}
+	}
+	sort.SliceStable(sessions, func(i, j int) bool { return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt) })
+	return sessions, nil
+}
+
+func (m *MemoryStore) ListAllSessions(ctx context.Context, userID string) ([]models.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var sessions []models.Session
+	for _, s := range m.sessions {
+		if s.UserID == userID {
+			sessions = append(sessions, cloneSession(s))
+		}
+	}
+	return sessions, nil
+}
+
+func (m *MemoryStore) IsSessionActive(ctx context.Context, sessionID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.session(sessionID)
+	return s != nil && s.RevokedAt == nil, nil
+}
+
+func (m *MemoryStore) RevokeSession(ctx context.Context, sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s := m.session(sessionID); s != nil && s.RevokedAt == nil {
+		s.RevokedAt = timePtr(time.Now())
+	}
+	return nil
+}
+
+func (m *MemoryStore) RevokeSessionByUser(ctx context.Context, userID, sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.session(sessionID)
+	if s == nil || s.UserID != userID || s.RevokedAt != nil {
+		return pgx.ErrNoRows
+	}
+	s.RevokedAt = timePtr(time.Now())
+	return nil
+}
+
+// revokeUserSessionsLocked revoga as sessões ativas do usuário, exceto keepSessionID.
+func (m *MemoryStore) revokeUserSessionsLocked(userID, keepSessionID string) int64 {
+	var n int64
+	now := time.Now()
+	for _, s := range m.sessions {
+		if s.UserID == userID && s.ID != keepSessionID && s.RevokedAt == nil {
+			s.RevokedAt = timePtr(now)
+			n++
+		}
+	}
+	return n
+}
+
+func (m *MemoryStore) RevokeOtherSessions(ctx context.Context, userID, keepSessionID string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.revokeUserSessionsLocked(userID, keepSessionID), nil
+}
+
+func (m *MemoryStore) RevokeUserSessions(ctx context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.revokeUserSessionsLocked(userID, "")
+	return nil
+}
+
+func (m *MemoryStore) insertRefreshTokenLocked(rt models.RefreshToken) error {
+	if strings.TrimSpace(rt.ID) == "" {
+		rt.ID = uuid.New().String()
+	}
+	for _, other := range m.refreshTokens {
+		if other.ID == rt.ID || other.TokenHash == rt.TokenHash {
+			return errors.New("falha ao registrar refresh token: token duplicado")
+		}
+	}
+	m.refreshTokens = append(m.refreshTokens, &models.RefreshToken{
+		ID:        rt.ID,
+		SessionID: rt.SessionID,
+		UserID:    rt.UserID,
+		TokenHash: rt.TokenHash,
+		ExpiresAt: rt.ExpiresAt,
+		CreatedAt: time.Now(),
+	})
+	return nil
+}
+
+func (m *MemoryStore) CreateRefreshToken(ctx context.Context, rt models.RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.insertRefreshTokenLocked(rt)
+}
+
+func (m *MemoryStore) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (models.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, rt := range m.refreshTokens {
+		if rt.TokenHash == tokenHash {
+			out := *rt
+			out.UsedAt = cloneTime(rt.UsedAt)
+			return out, nil
+		}
+	}
+	return models.RefreshToken{}, pgx.ErrNoRows
+}
+
+func (m *MemoryStore) RotateRefreshToken(ctx context.Context, currentID string, next models.RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var current *models.RefreshToken
+	for _, rt := range m.refreshTokens {
+		if rt.ID == currentID {
+			current = rt
+			break
+		}
+	}
+	if current == nil || current.UsedAt != nil {
+		return ErrRefreshTokenReused
+	}
+	if err := m.insertRefreshTokenLocked(next); err != nil {
+		return err
+	}
+	current.UsedAt = timePtr(time.Now())
+	return nil
+}
+
+// ===== 2FA =====
+
+func (m *MemoryStore) GetTOTPState(ctx context.Context, userID string) (TOTPState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok {
+		return TOTPState{}, pgx.ErrNoRows
+	}
+	return TOTPState{Enabled: u.TOTPEnabled, Secret: u.totpSecret, PendingSecret: u.totpPendingSecret}, nil
+}
+
+func (m *MemoryStore) SetPendingTOTPSecret(ctx context.Context, userID, secret string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok {
+		return pgx.ErrNoRows
+	}
+	u.totpPendingSecret = secret
+	return nil
+}
+
+func (m *MemoryStore) EnableTOTP(ctx context.Context, userID string, step int64, recoveryHashes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok || u.totpPendingSecret == "" {
+		return pgx.ErrNoRows
+	}
+	u.totpSecret, u.totpPendingSecret = u.totpPendingSecret, ""
+	u.TOTPEnabled = true
+	u.totpLastStep = &step
+	codes := make([]memRecoveryCode, 0, len(recoveryHashes))
+	for _, h := range recoveryHashes {
+		codes = append(codes, memRecoveryCode{hash: h})
+	}
+	m.recoveryCodes[userID] = codes
+	return nil
+}
+
+func (m *MemoryStore) DisableTOTP(ctx context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if u, ok := m.users[userID]; ok {
+		u.TOTPEnabled = false
+		u.totpSecret, u.totpPendingSecret, u.totpLastStep = "", "", nil
+	}
+	delete(m.recoveryCodes, userID)
+	return nil
+}
+
+func (m *MemoryStore) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok || (u.totpLastStep != nil && *u.totpLastStep >= step) {
+		return false, nil
+	}
+	u.totpLastStep = &step
+	return true, nil
+}
+
+func (m *MemoryStore) ConsumeRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	codes := m.recoveryCodes[userID]
+	for i := range codes {
+		if codes[i].hash == codeHash && !codes[i].used {
+			codes[i].used = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) CountRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, c := range m.recoveryCodes[userID] {
+		if !c.used {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// ===== Passkeys (WebAuthn) =====
+
+func cloneCredential(c *models.WebAuthnCredential) models.WebAuthnCredential {
+	out := *c
+	out.CredentialID = bytes.Clone(c.CredentialID)
+	out.PublicKey = bytes.Clone(c.PublicKey)
+	out.AAGUID = bytes.Clone(c.AAGUID)
+	if out.AAGUID == nil {
+		out.AAGUID = []byte{}
+	}
+	out.LastUsedAt = cloneTime(c.LastUsedAt)
+	return out
+}
+
+func (m *MemoryStore) CreateWebAuthnChallenge(ctx context.Context, challengeHash string, ch models.WebAuthnChallenge) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.challenges[challengeHash]; exists {
+		return errors.New("falha ao registrar desafio WebAuthn: desafio duplicado")
+	}
+	m.challenges[challengeHash] = ch
+	return nil
+}
+
+func (m *MemoryStore) ConsumeWebAuthnChallenge(ctx context.Context, challengeHash string) (models.WebAuthnChallenge, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ch, ok := m.challenges[challengeHash]
+	delete(m.challenges, challengeHash)
+	now := time.Now()
+	if !ok || !ch.ExpiresAt.After(now) {
+		return models.WebAuthnChallenge{}, ErrWebAuthnChallengeInvalid
+	}
+	for hash, other := range m.challenges {
+		if other.ExpiresAt.Before(now) {
+			delete(m.challenges, hash)
+		}
+	}
+	return ch, nil
+}
+
+func (m *MemoryStore) CreateWebAuthnCredential(ctx context.Context, cred models.WebAuthnCredential) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if strings.TrimSpace(cred.ID) == "" {
+		cred.ID = uuid.New().String()
+	}
+	for _, c := range m.credentials {
+		if c.ID == cred.ID || bytes.Equal(c.CredentialID, cred.CredentialID) {
+			return "", ErrWebAuthnCredentialExists
+		}
+	}
+	stored := cloneCredential(&cred)
+	stored.Name = strings.TrimSpace(cred.Name)
+	stored.CreatedAt = time.Now()
+	stored.LastUsedAt = nil
+	m.credentials = append(m.credentials, &stored)
+	return cred.ID, nil
+}
+
+func (m *MemoryStore) GetWebAuthnCredential(ctx context.Context, credentialID []byte) (models.WebAuthnCredential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.credentials {
+		if bytes.Equal(c.CredentialID, credentialID) {
+			return cloneCredential(c), nil
+		}
+	}
+	return models.WebAuthnCredential{}, pgx.ErrNoRows
+}
+
+func (m *MemoryStore) ListWebAuthnCredentials(ctx context.Context, userID string) ([]models.WebAuthnCredential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var creds []models.WebAuthnCredential
+	for _, c := range m.credentials {
+		if c.UserID == userID {
+			creds = append(creds, cloneCredential(c))
+		}
+	}
+	return creds, nil
+}
+
+func (m *MemoryStore) CountWebAuthnCredentials(ctx context.Context, userID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, c := range m.credentials {
+		if c.UserID == userID {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *MemoryStore) UseWebAuthnCredential(ctx context.Context, id string, storedCount, newCount uint32, backupState bool) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.credentials {
+		if c.ID == id && c.SignCount == storedCount {
+			c.SignCount = newCount
+			c.BackupState = backupState
+			c.LastUsedAt = timePtr(time.Now())
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) DeleteWebAuthnCredential(ctx context.Context, userID, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	before := len(m.credentials)
+	m.credentials = slices.DeleteFunc(m.credentials, func(c *models.WebAuthnCredential) bool { return c.ID == id && c.UserID == userID })
+	if len(m.credentials) == before {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// ===== Identidades externas (OIDC) =====
+
+func cloneIdentity(i *models.UserIdentity) models.UserIdentity {
+	out := *i
+	out.LastLoginAt = cloneTime(i.LastLoginAt)
+	return out
+}
+
+func (m *MemoryStore) CreateOIDCState(ctx context.Context, stateHash string, s models.OIDCLoginState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.oidcStates[stateHash]; exists {
+		return errors.New("falha ao registrar state OIDC: state duplicado")
+	}
+	m.oidcStates[stateHash] = s
+	return nil
+}
+
+func (m *MemoryStore) ConsumeOIDCState(ctx context.Context, stateHash string) (models.OIDCLoginState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.oidcStates[stateHash]
+	delete(m.oidcStates, stateHash)
+	now := time.Now()
+	if !ok || !s.ExpiresAt.After(now) {
+		return models.OIDCLoginState{}, ErrOIDCStateInvalid
+	}
+	for hash, other := range m.oidcStates {
+		if other.ExpiresAt.Before(now) {
+			delete(m.oidcStates, hash)
+		}
+	}
+	return s, nil
+}
+
+func (m *MemoryStore) GetIdentity(ctx context.Context, provider, subject string) (models.UserIdentity, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, i := range m.identities {
+		if i.Provider == provider && i.Subject == subject {
+			return cloneIdentity(i), nil
+		}
+	}
+	return models.UserIdentity{}, pgx.ErrNoRows
+}
+
+// linkIdentityLocked aplica as restrições de (provider, subject) e (user_id, provider).
+func (m *MemoryStore) linkIdentityLocked(i models.UserIdentity) error {
+	for _, other := range m.identities {
+		if other.Provider == i.Provider && (other.Subject == i.Subject || other.UserID == i.UserID) {
+			return ErrIdentityLinked
+		}
+	}
+	now := time.Now()
+	m.identities = append(m.identities, &models.UserIdentity{
+		Provider:    i.Provider,
+		Subject:     i.Subject,
+		UserID:      i.UserID,
+		Email:       normalizeEmail(i.Email),
+		CreatedAt:   now,
+		LastLoginAt: timePtr(now),
+	})
+	return nil
+}
+
+func (m *MemoryStore) LinkIdentity(ctx context.Context, i models.UserIdentity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.linkIdentityLocked(i)
+}
+
+func (m *MemoryStore) CreateUserWithIdentity(ctx context.Context, user models.User, i models.UserIdentity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.userByEmail(user.Email) != nil {
+		return ErrEmailTaken
+	}
+	for _, other := range m.identities {
+		if other.Provider == i.Provider && other.Subject == i.Subject {
+			return ErrIdentityLinked
+		}
+	}
+	id, err := m.createUserLocked(user)
+	if err != nil {
+		return err
+	}
+	i.UserID = id
+	return m.linkIdentityLocked(i)
+}
+
+func (m *MemoryStore) CountUserIdentities(ctx context.Context, userID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, i := range m.identities {
+		if i.UserID == userID {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *MemoryStore) TouchIdentity(ctx context.Context, provider, subject, email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, i := range m.identities {
+		if i.Provider == provider && i.Subject == subject {
+			i.LastLoginAt = timePtr(time.Now())
+			i.Email = normalizeEmail(email)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) ListUserIdentities(ctx context.Context, userID string) ([]models.UserIdentity, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []models.UserIdentity
+	for _, i := range m.identities {
+		if i.UserID == userID {
+			out = append(out, cloneIdentity(i))
+		}
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) UnlinkIdentity(ctx context.Context, userID, provider string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	before := len(m.identities)
+	m.identities = slices.DeleteFunc(m.identities, func(i *models.UserIdentity) bool { return i.UserID == userID && i.Provider == provider })
+	if len(m.identities) == before {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// ===== Chaves de API =====
+
+func cloneAPIKey(k *models.APIKey) models.APIKey {
+	out := *k
+	out.Scopes = slices.Clone(k.Scopes)
+	out.LastUsedAt = cloneTime(k.LastUsedAt)
+	out.ExpiresAt = cloneTime(k.ExpiresAt)
+	out.RevokedAt = cloneTime(k.RevokedAt)
+	return out
+}
+
+func (m *MemoryStore) CreateAPIKey(ctx context.Context, k models.APIKey) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if strings.TrimSpace(k.ID) == "" {
+		k.ID = uuid.New().String()
+	}
+	for _, other := range m.apiKeys {
+		if other.ID == k.ID || other.Prefix == k.Prefix {
+			return "", errors.New("falha ao criar chave de API: chave duplicada")
+		}
+	}
+	stored := cloneAPIKey(&k)
+	stored.Name = strings.TrimSpace(k.Name)
+	stored.CreatedAt = time.Now()
+	stored.LastUsedAt, stored.RevokedAt = nil, nil
+	m.apiKeys = append(m.apiKeys, &stored)
+	return k.ID, nil
+}
+
+func (m *MemoryStore) GetAPIKeyByPrefix(ctx context.Context, prefix string) (models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range m.apiKeys {
+		if k.Prefix == prefix {
+			return cloneAPIKey(k), nil
+		}
+	}
+	return models.APIKey{}, pgx.ErrNoRows
+}
+
+func (m *MemoryStore) ListAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []models.APIKey
+	for i := len(m.apiKeys) - 1; i >= 0; i-- {
+		if k := m.apiKeys[i]; k.UserID == userID && k.RevokedAt == nil {
+			keys = append(keys, cloneAPIKey(k))
+		}
+	}
+	return keys, nil
+}
+
+func (m *MemoryStore) TouchAPIKey(ctx context.Context, keyID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range m.apiKeys {
+		if k.ID == keyID {
+			k.LastUsedAt = timePtr(time.Now())
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) RevokeAPIKey(ctx context.Context, userID, keyID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range m.apiKeys {
+		if k.ID == keyID && k.UserID == userID && k.RevokedAt == nil {
+			k.RevokedAt = timePtr(time.Now())
+			return nil
+		}
+	}
+	return pgx.ErrNoRows
+}
+
+// ===== Tentativas de login e bloqueios =====
+
+func (a *memAttempts) view() models.LoginAttempts {
+	out := models.LoginAttempts{Failures: a.failures}
+	if a.last != nil {
+		out.LastFailure = *a.last
+	}
+	if a.locked != nil {
+		out.LockedUntil = *a.locked
+	}
+	return out
+}
+
+func cloneLockout(l *models.LoginLockout) models.LoginLockout {
+	out := *l
+	out.UnlockedAt = cloneTime(l.UnlockedAt)
+	return out
+}
+
+// LoginAccountKey segue Client.LoginAccountKey; sem chave HMAC, usa o SHA-256 do e-mail
+// normalizado (o store em memória não persiste nada).
+func (m *MemoryStore) LoginAccountKey(email string) string {
+	sum := sha256.Sum256([]byte(normalizeEmail(email)))
+	return hex.EncodeToString(sum[:])
+}
+
+func (m *MemoryStore) GetLoginAttempts(ctx context.Context, scope, key string, window time.Duration) (models.LoginAttempts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.attempts[[2]string{scope, key}]
+	if !ok {
+		return models.LoginAttempts{}, nil
+	}
+	out := a.view()
+	if a.last != nil && time.Since(*a.last) > window {
+		out.Failures = 0
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) RegisterLoginFailure(ctx context.Context, scope, key string, window time.Duration) (models.LoginAttempts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	k := [2]string{scope, key}
+	a, ok := m.attempts[k]
+	switch {
+	case !ok:
+		a = &memAttempts{failures: 1}
+		m.attempts[k] = a
+	case a.last != nil && a.last.Before(now.Add(-window)):
+		a.failures = 1
+	default:
+		a.failures++
+	}
+	a.last = timePtr(now)
+	return a.view(), nil
+}
+
+func (m *MemoryStore) LockLogin(ctx context.Context, scope, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := [2]string{scope, key}
+	a, ok := m.attempts[k]
+	if !ok {
+		a = &memAttempts{}
+		m.attempts[k] = a
+	}
+	a.locked = timePtr(until)
+	a.failures = 0
+	return nil
+}
+
+func (m *MemoryStore) ResetLoginAttempts(ctx context.Context, scope, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.attempts, [2]string{scope, key})
+	return nil
+}
+
+func (m *MemoryStore) RecordLockout(ctx context.Context, l models.LoginLockout) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lockouts = append(m.lockouts, &models.LoginLockout{
+		ID:          m.nextID(),
+		Scope:       l.Scope,
+		Key:         l.Key,
+		UserID:      l.UserID,
+		IP:          l.IP,
+		Failures:    l.Failures,
+		LockedUntil: l.LockedUntil,
+		CreatedAt:   time.Now(),
+	})
+	return nil
+}
+
+func (m *MemoryStore) ListLockouts(ctx context.Context, activeOnly bool, limit, offset int) ([]models.LoginLockout, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	var lockouts []models.LoginLockout
+	for i := len(m.lockouts) - 1; i >= 0; i-- {
+		l := m.lockouts[i]
+		if !activeOnly || (l.UnlockedAt == nil && l.LockedUntil.After(now)) {
+			lockouts = append(lockouts, cloneLockout(l))
+		}
+	}
+	return page(lockouts, limit, offset), nil
+}
+
+func (m *MemoryStore) GetLockout(ctx context.Context, id int64) (models.LoginLockout, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, l := range m.lockouts {
+		if l.ID == id {
+			return cloneLockout(l), nil
+		}
+	}
+	return models.LoginLockout{}, pgx.ErrNoRows
+}
+
+func (m *MemoryStore) MarkLockoutUnlocked(ctx context.Context, id int64, adminID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, l := range m.lockouts {
+		if l.ID == id && l.UnlockedAt == nil {
+			l.UnlockedAt = timePtr(time.Now())
+			l.UnlockedBy = adminID
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) PurgeLoginHistory(ctx context.Context, before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, a := range m.attempts {
+		if (a.last == nil || a.last.Before(before)) && (a.locked == nil || a.locked.Before(before)) {
+			delete(m.attempts, k)
+		}
+	}
+	m.lockouts = slices.DeleteFunc(m.lockouts, func(l *models.LoginLockout) bool { return l.LockedUntil.Before(before) })
+	return nil
+}
+
+// ===== Consentimentos =====
+
+func (m *MemoryStore) RecordConsents(ctx context.Context, records []models.ConsentRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for _, rec := range records {
+		if len(rec.UserAgent) > 512 {
+			rec.UserAgent = rec.UserAgent[:512]
+		}
+		rec.ID = m.nextID()
+		rec.CreatedAt = now
+		m.consents = append(m.consents, rec)
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetCurrentConsents(ctx context.Context, userID string) (map[string]models.ConsentRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current := map[string]models.ConsentRecord{}
+	for _, rec := range m.consents {
+		if rec.UserID == userID {
+			current[rec.Purpose] = rec // os registros estão em ordem de ID
+		}
+	}
+	return current, nil
+}
+
+func (m *MemoryStore) HasConsent(ctx context.Context, userID, purpose, termsVersion string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.consents) - 1; i >= 0; i-- {
+		if rec := m.consents[i]; rec.UserID == userID && rec.Purpose == purpose {
+			return rec.Granted && rec.TermsVersion == termsVersion, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) ListConsentHistory(ctx context.Context, userID string) ([]models.ConsentRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var records []models.ConsentRecord
+	for i := len(m.consents) - 1; i >= 0; i-- {
+		if m.consents[i].UserID == userID {
+			records = append(records, m.consents[i])
+		}
+	}
+	return records, nil
+}
+
+// ===== Auditoria =====
+
+// CreateAuditEvent guarda alterações e metadados serializados, como na coluna JSONB,
+// para que a leitura devolva os mesmos tipos que o Client.
+func (m *MemoryStore) CreateAuditEvent(ctx context.Context, ev models.AuditEvent) error {
+	var changes, metadata []byte
+	var err error
+	if len(ev.Changes) > 0 {
+		if changes, err = json.Marshal(ev.Changes); err != nil {
+			return fmt.Errorf("falha ao serializar alterações do evento: %w", err)
+		}
+	}
+	if len(ev.Metadata) > 0 {
+		if metadata, err = json.Marshal(ev.Metadata); err != nil {
+			return fmt.Errorf("falha ao serializar metadados do evento: %w", err)
+		}
+	}
+	if len(ev.UserAgent) > 512 {
+		ev.UserAgent = ev.UserAgent[:512]
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ev.ID = m.nextID()
+	ev.CreatedAt = time.Now()
+	ev.Changes, ev.Metadata = nil, nil
+	m.auditEvents = append(m.auditEvents, memAuditEvent{ev: ev, changes: changes, metadata: metadata})
+	return nil
+}
+
+func (f AuditEventFilter) matches(ev models.AuditEvent) bool {
+	switch {
+	case f.UserID != "" && ev.UserID != f.UserID,
+		f.ActorID != "" && ev.ActorID != f.ActorID,
+		f.Action != "" && ev.Action != f.Action,
+		f.TargetType != "" && ev.TargetType != f.TargetType,
+		f.TargetID != "" && ev.TargetID != f.TargetID,
+		f.IP != "" && ev.IP != f.IP,
+		!f.From.IsZero() && ev.CreatedAt.Before(f.From),
+		!f.To.IsZero() && !ev.CreatedAt.Before(f.To):
+		return false
+	}
+	return true
+}
+
+func (m *MemoryStore) ListAuditEvents(ctx context.Context, f AuditEventFilter, limit, offset int) ([]models.AuditEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var matched []memAuditEvent
+	for i := len(m.auditEvents) - 1; i >= 0; i-- {
+		if f.matches(m.auditEvents[i].ev) {
+			matched = append(matched, m.auditEvents[i])
+		}
+	}
+	var events []models.AuditEvent
+	for _, stored := range page(matched, limit, offset) {
+		ev := stored.ev
+		ev.RedactedAt = cloneTime(ev.RedactedAt)
+		if len(stored.changes) > 0 {
+			if err := json.Unmarshal(stored.changes, &ev.Changes); err != nil {
+				return nil, err
+			}
+		}
+		if len(stored.metadata) > 0 {
+			if err := json.Unmarshal(stored.metadata, &ev.Metadata); err != nil {
+				return nil, err
+			}
+		}
+		events = append(events, ev)
+	}
+	return events, nil
+}
+
+func (m *MemoryStore) AnonymizeAuditEvents(ctx context.Context, userID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.anonymizeAuditEvents(userID)
+}
+
+// anonymizeAuditEvents segue redact_audit_events; exige m.mu.
+func (m *MemoryStore) anonymizeAuditEvents(userID string) (int, error) {
+	n := 0
+	now := time.Now()
+	for i := range m.auditEvents {
+		stored := &m.auditEvents[i]
+		ev := &stored.ev
+		if ev.ActorID != userID && ev.UserID != userID {
+			continue
+		}
+		if ev.ActorID == userID || ev.ActorID == "" {
+			ev.IP, ev.UserAgent = "", ""
+		}
+		if ev.ActorID == userID {
+			ev.ActorID = ""
+		}
+		if ev.UserID == userID {
+			ev.UserID = ""
+		}
+		if len(stored.changes) > 0 {
+			var changes map[string]json.RawMessage
+			if err := json.Unmarshal(stored.changes, &changes); err != nil {
+				return n, err
+			}
+			redacted := map[string]models.AuditChange{}
+			for k := range changes {
+				redacted[k] = models.AuditChange{Redacted: true}
+			}
+			b, err := json.Marshal(redacted)
+			if err != nil {
+				return n, err
+			}
+			stored.changes = b
+		}
+		if len(stored.metadata) > 0 {
+			var metadata map[string]any
+			if err := json.Unmarshal(stored.metadata, &metadata); err != nil {
+				return n, err
+			}
+			delete(metadata, "email")
+			delete(metadata, "key")
+			delete(metadata, "name")
+			stored.metadata = nil
+			if len(metadata) > 0 {
+				b, err := json.Marshal(metadata)
+				if err != nil {
+					return n, err
+				}
+				stored.metadata = b
+			}
+		}
+		ev.RedactedAt = &now
+		n++
+	}
+	return n, nil
+}
+
+// ===== Exportações de dados =====
+
+func (e *memExport) view() models.DataExport {
+	out := e.DataExport
+	out.CompletedAt = cloneTime(e.CompletedAt)
+	out.ExpiresAt = cloneTime(e.ExpiresAt)
+	return out
+}
+
+func (m *MemoryStore) dataExport(exportID string) *memExport {
+	for _, e := range m.exports {
+		if e.ID == exportID {
+			return e
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) CreateDataExport(ctx context.Context, userID string) (models.DataExport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.exports {
+		if e.UserID == userID && (e.Status == models.ExportPending || e.Status == models.ExportRunning) {
+			return models.DataExport{}, ErrExportInProgress
+		}
+	}
+	e := &memExport{DataExport: models.DataExport{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Status:    models.ExportPending,
+		CreatedAt: time.Now(),
+	}}
+	m.exports = append(m.exports, e)
+	return e.view(), nil
+}
+
+func (m *MemoryStore) GetDataExport(ctx context.Context, exportID string) (models.DataExport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := m.dataExport(exportID)
+	if e == nil {
+		return models.DataExport{}, pgx.ErrNoRows
+	}
+	return e.view(), nil
+}
+
+func (m *MemoryStore) ListDataExports(ctx context.Context, userID string) ([]models.DataExport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []models.DataExport
+	for i := len(m.exports) - 1; i >= 0; i-- {
+		if m.exports[i].UserID == userID {
+			out = append(out, m.exports[i].view())
+		}
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) ClaimDataExport(ctx context.Context, staleAfter time.Duration) (models.DataExport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	staleBefore := now.Add(-staleAfter)
+	for _, e := range m.exports {
+		if e.UserID == "" {
+			continue
+		}
+		stale := e.Status == models.ExportRunning && e.startedAt != nil && e.startedAt.Before(staleBefore)
+		if e.Status == models.ExportPending || stale {
+			e.Status = models.ExportRunning
+			e.startedAt = timePtr(now)
+			return e.view(), nil
+		}
+	}
+	return models.DataExport{}, pgx.ErrNoRows
+}
+
+func (m *MemoryStore) CompleteDataExport(ctx context.Context, exportID, storageKey string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e := m.dataExport(exportID); e != nil {
+		e.Status = models.ExportReady
+		e.StorageKey = storageKey
+		e.CompletedAt = timePtr(time.Now())
+		e.ExpiresAt = timePtr(expiresAt)
+	}
+	return nil
+}
+
+func (m *MemoryStore) FailDataExport(ctx context.Context, exportID, reason string) error {
+	if len(reason) > 255 {
+		reason = reason[:255]
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e := m.dataExport(exportID); e != nil {
+		e.Status = models.ExportFailed
+		e.Error = reason
+		e.CompletedAt = timePtr(time.Now())
+	}
+	return nil
+}
+
+func (m *MemoryStore) ListStaleDataExports(ctx context.Context, limit int) ([]models.DataExport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	var out []models.DataExport
+	for _, e := range m.exports {
+		if (e.ExpiresAt != nil && e.ExpiresAt.Before(now)) || e.UserID == "" {
+			out = append(out, e.view())
+		}
+	}
+	return page(out, limit, 0), nil
+}
+
+func (m *MemoryStore) DeleteDataExport(ctx context.Context, exportID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.exports = slices.DeleteFunc(m.exports, func(e *memExport) bool { return e.ID == exportID })
+	return nil
+}
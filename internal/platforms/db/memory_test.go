@@ -0,0 +1,264 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"go-guardiao-api/pkg/models"
+)
+
+// newUser cria uma conta no store e devolve o ID.
+func newUser(t *testing.T, m *MemoryStore, email string) string {
+	t.Helper()
+	id := uuid.New().String()
+	if err := m.CreateUser(context.Background(), models.User{ID: id, Email: email, Name: "Ana"}); err != nil {
+		t.Fatalf("CreateUser(%s): %v", email, err)
+	}
+	return id
+}
+
+func newHabit(t *testing.T, m *MemoryStore, userID string) string {
+	t.Helper()
+	id, err := m.CreateHabit(context.Background(), models.Habit{UserID: userID, Name: "Água", Status: models.HabitActive})
+	if err != nil {
+		t.Fatalf("CreateHabit: %v", err)
+	}
+	return id
+}
+
+func TestEmailIsUniqueIgnoringCase(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore()
+	newUser(t, m, "ana@exemplo.com")
+
+	err := m.CreateUser(ctx, models.User{Email: " ANA@exemplo.com ", Name: "Outra"})
+	if !errors.Is(err, ErrEmailTaken) {
+		t.Fatalf("CreateUser com e-mail repetido: esperado ErrEmailTaken, veio %v", err)
+	}
+
+	other := newUser(t, m, "bia@exemplo.com")
+	if err := m.UpdateUserEmail(ctx, other, "Ana@Exemplo.com"); !errors.Is(err, ErrEmailTaken) {
+		t.Fatalf("UpdateUserEmail para e-mail de outra conta: esperado ErrEmailTaken, veio %v", err)
+	}
+	if err := m.UpdateUserEmail(ctx, other, "BIA@exemplo.com"); err != nil {
+		t.Fatalf("UpdateUserEmail para o próprio e-mail: %v", err)
+	}
+
+	u, err := m.GetUserByEmail(ctx, "ANA@EXEMPLO.COM")
+	if err != nil || u.Email != "ana@exemplo.com" {
+		t.Fatalf("GetUserByEmail: %+v, %v", u, err)
+	}
+}
+
+func TestBuyStreakFreezeRequiresMana(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore()
+	userID := newUser(t, m, "ana@exemplo.com")
+	habitID := newHabit(t, m, userID)
+	freeze := models.StreakFreeze{HabitID: habitID, UserID: userID, Day: "2026-10-15", ManaCost: 100}
+
+	if _, err := m.BuyStreakFreeze(ctx, freeze); !errors.Is(err, ErrInsufficientMana) {
+		t.Fatalf("sem saldo: esperado ErrInsufficientMana, veio %v", err)
+	}
+	if freezes, _ := m.ListStreakFreezes(ctx, habitID); len(freezes) != 0 {
+		t.Fatalf("compra recusada não pode gravar proteção: %+v", freezes)
+	}
+
+	if err := m.UpdateManaBalance(ctx, models.ManaTransaction{UserID: userID, Type: models.ManaTypeHabitCompletion, Amount: 150}); err != nil {
+		t.Fatal(err)
+	}
+	balance, err := m.BuyStreakFreeze(ctx, freeze)
+	if err != nil || balance != 50 {
+		t.Fatalf("compra com saldo: saldo %d, erro %v", balance, err)
+	}
+	if err := m.UpdateManaBalance(ctx, models.ManaTransaction{UserID: userID, Type: models.ManaTypeHabitCompletion, Amount: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.BuyStreakFreeze(ctx, freeze); !errors.Is(err, ErrStreakFreezeExists) {
+		t.Fatalf("mesmo dia: esperado ErrStreakFreezeExists, veio %v", err)
+	}
+	if got, _ := m.GetManaBalance(ctx, userID); got != 150 {
+		t.Fatalf("saldo após compra repetida: esperado 150, veio %d", got)
+	}
+}
+
+func TestUpdateHabitLogAdjustsMana(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore()
+	userID := newUser(t, m, "ana@exemplo.com")
+	habitID := newHabit(t, m, userID)
+	since := time.Now().Add(-24 * time.Hour)
+
+	logged, grant, err := m.LogHabit(ctx, models.HabitLog{HabitID: habitID, UserID: userID, Value: 0}, 0)
+	if err != nil || grant != nil {
+		t.Fatalf("registro sem mana: crédito %+v, erro %v", grant, err)
+	}
+	// Um registro que não rendia mana passa a render ao ser corrigido
+	_, adj, err := m.UpdateHabitLog(ctx, models.HabitLog{ID: logged.ID, HabitID: habitID, UserID: userID, Value: 1}, 25, since)
+	if err != nil || adj == nil || adj.Amount != 25 || adj.Balance != 25 {
+		t.Fatalf("registro sem mana corrigido: compensação %+v, erro %v", adj, err)
+	}
+
+	other, grant, err := m.LogHabit(ctx, models.HabitLog{HabitID: habitID, UserID: userID, Value: 1}, 25)
+	if err != nil || grant == nil || grant.Amount != 25 || grant.Balance != 50 {
+		t.Fatalf("registro com mana: crédito %+v, erro %v", grant, err)
+	}
+	_, adj, err = m.UpdateHabitLog(ctx, models.HabitLog{ID: other.ID, HabitID: habitID, UserID: userID, Value: 0}, 0, since)
+	if err != nil || adj == nil || adj.Amount != -25 || adj.Balance != 25 {
+		t.Fatalf("valor zerado: compensação %+v, erro %v", adj, err)
+	}
+	adj, err = m.DeleteHabitLog(ctx, habitID, other.ID, userID, since)
+	if err != nil || adj != nil {
+		t.Fatalf("exclusão após estorno: compensação %+v, erro %v", adj, err)
+	}
+}
+
+func TestHabitLogEditWindow(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore()
+	userID := newUser(t, m, "ana@exemplo.com")
+	habitID := newHabit(t, m, userID)
+	logged, _, err := m.LogHabit(ctx, models.HabitLog{HabitID: habitID, UserID: userID, Value: 1, Timestamp: time.Now().Add(-48 * time.Hour)}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	since := time.Now().Add(-24 * time.Hour)
+
+	_, _, err = m.UpdateHabitLog(ctx, models.HabitLog{ID: logged.ID, HabitID: habitID, UserID: userID, Value: 5}, 0, since)
+	if !errors.Is(err, ErrHabitLogTooOld) {
+		t.Fatalf("edição de registro antigo: esperado ErrHabitLogTooOld, veio %v", err)
+	}
+	if _, err := m.DeleteHabitLog(ctx, habitID, logged.ID, userID, since); !errors.Is(err, ErrHabitLogTooOld) {
+		t.Fatalf("exclusão de registro antigo: esperado ErrHabitLogTooOld, veio %v", err)
+	}
+	other := newUser(t, m, "bia@exemplo.com")
+	if _, err := m.DeleteHabitLog(ctx, habitID, logged.ID, other, time.Time{}); err == nil {
+		t.Fatal("exclusão por outra conta deveria falhar")
+	}
+}
+
+func TestPurgeUserAnonymizes(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore()
+	userID := newUser(t, m, "ana@exemplo.com")
+	adminID := newUser(t, m, "admin@exemplo.com")
+	habitID := newHabit(t, m, userID)
+	if _, _, err := m.LogHabit(ctx, models.HabitLog{HabitID: habitID, UserID: userID, Value: 1}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.UpdateManaBalance(ctx, models.ManaTransaction{UserID: userID, Type: models.ManaTypeHabitCompletion, Amount: 25, ReferenceID: "log"}); err != nil {
+		t.Fatal(err)
+	}
+	events := []models.AuditEvent{
+		{ActorID: userID, UserID: userID, Action: models.AuditProfileUpdated, IP: "10.0.0.1", UserAgent: "app",
+			Changes: map[string]models.AuditChange{"theme": {From: "claro", To: "escuro"}}},
+		{ActorID: userID, UserID: userID, Action: models.AuditIdentityLinked, IP: "10.0.0.1",
+			Metadata: map[string]any{"email": "ana@provedor.com", "via": "login"}},
+		{ActorID: adminID, UserID: userID, Action: models.AuditLockoutUnlocked, IP: "10.0.0.9",
+			Metadata: map[string]any{"scope": "account"}},
+		{ActorID: adminID, UserID: adminID, Action: models.AuditProfileUpdated, IP: "10.0.0.9"},
+	}
+	for _, ev := range events {
+		if err := m.CreateAuditEvent(ctx, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := m.PurgeUser(ctx, userID); err == nil {
+		t.Fatal("PurgeUser sem exclusão agendada deveria falhar")
+	}
+	if err := m.ScheduleAccountDeletion(ctx, userID, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.PurgeUser(ctx, userID); err != nil {
+		t.Fatalf("PurgeUser: %v", err)
+	}
+
+	if _, err := m.GetUserByID(ctx, userID); err == nil {
+		t.Fatal("a conta deveria ter sido apagada")
+	}
+	if habits, _ := m.GetHabitsByUserID(ctx, userID); len(habits) != 0 {
+		t.Fatalf("hábitos não foram apagados: %+v", habits)
+	}
+	if txs, _ := m.ListManaTransactions(ctx, userID); len(txs) != 0 {
+		t.Fatalf("extrato ainda ligado à conta: %+v", txs)
+	}
+	if txs, _ := m.ListManaTransactions(ctx, ""); len(txs) != 1 || txs[0].ReferenceID != "" || txs[0].Amount != 25 {
+		t.Fatalf("extrato anonimizado: %+v", txs)
+	}
+
+	if byUser, _ := m.ListAuditEvents(ctx, AuditEventFilter{UserID: userID}, 10, 0); len(byUser) != 0 {
+		t.Fatalf("eventos ainda ligados à conta: %+v", byUser)
+	}
+	all, err := m.ListAuditEvents(ctx, AuditEventFilter{}, 10, 0)
+	if err != nil || len(all) != len(events) {
+		t.Fatalf("a trilha deve manter os eventos: %d, %v", len(all), err)
+	}
+	for _, ev := range all {
+		own := ev.UserID == adminID && ev.ActorID == adminID
+		if own {
+			if ev.RedactedAt != nil || ev.IP == "" {
+				t.Fatalf("evento de outra conta foi alterado: %+v", ev)
+			}
+			continue
+		}
+		if ev.RedactedAt == nil || ev.UserID != "" {
+			t.Fatalf("evento não anonimizado: %+v", ev)
+		}
+		if _, ok := ev.Metadata["email"]; ok {
+			t.Fatalf("metadados pessoais mantidos: %+v", ev.Metadata)
+		}
+		for field, c := range ev.Changes {
+			if !c.Redacted || c.From != nil || c.To != nil {
+				t.Fatalf("alteração %s mantém valores: %+v", field, c)
+			}
+		}
+		switch ev.ActorID {
+		case "":
+			if ev.IP != "" || ev.UserAgent != "" {
+				t.Fatalf("IP/user agent da conta mantidos: %+v", ev)
+			}
+		case adminID:
+			if ev.IP != "10.0.0.9" {
+				t.Fatalf("IP do admin não deveria mudar: %+v", ev)
+			}
+		default:
+			t.Fatalf("ator inesperado: %+v", ev)
+		}
+	}
+}
+
+func TestPurgeLoginHistory(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	old := models.LoginLockout{Scope: "account", Key: store.LoginAccountKey("nao-existe@exemplo.com"), LockedUntil: time.Now().Add(-48 * time.Hour)}
+	recent := models.LoginLockout{Scope: "ip", Key: "10.0.0.1", LockedUntil: time.Now().Add(time.Minute)}
+	for _, l := range []models.LoginLockout{old, recent} {
+		if err := store.RecordLockout(ctx, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.RegisterLoginFailure(ctx, "account", old.Key, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.PurgeLoginHistory(ctx, time.Now().Add(-24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if lockouts, _ := store.ListLockouts(ctx, false, 10, 0); len(lockouts) != 1 || lockouts[0].Key != recent.Key {
+		t.Fatalf("só o bloqueio antigo deveria sair: %+v", lockouts)
+	}
+	if a, _ := store.GetLoginAttempts(ctx, "account", old.Key, time.Hour); a.Failures != 1 {
+		t.Fatalf("falha recente não deveria sair: %+v", a)
+	}
+	if err := store.PurgeLoginHistory(ctx, time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if a, _ := store.GetLoginAttempts(ctx, "account", old.Key, time.Hour); a.Failures != 0 || !a.LastFailure.IsZero() {
+		t.Fatalf("contador antigo deveria sair: %+v", a)
+	}
+}
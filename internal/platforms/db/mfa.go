@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// TOTPState agrupa os dados de 2FA do usuário (nunca expostos pela API).
+type TOTPState struct {
+	Enabled       bool
+	Secret        string
+	PendingSecret string
+}
+
+// GetTOTPState lê (e decifra) o estado de 2FA do usuário.
+func (c *Client) GetTOTPState(ctx context.Context, userID string) (TOTPState, error) {
+	var s TOTPState
+	const q = `SELECT totp_enabled, COALESCE(totp_secret, ''), COALESCE(totp_pending_secret, '') FROM users WHERE id = $1`
+	if err := c.pool.QueryRow(ctx, q, userID).Scan(&s.Enabled, &s.Secret, &s.PendingSecret); err != nil {
+		return TOTPState{}, err
+	}
+	var err error
+	if s.Secret, err = c.open(fieldUserTOTPSecret, userID, s.Secret); err != nil {
+		return TOTPState{}, err
+	}
+	if s.PendingSecret, err = c.open(fieldUserTOTPPending, userID, s.PendingSecret); err != nil {
+		return TOTPState{}, err
+	}
+	return s, nil
+}
+
+// SetPendingTOTPSecret guarda (cifrado) o segredo gerado no cadastro até a confirmação com um código válido.
+func (c *Client) SetPendingTOTPSecret(ctx context.Context, userID, secret string) error {
+	sealed, err := c.seal(fieldUserTOTPPending, userID, secret)
+	if err != nil {
+		return err
+	}
+	const q = `UPDATE users SET totp_pending_secret = $2 WHERE id = $1`
+	cmdTag, err := c.pool.Exec(ctx, q, userID, sealed)
+	if err != nil {
+		return fmt.Errorf("falha ao registrar segredo TOTP: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// EnableTOTP promove o segredo pendente a ativo e substitui os códigos de recuperação.
+// O segredo é recifrado: o campo faz parte do dado associado.
+func (c *Client) EnableTOTP(ctx context.Context, userID string, step int64, recoveryHashes []string) error {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("falha ao iniciar transação: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	var pending string
+	const lock = `SELECT totp_pending_secret FROM users WHERE id = $1 AND totp_pending_secret IS NOT NULL FOR UPDATE`
+	if err = tx.QueryRow(ctx, lock, userID).Scan(&pending); err != nil {
+		return err
+	}
+	var secret string
+	if secret, err = c.open(fieldUserTOTPPending, userID, pending); err != nil {
+		return err
+	}
+	if secret, err = c.seal(fieldUserTOTPSecret, userID, secret); err != nil {
+		return err
+	}
+
+	const enable = `
+       UPDATE users SET totp_secret = $3, totp_pending_secret = NULL,
+          totp_enabled = TRUE, totp_last_step = $2
+       WHERE id = $1`
+	if _, err = tx.Exec(ctx, enable, userID, step, secret); err != nil {
+		return fmt.Errorf("falha ao ativar 2FA: %w", err)
+	}
+	if err = replaceRecoveryCodes(ctx, tx, userID, recoveryHashes); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// DisableTOTP remove o segredo e os códigos de recuperação do usuário.
+func (c *Client) DisableTOTP(ctx context.Context, userID string) error {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("falha ao iniciar transação: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	const disable = `
+       UPDATE users SET totp_enabled = FALSE, totp_secret = NULL, totp_pending_secret = NULL, totp_last_step = NULL
+       WHERE id = $1`
+	if _, err = tx.Exec(ctx, disable, userID); err != nil {
+		return fmt.Errorf("falha ao desativar 2FA: %w", err)
+	}
+	if err = replaceRecoveryCodes(ctx, tx, userID, nil); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// UseTOTPStep registra o passo aceito; retorna false se ele (ou um posterior) já foi usado.
+func (c *Client) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	const q = `UPDATE users SET totp_last_step = $2 WHERE id = $1 AND (totp_last_step IS NULL OR totp_last_step < $2)`
+	cmdTag, err := c.pool.Exec(ctx, q, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("falha ao registrar código TOTP: %w", err)
+	}
+	return cmdTag.RowsAffected() > 0, nil
+}
+
+// ConsumeRecoveryCode marca o código de recuperação como usado; retorna false se não existir ou já tiver sido usado.
+func (c *Client) ConsumeRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	const q = `UPDATE mfa_recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+	cmdTag, err := c.pool.Exec(ctx, q, userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("falha ao consumir código de recuperação: %w", err)
+	}
+	return cmdTag.RowsAffected() > 0, nil
+}
+
+// CountRecoveryCodes retorna quantos códigos de recuperação ainda não foram usados.
+func (c *Client) CountRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	var n int
+	const q = `SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+	if err := c.pool.QueryRow(ctx, q, userID).Scan(&n); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return 0, err
+	}
+	return n, nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID string, hashes []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("falha ao remover códigos de recuperação: %w", err)
+	}
+	for _, h := range hashes {
+		const q = `INSERT INTO mfa_recovery_codes (id, user_id, code_hash) VALUES ($1, $2, $3)`
+		if _, err := tx.Exec(ctx, q, uuid.New().String(), userID, h); err != nil {
+			return fmt.Errorf("falha ao gravar código de recuperação: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ===== Migrações de esquema =====
+//
+// Cada mudança de esquema é um par de arquivos em migrations/:
+//
+//	NNNN_descricao.up.sql    aplica a mudança
+//	NNNN_descricao.down.sql  desfaz a mudança
+//
+// As versões aplicadas ficam em schema_migrations. Cada migração roda em uma transação
+// própria, e um advisory lock impede que duas instâncias migrem ao mesmo tempo.
+// Migrações já aplicadas não devem ser editadas: crie uma nova.
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockKey identifica o advisory lock das migrações ("guardiao" em hex).
+const migrationLockKey int64 = 0x67756172_6469616f
+
+// Migration é uma migração embutida no binário.
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// MigrationStatus descreve uma migração conhecida e se ela já foi aplicada.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+	Missing   bool // aplicada no banco, mas o arquivo não existe neste binário
+}
+
+// ErrSchemaOutdated indica migrações pendentes com a aplicação automática desligada.
+var ErrSchemaOutdated = errors.New("esquema do banco desatualizado: rode \"migrate up\"")
+
+// loadMigrations lê e valida os arquivos embutidos, em ordem de versão.
+func loadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		name := e.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("arquivo de migração inválido: %s", name)
+		}
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		num, desc, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(num)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("nome de migração inválido (use NNNN_descricao.up.sql): %s", name)
+		}
+		body, err := fs.ReadFile(migrationFiles, path.Join("migrations", name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: desc}
+			byVersion[version] = m
+		} else if m.Name != desc {
+			return nil, fmt.Errorf("versão %d usada por duas migrações: %s e %s", version, m.Name, desc)
+		}
+		if direction == "up" {
+			m.up = string(body)
+		} else {
+			m.down = string(body)
+		}
+	}
+
+	out := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migração %04d_%s precisa dos arquivos up e down", m.Version, m.Name)
+		}
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// withMigrationLock reserva uma conexão, garante a tabela schema_migrations e executa fn
+// segurando o advisory lock (que é por sessão, por isso a conexão dedicada).
+func (c *Client) withMigrationLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := c.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("falha ao reservar conexão para migrações: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return fmt.Errorf("falha ao obter lock de migrações: %w", err)
+	}
+	defer func() {
+		// Com o contexto cancelado o unlock falharia; o lock cai junto com a sessão
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockKey); err != nil {
+			log.Printf("AVISO: Falha ao liberar lock de migrações: %v", err)
+		}
+	}()
+
+	if _, err := conn.Exec(ctx, `
+       CREATE TABLE IF NOT EXISTS schema_migrations (
+          version INTEGER PRIMARY KEY,
+          name VARCHAR(255) NOT NULL,
+          applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+       );`); err != nil {
+		return fmt.Errorf("falha ao criar tabela schema_migrations: %w", err)
+	}
+	return fn(conn)
+}
+
+func appliedMigrations(ctx context.Context, q interface {
+	Query(context.Context, string, ...any) (pgx.Rows, error)
+}) (map[int]MigrationStatus, error) {
+	rows, err := q.Query(ctx, `SELECT version, name, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao ler schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[int]MigrationStatus{}
+	for rows.Next() {
+		var st MigrationStatus
+		var at time.Time
+		if err := rows.Scan(&st.Version, &st.Name, &at); err != nil {
+			return nil, err
+		}
+		st.AppliedAt = &at
+		applied[st.Version] = st
+	}
+	return applied, rows.Err()
+}
+
+// MigrateUp aplica, em ordem, todas as migrações pendentes e devolve quantas aplicou.
+func (c *Client) MigrateUp(ctx context.Context) (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	err = c.withMigrationLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			log.Printf("Aplicando migração %04d_%s...", m.Version, m.Name)
+			if err := runMigration(ctx, conn, m.up, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name)
+				return err
+			}); err != nil {
+				return fmt.Errorf("migração %04d_%s: %w", m.Version, m.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// MigrateDown desfaz as últimas steps migrações aplicadas e devolve quantas desfez.
+func (c *Client) MigrateDown(ctx context.Context, steps int) (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	known := map[int]Migration{}
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+	count := 0
+	err = c.withMigrationLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		versions := make([]int, 0, len(applied))
+		for v := range applied {
+			versions = append(versions, v)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+		for _, v := range versions {
+			if count == steps {
+				break
+			}
+			m, ok := known[v]
+			if !ok {
+				return fmt.Errorf("migração %d aplicada no banco não existe neste binário", v)
+			}
+			log.Printf("Desfazendo migração %04d_%s...", m.Version, m.Name)
+			if err := runMigration(ctx, conn, m.down, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+				return err
+			}); err != nil {
+				return fmt.Errorf("migração %04d_%s: %w", m.Version, m.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// MigrationStatuses lista as migrações embutidas e as aplicadas no banco, em ordem de versão.
+func (c *Client) MigrationStatuses(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	var out []MigrationStatus
+	err = c.withMigrationLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			st := MigrationStatus{Version: m.Version, Name: m.Name}
+			if a, ok := applied[m.Version]; ok {
+				st.AppliedAt = a.AppliedAt
+				delete(applied, m.Version)
+			}
+			out = append(out, st)
+		}
+		for _, a := range applied {
+			a.Missing = true
+			out = append(out, a)
+		}
+		return nil
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, err
+}
+
+// runMigration executa o SQL e o registro em schema_migrations na mesma transação.
+func runMigration(ctx context.Context, conn *pgxpool.Conn, sql string, record func(pgx.Tx) error) (err error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("falha ao iniciar transação: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+	// Sem argumentos o pgx usa o protocolo simples, que aceita vários comandos
+	if _, err = tx.Exec(ctx, sql); err != nil {
+		return err
+	}
+	if err = record(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,26 @@
+-- Remove todo o esquema inicial (APAGA TODOS OS DADOS).
+DROP TABLE IF EXISTS
+   data_exports,
+   audit_events,
+   consent_records,
+   webauthn_challenges,
+   webauthn_credentials,
+   api_keys,
+   oidc_login_states,
+   user_identities,
+   user_roles,
+   mfa_recovery_codes,
+   login_lockouts,
+   login_attempts,
+   email_verification_tokens,
+   password_reset_tokens,
+   refresh_tokens,
+   auth_sessions,
+   habit_logs,
+   habits,
+   support_contacts,
+   mana_transactions,
+   user_mana,
+   users
+CASCADE;
+DROP FUNCTION IF EXISTS audit_events_append_only();
@@ -0,0 +1,290 @@
+-- Esquema inicial (equivalente ao antigo InitSchema).
+-- Os comandos são idempotentes: em bases criadas pelo InitSchema, apenas completam o que faltar.
+
+-- Usuários. Nome e e-mail são cifrados (field_encryption.go): colunas TEXT e unicidade
+-- pelo índice cego, já que o mesmo e-mail gera textos cifrados diferentes.
+CREATE TABLE IF NOT EXISTS users (
+   id UUID PRIMARY KEY,
+   email TEXT NOT NULL,
+   email_bidx VARCHAR(64),
+   name TEXT,
+   theme VARCHAR(50),
+   password_hash VARCHAR(255),
+   email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+   pending_email TEXT,
+   totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+   totp_secret VARCHAR(64),
+   totp_pending_secret VARCHAR(64),
+   totp_last_step BIGINT,
+   deletion_requested_at TIMESTAMP,
+   deletion_scheduled_at TIMESTAMP,
+   created_at TIMESTAMP DEFAULT NOW()
+);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_hash VARCHAR(255);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_bidx VARCHAR(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS pending_email TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret VARCHAR(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_pending_secret VARCHAR(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_requested_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_scheduled_at TIMESTAMP;
+-- Bases antigas: password_hash em VARCHAR(72) (curto para argon2id) e e-mail único em claro
+ALTER TABLE users
+   ALTER COLUMN password_hash TYPE VARCHAR(255),
+   ALTER COLUMN email TYPE TEXT,
+   ALTER COLUMN name TYPE TEXT,
+   ALTER COLUMN pending_email TYPE TEXT;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+DROP INDEX IF EXISTS users_email_unique_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_bidx_idx ON users (email_bidx);
+
+-- Mana
+CREATE TABLE IF NOT EXISTS user_mana (
+   user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+   balance INTEGER NOT NULL DEFAULT 0,
+   updated_at TIMESTAMP DEFAULT NOW()
+);
+CREATE TABLE IF NOT EXISTS mana_transactions (
+   id SERIAL PRIMARY KEY,
+   user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+   type VARCHAR(50) NOT NULL,
+   amount INTEGER NOT NULL,
+   reference_id VARCHAR(255),
+   created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Rede de apoio (e-mail, telefone e apelido cifrados)
+CREATE TABLE IF NOT EXISTS support_contacts (
+   contact_id UUID PRIMARY KEY,
+   user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+   contact_email TEXT NOT NULL,
+   phone TEXT,
+   nickname TEXT,
+   notification_preference VARCHAR(50),
+   created_at TIMESTAMP DEFAULT NOW()
+);
+ALTER TABLE support_contacts ADD COLUMN IF NOT EXISTS phone TEXT;
+ALTER TABLE support_contacts
+   ALTER COLUMN contact_email TYPE TEXT,
+   ALTER COLUMN phone TYPE TEXT,
+   ALTER COLUMN nickname TYPE TEXT;
+
+-- Hábitos
+CREATE TABLE IF NOT EXISTS habits (
+   id UUID PRIMARY KEY,
+   user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+   name VARCHAR(255) NOT NULL,
+   goal_type VARCHAR(50),
+   frequency VARCHAR(50),
+   created_at TIMESTAMP DEFAULT NOW()
+);
+CREATE TABLE IF NOT EXISTS habit_logs (
+   id SERIAL PRIMARY KEY,
+   habit_id UUID REFERENCES habits(id) ON DELETE CASCADE,
+   user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+   value INTEGER NOT NULL,
+   timestamp TIMESTAMP DEFAULT NOW()
+);
+
+-- Sessões de autenticação (cada sessão agrupa uma família de refresh tokens)
+CREATE TABLE IF NOT EXISTS auth_sessions (
+   id UUID PRIMARY KEY,
+   user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+   user_agent VARCHAR(512),
+   ip VARCHAR(64),
+   created_at TIMESTAMP DEFAULT NOW(),
+   last_seen_at TIMESTAMP DEFAULT NOW(),
+   revoked_at TIMESTAMP
+);
+ALTER TABLE auth_sessions ADD COLUMN IF NOT EXISTS user_agent VARCHAR(512);
+ALTER TABLE auth_sessions ADD COLUMN IF NOT EXISTS ip VARCHAR(64);
+ALTER TABLE auth_sessions ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP DEFAULT NOW();
+CREATE INDEX IF NOT EXISTS auth_sessions_user_idx ON auth_sessions (user_id);
+
+-- Refresh tokens opacos (apenas o hash SHA-256 é armazenado)
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+   id UUID PRIMARY KEY,
+   session_id UUID REFERENCES auth_sessions(id) ON DELETE CASCADE,
+   user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+   token_hash CHAR(64) UNIQUE NOT NULL,
+   expires_at TIMESTAMP NOT NULL,
+   used_at TIMESTAMP,
+   created_at TIMESTAMP DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS refresh_tokens_session_idx ON refresh_tokens (session_id);
+
+-- Tokens de redefinição de senha e de verificação de e-mail (uso único, apenas hash)
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+   id UUID PRIMARY KEY,
+   user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+   token_hash CHAR(64) UNIQUE NOT NULL,
+   expires_at TIMESTAMP NOT NULL,
+   used_at TIMESTAMP,
+   created_at TIMESTAMP DEFAULT NOW()
+);
+CREATE TABLE IF NOT EXISTS email_verification_tokens (
+   id UUID PRIMARY KEY,
+   user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+   email VARCHAR(255) NOT NULL,
+   token_hash CHAR(64) UNIQUE NOT NULL,
+   expires_at TIMESTAMP NOT NULL,
+   used_at TIMESTAMP,
+   created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Tentativas de login (fallback do Redis) e histórico de bloqueios
+CREATE TABLE IF NOT EXISTS login_attempts (
+   scope VARCHAR(10) NOT NULL,
+   key VARCHAR(255) NOT NULL,
+   failures INTEGER NOT NULL DEFAULT 0,
+   last_failure_at TIMESTAMP,
+   locked_until TIMESTAMP,
+   PRIMARY KEY (scope, key)
+);
+CREATE TABLE IF NOT EXISTS login_lockouts (
+   id SERIAL PRIMARY KEY,
+   scope VARCHAR(10) NOT NULL,
+   key VARCHAR(255) NOT NULL,
+   user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+   ip VARCHAR(64),
+   failures INTEGER NOT NULL,
+   locked_until TIMESTAMP NOT NULL,
+   created_at TIMESTAMP DEFAULT NOW(),
+   unlocked_at TIMESTAMP,
+   unlocked_by UUID
+);
+
+-- Códigos de recuperação do 2FA (uso único, apenas hash)
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+   id UUID PRIMARY KEY,
+   user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+   code_hash CHAR(64) NOT NULL,
+   used_at TIMESTAMP,
+   created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Papéis de acesso (RBAC)
+CREATE TABLE IF NOT EXISTS user_roles (
+   user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+   role VARCHAR(20) NOT NULL,
+   granted_by UUID,
+   granted_at TIMESTAMP DEFAULT NOW(),
+   PRIMARY KEY (user_id, role)
+);
+
+-- Identidades externas (OpenID Connect)
+CREATE TABLE IF NOT EXISTS user_identities (
+   provider VARCHAR(50) NOT NULL,
+   subject VARCHAR(255) NOT NULL,
+   user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+   email VARCHAR(255),
+   created_at TIMESTAMP DEFAULT NOW(),
+   last_login_at TIMESTAMP,
+   PRIMARY KEY (provider, subject),
+   UNIQUE (user_id, provider)
+);
+CREATE TABLE IF NOT EXISTS oidc_login_states (
+   state_hash CHAR(64) PRIMARY KEY,
+   provider VARCHAR(50) NOT NULL,
+   code_verifier VARCHAR(128) NOT NULL,
+   nonce VARCHAR(64) NOT NULL,
+   link_user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+   expires_at TIMESTAMP NOT NULL,
+   created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Chaves de API pessoais (hash SHA-256; prefixo em claro para identificação)
+CREATE TABLE IF NOT EXISTS api_keys (
+   id UUID PRIMARY KEY,
+   user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+   name VARCHAR(100) NOT NULL,
+   prefix VARCHAR(32) UNIQUE NOT NULL,
+   key_hash CHAR(64) NOT NULL,
+   scopes TEXT[] NOT NULL DEFAULT '{}',
+   created_at TIMESTAMP DEFAULT NOW(),
+   last_used_at TIMESTAMP,
+   expires_at TIMESTAMP,
+   revoked_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS api_keys_user_idx ON api_keys (user_id);
+
+-- Passkeys (WebAuthn) e desafios das cerimônias em andamento (uso único, apenas hash)
+CREATE TABLE IF NOT EXISTS webauthn_credentials (
+   id UUID PRIMARY KEY,
+   user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+   name VARCHAR(100) NOT NULL,
+   credential_id BYTEA UNIQUE NOT NULL,
+   public_key BYTEA NOT NULL,
+   algorithm INTEGER NOT NULL,
+   sign_count BIGINT NOT NULL DEFAULT 0,
+   aaguid BYTEA,
+   backup_eligible BOOLEAN NOT NULL DEFAULT FALSE,
+   backup_state BOOLEAN NOT NULL DEFAULT FALSE,
+   created_at TIMESTAMP DEFAULT NOW(),
+   last_used_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS webauthn_credentials_user_idx ON webauthn_credentials (user_id);
+CREATE TABLE IF NOT EXISTS webauthn_challenges (
+   challenge_hash CHAR(64) PRIMARY KEY,
+   ceremony VARCHAR(10) NOT NULL,
+   user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+   expires_at TIMESTAMP NOT NULL,
+   created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Histórico de consentimentos (cada concessão/revogação é uma linha; a atual é a mais recente)
+CREATE TABLE IF NOT EXISTS consent_records (
+   id BIGSERIAL PRIMARY KEY,
+   user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+   purpose VARCHAR(32) NOT NULL,
+   granted BOOLEAN NOT NULL,
+   terms_version VARCHAR(32) NOT NULL,
+   ip VARCHAR(64),
+   user_agent VARCHAR(512),
+   created_at TIMESTAMP DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS consent_records_user_idx ON consent_records (user_id, purpose, id DESC);
+
+-- Trilha de auditoria (append-only: UPDATE e DELETE são bloqueados por trigger).
+-- Sem FK em users: os eventos sobrevivem à exclusão da conta.
+CREATE TABLE IF NOT EXISTS audit_events (
+   id BIGSERIAL PRIMARY KEY,
+   actor_id UUID,
+   user_id UUID,
+   action VARCHAR(64) NOT NULL,
+   target_type VARCHAR(32),
+   target_id VARCHAR(255),
+   ip VARCHAR(64),
+   user_agent VARCHAR(512),
+   changes JSONB,
+   metadata JSONB,
+   created_at TIMESTAMP DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS audit_events_user_idx ON audit_events (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS audit_events_created_idx ON audit_events (created_at DESC);
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+   RAISE EXCEPTION 'audit_events é append-only';
+END;
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE ON audit_events
+FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+-- Exportações de dados pessoais (LGPD). Sem usuário (conta excluída) o arquivo é apagado pelo job.
+CREATE TABLE IF NOT EXISTS data_exports (
+   id UUID PRIMARY KEY,
+   user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+   status VARCHAR(10) NOT NULL,
+   storage_key VARCHAR(255),
+   error VARCHAR(255),
+   created_at TIMESTAMP DEFAULT NOW(),
+   started_at TIMESTAMP,
+   completed_at TIMESTAMP,
+   expires_at TIMESTAMP
+);
+-- no máximo uma exportação pendente ou em andamento por usuário
+CREATE UNIQUE INDEX IF NOT EXISTS data_exports_active_user_idx
+ON data_exports (user_id) WHERE status IN ('pending', 'running');
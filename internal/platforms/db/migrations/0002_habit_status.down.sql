@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS habits_user_status_idx;
+ALTER TABLE habits
+   DROP COLUMN IF EXISTS updated_at,
+   DROP COLUMN IF EXISTS status;
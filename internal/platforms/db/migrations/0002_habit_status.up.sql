@@ -0,0 +1,6 @@
+-- Ciclo de vida dos hábitos: ativo, pausado ou arquivado. Pausar ou arquivar mantém o histórico.
+ALTER TABLE habits
+   ADD COLUMN status VARCHAR(10) NOT NULL DEFAULT 'active',
+   ADD COLUMN updated_at TIMESTAMP;
+ALTER TABLE habits ADD CONSTRAINT habits_status_check CHECK (status IN ('active', 'paused', 'archived'));
+CREATE INDEX habits_user_status_idx ON habits (user_id, status);
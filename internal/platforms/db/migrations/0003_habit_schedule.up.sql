@@ -0,0 +1,15 @@
+-- Agenda estruturada dos hábitos (JSON de models.HabitSchedule). Hábitos com frequência
+-- "Daily"/"Weekly" ganham a agenda equivalente a partir da data de criação; os demais ficam sem agenda.
+ALTER TABLE habits ADD COLUMN schedule JSONB;
+UPDATE habits SET schedule = jsonb_build_object(
+   'kind', 'daily',
+   'interval', 1,
+   'start_date', to_char(COALESCE(created_at, NOW()), 'YYYY-MM-DD'),
+   'timezone', 'UTC')
+WHERE lower(trim(frequency)) = 'daily';
+UPDATE habits SET schedule = jsonb_build_object(
+   'kind', 'times_per_week',
+   'times', 1,
+   'start_date', to_char(COALESCE(created_at, NOW()), 'YYYY-MM-DD'),
+   'timezone', 'UTC')
+WHERE lower(trim(frequency)) = 'weekly';
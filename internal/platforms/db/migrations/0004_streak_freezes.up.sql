@@ -0,0 +1,10 @@
+-- Proteções de sequência compradas com mana: cada uma cobre um dia (no fuso da agenda) de um hábito.
+CREATE TABLE habit_streak_freezes (
+   habit_id UUID REFERENCES habits(id) ON DELETE CASCADE,
+   user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+   day DATE NOT NULL,
+   mana_cost INTEGER NOT NULL,
+   created_at TIMESTAMP DEFAULT NOW(),
+   PRIMARY KEY (habit_id, day)
+);
+CREATE INDEX habit_streak_freezes_user_idx ON habit_streak_freezes (user_id);
@@ -0,0 +1,4 @@
+ALTER TABLE habits
+   DROP COLUMN IF EXISTS goal_aggregation,
+   DROP COLUMN IF EXISTS goal_unit,
+   DROP COLUMN IF EXISTS goal_target;
@@ -0,0 +1,10 @@
+-- Metas quantitativas diárias (todas nulas = hábito sem meta).
+ALTER TABLE habits
+   ADD COLUMN goal_target INTEGER,
+   ADD COLUMN goal_unit VARCHAR(16),
+   ADD COLUMN goal_aggregation VARCHAR(8);
+ALTER TABLE habits ADD CONSTRAINT habits_goal_check CHECK (
+   (goal_target IS NULL AND goal_unit IS NULL AND goal_aggregation IS NULL)
+   OR (goal_target > 0
+       AND goal_unit IN ('steps', 'ml', 'minutes', 'count', 'mg')
+       AND goal_aggregation IN ('sum', 'max', 'last')));
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS habits_track_pauses ON habits;
+DROP FUNCTION IF EXISTS habits_track_pauses();
+DROP TABLE IF EXISTS habit_pauses;
@@ -0,0 +1,25 @@
+-- Períodos em que o hábito esteve pausado ou arquivado: as ocorrências dentro deles não
+-- quebram a sequência. Mantidos pelo gatilho abaixo a cada mudança de situação.
+CREATE TABLE habit_pauses (
+   habit_id UUID NOT NULL REFERENCES habits(id) ON DELETE CASCADE,
+   paused_at TIMESTAMP NOT NULL,
+   resumed_at TIMESTAMP,
+   PRIMARY KEY (habit_id, paused_at)
+);
+
+CREATE FUNCTION habits_track_pauses() RETURNS trigger AS $$
+BEGIN
+   IF NEW.status <> 'active' AND (TG_OP = 'INSERT' OR OLD.status = 'active') THEN
+      INSERT INTO habit_pauses (habit_id, paused_at) VALUES (NEW.id, NOW());
+   ELSIF TG_OP = 'UPDATE' AND NEW.status = 'active' AND OLD.status <> 'active' THEN
+      UPDATE habit_pauses SET resumed_at = NOW() WHERE habit_id = NEW.id AND resumed_at IS NULL;
+   END IF;
+   RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER habits_track_pauses AFTER INSERT OR UPDATE OF status ON habits
+FOR EACH ROW EXECUTE FUNCTION habits_track_pauses();
+
+-- Hábitos já pausados ou arquivados: o período começa na última alteração conhecida.
+INSERT INTO habit_pauses (habit_id, paused_at)
+SELECT id, COALESCE(updated_at, created_at, NOW()) FROM habits WHERE status <> 'active';
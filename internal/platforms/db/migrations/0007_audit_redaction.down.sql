@@ -0,0 +1,9 @@
+-- Os dados anonimizados não voltam; só o gatilho estrito e o esquema são restaurados.
+DROP FUNCTION IF EXISTS redact_audit_events(UUID);
+DROP FUNCTION IF EXISTS audit_redact_changes(JSONB, TEXT[]);
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+   RAISE EXCEPTION 'audit_events é append-only';
+END;
+$$ LANGUAGE plpgsql;
+ALTER TABLE audit_events DROP COLUMN IF EXISTS redacted_at;
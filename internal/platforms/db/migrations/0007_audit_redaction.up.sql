@@ -0,0 +1,54 @@
+-- A trilha de auditoria continua append-only, com uma exceção: a anonimização dos eventos de
+-- uma conta excluída (redact_audit_events), que liga guardiao.audit_redaction só na transação.
+ALTER TABLE audit_events ADD COLUMN redacted_at TIMESTAMP;
+
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+   IF TG_OP = 'UPDATE' AND current_setting('guardiao.audit_redaction', true) = 'on'
+      AND NEW.id = OLD.id AND NEW.action = OLD.action
+      AND NEW.target_type IS NOT DISTINCT FROM OLD.target_type
+      AND NEW.created_at IS NOT DISTINCT FROM OLD.created_at THEN
+      RETURN NEW;
+   END IF;
+   RAISE EXCEPTION 'audit_events é append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+-- audit_redact_changes troca os valores das alterações indicadas (todas, se keys for nulo)
+-- por {"redacted": true}, mantendo apenas o nome do campo.
+CREATE FUNCTION audit_redact_changes(changes JSONB, keys TEXT[]) RETURNS JSONB AS $$
+   SELECT changes || COALESCE(jsonb_object_agg(k, '{"from": null, "to": null, "redacted": true}'::jsonb), '{}'::jsonb)
+   FROM jsonb_object_keys(changes) AS k
+   WHERE keys IS NULL OR k = ANY (keys);
+$$ LANGUAGE sql IMMUTABLE;
+
+-- redact_audit_events desvincula da pessoa os eventos em que ela é titular ou autora: apaga os
+-- identificadores, IP e user agent dela, os valores das alterações e os metadados pessoais.
+CREATE FUNCTION redact_audit_events(subject UUID) RETURNS INTEGER AS $$
+DECLARE
+   n INTEGER;
+BEGIN
+   PERFORM set_config('guardiao.audit_redaction', 'on', true);
+   UPDATE audit_events SET
+      ip = CASE WHEN actor_id = subject OR actor_id IS NULL THEN NULL ELSE ip END,
+      user_agent = CASE WHEN actor_id = subject OR actor_id IS NULL THEN NULL ELSE user_agent END,
+      actor_id = NULLIF(actor_id, subject),
+      user_id = NULLIF(user_id, subject),
+      changes = audit_redact_changes(changes, NULL),
+      metadata = NULLIF(metadata - 'email' - 'key' - 'name', '{}'::jsonb),
+      redacted_at = NOW()
+   WHERE actor_id = subject OR user_id = subject;
+   GET DIAGNOSTICS n = ROW_COUNT;
+   PERFORM set_config('guardiao.audit_redaction', 'off', true);
+   RETURN n;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Eventos antigos gravaram dados pessoais em texto puro: ficam só os nomes dos campos.
+SELECT set_config('guardiao.audit_redaction', 'on', true);
+UPDATE audit_events SET changes = audit_redact_changes(changes, ARRAY['name', 'email', 'pending_email', 'contact'])
+WHERE changes ?| ARRAY['name', 'email', 'pending_email', 'contact'];
+UPDATE audit_events SET metadata = NULLIF(metadata - 'email', '{}'::jsonb) WHERE metadata ? 'email';
+UPDATE audit_events SET metadata = metadata - 'key'
+WHERE action = 'admin.lockout_unlocked' AND metadata->>'scope' = 'account';
+SELECT set_config('guardiao.audit_redaction', 'off', true);
@@ -0,0 +1,3 @@
+-- Só volta se não houver valores cifrados maiores que 255 caracteres.
+ALTER TABLE email_verification_tokens ALTER COLUMN email TYPE VARCHAR(255);
+ALTER TABLE user_identities ALTER COLUMN email TYPE VARCHAR(255);
@@ -0,0 +1,5 @@
+-- E-mails das identidades externas e dos tokens de verificação passam a ser gravados
+-- cifrados (envelope fieldcrypt), maiores que 255 caracteres. Os valores em texto puro são
+-- convertidos pelo cmd/reencrypt.
+ALTER TABLE user_identities ALTER COLUMN email TYPE TEXT;
+ALTER TABLE email_verification_tokens ALTER COLUMN email TYPE TEXT;
@@ -0,0 +1,2 @@
+-- Os e-mails descartados não são recuperáveis; as chaves novas continuam válidas.
+SELECT 1;
@@ -0,0 +1,6 @@
+-- O escopo de conta do controle de login passa a ser identificado pelo índice cego do
+-- e-mail (HMAC, o mesmo de users.email_bidx) e não mais pelo e-mail. Os contadores antigos
+-- são descartados (valem no máximo LOGIN_LOCKOUT_DURATION) e os eventos de bloqueio perdem
+-- a chave: a conta continua em user_id.
+DELETE FROM login_attempts WHERE scope = 'account';
+UPDATE login_lockouts SET key = '' WHERE scope = 'account';
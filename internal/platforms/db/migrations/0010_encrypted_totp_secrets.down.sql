@@ -0,0 +1,3 @@
+-- Só volta se não houver segredos cifrados (maiores que 64 caracteres).
+ALTER TABLE users ALTER COLUMN totp_pending_secret TYPE VARCHAR(64);
+ALTER TABLE users ALTER COLUMN totp_secret TYPE VARCHAR(64);
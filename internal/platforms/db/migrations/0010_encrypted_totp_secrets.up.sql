@@ -0,0 +1,4 @@
+-- Segredos TOTP passam a ser gravados cifrados (envelope fieldcrypt, amarrados ao usuário),
+-- maiores que 64 caracteres. Os valores em texto puro são convertidos pelo cmd/reencrypt.
+ALTER TABLE users ALTER COLUMN totp_secret TYPE TEXT;
+ALTER TABLE users ALTER COLUMN totp_pending_secret TYPE TEXT;
@@ -0,0 +1,38 @@
+-- Volta à anonimização da migração 0007 (liberada por guardiao.audit_redaction).
+GRANT guardiao_audit_redactor TO CURRENT_USER;
+DROP FUNCTION IF EXISTS redact_audit_events(UUID);
+REVOKE guardiao_audit_redactor FROM CURRENT_USER;
+REVOKE ALL ON audit_events FROM guardiao_audit_redactor;
+DROP ROLE IF EXISTS guardiao_audit_redactor;
+
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+   IF TG_OP = 'UPDATE' AND current_setting('guardiao.audit_redaction', true) = 'on'
+      AND NEW.id = OLD.id AND NEW.action = OLD.action
+      AND NEW.target_type IS NOT DISTINCT FROM OLD.target_type
+      AND NEW.created_at IS NOT DISTINCT FROM OLD.created_at THEN
+      RETURN NEW;
+   END IF;
+   RAISE EXCEPTION 'audit_events é append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION redact_audit_events(subject UUID) RETURNS INTEGER AS $$
+DECLARE
+   n INTEGER;
+BEGIN
+   PERFORM set_config('guardiao.audit_redaction', 'on', true);
+   UPDATE audit_events SET
+      ip = CASE WHEN actor_id = subject OR actor_id IS NULL THEN NULL ELSE ip END,
+      user_agent = CASE WHEN actor_id = subject OR actor_id IS NULL THEN NULL ELSE user_agent END,
+      actor_id = NULLIF(actor_id, subject),
+      user_id = NULLIF(user_id, subject),
+      changes = audit_redact_changes(changes, NULL),
+      metadata = NULLIF(metadata - 'email' - 'key' - 'name', '{}'::jsonb),
+      redacted_at = NOW()
+   WHERE actor_id = subject OR user_id = subject;
+   GET DIAGNOSTICS n = ROW_COUNT;
+   PERFORM set_config('guardiao.audit_redaction', 'off', true);
+   RETURN n;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,50 @@
+-- A exceção ao append-only de audit_events deixa de depender de guardiao.audit_redaction,
+-- que qualquer sessão liga com SET. Só o papel guardiao_audit_redactor (sem login) passa
+-- pelo gatilho, e só por redact_audit_events, que roda com os privilégios dele.
+DO $$
+BEGIN
+   IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'guardiao_audit_redactor') THEN
+      CREATE ROLE guardiao_audit_redactor NOLOGIN;
+   END IF;
+END
+$$;
+GRANT SELECT, UPDATE ON audit_events TO guardiao_audit_redactor;
+
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+   IF TG_OP = 'UPDATE' AND current_user = 'guardiao_audit_redactor'
+      AND NEW.id = OLD.id AND NEW.action = OLD.action
+      AND NEW.target_type IS NOT DISTINCT FROM OLD.target_type
+      AND NEW.created_at IS NOT DISTINCT FROM OLD.created_at THEN
+      RETURN NEW;
+   END IF;
+   RAISE EXCEPTION 'audit_events é append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION redact_audit_events(subject UUID) RETURNS INTEGER
+SECURITY DEFINER SET search_path = public, pg_temp AS $$
+DECLARE
+   n INTEGER;
+BEGIN
+   UPDATE audit_events SET
+      ip = CASE WHEN actor_id = subject OR actor_id IS NULL THEN NULL ELSE ip END,
+      user_agent = CASE WHEN actor_id = subject OR actor_id IS NULL THEN NULL ELSE user_agent END,
+      actor_id = NULLIF(actor_id, subject),
+      user_id = NULLIF(user_id, subject),
+      changes = audit_redact_changes(changes, NULL),
+      metadata = NULLIF(metadata - 'email' - 'key' - 'name', '{}'::jsonb),
+      redacted_at = NOW()
+   WHERE actor_id = subject OR user_id = subject;
+   GET DIAGNOSTICS n = ROW_COUNT;
+   RETURN n;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Trocar o dono exige ser membro do papel; a participação vale só durante a migração.
+-- Depois dela, quem roda as migrações (o usuário da aplicação) só pode executar a função.
+GRANT guardiao_audit_redactor TO CURRENT_USER;
+ALTER FUNCTION redact_audit_events(UUID) OWNER TO guardiao_audit_redactor;
+REVOKE ALL ON FUNCTION redact_audit_events(UUID) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION redact_audit_events(UUID) TO CURRENT_USER;
+REVOKE guardiao_audit_redactor FROM CURRENT_USER;
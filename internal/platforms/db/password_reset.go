@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrResetTokenInvalid indica token de redefinição inexistente, expirado ou já utilizado.
+var ErrResetTokenInvalid = errors.New("token de redefinição inválido ou expirado")
+
+// CreatePasswordResetToken grava o hash de um novo token de redefinição de senha.
+func (c *Client) CreatePasswordResetToken(ctx context.Context, userID, tokenHash string, expiresAt time.Time) error {
+	const q = `INSERT INTO password_reset_tokens (id, user_id, token_hash, expires_at) VALUES ($1, $2, $3, $4)`
+	if _, err := c.pool.Exec(ctx, q, uuid.New().String(), userID, tokenHash, expiresAt); err != nil {
+		return fmt.Errorf("falha ao registrar token de redefinição: %w", err)
+	}
+	return nil
+}
+
+// ResetPasswordWithToken consome o token (uso único), grava a nova senha, invalida os demais
+// tokens pendentes e revoga todas as sessões do usuário — tudo na mesma transação.
+func (c *Client) ResetPasswordWithToken(ctx context.Context, tokenHash, passwordHash string) (string, error) {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("falha ao iniciar transação: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	var userID string
+	const consume = `
+       UPDATE password_reset_tokens SET used_at = NOW()
+       WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+       RETURNING user_id`
+	if err = tx.QueryRow(ctx, consume, tokenHash).Scan(&userID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrResetTokenInvalid
+		}
+		return "", fmt.Errorf("falha ao consumir token de redefinição: %w", err)
+	}
+
+	if _, err = tx.Exec(ctx, `UPDATE users SET password_hash = $2 WHERE id = $1`, userID, passwordHash); err != nil {
+		return "", fmt.Errorf("falha ao atualizar senha: %w", err)
+	}
+	if _, err = tx.Exec(ctx, `UPDATE password_reset_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL`, userID); err != nil {
+		return "", fmt.Errorf("falha ao invalidar tokens pendentes: %w", err)
+	}
+	if _, err = tx.Exec(ctx, `UPDATE auth_sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID); err != nil {
+		return "", fmt.Errorf("falha ao revogar sessões do usuário: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return "", err
+	}
+	return userID, nil
+}
@@ -5,26 +5,68 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"os"
 	"strings"
 	"time"
 
+	"go-guardiao-api/internal/platforms/fieldcrypt"
 	"go-guardiao-api/pkg/models"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Client struct {
-	pool *pgxpool.Pool
+	pool   *pgxpool.Pool
+	fields *fieldcrypt.Keyring
 }
 
-// NewDBClient cria pool de conexões com retries exponenciais.
+// NewDBClient conecta ao banco e deixa o esquema em dia: aplica as migrações pendentes
+// ou, com DB_AUTO_MIGRATE=false, apenas confere que não há nenhuma pendente.
+// As chaves da criptografia de campos vêm do ambiente (ver fieldcrypt.NewFromEnv).
 func NewDBClient(dsn string) (*Client, error) {
+	client, err := Connect(dsn)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	if strings.EqualFold(os.Getenv("DB_AUTO_MIGRATE"), "false") {
+		statuses, err := client.MigrationStatuses(ctx)
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("falha ao verificar migrações: %w", err)
+		}
+		for _, st := range statuses {
+			if st.AppliedAt == nil {
+				client.Close()
+				return nil, ErrSchemaOutdated
+			}
+		}
+	} else if _, err := client.MigrateUp(ctx); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("falha ao migrar o esquema do DB: %w", err)
+	}
+	if err := client.backfillEmailIndex(ctx); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// Connect cria pool de conexões com retries exponenciais, sem tocar no esquema
+// (usado pelo comando migrate).
+func Connect(dsn string) (*Client, error) {
 	const maxRetries = 5
 	var err error
 	var pool *pgxpool.Pool
 
+	fields, err := fieldcrypt.NewFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("falha ao carregar chaves de criptografia: %w", err)
+	}
+
 	log.Printf("Tentando conectar ao PostgreSQL... DSN: %s", dsn)
 
 	for i := 0; i < maxRetries; i++ {
@@ -36,13 +78,8 @@ func NewDBClient(dsn string) (*Client, error) {
 			err = pool.Ping(ctx)
 			cancel2()
 			if err == nil {
-				client := &Client{pool: pool}
-				if initErr := client.InitSchema(context.Background()); initErr != nil {
-					pool.Close()
-					return nil, fmt.Errorf("falha ao inicializar o esquema do DB: %w", initErr)
-				}
 				log.Println("Conexão com PostgreSQL estabelecida com sucesso.")
-				return client, nil
+				return &Client{pool: pool, fields: fields}, nil
 			}
 		}
 		log.Printf("Falha na conexão (tentativa %d/%d): %v", i+1, maxRetries, err)
@@ -60,170 +97,81 @@ func (c *Client) Close() {
 	}
 }
 
-// ensureColumn adiciona uma coluna se ela não existir (compatível com Neon/Postgres)
-func ensureColumn(ctx context.Context, tx pgx.Tx, table, column, definition string) error {
-	var exists bool
-	const q = `
-       SELECT EXISTS (
-          SELECT 1
-          FROM information_schema.columns
-          WHERE table_name = $1 AND column_name = $2
-       );`
-	if err := tx.QueryRow(ctx, q, table, column).Scan(&exists); err != nil {
-		return fmt.Errorf("falha ao checar coluna %s.%s: %w", table, column, err)
-	}
-	if exists {
-		return nil
-	}
-	stmt := fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition)
-	if _, err := tx.Exec(ctx, stmt); err != nil {
-		return fmt.Errorf("falha ao adicionar coluna %s.%s: %w", table, column, err)
-	}
-	return nil
-}
-
-func (c *Client) InitSchema(ctx context.Context) error {
-	log.Println("Verificando e inicializando o esquema do banco de dados...")
+func (c *Client) CreateUser(ctx context.Context, user models.User) error {
 	tx, err := c.pool.Begin(ctx)
 	if err != nil {
-		return err
+		return fmt.Errorf("falha ao iniciar transação: %w", err)
 	}
 	defer func() {
 		if err != nil {
 			_ = tx.Rollback(ctx)
 		}
 	}()
-
-	// Tabela users
-	if _, err = tx.Exec(ctx, `
-       CREATE TABLE IF NOT EXISTS users (
-          id UUID PRIMARY KEY,
-          email VARCHAR(255) UNIQUE NOT NULL,
-          name VARCHAR(255),
-          theme VARCHAR(50),
-          created_at TIMESTAMP DEFAULT NOW()
-       );`); err != nil {
-		return fmt.Errorf("falha ao criar tabela users: %w", err)
-	}
-	// Coluna de senha (bcrypt ~60 chars; usamos VARCHAR(72))
-	if err = ensureColumn(ctx, tx, "users", "password_hash", "VARCHAR(72)"); err != nil {
-		return err
-	}
-	// Índice único (idempotente)
-	if _, err = tx.Exec(ctx, `CREATE UNIQUE INDEX IF NOT EXISTS users_email_unique_idx ON users (email);`); err != nil {
-		return fmt.Errorf("falha ao criar índice de email: %w", err)
-	}
-
-	// Demais tabelas
-	if _, err = tx.Exec(ctx, `
-       CREATE TABLE IF NOT EXISTS user_mana (
-          user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
-          balance INTEGER NOT NULL DEFAULT 0,
-          updated_at TIMESTAMP DEFAULT NOW()
-       );`); err != nil {
-		return fmt.Errorf("falha ao criar tabela user_mana: %w", err)
-	}
-	if _, err = tx.Exec(ctx, `
-       CREATE TABLE IF NOT EXISTS mana_transactions (
-          id SERIAL PRIMARY KEY,
-          user_id UUID REFERENCES users(id) ON DELETE CASCADE,
-          type VARCHAR(50) NOT NULL,
-          amount INTEGER NOT NULL,
-          reference_id VARCHAR(255),
-          created_at TIMESTAMP DEFAULT NOW()
-       );`); err != nil {
-		return fmt.Errorf("falha ao criar tabela mana_transactions: %w", err)
-	}
-
-	// Tabela support_contacts
-	if _, err = tx.Exec(ctx, `
-       CREATE TABLE IF NOT EXISTS support_contacts (
-          contact_id UUID PRIMARY KEY,
-          user_id UUID REFERENCES users(id) ON DELETE CASCADE,
-          contact_email VARCHAR(255) NOT NULL,
-          nickname VARCHAR(255),
-          notification_preference VARCHAR(50),
-          created_at TIMESTAMP DEFAULT NOW()
-       );`); err != nil {
-		return fmt.Errorf("falha ao criar tabela support_contacts: %w", err)
-	}
-	// garante coluna phone (idempotente)
-	if err = ensureColumn(ctx, tx, "support_contacts", "phone", "VARCHAR(30)"); err != nil {
+	if err = c.createUserTx(ctx, tx, user); err != nil {
 		return err
 	}
-
-	if _, err = tx.Exec(ctx, `
-       CREATE TABLE IF NOT EXISTS habits (
-          id UUID PRIMARY KEY,
-          user_id UUID REFERENCES users(id) ON DELETE CASCADE,
-          name VARCHAR(255) NOT NULL,
-          goal_type VARCHAR(50),
-          frequency VARCHAR(50),
-          created_at TIMESTAMP DEFAULT NOW()
-       );`); err != nil {
-		return fmt.Errorf("falha ao criar tabela habits: %w", err)
-	}
-	if _, err = tx.Exec(ctx, `
-       CREATE TABLE IF NOT EXISTS habit_logs (
-          id SERIAL PRIMARY KEY,
-          habit_id UUID REFERENCES habits(id) ON DELETE CASCADE,
-          user_id UUID REFERENCES users(id) ON DELETE CASCADE,
-          value INTEGER NOT NULL,
-          timestamp TIMESTAMP DEFAULT NOW()
-       );`); err != nil {
-		return fmt.Errorf("falha ao criar tabela habit_logs: %w", err)
-	}
-
 	return tx.Commit(ctx)
 }
 
-func (c *Client) CreateUser(ctx context.Context, user models.User) error {
-	tx, err := c.pool.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("falha ao iniciar transação: %w", err)
-	}
-	defer func() {
-		if err != nil {
-			_ = tx.Rollback(ctx)
-		}
-	}()
+// createUserTx insere o usuário com saldo de mana zerado e o papel padrão.
+func (c *Client) createUserTx(ctx context.Context, tx pgx.Tx, user models.User) error {
 	if strings.TrimSpace(user.ID) == "" {
 		user.ID = uuid.New().String()
 	}
-	const sqlUser = `INSERT INTO users (id, email, name, theme, password_hash) VALUES ($1, $2, $3, $4, $5)`
-	if _, err = tx.Exec(ctx, sqlUser, strings.ToLower(strings.TrimSpace(user.ID)), strings.ToLower(strings.TrimSpace(user.Email)), strings.TrimSpace(user.Name), strings.TrimSpace(user.Theme), user.PasswordHash); err != nil {
+	user.ID = strings.ToLower(strings.TrimSpace(user.ID))
+	email := strings.ToLower(strings.TrimSpace(user.Email))
+	sealedEmail, err := c.seal(fieldUserEmail, user.ID, email)
+	if err != nil {
+		return err
+	}
+	sealedName, err := c.seal(fieldUserName, user.ID, strings.TrimSpace(user.Name))
+	if err != nil {
+		return err
+	}
+	const sqlUser = `INSERT INTO users (id, email, email_bidx, name, theme, password_hash, email_verified) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	if _, err := tx.Exec(ctx, sqlUser, user.ID, sealedEmail, c.emailIndex(email), sealedName, strings.TrimSpace(user.Theme), user.PasswordHash, user.EmailVerified); err != nil {
 		return fmt.Errorf("falha ao inserir usuário: %w", err)
 	}
 	const sqlMana = `INSERT INTO user_mana (user_id, balance) VALUES ($1, 0)`
-	if _, err = tx.Exec(ctx, sqlMana, user.ID); err != nil {
+	if _, err := tx.Exec(ctx, sqlMana, user.ID); err != nil {
 		return fmt.Errorf("falha ao inicializar saldo de mana: %w", err)
 	}
-	return tx.Commit(ctx)
+	const sqlRole = `INSERT INTO user_roles (user_id, role) VALUES ($1, $2)`
+	if _, err := tx.Exec(ctx, sqlRole, user.ID, models.RoleUser); err != nil {
+		return fmt.Errorf("falha ao atribuir papel padrão: %w", err)
+	}
+	return nil
 }
 
 func (c *Client) GetUserByID(ctx context.Context, userID string) (models.User, error) {
 	user := models.User{}
-	sql := `SELECT id, email, name, theme FROM users WHERE id = $1`
-	err := c.pool.QueryRow(ctx, sql, userID).Scan(&user.ID, &user.Email, &user.Name, &user.Theme)
+	sql := `SELECT id, email, name, theme, email_verified, COALESCE(pending_email, ''), totp_enabled, deletion_scheduled_at FROM users WHERE id = $1`
+	err := c.pool.QueryRow(ctx, sql, userID).Scan(&user.ID, &user.Email, &user.Name, &user.Theme, &user.EmailVerified, &user.PendingEmail, &user.TOTPEnabled, &user.DeletionScheduledAt)
 	if err != nil {
 		return models.User{}, err
 	}
+	if err := c.openUser(&user); err != nil {
+		return models.User{}, err
+	}
 	return user, nil
 }
 
 func (c *Client) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
 	u := models.User{}
-	sql := `SELECT id, email, name, theme, password_hash FROM users WHERE LOWER(email) = LOWER($1) LIMIT 1`
-	err := c.pool.QueryRow(ctx, sql, strings.TrimSpace(email)).Scan(&u.ID, &u.Email, &u.Name, &u.Theme, &u.PasswordHash)
+	sql := `SELECT id, email, name, theme, email_verified, COALESCE(pending_email, ''), totp_enabled, deletion_scheduled_at, COALESCE(password_hash, '') FROM users WHERE email_bidx = $1`
+	err := c.pool.QueryRow(ctx, sql, c.emailIndex(email)).Scan(&u.ID, &u.Email, &u.Name, &u.Theme, &u.EmailVerified, &u.PendingEmail, &u.TOTPEnabled, &u.DeletionScheduledAt, &u.PasswordHash)
 	if err != nil {
 		return models.User{}, err
 	}
+	if err := c.openUser(&u); err != nil {
+		return models.User{}, err
+	}
 	return u, nil
 }
 
 func (c *Client) GetUserPasswordHash(ctx context.Context, userID string) (string, error) {
 	var hash string
-	const q = `SELECT password_hash FROM users WHERE id = $1`
+	const q = `SELECT COALESCE(password_hash, '') FROM users WHERE id = $1`
 	err := c.pool.QueryRow(ctx, q, userID).Scan(&hash)
 	if err != nil {
 		return "", err
@@ -243,9 +191,23 @@ func (c *Client) SetUserPassword(ctx context.Context, userID, hash string) error
 	return nil
 }
 
+// ReplacePasswordHash troca o hash apenas se ele ainda for oldHash (rehash após login),
+// para não sobrescrever uma troca de senha concorrente.
+func (c *Client) ReplacePasswordHash(ctx context.Context, userID, oldHash, newHash string) error {
+	const q = `UPDATE users SET password_hash = $3 WHERE id = $1 AND password_hash = $2`
+	if _, err := c.pool.Exec(ctx, q, userID, oldHash, newHash); err != nil {
+		return fmt.Errorf("falha ao atualizar hash de senha: %w", err)
+	}
+	return nil
+}
+
 func (c *Client) UpdateUser(ctx context.Context, user models.User) error {
+	name, err := c.seal(fieldUserName, user.ID, strings.TrimSpace(user.Name))
+	if err != nil {
+		return err
+	}
 	sql := `UPDATE users SET name = $2, theme = $3 WHERE id = $1`
-	cmdTag, err := c.pool.Exec(ctx, sql, user.ID, strings.TrimSpace(user.Name), strings.TrimSpace(user.Theme))
+	cmdTag, err := c.pool.Exec(ctx, sql, user.ID, name, strings.TrimSpace(user.Theme))
 	if err != nil {
 		return err
 	}
@@ -256,9 +218,18 @@ func (c *Client) UpdateUser(ctx context.Context, user models.User) error {
 }
 
 func (c *Client) UpdateUserEmail(ctx context.Context, userID, email string) error {
-	const q = `UPDATE users SET email = $2 WHERE id = $1`
-	cmdTag, err := c.pool.Exec(ctx, q, userID, strings.ToLower(strings.TrimSpace(email)))
+	email = strings.ToLower(strings.TrimSpace(email))
+	sealed, err := c.seal(fieldUserEmail, userID, email)
+	if err != nil {
+		return err
+	}
+	const q = `UPDATE users SET email = $2, email_bidx = $3 WHERE id = $1`
+	cmdTag, err := c.pool.Exec(ctx, q, userID, sealed, c.emailIndex(email))
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrEmailTaken
+		}
 		return fmt.Errorf("falha ao atualizar e-mail: %w", err)
 	}
 	if cmdTag.RowsAffected() == 0 {
@@ -273,12 +244,25 @@ func (c *Client) CreateSupportContact(ctx context.Context, contact models.Suppor
 	if strings.TrimSpace(contact.ContactID) == "" {
 		contact.ContactID = uuid.New().String()
 	}
-	_, err := c.pool.Exec(ctx, sql,
+	contact.ContactID = strings.ToLower(strings.TrimSpace(contact.ContactID))
+	email, err := c.seal(fieldContactEmail, contact.ContactID, strings.TrimSpace(contact.ContactEmail))
+	if err != nil {
+		return err
+	}
+	phone, err := c.seal(fieldContactPhone, contact.ContactID, strings.TrimSpace(contact.Phone))
+	if err != nil {
+		return err
+	}
+	nickname, err := c.seal(fieldContactNickname, contact.ContactID, strings.TrimSpace(contact.Nickname))
+	if err != nil {
+		return err
+	}
+	_, err = c.pool.Exec(ctx, sql,
 		contact.ContactID,
 		contact.UserID,
-		strings.TrimSpace(contact.ContactEmail),
-		strings.TrimSpace(contact.Phone),
-		strings.TrimSpace(contact.Nickname),
+		email,
+		phone,
+		nickname,
 		strings.TrimSpace(contact.NotificationPreference),
 	)
 	return err
@@ -305,6 +289,9 @@ func (c *Client) GetSupportContactsByUserID(ctx context.Context, userID string)
 		); err != nil {
 			return nil, err
 		}
+		if err := c.openContact(&contact); err != nil {
+			return nil, err
+		}
 		contacts = append(contacts, contact)
 	}
 	return contacts, nil
@@ -326,8 +313,19 @@ func (c *Client) CreateHabit(ctx context.Context, habit models.Habit) (string, e
 	if strings.TrimSpace(habit.ID) == "" {
 		habit.ID = uuid.New().String()
 	}
-	sql := `INSERT INTO habits (id, user_id, name, goal_type, frequency) VALUES ($1, $2, $3, $4, $5)`
-	_, err := c.pool.Exec(ctx, sql, habit.ID, habit.UserID, strings.TrimSpace(habit.Name), strings.TrimSpace(habit.GoalType), strings.TrimSpace(habit.Frequency))
+	if habit.Status == "" {
+		habit.Status = models.HabitActive
+	}
+	schedule, err := marshalSchedule(habit.Schedule)
+	if err != nil {
+		return "", err
+	}
+	target, unit, aggregation := goalValues(habit.Goal)
+	sql := `
+       INSERT INTO habits (id, user_id, name, goal_type, frequency, schedule, goal_target, goal_unit, goal_aggregation, status)
+       VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	_, err = c.pool.Exec(ctx, sql, habit.ID, habit.UserID, strings.TrimSpace(habit.Name), strings.TrimSpace(habit.GoalType), strings.TrimSpace(habit.Frequency), schedule,
+		target, unit, aggregation, habit.Status)
 	if err != nil {
 		return "", err
 	}
@@ -335,7 +333,7 @@ func (c *Client) CreateHabit(ctx context.Context, habit models.Habit) (string, e
 }
 
 func (c *Client) GetHabitsByUserID(ctx context.Context, userID string) ([]models.Habit, error) {
-	sql := `SELECT id, user_id, name, goal_type, frequency, created_at FROM habits WHERE user_id = $1 ORDER BY created_at DESC`
+	sql := `SELECT ` + habitColumns + ` FROM habits WHERE user_id = $1 ORDER BY created_at DESC`
 	rows, err := c.pool.Query(ctx, sql, userID)
 	if err != nil {
 		return nil, err
@@ -344,8 +342,8 @@ func (c *Client) GetHabitsByUserID(ctx context.Context, userID string) ([]models
 
 	var habits []models.Habit
 	for rows.Next() {
-		habit := models.Habit{}
-		if err := rows.Scan(&habit.ID, &habit.UserID, &habit.Name, &habit.GoalType, &habit.Frequency, &habit.CreatedAt); err != nil {
+		habit, err := scanHabit(rows)
+		if err != nil {
 			return nil, err
 		}
 		habits = append(habits, habit)
@@ -353,14 +351,48 @@ func (c *Client) GetHabitsByUserID(ctx context.Context, userID string) ([]models
 	return habits, nil
 }
 
-func (c *Client) LogHabit(ctx context.Context, logData models.HabitLog) error {
-	sql := `INSERT INTO habit_logs (habit_id, user_id, value) VALUES ($1, $2, $3)`
-	_, err := c.pool.Exec(ctx, sql, logData.HabitID, logData.UserID, logData.Value)
+// LogHabit grava o registro com o horário informado (ou NOW(), se vier zerado) e o devolve com ID e horário.
+// Se mana > 0, credita o ganho na mesma transação (HABIT_COMPLETION com o registro como
+// reference_id, para que editá-lo ou excluí-lo ajuste a mana) e devolve o crédito.
+func (c *Client) LogHabit(ctx context.Context, logData models.HabitLog, mana int) (_ models.HabitLog, _ *LogManaAdjustment, err error) {
+	var ts *time.Time
+	if !logData.Timestamp.IsZero() {
+		t := logData.Timestamp.UTC()
+		ts = &t
+	}
+	tx, err := c.pool.Begin(ctx)
 	if err != nil {
-		return err
+		return models.HabitLog{}, nil, fmt.Errorf("falha ao iniciar transação: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	sql := `INSERT INTO habit_logs (habit_id, user_id, value, timestamp) VALUES ($1, $2, $3, COALESCE($4, NOW())) RETURNING id::text, timestamp`
+	if err = tx.QueryRow(ctx, sql, logData.HabitID, logData.UserID, logData.Value, ts).Scan(&logData.ID, &logData.Timestamp); err != nil {
+		return models.HabitLog{}, nil, err
+	}
+	var grant *LogManaAdjustment
+	if mana > 0 {
+		grant = &LogManaAdjustment{Amount: mana}
+		err = tx.QueryRow(ctx, `UPDATE user_mana SET balance = balance + $1, updated_at = NOW() WHERE user_id = $2 RETURNING balance`,
+			mana, logData.UserID).Scan(&grant.Balance)
+		if err != nil {
+			return models.HabitLog{}, nil, fmt.Errorf("falha ao atualizar saldo: %w", err)
+		}
+		_, err = tx.Exec(ctx, `INSERT INTO mana_transactions (user_id, type, amount, reference_id) VALUES ($1, $2, $3, $4)`,
+			logData.UserID, models.ManaTypeHabitCompletion, mana, logData.ID)
+		if err != nil {
+			return models.HabitLog{}, nil, fmt.Errorf("falha ao registrar transação de mana: %w", err)
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return models.HabitLog{}, nil, err
 	}
 	log.Printf("DB Ação: Log de hábito %s registrado.", logData.HabitID)
-	return nil
+	return logData, grant, nil
 }
 
 func (c *Client) GetHabitLogs(ctx context.Context, habitID string) ([]models.HabitLog, error) {
@@ -442,6 +474,9 @@ func (c *Client) GetTopManaUsers(ctx context.Context, limit int) ([]models.Leade
 		if err := rows.Scan(&e.UserID, &e.UserName, &e.Mana); err != nil {
 			return nil, err
 		}
+		if e.UserName, err = c.open(fieldUserName, e.UserID, e.UserName); err != nil {
+			return nil, err
+		}
 		entries = append(entries, e)
 	}
 	return entries, nil
@@ -473,11 +508,6 @@ func (c *Client) DeleteHabit(ctx context.Context, habitID string, userID string)
 }
 
 func (c *Client) GetHabitById(ctx context.Context, habitID string) (models.Habit, error) {
-	habit := models.Habit{}
-	sql := `SELECT id, user_id, name, goal_type, frequency, created_at FROM habits WHERE id = $1`
-	err := c.pool.QueryRow(ctx, sql, habitID).Scan(&habit.ID, &habit.UserID, &habit.Name, &habit.GoalType, &habit.Frequency, &habit.CreatedAt)
-	if err != nil {
-		return models.Habit{}, err
-	}
-	return habit, nil
+	sql := `SELECT ` + habitColumns + ` FROM habits WHERE id = $1`
+	return scanHabit(c.pool.QueryRow(ctx, sql, habitID))
 }
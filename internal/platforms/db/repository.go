@@ -0,0 +1,209 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Repositórios =====
+//
+// Os serviços dependem destas interfaces, não do Client concreto. O Client (PostgreSQL)
+// e o MemoryStore (em memória, para desenvolvimento e testes sem banco) implementam
+// todas elas com a mesma semântica: pgx.ErrNoRows quando o registro não existe e os
+// mesmos erros sentinela deste pacote (ErrEmailTaken, ErrRefreshTokenReused...).
+
+// UserRepository reúne conta, credenciais, confirmação de e-mail e exclusão de conta.
+type UserRepository interface {
+	CreateUser(ctx context.Context, user models.User) error
+	GetUserByID(ctx context.Context, userID string) (models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (models.User, error)
+	GetUserPasswordHash(ctx context.Context, userID string) (string, error)
+	SetUserPassword(ctx context.Context, userID, hash string) error
+	ReplacePasswordHash(ctx context.Context, userID, oldHash, newHash string) error
+	UpdateUser(ctx context.Context, user models.User) error
+	UpdateUserEmail(ctx context.Context, userID, email string) error
+
+	SetPendingEmail(ctx context.Context, userID, email string) error
+	CreateEmailVerificationToken(ctx context.Context, userID, email, tokenHash string, expiresAt time.Time) error
+	VerifyEmailWithToken(ctx context.Context, tokenHash string) (string, string, string, error)
+	CreatePasswordResetToken(ctx context.Context, userID, tokenHash string, expiresAt time.Time) error
+	ResetPasswordWithToken(ctx context.Context, tokenHash, passwordHash string) (string, error)
+
+	ScheduleAccountDeletion(ctx context.Context, userID string, at time.Time) error
+	CancelAccountDeletion(ctx context.Context, userID string) error
+	ListDueAccountDeletions(ctx context.Context, limit int) ([]string, error)
+	PurgeUser(ctx context.Context, userID string) error
+}
+
+// RoleRepository guarda os papéis (RBAC) dos usuários.
+type RoleRepository interface {
+	GetUserRoles(ctx context.Context, userID string) ([]string, error)
+	GrantRole(ctx context.Context, userID, role, grantedBy string) error
+	SetUserRoles(ctx context.Context, userID string, roles []string, grantedBy string) error
+}
+
+// ContactRepository guarda a rede de apoio do usuário.
+type ContactRepository interface {
+	CreateSupportContact(ctx context.Context, contact models.SupportContact) error
+	GetSupportContactsByUserID(ctx context.Context, userID string) ([]models.SupportContact, error)
+	DeleteSupportContact(ctx context.Context, contactID string) error
+	DeleteSupportContactByUser(ctx context.Context, userID, contactID string) error
+}
+
+// HabitRepository guarda os hábitos, seus registros, pausas e proteções de sequência.
+type HabitRepository interface {
+	CreateHabit(ctx context.Context, habit models.Habit) (string, error)
+	GetHabitsByUserID(ctx context.Context, userID string) ([]models.Habit, error)
+	GetHabitById(ctx context.Context, habitID string) (models.Habit, error)
+	DeleteHabit(ctx context.Context, habitID string, userID string) error
+	UpdateHabit(ctx context.Context, habit models.Habit) (models.Habit, error)
+	SetHabitStatus(ctx context.Context, habitID, userID, status string) (models.Habit, error)
+	LogHabit(ctx context.Context, logData models.HabitLog, mana int) (models.HabitLog, *LogManaAdjustment, error)
+	UpdateHabitLog(ctx context.Context, logData models.HabitLog, mana int, since time.Time) (models.HabitLog, *LogManaAdjustment, error)
+	DeleteHabitLog(ctx context.Context, habitID, logID, userID string, since time.Time) (*LogManaAdjustment, error)
+	GetHabitLogs(ctx context.Context, habitID string) ([]models.HabitLog, error)
+	ListHabitLogsByUser(ctx context.Context, userID string) ([]models.HabitLog, error)
+	ListHabitPauses(ctx context.Context, habitID string) ([]models.HabitPause, error)
+	ListHabitPausesByUser(ctx context.Context, userID string) ([]models.HabitPause, error)
+	ListStreakFreezes(ctx context.Context, habitID string) ([]models.StreakFreeze, error)
+	ListStreakFreezesByUser(ctx context.Context, userID string) ([]models.StreakFreeze, error)
+	BuyStreakFreeze(ctx context.Context, freeze models.StreakFreeze) (int, error)
+}
+
+// ManaRepository guarda o saldo e o extrato de mana.
+type ManaRepository interface {
+	GetManaBalance(ctx context.Context, userID string) (int, error)
+	UpdateManaBalance(ctx context.Context, txData models.ManaTransaction) error
+	CreateManaTransaction(ctx context.Context, tx models.ManaTransaction) error
+	GetTopManaUsers(ctx context.Context, limit int) ([]models.LeaderboardEntry, error)
+	ListManaTransactions(ctx context.Context, userID string) ([]models.ManaTransaction, error)
+}
+
+// SessionRepository guarda as sessões de autenticação e seus refresh tokens.
+type SessionRepository interface {
+	CreateSession(ctx context.Context, userID, userAgent, ip string) (string, error)
+	GetSession(ctx context.Context, sessionID string) (models.Session, error)
+	TouchSession(ctx context.Context, sessionID, ip string) error
+	ListActiveSessions(ctx context.Context, userID string) ([]models.Session, error)
+	ListAllSessions(ctx context.Context, userID string) ([]models.Session, error)
+	IsSessionActive(ctx context.Context, sessionID string) (bool, error)
+	RevokeSession(ctx context.Context, sessionID string) error
+	RevokeSessionByUser(ctx context.Context, userID, sessionID string) error
+	RevokeOtherSessions(ctx context.Context, userID, keepSessionID string) (int64, error)
+	RevokeUserSessions(ctx context.Context, userID string) error
+	CreateRefreshToken(ctx context.Context, rt models.RefreshToken) error
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (models.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, currentID string, next models.RefreshToken) error
+}
+
+// MFARepository guarda o segredo TOTP e os códigos de recuperação.
+type MFARepository interface {
+	GetTOTPState(ctx context.Context, userID string) (TOTPState, error)
+	SetPendingTOTPSecret(ctx context.Context, userID, secret string) error
+	EnableTOTP(ctx context.Context, userID string, step int64, recoveryHashes []string) error
+	DisableTOTP(ctx context.Context, userID string) error
+	UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error)
+	ConsumeRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userID string) (int, error)
+}
+
+// PasskeyRepository guarda as passkeys (WebAuthn) e os desafios em andamento.
+type PasskeyRepository interface {
+	CreateWebAuthnChallenge(ctx context.Context, challengeHash string, ch models.WebAuthnChallenge) error
+	ConsumeWebAuthnChallenge(ctx context.Context, challengeHash string) (models.WebAuthnChallenge, error)
+	CreateWebAuthnCredential(ctx context.Context, cred models.WebAuthnCredential) (string, error)
+	GetWebAuthnCredential(ctx context.Context, credentialID []byte) (models.WebAuthnCredential, error)
+	ListWebAuthnCredentials(ctx context.Context, userID string) ([]models.WebAuthnCredential, error)
+	CountWebAuthnCredentials(ctx context.Context, userID string) (int, error)
+	UseWebAuthnCredential(ctx context.Context, id string, storedCount, newCount uint32, backupState bool) (bool, error)
+	DeleteWebAuthnCredential(ctx context.Context, userID, id string) error
+}
+
+// IdentityRepository guarda as identidades externas (OIDC) e os states de login.
+type IdentityRepository interface {
+	CreateOIDCState(ctx context.Context, stateHash string, s models.OIDCLoginState) error
+	ConsumeOIDCState(ctx context.Context, stateHash string) (models.OIDCLoginState, error)
+	GetIdentity(ctx context.Context, provider, subject string) (models.UserIdentity, error)
+	LinkIdentity(ctx context.Context, i models.UserIdentity) error
+	CreateUserWithIdentity(ctx context.Context, user models.User, i models.UserIdentity) error
+	CountUserIdentities(ctx context.Context, userID string) (int, error)
+	TouchIdentity(ctx context.Context, provider, subject, email string) error
+	ListUserIdentities(ctx context.Context, userID string) ([]models.UserIdentity, error)
+	UnlinkIdentity(ctx context.Context, userID, provider string) error
+}
+
+// APIKeyRepository guarda as chaves de API pessoais.
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, k models.APIKey) (string, error)
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (models.APIKey, error)
+	ListAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error)
+	TouchAPIKey(ctx context.Context, keyID string) error
+	RevokeAPIKey(ctx context.Context, userID, keyID string) error
+}
+
+// LoginAttemptRepository guarda o contador de falhas de login e o histórico de bloqueios.
+type LoginAttemptRepository interface {
+	LoginAccountKey(email string) string
+	GetLoginAttempts(ctx context.Context, scope, key string, window time.Duration) (models.LoginAttempts, error)
+	RegisterLoginFailure(ctx context.Context, scope, key string, window time.Duration) (models.LoginAttempts, error)
+	LockLogin(ctx context.Context, scope, key string, until time.Time) error
+	ResetLoginAttempts(ctx context.Context, scope, key string) error
+	RecordLockout(ctx context.Context, l models.LoginLockout) error
+	ListLockouts(ctx context.Context, activeOnly bool, limit, offset int) ([]models.LoginLockout, error)
+	GetLockout(ctx context.Context, id int64) (models.LoginLockout, error)
+	MarkLockoutUnlocked(ctx context.Context, id int64, adminID string) error
+	PurgeLoginHistory(ctx context.Context, before time.Time) error
+}
+
+// ConsentRepository guarda o histórico de consentimentos.
+type ConsentRepository interface {
+	RecordConsents(ctx context.Context, records []models.ConsentRecord) error
+	GetCurrentConsents(ctx context.Context, userID string) (map[string]models.ConsentRecord, error)
+	HasConsent(ctx context.Context, userID, purpose, termsVersion string) (bool, error)
+	ListConsentHistory(ctx context.Context, userID string) ([]models.ConsentRecord, error)
+}
+
+// AuditRepository guarda a trilha de auditoria (append-only).
+type AuditRepository interface {
+	CreateAuditEvent(ctx context.Context, ev models.AuditEvent) error
+	ListAuditEvents(ctx context.Context, f AuditEventFilter, limit, offset int) ([]models.AuditEvent, error)
+	AnonymizeAuditEvents(ctx context.Context, userID string) (int, error)
+}
+
+// DataExportRepository guarda a fila de exportações de dados pessoais.
+type DataExportRepository interface {
+	CreateDataExport(ctx context.Context, userID string) (models.DataExport, error)
+	GetDataExport(ctx context.Context, exportID string) (models.DataExport, error)
+	ListDataExports(ctx context.Context, userID string) ([]models.DataExport, error)
+	ClaimDataExport(ctx context.Context, staleAfter time.Duration) (models.DataExport, error)
+	CompleteDataExport(ctx context.Context, exportID, storageKey string, expiresAt time.Time) error
+	FailDataExport(ctx context.Context, exportID, reason string) error
+	ListStaleDataExports(ctx context.Context, limit int) ([]models.DataExport, error)
+	DeleteDataExport(ctx context.Context, exportID string) error
+}
+
+// Store é o armazenamento completo usado pela API.
+type Store interface {
+	UserRepository
+	RoleRepository
+	ContactRepository
+	HabitRepository
+	ManaRepository
+	SessionRepository
+	MFARepository
+	PasskeyRepository
+	IdentityRepository
+	APIKeyRepository
+	LoginAttemptRepository
+	ConsentRepository
+	AuditRepository
+	DataExportRepository
+	Close()
+}
+
+var (
+	_ Store = (*Client)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"go-guardiao-api/pkg/models"
+)
+
+// GetUserRoles lista os papéis do usuário. Contas sem registro (anteriores ao RBAC) têm apenas "user".
+func (c *Client) GetUserRoles(ctx context.Context, userID string) ([]string, error) {
+	const q = `SELECT role FROM user_roles WHERE user_id = $1 ORDER BY role`
+	rows, err := c.pool.Query(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(roles) == 0 {
+		roles = []string{models.RoleUser}
+	}
+	return roles, nil
+}
+
+// GrantRole atribui um papel ao usuário (idempotente).
+func (c *Client) GrantRole(ctx context.Context, userID, role, grantedBy string) error {
+	var by *string
+	if grantedBy != "" {
+		by = &grantedBy
+	}
+	const q = `INSERT INTO user_roles (user_id, role, granted_by) VALUES ($1, $2, $3) ON CONFLICT (user_id, role) DO NOTHING`
+	if _, err := c.pool.Exec(ctx, q, userID, role, by); err != nil {
+		return fmt.Errorf("falha ao atribuir papel: %w", err)
+	}
+	return nil
+}
+
+// SetUserRoles substitui o conjunto de papéis do usuário ("user" é sempre mantido).
+func (c *Client) SetUserRoles(ctx context.Context, userID string, roles []string, grantedBy string) error {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("falha ao iniciar transação: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	keep := append([]string{models.RoleUser}, roles...)
+	if _, err = tx.Exec(ctx, `DELETE FROM user_roles WHERE user_id = $1 AND role <> ALL($2)`, userID, keep); err != nil {
+		return fmt.Errorf("falha ao remover papéis: %w", err)
+	}
+	for _, role := range keep {
+		const q = `INSERT INTO user_roles (user_id, role, granted_by) VALUES ($1, $2, $3) ON CONFLICT (user_id, role) DO NOTHING`
+		if _, err = tx.Exec(ctx, q, userID, role, grantedBy); err != nil {
+			return fmt.Errorf("falha ao atribuir papel: %w", err)
+		}
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,189 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go-guardiao-api/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrRefreshTokenReused indica que um refresh token já rotacionado foi apresentado novamente.
+var ErrRefreshTokenReused = errors.New("refresh token já utilizado")
+
+// CreateSession abre uma nova sessão de autenticação (dispositivo) para o usuário e retorna seu ID.
+func (c *Client) CreateSession(ctx context.Context, userID, userAgent, ip string) (string, error) {
+	sessionID := uuid.New().String()
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	const q = `INSERT INTO auth_sessions (id, user_id, user_agent, ip, last_seen_at) VALUES ($1, $2, $3, $4, NOW())`
+	if _, err := c.pool.Exec(ctx, q, sessionID, userID, userAgent, ip); err != nil {
+		return "", fmt.Errorf("falha ao criar sessão: %w", err)
+	}
+	return sessionID, nil
+}
+
+// GetSession busca uma sessão pelo ID. Retorna pgx.ErrNoRows se não existir.
+func (c *Client) GetSession(ctx context.Context, sessionID string) (models.Session, error) {
+	const q = `
+       SELECT id, user_id, COALESCE(user_agent, ''), COALESCE(ip, ''), created_at, COALESCE(last_seen_at, created_at), revoked_at
+       FROM auth_sessions WHERE id = $1`
+	s := models.Session{}
+	err := c.pool.QueryRow(ctx, q, sessionID).Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.RevokedAt)
+	if err != nil {
+		return models.Session{}, err
+	}
+	return s, nil
+}
+
+// TouchSession atualiza o último acesso (e IP) da sessão.
+func (c *Client) TouchSession(ctx context.Context, sessionID, ip string) error {
+	const q = `UPDATE auth_sessions SET last_seen_at = NOW(), ip = $2 WHERE id = $1`
+	if _, err := c.pool.Exec(ctx, q, sessionID, ip); err != nil {
+		return fmt.Errorf("falha ao atualizar sessão: %w", err)
+	}
+	return nil
+}
+
+// ListActiveSessions lista as sessões não revogadas do usuário, da mais recente para a mais antiga.
+// Sessões cujo último refresh token expirou não são listadas.
+func (c *Client) ListActiveSessions(ctx context.Context, userID string) ([]models.Session, error) {
+	const q = `
+       SELECT s.id, s.user_id, COALESCE(s.user_agent, ''), COALESCE(s.ip, ''), s.created_at, COALESCE(s.last_seen_at, s.created_at), s.revoked_at
+       FROM auth_sessions s
+       WHERE s.user_id = $1 AND s.revoked_at IS NULL
+         AND EXISTS (SELECT 1 FROM refresh_tokens rt WHERE rt.session_id = s.id AND rt.used_at IS NULL AND rt.expires_at > NOW())
+       ORDER BY COALESCE(s.last_seen_at, s.created_at) DESC`
+	rows, err := c.pool.Query(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		s := models.Session{}
+		if err := rows.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.RevokedAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// IsSessionActive informa se a sessão existe e não foi revogada.
+func (c *Client) IsSessionActive(ctx context.Context, sessionID string) (bool, error) {
+	var active bool
+	const q = `SELECT revoked_at IS NULL FROM auth_sessions WHERE id = $1`
+	err := c.pool.QueryRow(ctx, q, sessionID).Scan(&active)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return active, nil
+}
+
+// RevokeSession revoga uma sessão (e, portanto, toda a sua família de refresh tokens).
+func (c *Client) RevokeSession(ctx context.Context, sessionID string) error {
+	const q = `UPDATE auth_sessions SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`
+	if _, err := c.pool.Exec(ctx, q, sessionID); err != nil {
+		return fmt.Errorf("falha ao revogar sessão: %w", err)
+	}
+	return nil
+}
+
+// RevokeSessionByUser revoga uma sessão garantindo que pertence ao userID.
+// Retorna pgx.ErrNoRows se a sessão não existir, for de outro usuário ou já estiver revogada.
+func (c *Client) RevokeSessionByUser(ctx context.Context, userID, sessionID string) error {
+	const q = `UPDATE auth_sessions SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+	cmdTag, err := c.pool.Exec(ctx, q, sessionID, userID)
+	if err != nil {
+		return fmt.Errorf("falha ao revogar sessão: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// RevokeOtherSessions revoga todas as sessões do usuário exceto a informada; retorna quantas foram encerradas.
+func (c *Client) RevokeOtherSessions(ctx context.Context, userID, keepSessionID string) (int64, error) {
+	const q = `UPDATE auth_sessions SET revoked_at = NOW() WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL`
+	cmdTag, err := c.pool.Exec(ctx, q, userID, keepSessionID)
+	if err != nil {
+		return 0, fmt.Errorf("falha ao revogar sessões: %w", err)
+	}
+	return cmdTag.RowsAffected(), nil
+}
+
+// RevokeUserSessions revoga todas as sessões ativas do usuário.
+func (c *Client) RevokeUserSessions(ctx context.Context, userID string) error {
+	const q = `UPDATE auth_sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+	if _, err := c.pool.Exec(ctx, q, userID); err != nil {
+		return fmt.Errorf("falha ao revogar sessões do usuário: %w", err)
+	}
+	return nil
+}
+
+// CreateRefreshToken persiste o hash de um novo refresh token.
+func (c *Client) CreateRefreshToken(ctx context.Context, rt models.RefreshToken) error {
+	if strings.TrimSpace(rt.ID) == "" {
+		rt.ID = uuid.New().String()
+	}
+	const q = `INSERT INTO refresh_tokens (id, session_id, user_id, token_hash, expires_at) VALUES ($1, $2, $3, $4, $5)`
+	if _, err := c.pool.Exec(ctx, q, rt.ID, rt.SessionID, rt.UserID, rt.TokenHash, rt.ExpiresAt); err != nil {
+		return fmt.Errorf("falha ao registrar refresh token: %w", err)
+	}
+	return nil
+}
+
+// GetRefreshTokenByHash busca um refresh token pelo hash. Retorna pgx.ErrNoRows se não existir.
+func (c *Client) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (models.RefreshToken, error) {
+	rt := models.RefreshToken{}
+	const q = `SELECT id, session_id, user_id, token_hash, expires_at, used_at, created_at FROM refresh_tokens WHERE token_hash = $1`
+	err := c.pool.QueryRow(ctx, q, tokenHash).Scan(&rt.ID, &rt.SessionID, &rt.UserID, &rt.TokenHash, &rt.ExpiresAt, &rt.UsedAt, &rt.CreatedAt)
+	if err != nil {
+		return models.RefreshToken{}, err
+	}
+	return rt, nil
+}
+
+// RotateRefreshToken marca o token atual como usado e grava o seu sucessor na mesma transação.
+// Se o token já tiver sido usado (corrida ou reuso), retorna ErrRefreshTokenReused.
+func (c *Client) RotateRefreshToken(ctx context.Context, currentID string, next models.RefreshToken) error {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("falha ao iniciar transação: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	const markUsed = `UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1 AND used_at IS NULL`
+	cmdTag, err := tx.Exec(ctx, markUsed, currentID)
+	if err != nil {
+		return fmt.Errorf("falha ao rotacionar refresh token: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		err = ErrRefreshTokenReused
+		return err
+	}
+
+	if strings.TrimSpace(next.ID) == "" {
+		next.ID = uuid.New().String()
+	}
+	const insert = `INSERT INTO refresh_tokens (id, session_id, user_id, token_hash, expires_at) VALUES ($1, $2, $3, $4, $5)`
+	if _, err = tx.Exec(ctx, insert, next.ID, next.SessionID, next.UserID, next.TokenHash, next.ExpiresAt); err != nil {
+		return fmt.Errorf("falha ao registrar refresh token: %w", err)
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,148 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"go-guardiao-api/pkg/models"
+)
+
+var (
+	// ErrWebAuthnCredentialExists indica que a passkey já foi registrada.
+	ErrWebAuthnCredentialExists = errors.New("passkey já registrada")
+	// ErrWebAuthnChallengeInvalid indica desafio inexistente, expirado ou já utilizado.
+	ErrWebAuthnChallengeInvalid = errors.New("desafio WebAuthn inválido ou expirado")
+)
+
+const webauthnCredentialColumns = `id, user_id, name, credential_id, public_key, algorithm, sign_count, COALESCE(aaguid, ''::bytea),
+       backup_eligible, backup_state, created_at, last_used_at`
+
+func scanWebAuthnCredential(row pgx.Row) (models.WebAuthnCredential, error) {
+	cred := models.WebAuthnCredential{}
+	var signCount int64
+	err := row.Scan(&cred.ID, &cred.UserID, &cred.Name, &cred.CredentialID, &cred.PublicKey, &cred.Algorithm, &signCount,
+		&cred.AAGUID, &cred.BackupEligible, &cred.BackupState, &cred.CreatedAt, &cred.LastUsedAt)
+	cred.SignCount = uint32(signCount)
+	return cred, err
+}
+
+// CreateWebAuthnChallenge registra o desafio (hash) de uma cerimônia em andamento.
+func (c *Client) CreateWebAuthnChallenge(ctx context.Context, challengeHash string, ch models.WebAuthnChallenge) error {
+	const q = `
+       INSERT INTO webauthn_challenges (challenge_hash, ceremony, user_id, expires_at)
+       VALUES ($1, $2, NULLIF($3, '')::uuid, $4)`
+	if _, err := c.pool.Exec(ctx, q, challengeHash, ch.Ceremony, ch.UserID, ch.ExpiresAt); err != nil {
+		return fmt.Errorf("falha ao registrar desafio WebAuthn: %w", err)
+	}
+	return nil
+}
+
+// ConsumeWebAuthnChallenge remove e devolve o desafio (uso único). Expirados também são limpos.
+func (c *Client) ConsumeWebAuthnChallenge(ctx context.Context, challengeHash string) (models.WebAuthnChallenge, error) {
+	const q = `
+       DELETE FROM webauthn_challenges WHERE challenge_hash = $1
+       RETURNING ceremony, COALESCE(user_id::text, ''), expires_at, expires_at > NOW()`
+	ch := models.WebAuthnChallenge{}
+	var valid bool
+	err := c.pool.QueryRow(ctx, q, challengeHash).Scan(&ch.Ceremony, &ch.UserID, &ch.ExpiresAt, &valid)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.WebAuthnChallenge{}, ErrWebAuthnChallengeInvalid
+	}
+	if err != nil {
+		return models.WebAuthnChallenge{}, fmt.Errorf("falha ao consumir desafio WebAuthn: %w", err)
+	}
+	if !valid {
+		return models.WebAuthnChallenge{}, ErrWebAuthnChallengeInvalid
+	}
+	if _, err := c.pool.Exec(ctx, `DELETE FROM webauthn_challenges WHERE expires_at < NOW()`); err != nil {
+		return models.WebAuthnChallenge{}, fmt.Errorf("falha ao limpar desafios WebAuthn: %w", err)
+	}
+	return ch, nil
+}
+
+// CreateWebAuthnCredential grava a passkey e devolve o ID gerado.
+func (c *Client) CreateWebAuthnCredential(ctx context.Context, cred models.WebAuthnCredential) (string, error) {
+	if strings.TrimSpace(cred.ID) == "" {
+		cred.ID = uuid.New().String()
+	}
+	const q = `
+       INSERT INTO webauthn_credentials (id, user_id, name, credential_id, public_key, algorithm, sign_count, aaguid, backup_eligible, backup_state)
+       VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	if _, err := c.pool.Exec(ctx, q, cred.ID, cred.UserID, strings.TrimSpace(cred.Name), cred.CredentialID, cred.PublicKey,
+		cred.Algorithm, int64(cred.SignCount), cred.AAGUID, cred.BackupEligible, cred.BackupState); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return "", ErrWebAuthnCredentialExists
+		}
+		return "", fmt.Errorf("falha ao registrar passkey: %w", err)
+	}
+	return cred.ID, nil
+}
+
+// GetWebAuthnCredential busca a passkey pelo credential ID do autenticador. Retorna pgx.ErrNoRows se não existir.
+func (c *Client) GetWebAuthnCredential(ctx context.Context, credentialID []byte) (models.WebAuthnCredential, error) {
+	q := `SELECT ` + webauthnCredentialColumns + ` FROM webauthn_credentials WHERE credential_id = $1`
+	return scanWebAuthnCredential(c.pool.QueryRow(ctx, q, credentialID))
+}
+
+// ListWebAuthnCredentials lista as passkeys do usuário.
+func (c *Client) ListWebAuthnCredentials(ctx context.Context, userID string) ([]models.WebAuthnCredential, error) {
+	q := `SELECT ` + webauthnCredentialColumns + ` FROM webauthn_credentials WHERE user_id = $1 ORDER BY created_at`
+	rows, err := c.pool.Query(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var creds []models.WebAuthnCredential
+	for rows.Next() {
+		cred, err := scanWebAuthnCredential(rows)
+		if err != nil {
+			return nil, err
+		}
+		creds = append(creds, cred)
+	}
+	return creds, rows.Err()
+}
+
+// CountWebAuthnCredentials informa quantas passkeys o usuário possui.
+func (c *Client) CountWebAuthnCredentials(ctx context.Context, userID string) (int, error) {
+	var n int
+	if err := c.pool.QueryRow(ctx, `SELECT COUNT(*) FROM webauthn_credentials WHERE user_id = $1`, userID).Scan(&n); err != nil {
+		return 0, fmt.Errorf("falha ao contar passkeys: %w", err)
+	}
+	return n, nil
+}
+
+// UseWebAuthnCredential grava o novo contador de assinaturas e o último uso.
+// Só atualiza se o contador armazenado ainda for o lido na verificação; retorna false
+// se outra asserção com a mesma passkey foi aceita nesse meio-tempo.
+func (c *Client) UseWebAuthnCredential(ctx context.Context, id string, storedCount, newCount uint32, backupState bool) (bool, error) {
+	const q = `
+       UPDATE webauthn_credentials SET sign_count = $3, backup_state = $4, last_used_at = NOW()
+       WHERE id = $1 AND sign_count = $2`
+	cmdTag, err := c.pool.Exec(ctx, q, id, int64(storedCount), int64(newCount), backupState)
+	if err != nil {
+		return false, fmt.Errorf("falha ao atualizar passkey: %w", err)
+	}
+	return cmdTag.RowsAffected() > 0, nil
+}
+
+// DeleteWebAuthnCredential remove a passkey garantindo que pertence ao userID.
+// Retorna pgx.ErrNoRows se não existir ou for de outro usuário.
+func (c *Client) DeleteWebAuthnCredential(ctx context.Context, userID, id string) error {
+	cmdTag, err := c.pool.Exec(ctx, `DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("falha ao remover passkey: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// ===== Criptografia de campos (envelope AES-GCM) =====
+//
+// Cada valor é cifrado com uma chave de dados (DEK) aleatória, e a DEK é cifrada com a
+// chave mestra (KEK) ativa. O valor gravado no banco fica assim:
+//
+//	enc:v2:<kid>:<DEK cifrada>:<nonce+texto cifrado>
+//
+// O nome do campo ("tabela.coluna") e a chave da linha entram como dado associado do GCM,
+// então um valor copiado para outra coluna ou outra linha não decifra. Valores "enc:v1:"
+// (só o campo no dado associado) ainda são lidos e valores sem prefixo são texto puro
+// legado; ambos são devolvidos normalmente até a recriptografia (cmd/reencrypt).
+
+const (
+	prefix   = "enc:v2:"
+	prefixV1 = "enc:v1:"
+	keyLen   = 32 // AES-256
+)
+
+// ErrUnknownKey indica um valor cifrado com uma KEK que não está mais configurada.
+var ErrUnknownKey = errors.New("chave de criptografia desconhecida")
+
+// Keyring guarda as KEKs por identificador (kid), a KEK ativa usada nas novas gravações
+// e a chave HMAC dos índices cegos.
+type Keyring struct {
+	keks     map[string]cipher.AEAD
+	activeID string
+	indexKey []byte
+}
+
+// NewFromEnv carrega as chaves do ambiente:
+//   - FIELD_ENCRYPTION_KEYS_FILE ou FIELD_ENCRYPTION_KEYS: "kid:base64" (32 bytes), uma por
+//     linha ou separadas por vírgula; chaves antigas ficam na lista até a recriptografia
+//   - FIELD_ENCRYPTION_ACTIVE_KEY: kid usado nas novas gravações (padrão: a primeira da lista)
+//   - BLIND_INDEX_KEY: chave HMAC (base64, 32 bytes) dos índices de busca
+//
+// Sem chaves, em produção (GO_ENV=production) retorna erro; fora dela usa chaves fixas de
+// desenvolvimento (os dados continuam legíveis entre reinícios, mas não são protegidos).
+func NewFromEnv() (*Keyring, error) {
+	spec, err := readSource("FIELD_ENCRYPTION_KEYS_FILE", "FIELD_ENCRYPTION_KEYS")
+	if err != nil {
+		return nil, err
+	}
+	indexKey := strings.TrimSpace(os.Getenv("BLIND_INDEX_KEY"))
+
+	if spec == "" && indexKey == "" {
+		if strings.EqualFold(os.Getenv("GO_ENV"), "production") {
+			return nil, errors.New("nenhuma chave de criptografia de campos configurada (defina FIELD_ENCRYPTION_KEYS e BLIND_INDEX_KEY)")
+		}
+		log.Println("⚠️ Criptografia de campos: nenhuma chave configurada; usando chaves fixas de desenvolvimento.")
+		kek := sha256.Sum256([]byte("guardiao-dev-field-kek"))
+		idx := sha256.Sum256([]byte("guardiao-dev-blind-index"))
+		return New(map[string][]byte{"dev": kek[:]}, "dev", idx[:])
+	}
+	if spec == "" || indexKey == "" {
+		return nil, errors.New("FIELD_ENCRYPTION_KEYS e BLIND_INDEX_KEY devem ser configuradas juntas")
+	}
+
+	keks := map[string][]byte{}
+	first := ""
+	for _, item := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if item = strings.TrimSpace(item); item == "" || strings.HasPrefix(item, "#") {
+			continue
+		}
+		kid, encoded, ok := strings.Cut(item, ":")
+		kid = strings.TrimSpace(kid)
+		if !ok {
+			return nil, fmt.Errorf("chave de criptografia inválida (use kid:base64): %q", kid)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("chave de criptografia %s não é base64 válido: %w", kid, err)
+		}
+		if _, dup := keks[kid]; dup {
+			return nil, fmt.Errorf("chave de criptografia %s repetida", kid)
+		}
+		keks[kid] = key
+		if first == "" {
+			first = kid
+		}
+	}
+	idx, err := base64.StdEncoding.DecodeString(indexKey)
+	if err != nil {
+		return nil, fmt.Errorf("BLIND_INDEX_KEY não é base64 válido: %w", err)
+	}
+	active := strings.TrimSpace(os.Getenv("FIELD_ENCRYPTION_ACTIVE_KEY"))
+	if active == "" {
+		active = first
+	}
+	return New(keks, active, idx)
+}
+
+// New monta o chaveiro a partir das KEKs (kid -> chave de 32 bytes), do kid ativo e da
+// chave dos índices cegos.
+func New(keks map[string][]byte, activeID string, indexKey []byte) (*Keyring, error) {
+	k := &Keyring{keks: map[string]cipher.AEAD{}, activeID: activeID, indexKey: indexKey}
+	for kid, key := range keks {
+		if kid == "" || strings.Contains(kid, ":") {
+			return nil, fmt.Errorf("kid inválido: %q", kid)
+		}
+		if len(key) != keyLen {
+			return nil, fmt.Errorf("chave de criptografia %s deve ter %d bytes (tem %d)", kid, keyLen, len(key))
+		}
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		k.keks[kid] = aead
+	}
+	if _, ok := k.keks[activeID]; !ok {
+		return nil, fmt.Errorf("chave ativa %q não está entre as chaves configuradas", activeID)
+	}
+	if len(indexKey) < keyLen {
+		return nil, fmt.Errorf("a chave dos índices cegos deve ter ao menos %d bytes", keyLen)
+	}
+	return k, nil
+}
+
+// ActiveKeyID devolve o kid usado nas novas gravações.
+func (k *Keyring) ActiveKeyID() string { return k.activeID }
+
+// Encrypt cifra o valor do campo da linha row com uma DEK nova protegida pela KEK ativa.
+// Valor vazio continua vazio.
+func (k *Keyring) Encrypt(field, row, plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	dek := make([]byte, keyLen)
+	if _, err := rand.Read(dek); err != nil {
+		return "", fmt.Errorf("falha ao gerar chave de dados: %w", err)
+	}
+	data, err := newGCM(dek)
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := seal(k.keks[k.activeID], dek, []byte(k.activeID))
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(data, []byte(plaintext), associated(field, row))
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	return prefix + k.activeID + ":" + enc.EncodeToString(wrapped) + ":" + enc.EncodeToString(ciphertext), nil
+}
+
+// Decrypt devolve o texto puro do campo da linha row. Valores sem o prefixo (legado) voltam
+// como estão.
+func (k *Keyring) Decrypt(field, row, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	ad := associated(field, row)
+	rest, v1 := strings.CutPrefix(value, prefixV1)
+	if v1 {
+		ad = []byte(field)
+	} else {
+		rest = strings.TrimPrefix(value, prefix)
+	}
+	parts := strings.Split(rest, ":")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("valor cifrado malformado em %s", field)
+	}
+	kek, ok := k.keks[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("%w: %s (campo %s)", ErrUnknownKey, parts[0], field)
+	}
+	enc := base64.RawURLEncoding
+	wrapped, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("chave de dados malformada em %s: %w", field, err)
+	}
+	ciphertext, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("valor cifrado malformado em %s: %w", field, err)
+	}
+	dek, err := open(kek, wrapped, []byte(parts[0]))
+	if err != nil {
+		return "", fmt.Errorf("falha ao abrir chave de dados de %s: %w", field, err)
+	}
+	data, err := newGCM(dek)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(data, ciphertext, ad)
+	if err != nil {
+		return "", fmt.Errorf("falha ao decifrar %s: %w", field, err)
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation informa se o valor está em texto puro, no formato v1 ou cifrado com uma KEK
+// que não é a ativa.
+func (k *Keyring) NeedsRotation(value string) bool {
+	if value == "" {
+		return false
+	}
+	if !strings.HasPrefix(value, prefix) {
+		return true
+	}
+	kid, _, _ := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	return kid != k.activeID
+}
+
+// BlindIndex calcula o índice de busca determinístico (HMAC-SHA256 em hex) do valor já
+// normalizado. O nome do campo separa os índices de colunas diferentes.
+func (k *Keyring) BlindIndex(field, value string) string {
+	mac := hmac.New(sha256.New, k.indexKey)
+	mac.Write([]byte(field))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsEncrypted informa se o valor está no formato de envelope (v1 ou v2).
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix) || strings.HasPrefix(value, prefixV1)
+}
+
+// associated monta o dado associado do GCM: campo e chave da linha separados por NUL.
+func associated(field, row string) []byte {
+	return []byte(field + "\x00" + row)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao preparar AES: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal devolve nonce || texto cifrado.
+func seal(aead cipher.AEAD, plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("falha ao gerar nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+func open(aead cipher.AEAD, sealed, additional []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("texto cifrado curto demais")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additional)
+}
+
+func readSource(fileEnv, valueEnv string) (string, error) {
+	if path := strings.TrimSpace(os.Getenv(fileEnv)); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("falha ao ler %s (%s): %w", fileEnv, path, err)
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+	return strings.TrimSpace(os.Getenv(valueEnv)), nil
+}
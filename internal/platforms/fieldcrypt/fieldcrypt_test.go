@@ -0,0 +1,113 @@
+package fieldcrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKeyring(t *testing.T, active string, kids ...string) *Keyring {
+	t.Helper()
+	keks := map[string][]byte{}
+	for i, kid := range kids {
+		keks[kid] = bytes.Repeat([]byte{byte(i + 1)}, keyLen)
+	}
+	k, err := New(keks, active, bytes.Repeat([]byte{9}, keyLen))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestEncryptBindsFieldAndRow(t *testing.T) {
+	k := testKeyring(t, "k1", "k1")
+	sealed, err := k.Encrypt("users.email", "u1", "ana@exemplo.com")
+	if err != nil || !strings.HasPrefix(sealed, "enc:v2:k1:") {
+		t.Fatalf("Encrypt: %q, %v", sealed, err)
+	}
+	if got, err := k.Decrypt("users.email", "u1", sealed); err != nil || got != "ana@exemplo.com" {
+		t.Fatalf("Decrypt: %q, %v", got, err)
+	}
+	if _, err := k.Decrypt("users.email", "u2", sealed); err == nil {
+		t.Fatal("valor copiado para outra linha não pode decifrar")
+	}
+	if _, err := k.Decrypt("users.pending_email", "u1", sealed); err == nil {
+		t.Fatal("valor copiado para outra coluna não pode decifrar")
+	}
+	if empty, _ := k.Encrypt("users.email", "u1", ""); empty != "" {
+		t.Fatalf("valor vazio deveria continuar vazio: %q", empty)
+	}
+}
+
+func TestDecryptLegacyValues(t *testing.T) {
+	k := testKeyring(t, "k1", "k1")
+	if got, err := k.Decrypt("users.email", "u1", "ana@exemplo.com"); err != nil || got != "ana@exemplo.com" {
+		t.Fatalf("texto puro: %q, %v", got, err)
+	}
+
+	// Formato v1: só o campo no dado associado
+	dek := bytes.Repeat([]byte{7}, keyLen)
+	data, _ := newGCM(dek)
+	wrapped, _ := seal(k.keks["k1"], dek, []byte("k1"))
+	ciphertext, _ := seal(data, []byte("ana@exemplo.com"), []byte("users.email"))
+	enc := base64.RawURLEncoding
+	v1 := prefixV1 + "k1:" + enc.EncodeToString(wrapped) + ":" + enc.EncodeToString(ciphertext)
+	if got, err := k.Decrypt("users.email", "u1", v1); err != nil || got != "ana@exemplo.com" {
+		t.Fatalf("v1: %q, %v", got, err)
+	}
+	if !k.NeedsRotation(v1) || !k.NeedsRotation("ana@exemplo.com") {
+		t.Fatal("v1 e texto puro precisam de recriptografia")
+	}
+}
+
+func TestNeedsRotationAfterKeyChange(t *testing.T) {
+	old := testKeyring(t, "k1", "k1", "k2")
+	sealed, err := old.Encrypt("users.name", "u1", "Ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated := testKeyring(t, "k2", "k1", "k2")
+	if old.NeedsRotation(sealed) || !rotated.NeedsRotation(sealed) {
+		t.Fatal("só valores de chaves inativas precisam de recriptografia")
+	}
+	if got, err := rotated.Decrypt("users.name", "u1", sealed); err != nil || got != "Ana" {
+		t.Fatalf("chave antiga ainda configurada deve decifrar: %q, %v", got, err)
+	}
+	if _, err := testKeyring(t, "k2", "k2").Decrypt("users.name", "u1", sealed); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("sem a chave antiga: esperado ErrUnknownKey, veio %v", err)
+	}
+}
+
+func TestBlindIndexSeparatesFields(t *testing.T) {
+	k := testKeyring(t, "k1", "k1")
+	a := k.BlindIndex("users.email", "ana@exemplo.com")
+	if a != k.BlindIndex("users.email", "ana@exemplo.com") {
+		t.Fatal("o índice cego deve ser determinístico")
+	}
+	if a == k.BlindIndex("user_identities.email", "ana@exemplo.com") {
+		t.Fatal("campos diferentes não podem compartilhar o índice")
+	}
+}
+
+func TestNewFromEnvRequiresKeysInProduction(t *testing.T) {
+	t.Setenv("FIELD_ENCRYPTION_KEYS_FILE", "")
+	t.Setenv("FIELD_ENCRYPTION_KEYS", "")
+	t.Setenv("BLIND_INDEX_KEY", "")
+	t.Setenv("GO_ENV", "production")
+	if _, err := NewFromEnv(); err == nil {
+		t.Fatal("em produção, sem chaves, NewFromEnv deve falhar")
+	}
+
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, keyLen))
+	t.Setenv("FIELD_ENCRYPTION_KEYS", "k1:"+key)
+	if _, err := NewFromEnv(); err == nil {
+		t.Fatal("FIELD_ENCRYPTION_KEYS sem BLIND_INDEX_KEY deve falhar")
+	}
+	t.Setenv("BLIND_INDEX_KEY", key)
+	k, err := NewFromEnv()
+	if err != nil || k.ActiveKeyID() != "k1" {
+		t.Fatalf("NewFromEnv: %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogMailer apenas registra o e-mail no log. Padrão para DEV.
+type LogMailer struct{}
+
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+func (LogMailer) Send(_ context.Context, msg Message) error {
+	log.Printf("MAILER (log): para=%s assunto=%q\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileMailer grava cada e-mail como um arquivo .eml em um diretório local.
+type FileMailer struct {
+	mu  sync.Mutex
+	dir string
+	seq int
+}
+
+func NewFileMailer(dir string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("falha ao criar diretório de e-mails %s: %w", dir, err)
+	}
+	return &FileMailer{dir: dir}, nil
+}
+
+func (m *FileMailer) Send(_ context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	recipient := strings.NewReplacer("@", "_at_", "/", "_", "\\", "_").Replace(msg.To)
+	name := fmt.Sprintf("%s-%03d-%s.eml", time.Now().Format("20060102T150405"), m.seq, recipient)
+	path := filepath.Join(m.dir, name)
+	if err := os.WriteFile(path, buildMessage("no-reply@localhost", msg), 0o600); err != nil {
+		return fmt.Errorf("falha ao gravar e-mail em %s: %w", path, err)
+	}
+	log.Printf("MAILER (file): e-mail para %s gravado em %s", msg.To, path)
+	return nil
+}
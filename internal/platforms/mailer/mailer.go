@@ -0,0 +1,47 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"strings"
+)
+
+// Message representa um e-mail simples (texto puro).
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer define o envio de e-mails transacionais (reset de senha, verificação, etc.).
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewFromEnv escolhe a implementação via MAIL_DRIVER:
+// - "smtp": usa SMTP_HOST, SMTP_PORT, SMTP_USER, SMTP_PASSWORD, SMTP_FROM
+// - "file": grava os e-mails em MAIL_DIR (padrão ./tmp/mail) — útil em DEV
+// - qualquer outro valor (padrão): apenas registra no log
+func NewFromEnv() (Mailer, error) {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("MAIL_DRIVER"))) {
+	case "smtp":
+		return NewSMTPMailer(SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     getenv("SMTP_PORT", "587"),
+			User:     os.Getenv("SMTP_USER"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     getenv("SMTP_FROM", os.Getenv("SMTP_USER")),
+		})
+	case "file":
+		return NewFileMailer(getenv("MAIL_DIR", "./tmp/mail"))
+	default:
+		return NewLogMailer(), nil
+	}
+}
+
+func getenv(k, fallback string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+	return fallback
+}
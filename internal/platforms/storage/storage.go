@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrNotFound indica que o objeto não existe no backend.
+var ErrNotFound = errors.New("objeto não encontrado")
+
+// Storage guarda arquivos gerados pela API (ex.: exportações de dados) por chave.
+// Chaves usam "/" como separador, independentemente do backend.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewFromEnv escolhe a implementação via STORAGE_DRIVER:
+// - "local" (padrão): grava em STORAGE_DIR (padrão ./tmp/storage)
+func NewFromEnv() (Storage, error) {
+	switch driver := strings.ToLower(strings.TrimSpace(os.Getenv("STORAGE_DRIVER"))); driver {
+	case "", "local":
+		return NewLocalStorage(getenv("STORAGE_DIR", "./tmp/storage"))
+	default:
+		return nil, fmt.Errorf("STORAGE_DRIVER desconhecido: %s", driver)
+	}
+}
+
+func getenv(k, fallback string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+	return fallback
+}
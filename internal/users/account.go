@@ -0,0 +1,150 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"go-guardiao-api/internal/auth"
+	"go-guardiao-api/internal/platforms/cache"
+	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Exclusão de conta (LGPD, direito à eliminação) =====
+
+var accountDeletionGrace = getEnvDuration("ACCOUNT_DELETION_GRACE", 30*24*time.Hour)
+
+func getEnvDuration(k string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(k))
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+// DELETE /user/account { "password": "..." } — agenda a exclusão após o prazo de carência.
+// Até lá a conta continua acessível e a exclusão pode ser cancelada.
+func (s *Service) HandleDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+
+	var payload struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || strings.TrimSpace(payload.Password) == "" {
+		writeError(w, http.StatusBadRequest, "Senha é obrigatória.")
+		return
+	}
+
+	hash, err := s.DBClient.GetUserPasswordHash(r.Context(), userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Usuário não encontrado.")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao buscar senha: %v", err))
+		return
+	}
+	if strings.TrimSpace(hash) == "" {
+		writeError(w, http.StatusConflict, "Defina uma senha (em \"Esqueci minha senha\") para confirmar a exclusão da conta.")
+		return
+	}
+	if err := auth.CheckPassword(payload.Password, hash); err != nil {
+		writeError(w, http.StatusUnauthorized, "Senha incorreta.")
+		return
+	}
+
+	scheduledAt := time.Now().Add(accountDeletionGrace)
+	if err := s.DBClient.ScheduleAccountDeletion(r.Context(), userID, scheduledAt); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao agendar exclusão: %v", err))
+		return
+	}
+	auth.Audit(r, s.DBClient, models.AuditEvent{
+		Action:  models.AuditAccountDeletionScheduled,
+		Changes: auth.AuditDiff("deletion_scheduled_at", nil, scheduledAt),
+	})
+	writeJSON(w, http.StatusAccepted, map[string]any{
+		"message":               "Exclusão agendada. Você pode cancelá-la até a data indicada.",
+		"deletion_scheduled_at": scheduledAt,
+	})
+}
+
+// POST /user/account/deletion/cancel — cancela a exclusão agendada
+func (s *Service) HandleCancelAccountDeletion(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+
+	if err := s.DBClient.CancelAccountDeletion(r.Context(), userID); errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Nenhuma exclusão agendada.")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao cancelar exclusão: %v", err))
+		return
+	}
+	auth.Audit(r, s.DBClient, models.AuditEvent{Action: models.AuditAccountDeletionCanceled})
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Exclusão cancelada."})
+}
+
+// ===== Job de exclusão =====
+
+const accountPurgeBatch = 50
+
+// AccountPurger apaga as contas cujo prazo de carência terminou. Várias instâncias
+// podem rodar ao mesmo tempo: cada conta é bloqueada durante a exclusão.
+type AccountPurger struct {
+	dbClient    db.Store
+	cacheClient cache.Cache
+}
+
+func NewAccountPurger(dbClient db.Store, cacheClient cache.Cache) *AccountPurger {
+	return &AccountPurger{dbClient: dbClient, cacheClient: cacheClient}
+}
+
+// Run executa a exclusão a cada intervalo até o contexto ser cancelado.
+func (p *AccountPurger) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		p.PurgeDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeDue exclui as contas vencidas e remove seus dados do Redis (saldo e leaderboard).
+func (p *AccountPurger) PurgeDue(ctx context.Context) {
+	ids, err := p.dbClient.ListDueAccountDeletions(ctx, accountPurgeBatch)
+	if err != nil {
+		log.Printf("AVISO: Falha ao listar contas a excluir: %v", err)
+		return
+	}
+	for _, id := range ids {
+		if err := p.dbClient.PurgeUser(ctx, id); errors.Is(err, pgx.ErrNoRows) {
+			continue
+		} else if err != nil {
+			log.Printf("ERRO: Falha ao excluir conta %s: %v", id, err)
+			continue
+		}
+		if err := p.cacheClient.RemoveUser(ctx, id); err != nil {
+			log.Printf("AVISO: Conta %s excluída, mas o cache não foi limpo: %v", id, err)
+		}
+		log.Printf("Conta %s excluída (prazo de carência encerrado).", id)
+	}
+}
@@ -0,0 +1,139 @@
+package users
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+
+	"go-guardiao-api/internal/auth"
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Chaves de API pessoais =====
+
+// POST /user/api-keys { "name": "Relógio", "scopes": ["habits:write"], "expires_in_days": 90 }
+// A chave completa só é devolvida nesta resposta.
+func (s *Service) HandleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+
+	var payload struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"` // opcional; 0 = sem expiração
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, "Requisição inválida.")
+		return
+	}
+	name := strings.TrimSpace(payload.Name)
+	if name == "" || len(name) > 100 {
+		writeError(w, http.StatusBadRequest, "Nome é obrigatório (até 100 caracteres).")
+		return
+	}
+	if len(payload.Scopes) == 0 {
+		writeError(w, http.StatusBadRequest, "Informe ao menos um escopo.")
+		return
+	}
+	scopes := make([]string, 0, len(payload.Scopes))
+	for _, scope := range payload.Scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !models.IsValidScope(scope) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Escopo inválido: %q.", scope))
+			return
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if payload.ExpiresInDays < 0 {
+		writeError(w, http.StatusBadRequest, "expires_in_days inválido.")
+		return
+	}
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao gerar chave: %v", err))
+		return
+	}
+	apiKey := models.APIKey{UserID: userID, Name: name, Prefix: prefix, KeyHash: hash, Scopes: scopes}
+	if payload.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, payload.ExpiresInDays)
+		apiKey.ExpiresAt = &expiresAt
+	}
+	if apiKey.ID, err = s.DBClient.CreateAPIKey(r.Context(), apiKey); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao criar chave: %v", err))
+		return
+	}
+	auth.Audit(r, s.DBClient, models.AuditEvent{
+		Action:     models.AuditAPIKeyCreated,
+		TargetType: "api_key",
+		TargetID:   apiKey.ID,
+		Metadata:   map[string]any{"name": apiKey.Name, "prefix": apiKey.Prefix, "scopes": apiKey.Scopes},
+	})
+
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"message":    "Chave criada. Copie agora: ela não será exibida novamente.",
+		"id":         apiKey.ID,
+		"name":       apiKey.Name,
+		"prefix":     apiKey.Prefix,
+		"scopes":     apiKey.Scopes,
+		"expires_at": apiKey.ExpiresAt,
+		"key":        key,
+	})
+}
+
+// GET /user/api-keys — lista as chaves ativas (sem o segredo)
+func (s *Service) HandleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+
+	keys, err := s.DBClient.ListAPIKeys(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao buscar chaves: %v", err))
+		return
+	}
+	if keys == nil {
+		keys = []models.APIKey{}
+	}
+	writeJSON(w, http.StatusOK, keys)
+}
+
+// DELETE /user/api-keys/{keyId} — revoga a chave
+func (s *Service) HandleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+
+	keyID := strings.TrimSpace(mux.Vars(r)["keyId"])
+	if _, err := uuid.Parse(keyID); err != nil {
+		writeError(w, http.StatusNotFound, "Chave não encontrada.")
+		return
+	}
+
+	if err := s.DBClient.RevokeAPIKey(r.Context(), userID, keyID); errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Chave não encontrada.")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao revogar chave: %v", err))
+		return
+	}
+	auth.Audit(r, s.DBClient, models.AuditEvent{Action: models.AuditAPIKeyRevoked, TargetType: "api_key", TargetID: keyID})
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Chave revogada."})
+}
@@ -0,0 +1,106 @@
+package users
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go-guardiao-api/internal/auth"
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Consentimentos =====
+
+// GET /user/consents — situação atual de cada finalidade; reconsent_required indica que
+// falta aceitar a versão vigente dos termos para alguma finalidade obrigatória.
+func (s *Service) HandleGetConsents(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+	s.writeConsents(w, r, userID)
+}
+
+// PUT /user/consents { "terms_version": "2", "consents": { "core_tracking": true, "marketing": false } }
+// Concede ou revoga finalidades na versão vigente dos termos (também é o fluxo de novo consentimento).
+func (s *Service) HandleUpdateConsents(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+
+	var payload struct {
+		TermsVersion string          `json:"terms_version"`
+		Consents     map[string]bool `json:"consents"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || len(payload.Consents) == 0 {
+		writeError(w, http.StatusBadRequest, "Informe as finalidades em consents.")
+		return
+	}
+	if strings.TrimSpace(payload.TermsVersion) != auth.TermsVersion {
+		writeError(w, http.StatusConflict, fmt.Sprintf("Os termos foram atualizados. Revise a versão %s antes de continuar.", auth.TermsVersion))
+		return
+	}
+
+	current, err := s.DBClient.GetCurrentConsents(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao buscar consentimentos: %v", err))
+		return
+	}
+
+	// Só grava o que muda: concessão nova (ou dada em versão anterior) e revogação do que vale hoje
+	changes := map[string]bool{}
+	for purpose, granted := range payload.Consents {
+		purpose = strings.ToLower(strings.TrimSpace(purpose))
+		if !models.IsValidConsentPurpose(purpose) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Finalidade inválida: %q.", purpose))
+			return
+		}
+		rec, ok := current[purpose]
+		upToDate := ok && rec.Granted && rec.TermsVersion == auth.TermsVersion
+		if granted != upToDate {
+			changes[purpose] = granted
+		}
+	}
+	if err := auth.RecordConsents(r, s.DBClient, userID, changes); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao registrar consentimentos: %v", err))
+		return
+	}
+	s.writeConsents(w, r, userID)
+}
+
+// GET /user/consents/history — todas as concessões e revogações, da mais recente à mais antiga
+func (s *Service) HandleGetConsentHistory(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+
+	history, err := s.DBClient.ListConsentHistory(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao buscar histórico: %v", err))
+		return
+	}
+	if history == nil {
+		history = []models.ConsentRecord{}
+	}
+	writeJSON(w, http.StatusOK, history)
+}
+
+func (s *Service) writeConsents(w http.ResponseWriter, r *http.Request, userID string) {
+	current, err := s.DBClient.GetCurrentConsents(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao buscar consentimentos: %v", err))
+		return
+	}
+	statuses, reconsent := auth.ConsentStatuses(current)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"terms_version":      auth.TermsVersion,
+		"reconsent_required": reconsent,
+		"consents":           statuses,
+	})
+}
@@ -8,21 +8,24 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v5"
 
 	"go-guardiao-api/internal/auth"
 	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/internal/platforms/mailer"
 	"go-guardiao-api/pkg/models"
 )
 
 // Service representa o serviço de Usuários.
 type Service struct {
-	DBClient *db.Client
+	DBClient db.Store
+	Mailer   mailer.Mailer
 }
 
-func NewService(dbClient *db.Client) *Service {
-	return &Service{DBClient: dbClient}
+func NewService(dbClient db.Store, mailClient mailer.Mailer) *Service {
+	return &Service{DBClient: dbClient, Mailer: mailClient}
 }
 
 // ===== Helpers JSON =====
@@ -74,6 +77,15 @@ func (s *Service) HandleUpdateProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	current, err := s.DBClient.GetUserByID(r.Context(), userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Perfil não encontrado para atualização.")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha interna ao buscar perfil: %v", err))
+		return
+	}
+
 	updateData.ID = userID
 	err = s.DBClient.UpdateUser(r.Context(), updateData)
 	switch {
@@ -85,6 +97,17 @@ func (s *Service) HandleUpdateProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	changes := map[string]models.AuditChange{}
+	if name := strings.TrimSpace(updateData.Name); name != current.Name {
+		changes["name"] = models.AuditChange{Redacted: true}
+	}
+	if theme := strings.TrimSpace(updateData.Theme); theme != current.Theme {
+		changes["theme"] = models.AuditChange{From: current.Theme, To: theme}
+	}
+	if len(changes) > 0 {
+		auth.Audit(r, s.DBClient, models.AuditEvent{Action: models.AuditProfileUpdated, Changes: changes})
+	}
+
 	writeJSON(w, http.StatusOK, map[string]string{
 		"message": "Perfil atualizado com sucesso.",
 		"user_id": userID,
@@ -96,6 +119,7 @@ func (s *Service) HandleUpdateProfile(w http.ResponseWriter, r *http.Request) {
 var emailRx = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
 
 // PUT /user/email { "email": "novo@exemplo.com" }
+// O novo endereço fica pendente até ser confirmado pelo link enviado à nova caixa.
 func (s *Service) HandleUpdateEmail(w http.ResponseWriter, r *http.Request) {
 	userID, err := auth.GetUserIDFromContext(r)
 	if err != nil {
@@ -117,7 +141,28 @@ func (s *Service) HandleUpdateEmail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.DBClient.UpdateUserEmail(r.Context(), userID, email); errors.Is(err, pgx.ErrNoRows) {
+	user, err := s.DBClient.GetUserByID(r.Context(), userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Usuário não encontrado para atualizar e-mail.")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao buscar usuário: %v", err))
+		return
+	}
+	if strings.EqualFold(user.Email, email) {
+		writeError(w, http.StatusBadRequest, "O novo e-mail é igual ao atual.")
+		return
+	}
+
+	if _, err := s.DBClient.GetUserByEmail(r.Context(), email); err == nil {
+		writeError(w, http.StatusConflict, "E-mail já cadastrado.")
+		return
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao verificar e-mail: %v", err))
+		return
+	}
+
+	if err := s.DBClient.SetPendingEmail(r.Context(), userID, email); errors.Is(err, pgx.ErrNoRows) {
 		writeError(w, http.StatusNotFound, "Usuário não encontrado para atualizar e-mail.")
 		return
 	} else if err != nil {
@@ -125,8 +170,56 @@ func (s *Service) HandleUpdateEmail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]string{
-		"message": "E-mail atualizado com sucesso.",
+	auth.Audit(r, s.DBClient, models.AuditEvent{
+		Action:  models.AuditEmailChangeRequested,
+		Changes: auth.AuditRedacted("pending_email"),
+	})
+
+	if err := auth.SendEmailVerification(r.Context(), s.DBClient, s.Mailer, user, email); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao enviar confirmação: %v", err))
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]string{
+		"message": "Enviamos um link de confirmação para o novo e-mail. A troca só será efetivada após a confirmação.",
+		"user_id": userID,
+	})
+}
+
+// POST /user/email/verification — reenvia o link de confirmação
+// (para o e-mail pendente, se houver; senão para o atual ainda não verificado).
+func (s *Service) HandleResendEmailVerification(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+
+	user, err := s.DBClient.GetUserByID(r.Context(), userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Usuário não encontrado.")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao buscar usuário: %v", err))
+		return
+	}
+
+	target := user.PendingEmail
+	if target == "" {
+		if user.EmailVerified {
+			writeError(w, http.StatusBadRequest, "E-mail já confirmado.")
+			return
+		}
+		target = user.Email
+	}
+
+	if err := auth.SendEmailVerification(r.Context(), s.DBClient, s.Mailer, user, target); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao enviar confirmação: %v", err))
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]string{
+		"message": "Link de confirmação reenviado.",
 		"user_id": userID,
 	})
 }
@@ -178,6 +271,7 @@ func (s *Service) HandleUpdatePassword(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao atualizar senha: %v", err))
 		return
 	}
+	auth.Audit(r, s.DBClient, models.AuditEvent{Action: models.AuditPasswordChanged})
 
 	writeJSON(w, http.StatusOK, map[string]string{
 		"message": "Senha alterada com sucesso.",
@@ -211,6 +305,7 @@ func (s *Service) HandleAddSupportContact(w http.ResponseWriter, r *http.Request
 	}
 
 	contact := models.SupportContact{
+		ContactID:              uuid.New().String(),
 		UserID:                 userID,
 		ContactEmail:           strings.TrimSpace(payload.Email),
 		Phone:                  strings.TrimSpace(payload.Phone),
@@ -223,6 +318,12 @@ func (s *Service) HandleAddSupportContact(w http.ResponseWriter, r *http.Request
 		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao adicionar contato: %v", err))
 		return
 	}
+	auth.Audit(r, s.DBClient, models.AuditEvent{
+		Action:     models.AuditSupportContactAdded,
+		TargetType: "support_contact",
+		TargetID:   contact.ContactID,
+		Changes:    auth.AuditRedacted(contactFields(contact)...),
+	})
 
 	writeJSON(w, http.StatusCreated, map[string]string{
 		"message": "Contato de apoio adicionado.",
@@ -287,6 +388,16 @@ func (s *Service) HandleDeleteSupportContact(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	// Estado anterior para a auditoria (só contatos do próprio usuário)
+	var removed *models.SupportContact
+	if contacts, err := s.DBClient.GetSupportContactsByUserID(r.Context(), userID); err == nil {
+		for i := range contacts {
+			if contacts[i].ContactID == contactID {
+				removed = &contacts[i]
+			}
+		}
+	}
+
 	// Segurança: garante que o contato pertence ao usuário logado
 	if err := s.DBClient.DeleteSupportContactByUser(r.Context(), userID, contactID); err != nil {
 		// quando não encontrado, retorne 404
@@ -298,5 +409,27 @@ func (s *Service) HandleDeleteSupportContact(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	ev := models.AuditEvent{Action: models.AuditSupportContactRemoved, TargetType: "support_contact", TargetID: contactID}
+	if removed != nil {
+		ev.Changes = auth.AuditRedacted(contactFields(*removed)...)
+	}
+	auth.Audit(r, s.DBClient, ev)
+
 	writeJSON(w, http.StatusOK, map[string]string{"message": "Contato removido."})
 }
+
+// contactFields lista os campos preenchidos do contato, para a auditoria (sem os valores).
+func contactFields(c models.SupportContact) []string {
+	var fields []string
+	for _, f := range []struct{ name, value string }{
+		{"name", firstNonEmpty(c.Nickname, c.Name)},
+		{"phone", c.Phone},
+		{"email", c.ContactEmail},
+		{"relation", c.NotificationPreference},
+	} {
+		if f.value != "" {
+			fields = append(fields, f.name)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,151 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"go-guardiao-api/internal/apitest"
+	"go-guardiao-api/internal/platforms/cache"
+	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/internal/platforms/mailer"
+	"go-guardiao-api/pkg/models"
+)
+
+func newTestAPI(t *testing.T) *apitest.API {
+	return apitest.New(t, func(router *mux.Router, store *db.MemoryStore, _ *cache.Memory) {
+		s := NewService(store, mailer.NewLogMailer())
+		router.HandleFunc("/user/profile", s.HandleGetUserProfile).Methods("GET")
+		router.HandleFunc("/user/profile", s.HandleUpdateProfile).Methods("PUT")
+		router.HandleFunc("/user/email", s.HandleUpdateEmail).Methods("PUT")
+		router.HandleFunc("/user/support-contact", s.HandleAddSupportContact).Methods("POST")
+		router.HandleFunc("/user/support-contact", s.HandleGetSupportContacts).Methods("GET")
+		router.HandleFunc("/user/support-contact/{contactId}", s.HandleDeleteSupportContact).Methods("DELETE")
+		router.HandleFunc("/user/security-events", s.HandleListSecurityEvents).Methods("GET")
+	})
+}
+
+// signIn cria uma conta com tema "claro" e sessão ativa.
+func signIn(t *testing.T, a *apitest.API, email string) (string, string) {
+	t.Helper()
+	return a.SignIn(t, models.User{Email: email, Theme: "claro"})
+}
+
+// auditOf devolve o evento mais recente da ação na trilha do usuário.
+func auditOf(t *testing.T, a *apitest.API, userID, action string) models.AuditEvent {
+	t.Helper()
+	events, err := a.Store.ListAuditEvents(context.Background(), db.AuditEventFilter{UserID: userID, Action: action}, 1, 0)
+	if err != nil || len(events) == 0 {
+		t.Fatalf("evento %s não registrado: %v", action, err)
+	}
+	return events[0]
+}
+
+// assertNoPII falha se algum valor pessoal aparecer no evento serializado.
+func assertNoPII(t *testing.T, ev models.AuditEvent, values ...string) {
+	t.Helper()
+	raw, _ := json.Marshal(ev)
+	for _, v := range values {
+		if strings.Contains(strings.ToLower(string(raw)), strings.ToLower(v)) {
+			t.Fatalf("evento %s contém dado pessoal %q: %s", ev.Action, v, raw)
+		}
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	a := newTestAPI(t)
+	userID, token := signIn(t, a, "ana@exemplo.com")
+
+	if code := a.Do(t, "PUT", "/user/profile", token, `{"name":" Ana Souza ","theme":"escuro"}`, nil); code != http.StatusOK {
+		t.Fatalf("PUT /user/profile: %d", code)
+	}
+	var profile models.User
+	if code := a.Do(t, "GET", "/user/profile", token, "", &profile); code != http.StatusOK {
+		t.Fatalf("GET /user/profile: %d", code)
+	}
+	if profile.Name != "Ana Souza" || profile.Theme != "escuro" || profile.PasswordHash != "" {
+		t.Fatalf("perfil: %+v", profile)
+	}
+
+	ev := auditOf(t, a, userID, models.AuditProfileUpdated)
+	if c := ev.Changes["name"]; !c.Redacted || c.From != nil || c.To != nil {
+		t.Fatalf("troca de nome deve ser registrada sem valores: %+v", c)
+	}
+	if c := ev.Changes["theme"]; c.From != "claro" || c.To != "escuro" {
+		t.Fatalf("troca de tema: %+v", c)
+	}
+	assertNoPII(t, ev, "Ana Souza")
+}
+
+func TestUpdateEmail(t *testing.T) {
+	a := newTestAPI(t)
+	userID, token := signIn(t, a, "ana@exemplo.com")
+	signIn(t, a, "bia@exemplo.com")
+
+	for body, want := range map[string]int{
+		`{"email":"nao-e-email"}`:     http.StatusBadRequest,
+		`{"email":"ANA@exemplo.com"}`: http.StatusBadRequest,
+		`{"email":"Bia@Exemplo.com"}`: http.StatusConflict,
+		`{"email":"ana@novo.com"}`:    http.StatusAccepted,
+	} {
+		if code := a.Do(t, "PUT", "/user/email", token, body, nil); code != want {
+			t.Errorf("PUT /user/email %s: esperado %d, veio %d", body, want, code)
+		}
+	}
+
+	u, err := a.Store.GetUserByID(context.Background(), userID)
+	if err != nil || u.Email != "ana@exemplo.com" || u.PendingEmail != "ana@novo.com" {
+		t.Fatalf("a troca só vale após a confirmação: %+v, %v", u, err)
+	}
+	ev := auditOf(t, a, userID, models.AuditEmailChangeRequested)
+	if !ev.Changes["pending_email"].Redacted {
+		t.Fatalf("e-mail pendente deve ser registrado sem valor: %+v", ev.Changes)
+	}
+	assertNoPII(t, ev, "ana@novo.com", "ana@exemplo.com")
+}
+
+func TestSupportContacts(t *testing.T) {
+	a := newTestAPI(t)
+	userID, token := signIn(t, a, "ana@exemplo.com")
+	_, other := signIn(t, a, "bia@exemplo.com")
+
+	if code := a.Do(t, "POST", "/user/support-contact", token, `{"phone":"+5511999990000"}`, nil); code != http.StatusBadRequest {
+		t.Fatalf("contato sem nome: esperado 400, veio %d", code)
+	}
+	body := `{"name":"Mãe","phone":"+5511999990000","email":"mae@exemplo.com","relation":"família"}`
+	if code := a.Do(t, "POST", "/user/support-contact", token, body, nil); code != http.StatusCreated {
+		t.Fatalf("POST /user/support-contact: %d", code)
+	}
+
+	var contacts []struct {
+		ID    string `json:"id"`
+		Name  string `json:"name"`
+		Phone string `json:"phone"`
+	}
+	if code := a.Do(t, "GET", "/user/support-contact", token, "", &contacts); code != http.StatusOK || len(contacts) != 1 {
+		t.Fatalf("GET /user/support-contact: %d, %+v", code, contacts)
+	}
+	if contacts[0].Name != "Mãe" || contacts[0].Phone != "+5511999990000" {
+		t.Fatalf("contato: %+v", contacts[0])
+	}
+
+	added := auditOf(t, a, userID, models.AuditSupportContactAdded)
+	for _, field := range []string{"name", "phone", "email", "relation"} {
+		if !added.Changes[field].Redacted {
+			t.Fatalf("campo %s do contato deve ser registrado sem valor: %+v", field, added.Changes)
+		}
+	}
+	assertNoPII(t, added, "Mãe", "+5511999990000", "mae@exemplo.com")
+
+	if code := a.Do(t, "DELETE", "/user/support-contact/"+contacts[0].ID, other, "", nil); code != http.StatusNotFound {
+		t.Fatalf("remoção por outra conta: esperado 404, veio %d", code)
+	}
+	if code := a.Do(t, "DELETE", "/user/support-contact/"+contacts[0].ID, token, "", nil); code != http.StatusOK {
+		t.Fatalf("DELETE /user/support-contact: %d", code)
+	}
+	assertNoPII(t, auditOf(t, a, userID, models.AuditSupportContactRemoved), "Mãe", "+5511999990000", "mae@exemplo.com")
+}
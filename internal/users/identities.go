@@ -0,0 +1,76 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+
+	"go-guardiao-api/internal/auth"
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Identidades externas (OIDC) =====
+
+// GET /user/identities — provedores externos vinculados à conta
+func (s *Service) HandleListIdentities(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+
+	identities, err := s.DBClient.ListUserIdentities(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao buscar identidades: %v", err))
+		return
+	}
+	if identities == nil {
+		identities = []models.UserIdentity{}
+	}
+	writeJSON(w, http.StatusOK, identities)
+}
+
+// DELETE /user/identities/{provider} — desvincula o provedor.
+// Recusa se for a última forma de login da conta (sem senha, passkey ou outra identidade).
+func (s *Service) HandleUnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+	provider := strings.ToLower(strings.TrimSpace(mux.Vars(r)["provider"]))
+
+	hash, err := s.DBClient.GetUserPasswordHash(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao buscar senha: %v", err))
+		return
+	}
+	count, err := s.DBClient.CountUserIdentities(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao buscar identidades: %v", err))
+		return
+	}
+	passkeys, err := s.DBClient.CountWebAuthnCredentials(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao buscar passkeys: %v", err))
+		return
+	}
+	if strings.TrimSpace(hash) == "" && count <= 1 && passkeys == 0 {
+		writeError(w, http.StatusConflict, "Defina uma senha (em \"Esqueci minha senha\") antes de desvincular o último provedor de login.")
+		return
+	}
+
+	if err := s.DBClient.UnlinkIdentity(r.Context(), userID, provider); errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Provedor não vinculado.")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao desvincular: %v", err))
+		return
+	}
+	auth.Audit(r, s.DBClient, models.AuditEvent{Action: models.AuditIdentityUnlinked, TargetType: "identity", TargetID: provider})
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Provedor desvinculado."})
+}
@@ -0,0 +1,83 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+
+	"go-guardiao-api/internal/auth"
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Passkeys (WebAuthn) =====
+// O cadastro (cerimônia de registro) fica em internal/auth.
+
+// GET /user/passkeys — passkeys cadastradas na conta
+func (s *Service) HandleListPasskeys(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+
+	creds, err := s.DBClient.ListWebAuthnCredentials(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao buscar passkeys: %v", err))
+		return
+	}
+	if creds == nil {
+		creds = []models.WebAuthnCredential{}
+	}
+	writeJSON(w, http.StatusOK, creds)
+}
+
+// DELETE /user/passkeys/{passkeyId} — remove a passkey.
+// Recusa se for a última forma de login da conta (sem senha e sem identidade externa).
+func (s *Service) HandleDeletePasskey(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+
+	passkeyID := strings.TrimSpace(mux.Vars(r)["passkeyId"])
+	if _, err := uuid.Parse(passkeyID); err != nil {
+		writeError(w, http.StatusNotFound, "Passkey não encontrada.")
+		return
+	}
+
+	hash, err := s.DBClient.GetUserPasswordHash(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao buscar senha: %v", err))
+		return
+	}
+	identities, err := s.DBClient.CountUserIdentities(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao buscar identidades: %v", err))
+		return
+	}
+	passkeys, err := s.DBClient.CountWebAuthnCredentials(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao buscar passkeys: %v", err))
+		return
+	}
+	if strings.TrimSpace(hash) == "" && identities == 0 && passkeys <= 1 {
+		writeError(w, http.StatusConflict, "Defina uma senha (em \"Esqueci minha senha\") antes de remover a última passkey.")
+		return
+	}
+
+	if err := s.DBClient.DeleteWebAuthnCredential(r.Context(), userID, passkeyID); errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Passkey não encontrada.")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao remover passkey: %v", err))
+		return
+	}
+	auth.Audit(r, s.DBClient, models.AuditEvent{Action: models.AuditPasskeyRemoved, TargetType: "passkey", TargetID: passkeyID})
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Passkey removida."})
+}
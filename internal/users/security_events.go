@@ -0,0 +1,43 @@
+package users
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"go-guardiao-api/internal/auth"
+	"go-guardiao-api/internal/platforms/db"
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Atividade de segurança =====
+
+// GET /user/security-events?limit=&offset= — eventos de auditoria da própria conta
+// (logins, trocas de e-mail/senha, 2FA, rede de apoio...), do mais recente ao mais antigo.
+func (s *Service) HandleListSecurityEvents(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+
+	q := r.URL.Query()
+	limit := 50
+	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+	offset := 0
+	if o, err := strconv.Atoi(q.Get("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	events, err := s.DBClient.ListAuditEvents(r.Context(), db.AuditEventFilter{UserID: userID}, limit, offset)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao listar eventos: %v", err))
+		return
+	}
+	if events == nil {
+		events = []models.AuditEvent{}
+	}
+	writeJSON(w, http.StatusOK, events)
+}
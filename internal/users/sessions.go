@@ -0,0 +1,104 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+
+	"go-guardiao-api/internal/auth"
+	"go-guardiao-api/pkg/models"
+)
+
+// ===== Sessões / Dispositivos =====
+
+// GET /user/sessions — lista os dispositivos conectados (a sessão atual vem com "current": true)
+func (s *Service) HandleListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+	currentID, _ := auth.GetSessionIDFromContext(r)
+
+	sessions, err := s.DBClient.ListActiveSessions(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao buscar sessões: %v", err))
+		return
+	}
+	if sessions == nil {
+		sessions = []models.Session{}
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+// DELETE /user/sessions/{sessionId} — desconecta um dispositivo
+func (s *Service) HandleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+
+	sessionID := strings.TrimSpace(mux.Vars(r)["sessionId"])
+	if _, err := uuid.Parse(sessionID); err != nil {
+		writeError(w, http.StatusNotFound, "Sessão não encontrada.")
+		return
+	}
+
+	// Segurança: só revoga sessões do próprio usuário
+	if err := s.DBClient.RevokeSessionByUser(r.Context(), userID, sessionID); errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Sessão não encontrada.")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao encerrar sessão: %v", err))
+		return
+	}
+
+	auth.Audit(r, s.DBClient, models.AuditEvent{Action: models.AuditSessionRevoked, TargetType: "session", TargetID: sessionID})
+
+	currentID, _ := auth.GetSessionIDFromContext(r)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"message": "Sessão encerrada.",
+		"current": sessionID == currentID,
+	})
+}
+
+// DELETE /user/sessions — desconecta todos os outros dispositivos, mantendo a sessão atual
+func (s *Service) HandleRevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado: UserID ausente.")
+		return
+	}
+
+	currentID, err := auth.GetSessionIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Acesso negado: sessão ausente.")
+		return
+	}
+
+	revoked, err := s.DBClient.RevokeOtherSessions(r.Context(), userID, currentID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Falha ao encerrar sessões: %v", err))
+		return
+	}
+	if revoked > 0 {
+		auth.Audit(r, s.DBClient, models.AuditEvent{
+			Action:   models.AuditSessionRevoked,
+			Metadata: map[string]any{"scope": "others", "revoked": revoked, "kept_session_id": currentID},
+		})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"message": "Outros dispositivos desconectados.",
+		"revoked": revoked,
+	})
+}
@@ -4,13 +4,53 @@ import "time"
 
 // User representa o perfil básico do usuário.
 type User struct {
-	ID           string    `json:"id,omitempty"`
-	Email        string    `json:"email"`
-	Name         string    `json:"name"`
-	Theme        string    `json:"theme,omitempty"` // Ex: "OutubroRosa", "Padrao"
-	CreatedAt    time.Time `json:"created_at,omitempty"`
-	UpdatedAt    time.Time `json:"updated_at,omitempty"`
-	PasswordHash string    `json:"-"` // nunca expor
+	ID            string    `json:"id,omitempty"`
+	Email         string    `json:"email"`
+	Name          string    `json:"name"`
+	Theme         string    `json:"theme,omitempty"` // Ex: "OutubroRosa", "Padrao"
+	EmailVerified bool      `json:"email_verified"`
+	PendingEmail  string    `json:"pending_email,omitempty"` // aguardando confirmação pela nova caixa
+	TOTPEnabled   bool      `json:"totp_enabled"`            // 2FA por app autenticador
+	Roles         []string  `json:"roles,omitempty"`         // Ex: "user", "caregiver", "clinician", "admin"
+	CreatedAt     time.Time `json:"created_at,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at,omitempty"`
+	PasswordHash  string    `json:"-"` // nunca expor
+
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"` // exclusão pendente (cancelável até a data)
+}
+
+// Papéis (roles) de acesso. Todo usuário tem ao menos RoleUser.
+const (
+	RoleUser      = "user"
+	RoleCaregiver = "caregiver"
+	RoleClinician = "clinician"
+	RoleAdmin     = "admin"
+)
+
+// IsValidRole informa se o papel é conhecido.
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleUser, RoleCaregiver, RoleClinician, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+// Escopos das chaves de API pessoais.
+const (
+	ScopeHabitsRead  = "habits:read"
+	ScopeHabitsWrite = "habits:write"
+	ScopeManaRead    = "mana:read"
+	ScopeManaWrite   = "mana:write"
+)
+
+// IsValidScope informa se o escopo de chave de API é conhecido.
+func IsValidScope(scope string) bool {
+	switch scope {
+	case ScopeHabitsRead, ScopeHabitsWrite, ScopeManaRead, ScopeManaWrite:
+		return true
+	}
+	return false
 }
 
 // UserMana representa o saldo atual de Mana do usuário.
@@ -28,6 +68,9 @@ const (
 	ManaTypeRewardRedeem    ManaTransactionType = "REWARD_REDEEM"
 	ManaTypeActivityGrant   ManaTransactionType = "ACTIVITY_GRANT"
 	ManaTypeChallengeDone   ManaTransactionType = "CHALLENGE_COMPLETE"
+	ManaTypeStreakFreeze    ManaTransactionType = "STREAK_FREEZE"
+	// Compensação de mana de um registro de hábito editado ou excluído (reference_id = ID do registro)
+	ManaTypeHabitLogAdjustment ManaTransactionType = "HABIT_LOG_ADJUSTMENT"
 )
 
 // ManaTransaction registra cada ganho ou perda de Mana.
@@ -40,14 +83,103 @@ type ManaTransaction struct {
 	CreatedAt   time.Time           `json:"created_at"`   // Definido no momento da gravação
 }
 
+// Situações de um hábito. Pausados e arquivados mantêm o histórico, mas não recebem
+// registros nem contam para sequências, lembretes e mana; arquivados também não são editáveis.
+const (
+	HabitActive   = "active"
+	HabitPaused   = "paused"
+	HabitArchived = "archived"
+)
+
 // Habit representa um hábito/meta.
 type Habit struct {
-	ID        string    `json:"id,omitempty"`
-	UserID    string    `json:"user_id,omitempty"`
-	Name      string    `json:"name"`
-	GoalType  string    `json:"goal_type,omitempty"` // Ex: "STEPS", "MEDICATION_LOG", "ACTIVITY"
-	Frequency string    `json:"frequency,omitempty"` // Ex: "Daily", "Weekly"
-	CreatedAt time.Time `json:"created_at,omitempty"`
+	ID        string         `json:"id,omitempty"`
+	UserID    string         `json:"user_id,omitempty"`
+	Name      string         `json:"name"`
+	GoalType  string         `json:"goal_type,omitempty"` // Ex: "STEPS", "MEDICATION_LOG", "ACTIVITY"
+	Frequency string         `json:"frequency,omitempty"` // Rótulo livre, ex: "Daily"; a regra fica em Schedule
+	Schedule  *HabitSchedule `json:"schedule,omitempty"`
+	Goal      *HabitGoal     `json:"goal,omitempty"`   // sem meta, o dia é cumprido com um registro de valor positivo
+	Status    string         `json:"status,omitempty"` // HabitActive, HabitPaused ou HabitArchived
+	CreatedAt time.Time      `json:"created_at,omitempty"`
+	UpdatedAt *time.Time     `json:"updated_at,omitempty"`
+	Streak    *HabitStreak   `json:"streak,omitempty"` // calculada na leitura; não é gravada
+}
+
+// Unidades das metas quantitativas.
+const (
+	UnitSteps   = "steps"
+	UnitML      = "ml"
+	UnitMinutes = "minutes"
+	UnitCount   = "count"
+	UnitMG      = "mg"
+)
+
+// Regras de agregação dos registros de um dia.
+const (
+	AggregateSum  = "sum"  // soma dos valores (ex: copos de água)
+	AggregateMax  = "max"  // maior valor (ex: passos lidos de um contador acumulado)
+	AggregateLast = "last" // valor do registro mais recente
+)
+
+// HabitGoal é a meta diária de um hábito: o dia é cumprido quando a agregação dos
+// registros do dia atinge Target (ex: 10000 steps, 2000 ml).
+type HabitGoal struct {
+	Target      int    `json:"target"`
+	Unit        string `json:"unit"`
+	Aggregation string `json:"aggregation,omitempty"` // padrão AggregateSum
+}
+
+// HabitStreak resume a sequência de ocorrências cumpridas de um hábito ativo.
+type HabitStreak struct {
+	Current        int        `json:"current"`
+	Longest        int        `json:"longest"`
+	LastCompletion *time.Time `json:"last_completion,omitempty"`
+	AtRisk         bool       `json:"at_risk"` // a sequência atual se perde se a ocorrência em curso não for cumprida
+}
+
+// StreakFreeze é uma proteção comprada com mana: cobre o dia perdido sem quebrar a sequência.
+type StreakFreeze struct {
+	HabitID   string    `json:"habit_id"`
+	UserID    string    `json:"-"`
+	Day       string    `json:"day"` // AAAA-MM-DD no fuso da agenda
+	ManaCost  int       `json:"mana_cost"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HabitPause é um período em que o hábito esteve pausado ou arquivado (ResumedAt nulo enquanto
+// durar). As ocorrências dentro dele não quebram a sequência.
+type HabitPause struct {
+	HabitID   string     `json:"habit_id"`
+	PausedAt  time.Time  `json:"paused_at"`
+	ResumedAt *time.Time `json:"resumed_at,omitempty"`
+}
+
+// Tipos de agenda de um hábito.
+const (
+	ScheduleDaily        = "daily"          // todo dia (ou a cada Interval dias)
+	ScheduleWeekdays     = "weekdays"       // dias da semana fixos (a cada Interval semanas)
+	ScheduleTimesPerWeek = "times_per_week" // Times registros por semana, em qualquer dia
+	ScheduleMonthly      = "monthly"        // dias fixos do mês (a cada Interval meses)
+)
+
+// HabitSchedule é a regra de recorrência de um hábito. Pode ser enviada pelos campos
+// estruturados ou por RRule (subconjunto da RFC 5545), que é convertida nos campos.
+type HabitSchedule struct {
+	Kind      string   `json:"kind"`
+	Interval  int      `json:"interval,omitempty"`   // padrão 1
+	Weekdays  []string `json:"weekdays,omitempty"`   // weekdays: MO, TU, WE, TH, FR, SA, SU
+	Times     int      `json:"times,omitempty"`      // times_per_week
+	MonthDays []int    `json:"month_days,omitempty"` // monthly: 1 a 31, ou negativos a partir do fim (-1 = último dia)
+	StartDate string   `json:"start_date,omitempty"` // AAAA-MM-DD no fuso da agenda (padrão: hoje)
+	EndDate   string   `json:"end_date,omitempty"`   // AAAA-MM-DD, inclusivo
+	Timezone  string   `json:"timezone,omitempty"`   // IANA, ex: "America/Sao_Paulo" (padrão: UTC)
+	RRule     string   `json:"rrule,omitempty"`      // ex: "FREQ=WEEKLY;BYDAY=MO,WE,FR"
+}
+
+// Active informa se o hábito está sendo acompanhado (aceita registros e conta para as regras).
+func (h Habit) Active() bool {
+	return h.Status == HabitActive
 }
 
 // HabitLog registra o progresso de um hábito.
@@ -96,3 +228,230 @@ type SupportContact struct {
 	Nickname               string `json:"nickname,omitempty"`
 	NotificationPreference string `json:"notification_preference,omitempty"`
 }
+
+// Session representa uma sessão de autenticação (um dispositivo; família de refresh tokens).
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	UserAgent  string     `json:"user_agent,omitempty"`
+	IP         string     `json:"ip,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	Current    bool       `json:"current"` // sessão do token usado na requisição
+}
+
+// RefreshToken representa um refresh token opaco persistido apenas como hash.
+type RefreshToken struct {
+	ID        string
+	SessionID string
+	UserID    string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// LoginAttempts representa o estado de tentativas de login falhas de uma conta ou IP.
+type LoginAttempts struct {
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"last_failure,omitempty"`
+	LockedUntil time.Time `json:"locked_until,omitempty"`
+}
+
+// LoginLockout registra um bloqueio temporário aplicado por excesso de tentativas.
+type LoginLockout struct {
+	ID          int64      `json:"id"`
+	Scope       string     `json:"scope"`         // "account" ou "ip"
+	Key         string     `json:"key,omitempty"` // índice cego do e-mail (omitido nas respostas) ou IP
+	UserID      string     `json:"user_id,omitempty"`
+	IP          string     `json:"ip,omitempty"`
+	Failures    int        `json:"failures"`
+	LockedUntil time.Time  `json:"locked_until"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UnlockedAt  *time.Time `json:"unlocked_at,omitempty"`
+	UnlockedBy  string     `json:"unlocked_by,omitempty"`
+}
+
+// UserIdentity vincula uma conta a um provedor OpenID Connect externo (Google, Microsoft...).
+type UserIdentity struct {
+	Provider    string     `json:"provider"`
+	Subject     string     `json:"-"` // claim "sub" do provedor
+	UserID      string     `json:"user_id"`
+	Email       string     `json:"email,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// OIDCLoginState guarda o estado de um fluxo authorization code + PKCE em andamento.
+type OIDCLoginState struct {
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	LinkUserID   string // preenchido quando o fluxo vincula a conta de um usuário já logado
+	ExpiresAt    time.Time
+}
+
+// APIKey é uma chave de API pessoal (integrações, scripts, wearables).
+// Só o hash é persistido; o prefixo identifica a chave para o usuário.
+type APIKey struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// WebAuthnCredential é uma passkey (credencial WebAuthn) registrada pelo usuário.
+// Serve como login sem senha e como segundo fator.
+type WebAuthnCredential struct {
+	ID             string     `json:"id"`
+	UserID         string     `json:"user_id"`
+	Name           string     `json:"name"`
+	CredentialID   []byte     `json:"-"`
+	PublicKey      []byte     `json:"-"` // chave COSE
+	Algorithm      int        `json:"algorithm"`
+	SignCount      uint32     `json:"-"`
+	AAGUID         []byte     `json:"-"`
+	BackupEligible bool       `json:"backup_eligible"`
+	BackupState    bool       `json:"backup_state"` // sincronizada entre dispositivos
+	CreatedAt      time.Time  `json:"created_at"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+}
+
+// Cerimônias WebAuthn.
+const (
+	WebAuthnRegister = "register"
+	WebAuthnLogin    = "login"
+	WebAuthnMFA      = "mfa"
+)
+
+// WebAuthnChallenge guarda o desafio de uma cerimônia WebAuthn em andamento.
+type WebAuthnChallenge struct {
+	Ceremony  string
+	UserID    string // vazio no login sem e-mail (passkey escolhida pelo navegador)
+	ExpiresAt time.Time
+}
+
+// Situações de uma exportação de dados.
+const (
+	ExportPending = "pending"
+	ExportRunning = "running"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// DataExport é um pedido de cópia dos dados pessoais (LGPD, portabilidade),
+// gerado em background como um ZIP de arquivos JSON e CSV.
+type DataExport struct {
+	ID          string     `json:"id"`
+	UserID      string     `json:"user_id"`
+	Status      string     `json:"status"`
+	StorageKey  string     `json:"-"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // o arquivo é apagado após esta data
+}
+
+// Ações registradas na trilha de auditoria.
+const (
+	AuditRegister                 = "user.registered"
+	AuditLogin                    = "auth.login"
+	AuditLoginFailed              = "auth.login_failed"
+	AuditPasswordReset            = "auth.password_reset"
+	AuditEmailChangeRequested     = "user.email_change_requested"
+	AuditEmailChanged             = "user.email_changed"
+	AuditPasswordChanged          = "user.password_changed"
+	AuditProfileUpdated           = "user.profile_updated"
+	AuditSupportContactAdded      = "user.support_contact_added"
+	AuditSupportContactRemoved    = "user.support_contact_removed"
+	AuditTOTPEnabled              = "user.totp_enabled"
+	AuditTOTPDisabled             = "user.totp_disabled"
+	AuditPasskeyAdded             = "user.passkey_added"
+	AuditPasskeyRemoved           = "user.passkey_removed"
+	AuditAPIKeyCreated            = "user.api_key_created"
+	AuditAPIKeyRevoked            = "user.api_key_revoked"
+	AuditIdentityLinked           = "user.identity_linked"
+	AuditIdentityUnlinked         = "user.identity_unlinked"
+	AuditSessionRevoked           = "user.session_revoked"
+	AuditAccountDeletionScheduled = "user.account_deletion_scheduled"
+	AuditAccountDeletionCanceled  = "user.account_deletion_canceled"
+	AuditDataExportRequested      = "user.data_export_requested"
+	AuditConsentGranted           = "user.consent_granted"
+	AuditConsentRevoked           = "user.consent_revoked"
+	AuditRolesChanged             = "admin.roles_changed"
+	AuditLockoutUnlocked          = "admin.lockout_unlocked"
+)
+
+// AuditChange guarda o valor anterior e o novo de um campo alterado. Em dados pessoais
+// (nome, e-mails, contatos) os valores não são gravados: Redacted indica só que o campo mudou.
+type AuditChange struct {
+	From     any  `json:"from"`
+	To       any  `json:"to"`
+	Redacted bool `json:"redacted,omitempty"`
+}
+
+// AuditEvent é um registro imutável da trilha de auditoria (append-only).
+// Nunca guarda segredos (senhas, hashes, tokens) nem dados pessoais em claro; na exclusão da
+// conta, os eventos do titular são anonimizados (RedactedAt).
+type AuditEvent struct {
+	ID         int64                  `json:"id"`
+	ActorID    string                 `json:"actor_id,omitempty"` // quem executou; vazio se anônimo
+	UserID     string                 `json:"user_id,omitempty"`  // conta afetada
+	Action     string                 `json:"action"`             // Use constantes acima
+	TargetType string                 `json:"target_type,omitempty"`
+	TargetID   string                 `json:"target_id,omitempty"`
+	IP         string                 `json:"ip,omitempty"`
+	UserAgent  string                 `json:"user_agent,omitempty"`
+	Changes    map[string]AuditChange `json:"changes,omitempty"`  // antes/depois
+	Metadata   map[string]any         `json:"metadata,omitempty"` // ex.: método de login, motivo da falha
+	CreatedAt  time.Time              `json:"created_at"`
+	RedactedAt *time.Time             `json:"redacted_at,omitempty"`
+}
+
+// Finalidades de tratamento de dados que dependem de consentimento (dados de saúde, LGPD art. 11).
+const (
+	ConsentCoreTracking   = "core_tracking"   // registro de hábitos e medicação (obrigatório para usar o app)
+	ConsentSupportSharing = "support_sharing" // compartilhar alertas e progresso com a rede de apoio
+	ConsentAnalytics      = "analytics"       // métricas de uso anonimizadas
+	ConsentMarketing      = "marketing"       // comunicações promocionais
+)
+
+// ConsentPurposes lista as finalidades válidas, na ordem de exibição.
+var ConsentPurposes = []string{ConsentCoreTracking, ConsentSupportSharing, ConsentAnalytics, ConsentMarketing}
+
+// IsValidConsentPurpose informa se a finalidade é conhecida.
+func IsValidConsentPurpose(purpose string) bool {
+	switch purpose {
+	case ConsentCoreTracking, ConsentSupportSharing, ConsentAnalytics, ConsentMarketing:
+		return true
+	}
+	return false
+}
+
+// ConsentRecord é um registro imutável de concessão ou revogação (histórico).
+type ConsentRecord struct {
+	ID           int64     `json:"id"`
+	UserID       string    `json:"user_id"`
+	Purpose      string    `json:"purpose"`
+	Granted      bool      `json:"granted"`       // false = revogação
+	TermsVersion string    `json:"terms_version"` // versão dos termos aceita no momento
+	IP           string    `json:"ip,omitempty"`
+	UserAgent    string    `json:"user_agent,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ConsentStatus é a situação atual do usuário para uma finalidade.
+type ConsentStatus struct {
+	Purpose      string     `json:"purpose"`
+	Required     bool       `json:"required"`
+	Granted      bool       `json:"granted"`                 // concedido na versão atual dos termos
+	TermsVersion string     `json:"terms_version,omitempty"` // versão do último registro
+	UpdatedAt    *time.Time `json:"updated_at,omitempty"`
+}